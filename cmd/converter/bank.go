@@ -0,0 +1,295 @@
+// bank.go implements the CLI "bank" command that manages the bank file
+// templates kept in the --templates directory and infers new ones from
+// sample files.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lgican/File-Converter/parsers/bank"
+)
+
+// cmdBank runs a "bank" subcommand. Exits 1 on error.
+func cmdBank(args []string) {
+	if len(args) >= 1 && args[0] == "infer" {
+		if err := bankInfer(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) < 2 || args[0] != "templates" {
+		fmt.Fprintln(os.Stderr, "Error: usage: converter bank templates list|show|import|export, or converter bank infer")
+		os.Exit(1)
+	}
+	var err error
+	switch sub, rest := args[1], args[2:]; sub {
+	case "list":
+		err = bankTemplatesList(rest)
+	case "show":
+		err = bankTemplatesShow(rest)
+	case "import":
+		err = bankTemplatesImport(rest)
+	case "export":
+		err = bankTemplatesExport(rest)
+	default:
+		err = fmt.Errorf("unknown templates command %q: want list, show, import or export", sub)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// bankTemplatesList prints the built-in and stored templates.
+func bankTemplatesList(args []string) error {
+	asJSON, args := takeBoolFlag(args, "--json")
+	if len(args) != 0 {
+		return fmt.Errorf("list takes no arguments")
+	}
+	list := bank.ListTemplates()
+	if asJSON {
+		return printJSON(os.Stdout, list)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVERSION\tFIELDS\tSOURCE\tNAME")
+	for _, t := range list {
+		source, version := "stored", strconv.Itoa(t.Version)
+		if t.Builtin {
+			source, version = "built-in", "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", t.Key, version, len(t.Fields), source, t.Name)
+	}
+	return tw.Flush()
+}
+
+// bankTemplatesShow prints a template's fields, or its JSON with --json.
+// --version shows an older version of a stored template.
+func bankTemplatesShow(args []string) error {
+	asJSON, args := takeBoolFlag(args, "--json")
+	version, args := takeValueFlag(args, "--version")
+	if len(args) != 1 {
+		return fmt.Errorf("show requires a template key")
+	}
+	t, err := findTemplate(args[0], version)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return printJSON(os.Stdout, t)
+	}
+	fmt.Printf("%s (%s", t.Name, t.Key)
+	if !t.Builtin {
+		fmt.Printf(", version %d", t.Version)
+	}
+	if !t.Updated.IsZero() {
+		fmt.Printf(", updated %s", t.Updated.Format("2006-01-02 15:04"))
+	}
+	fmt.Println(")")
+	if t.Description != "" {
+		fmt.Println(t.Description)
+	}
+	fmt.Println()
+	if err := printFields(t.Fields); err != nil {
+		return err
+	}
+	for _, l := range t.Records {
+		var when []string
+		if l.Key != "" {
+			when = append(when, fmt.Sprintf("%s = %s", t.Discriminator, l.Key))
+		}
+		if l.When != nil {
+			when = append(when, fmt.Sprintf("%s is not empty", l.When.Column))
+			if len(l.When.In) > 0 {
+				when[len(when)-1] = fmt.Sprintf("%s in %s", l.When.Column, strings.Join(l.When.In, ", "))
+			}
+			if l.When.Pattern != "" {
+				when = append(when, fmt.Sprintf("%s matches %s", l.When.Column, l.When.Pattern))
+			}
+		}
+		emit := l.Emit
+		if emit == "" || emit == bank.EmitInstead {
+			emit = "instead of"
+		}
+		fmt.Printf("\n%s record (%s the detail record when %s)\n\n", l.Name, emit, strings.Join(when, " and "))
+		if err := printFields(l.Fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printFields prints a table of a record's fields.
+func printFields(fields []bank.Field) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tPOSITION\tLENGTH\tTYPE\tALIGN\tPAD\tDESCRIPTION")
+	for _, f := range fields {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%q\t%s\n",
+			f.Name, f.Position, f.Length, f.Type, f.Align, f.Padding, f.Description)
+	}
+	return tw.Flush()
+}
+
+// bankTemplatesImport stores the template in a JSON file under --key, the
+// file's "key" or the file name. An existing template gets a new version.
+func bankTemplatesImport(args []string) error {
+	key, args := takeValueFlag(args, "--key")
+	if len(args) != 1 {
+		return fmt.Errorf("import requires a template file")
+	}
+	store, err := templateStore()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var t bank.StoredTemplate
+	if err := json.Unmarshal(data, &t); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	if key == "" {
+		key = t.Key
+	}
+	if key == "" {
+		key = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	}
+
+	var saved *bank.StoredTemplate
+	if cur, ok := store.Get(key); ok {
+		saved, err = store.Update(key, t.Template, cur.Version)
+	} else {
+		saved, err = store.Create(key, t.Template)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	fmt.Printf("Imported: %s version %d (%d fields)\n", saved.Key, saved.Version, len(saved.Fields))
+	return nil
+}
+
+// bankTemplatesExport writes a template's JSON to a file, or to stdout.
+func bankTemplatesExport(args []string) error {
+	version, args := takeValueFlag(args, "--version")
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("export requires a template key and optionally a file")
+	}
+	t, err := findTemplate(args[0], version)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		return printJSON(os.Stdout, t)
+	}
+	f, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	if err := printJSON(f, t); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported: %s version %d to %s\n", t.Key, t.Version, args[1])
+	return nil
+}
+
+// bankInfer proposes a template for a sample fixed-width file, helped by
+// a CSV or Excel file of the same records given with --csv, and writes
+// its JSON to --out or stdout. Notes on what to check go to stderr.
+func bankInfer(args []string) error {
+	sampleFile, args := takeValueFlag(args, "--csv")
+	name, args := takeValueFlag(args, "--name")
+	out, args := takeValueFlag(args, "--out")
+	if len(args) != 1 {
+		return fmt.Errorf("infer requires a fixed-width file")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var sample []byte
+	if sampleFile != "" {
+		if sample, err = os.ReadFile(sampleFile); err != nil {
+			return err
+		}
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	}
+	inf, err := bank.InferTemplate(data, sample, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	for _, note := range inf.Notes {
+		fmt.Fprintf(os.Stderr, "Note: %s\n", note)
+	}
+	if out == "" {
+		return printJSON(os.Stdout, inf.Template)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := printJSON(f, inf.Template); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Inferred: %d fields to %s\n", len(inf.Template.Fields), out)
+	return nil
+}
+
+// findTemplate returns a built-in or stored template, or the given
+// version of a stored one. Shared with /api/bank/templates.
+func findTemplate(key, version string) (*bank.StoredTemplate, error) {
+	if version == "" {
+		for _, t := range bank.ListTemplates() {
+			if t.Key == key {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", bank.ErrTemplateNotFound, key)
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+	if tpl, ok := bank.DefaultTemplates[key]; ok && v == 1 {
+		return &bank.StoredTemplate{Key: key, Version: 1, Builtin: true, Template: tpl}, nil
+	}
+	store, err := templateStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Version(key, v)
+}
+
+// templateStore returns the --templates store.
+func templateStore() (*bank.TemplateStore, error) {
+	store := bank.Templates()
+	if store == nil {
+		return nil, fmt.Errorf("no template directory: use --templates <dir>")
+	}
+	return store, nil
+}
+
+// printJSON writes v as indented JSON.
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,90 @@
+// detect.go implements the CLI "detect" command that shows how each
+// registered converter rated a file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lgican/File-Converter/formats"
+)
+
+// candidateInfo is the JSON form of a detection candidate, shared with
+// /api/detect.
+type candidateInfo struct {
+	Converter string           `json:"converter"`
+	Score     int              `json:"score"`
+	Reasons   []string         `json:"reasons"`
+	Options   []formats.Option `json:"options,omitempty"`
+}
+
+// candidateInfos converts ranked candidates to their JSON form.
+func candidateInfos(cands []formats.Candidate) []candidateInfo {
+	out := make([]candidateInfo, len(cands))
+	for i, c := range cands {
+		out[i] = candidateInfo{
+			Converter: c.Converter.Name(),
+			Score:     c.Score,
+			Reasons:   c.Reasons,
+			Options:   formats.OptionsFor(c.Converter),
+		}
+	}
+	return out
+}
+
+// cmdDetect ranks the converters for a file and prints each candidate's
+// score and reasons, best first. Exits 1 if no converter claims the file.
+func cmdDetect(args []string) {
+	asJSON := false
+	var paths []string
+	for _, a := range args {
+		if a == "--json" {
+			asJSON = true
+		} else {
+			paths = append(paths, a)
+		}
+	}
+	if len(paths) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: detect requires one file")
+		usage()
+		os.Exit(1)
+	}
+	path := paths[0]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	cands := formats.Rank(filepath.Base(path), data)
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			File       string          `json:"file"`
+			Size       int             `json:"size"`
+			Candidates []candidateInfo `json:"candidates"`
+		}{filepath.Base(path), len(data), candidateInfos(cands)})
+	} else {
+		fmt.Printf("%s (%s)\n", filepath.Base(path), formats.HumanSize(len(data)))
+		if len(cands) == 0 {
+			fmt.Println("  No converter recognises this file.")
+		}
+		for i, c := range cands {
+			mark := ""
+			if i == 0 {
+				mark = "  <- selected"
+			}
+			fmt.Printf("  %3d  %s%s\n", c.Score, c.Converter.Name(), mark)
+			for _, r := range c.Reasons {
+				fmt.Printf("         - %s\n", r)
+			}
+		}
+	}
+	if len(cands) == 0 {
+		os.Exit(1)
+	}
+}
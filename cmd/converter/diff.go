@@ -0,0 +1,160 @@
+// diff.go implements the CLI "diff" command that compares the decoded
+// structure of two TNEF or Outlook .msg files.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lgican/File-Converter/formats"
+	"github.com/lgican/File-Converter/parsers/msg"
+	"github.com/lgican/File-Converter/parsers/tnef"
+)
+
+// cmdDiff decodes two TNEF or .msg files and prints their differences as
+// text or, with --json, as JSON. Like diff(1) it exits 0 when the files
+// match, 1 when they differ, and 2 on error.
+func cmdDiff(args []string) {
+	asJSON := false
+	var paths []string
+	for _, a := range args {
+		if a == "--json" {
+			asJSON = true
+		} else {
+			paths = append(paths, a)
+		}
+	}
+	if len(paths) != 2 {
+		fmt.Fprintln(os.Stderr, "Error: diff requires two files")
+		usage()
+		os.Exit(2)
+	}
+
+	a, errA := decodeForDiff(paths[0])
+	b, errB := decodeForDiff(paths[1])
+	if err := errors.Join(errA, errB); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	d := tnef.Compare(a, b)
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			Old       string     `json:"old"`
+			New       string     `json:"new"`
+			Identical bool       `json:"identical"`
+			Diff      *tnef.Diff `json:"diff"`
+		}{paths[0], paths[1], d.Empty(), d})
+	} else {
+		fmt.Printf("--- %s\n+++ %s\n", paths[0], paths[1])
+		if d.Empty() {
+			fmt.Println("No differences.")
+		}
+		printDiff(d, "")
+	}
+	if !d.Empty() {
+		os.Exit(1)
+	}
+}
+
+// decodeForDiff reads and decodes one TNEF or .msg file, told apart by
+// the compound file signature.
+func decodeForDiff(path string) (*tnef.Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decode := tnef.Decode
+	if msg.IsMsg(data) {
+		decode = msg.Decode
+	}
+	m, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w (only TNEF and .msg files can be compared)", filepath.Base(path), err)
+	}
+	return m, nil
+}
+
+// printDiff writes d in a readable form, indenting embedded messages.
+func printDiff(d *tnef.Diff, indent string) {
+	if d.Path != "" {
+		fmt.Printf("\n%sEmbedded message %s:\n", indent, d.Path)
+	}
+	if len(d.Properties) > 0 {
+		fmt.Printf("%sProperties:\n", indent)
+		printPropChanges(d.Properties, indent+"  ")
+	}
+	if len(d.Bodies) > 0 {
+		fmt.Printf("%sBodies:\n", indent)
+		for _, b := range d.Bodies {
+			fmt.Printf("%s  %s %-8s %s -> %s\n", indent, changeMark(b.Change), b.Body,
+				formats.HumanSize(b.OldSize), formats.HumanSize(b.NewSize))
+			for _, line := range strings.Split(strings.TrimSuffix(b.Unified, "\n"), "\n") {
+				if line != "" {
+					fmt.Printf("%s      %s\n", indent, line)
+				}
+			}
+		}
+	}
+	if len(d.Attachments) > 0 {
+		fmt.Printf("%sAttachments:\n", indent)
+		for _, a := range d.Attachments {
+			switch a.Change {
+			case tnef.Added:
+				fmt.Printf("%s  + %s (%s, sha256 %s)\n", indent, a.Name, formats.HumanSize(a.NewSize), a.NewSHA256)
+			case tnef.Removed:
+				fmt.Printf("%s  - %s (%s, sha256 %s)\n", indent, a.Name, formats.HumanSize(a.OldSize), a.OldSHA256)
+			default:
+				fmt.Printf("%s  ~ %s\n", indent, a.Name)
+				if a.OldSHA256 != "" {
+					fmt.Printf("%s      size   %s -> %s\n", indent, formats.HumanSize(a.OldSize), formats.HumanSize(a.NewSize))
+					fmt.Printf("%s      sha256 %s\n%s          -> %s\n", indent, a.OldSHA256, indent, a.NewSHA256)
+				}
+				printPropChanges(a.Properties, indent+"      ")
+			}
+		}
+	}
+	for _, e := range d.Embedded {
+		printDiff(e, indent+"  ")
+	}
+}
+
+// printPropChanges writes one line per property change.
+func printPropChanges(changes []tnef.PropChange, indent string) {
+	for _, c := range changes {
+		label := c.Key
+		if c.Name != "" {
+			label = c.Name + " (" + c.Key + ")"
+		}
+		switch c.Change {
+		case tnef.Added:
+			fmt.Printf("%s+ %s %s: %s\n", indent, label, c.NewType, c.New)
+		case tnef.Removed:
+			fmt.Printf("%s- %s %s: %s\n", indent, label, c.OldType, c.Old)
+		default:
+			typ := c.NewType
+			if c.OldType != c.NewType {
+				typ = c.OldType + " -> " + c.NewType
+			}
+			fmt.Printf("%s~ %s %s: %s -> %s\n", indent, label, typ, c.Old, c.New)
+		}
+	}
+}
+
+// changeMark returns the diff-style marker for a change kind.
+func changeMark(change string) string {
+	switch change {
+	case tnef.Added:
+		return "+"
+	case tnef.Removed:
+		return "-"
+	}
+	return "~"
+}
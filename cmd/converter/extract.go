@@ -4,73 +4,183 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
 
 	"github.com/lgican/File-Converter/formats"
 )
 
-// convertFile reads a file, auto-detects its format, and returns the
-// converted output files. Exits on error.
-func convertFile(path string) []formats.ConvertedFile {
+// conversion is the result of convertFile.
+type conversion struct {
+	files    []formats.ConvertedFile
+	outDir   string
+	in       formats.Input
+	conv     formats.Converter
+	opts     formats.Options
+	limits   *formats.RecursiveLimits // nil unless --recursive
+	manifest bool                     // --manifest: also write manifest.json
+}
+
+// convertFile reads the file named by args[0], auto-detects its format,
+// and converts it with the converter options given in the remaining
+// arguments; --converter <name> bypasses detection, --recursive expands
+// nested containers and --manifest requests a manifest. The output
+// directory is the first remaining positional argument (default ".").
+// Ctrl-C cancels the conversion. Exits on error.
+func convertFile(args []string) *conversion {
+	path := args[0]
 	data, err := os.ReadFile(path)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
 		os.Exit(1)
 	}
+	override, args := takeValueFlag(args[1:], "--converter")
+	recursive, args := takeBoolFlag(args, "--recursive")
+	manifest, args := takeBoolFlag(args, "--manifest")
+	maxDepth, args := takeValueFlag(args, "--max-depth")
+	maxTotal, args := takeValueFlag(args, "--max-total")
+	limits, err := recursiveLimits(maxDepth, maxTotal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 	conv := formats.Detect(filepath.Base(path), data)
+	if override != "" {
+		conv, err = formats.Lookup(override)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	if conv == nil {
 		fmt.Fprintf(os.Stderr, "Unsupported file format: %s\n", filepath.Base(path))
 		os.Exit(1)
 	}
-	files, err := conv.Convert(data)
+
+	cc := formats.Adapt(conv)
+	raw, rest, err := formats.SplitOptionArgs(cc.Options(), args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	outDir := "."
+	for _, a := range rest {
+		if strings.HasPrefix(a, "--") {
+			fmt.Fprintf(os.Stderr, "Error: unknown option %s for %s files\n", a, conv.Name())
+			os.Exit(1)
+		}
+		outDir = a
+	}
+	opts, err := formats.ParseOptions(cc.Options(), raw)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	in := formats.Input{Name: filepath.Base(path), Data: data}
+	if err := formats.CurrentPolicy().Check(conv, in); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	c := &conversion{
+		outDir:   outDir,
+		in:       in,
+		conv:     conv,
+		opts:     opts,
+		manifest: manifest,
+	}
+	var files []formats.ConvertedFile
+	if recursive {
+		c.limits = &limits
+		files, err = formats.ExtractRecursive(ctx, conv, c.in, opts, limits)
+	} else {
+		files, err = cc.ConvertContext(ctx, c.in, opts)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error converting %s: %v\n", path, err)
 		os.Exit(1)
 	}
-	return files
+	c.files = formats.Finalize(formats.SanitizeFiles(files), conv, c.in.Name)
+	return c
 }
 
-// writeConvertedFiles writes each converted file to the given output directory.
-func writeConvertedFiles(files []formats.ConvertedFile, outDir string) {
+// write writes files, a selection of c.files, to the output directory,
+// keeping their original modification times when known, followed by the
+// manifest if one was requested.
+func (c *conversion) write(files []formats.ConvertedFile) {
 	if len(files) == 0 {
 		fmt.Println("No content to extract.")
-		return
+		if !c.manifest {
+			return
+		}
 	}
+	outDir := c.outDir
 	for _, f := range files {
 		if err := writeFile(outDir, f.Name, f.Data); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			continue
+		}
+		if !f.ModTime.IsZero() {
+			p := filepath.Join(outDir, f.Name)
+			if err := os.Chtimes(p, f.ModTime, f.ModTime); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: setting time of %s: %v\n", p, err)
+			}
+		}
+		if len(f.Provenance) > 1 {
+			var chain []string
+			for _, o := range f.Provenance[1:] {
+				chain = append(chain, o.Name)
+			}
+			fmt.Printf("           from %s\n", strings.Join(chain, " > "))
+		}
+	}
+	if c.manifest {
+		m := formats.NewManifest(c.in, c.conv, version, c.opts, files)
+		m.Recursive = c.limits
+		mf := m.File()
+		if err := writeFile(outDir, mf.Name, mf.Data); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
 	}
 }
 
-// cmdExtract converts a file and writes only the attachment outputs to outDir.
-func cmdExtract(path, outDir string) {
-	files := convertFile(path)
+// cmdExtract converts a file and writes only the attachment outputs,
+// including inline parts, to the output directory.
+func cmdExtract(args []string) {
+	c := convertFile(args)
 	var filtered []formats.ConvertedFile
-	for _, f := range files {
-		if f.Category == "attachment" {
+	for _, f := range c.files {
+		if f.Category == formats.CategoryAttachment || f.Category == formats.CategoryInline {
 			filtered = append(filtered, f)
 		}
 	}
-	writeConvertedFiles(filtered, outDir)
+	c.write(filtered)
 }
 
-// cmdBody converts a file and writes only the message body outputs to outDir.
-func cmdBody(path, outDir string) {
-	files := convertFile(path)
+// cmdBody converts a file and writes only the message body outputs to the
+// output directory.
+func cmdBody(args []string) {
+	c := convertFile(args)
 	var filtered []formats.ConvertedFile
-	for _, f := range files {
-		if f.Category == "body" {
+	for _, f := range c.files {
+		if f.Category == formats.CategoryBody {
 			filtered = append(filtered, f)
 		}
 	}
-	writeConvertedFiles(filtered, outDir)
+	c.write(filtered)
 }
 
-// cmdDump converts a file and writes all extracted outputs to outDir.
-func cmdDump(path, outDir string) {
-	files := convertFile(path)
-	writeConvertedFiles(files, outDir)
+// cmdDump converts a file and writes all extracted outputs to the output
+// directory.
+func cmdDump(args []string) {
+	c := convertFile(args)
+	c.write(c.files)
 }
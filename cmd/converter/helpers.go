@@ -6,22 +6,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
-)
+	"time"
 
-// humanSize formats a byte count as a human-readable string (e.g. "1.2 KB").
-func humanSize(b int) string {
-	const unit = 1024
-	if b < unit {
-		return fmt.Sprintf("%d B", b)
-	}
-	div, exp := unit, 0
-	for n := b / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
-}
+	"github.com/lgican/File-Converter/formats"
+	"github.com/lgican/File-Converter/formats/plugin"
+	"github.com/lgican/File-Converter/parsers/bank"
+)
 
 // writeFile writes data to outDir/name, ensuring the resulting path stays
 // within outDir to prevent directory traversal attacks.
@@ -47,6 +40,292 @@ func writeFile(outDir, name string, data []byte) error {
 	if err := os.WriteFile(outPath, data, 0o644); err != nil {
 		return fmt.Errorf("writing %s: %w", outPath, err)
 	}
-	fmt.Printf("Extracted: %s (%s)\n", outPath, humanSize(len(data)))
+	fmt.Printf("Extracted: %s (%s)\n", outPath, formats.HumanSize(len(data)))
 	return nil
 }
+
+// applyConversionFlags consumes the --remote-*, --sanitize-html, --policy,
+// --ach-profile, --mappings, --templates and --plugin* options from args,
+// installs the resulting conversion settings, loads any plugins, and
+// returns the remaining arguments. Exits on invalid values.
+func applyConversionFlags(args []string) []string {
+	policy := formats.CurrentRemotePolicy()
+	modeSet := false
+	var plugins plugin.Config
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+		switch flag {
+		case "--sanitize-html":
+			formats.SetSanitizeHTML(true)
+			continue
+		case "--remote-images", "--remote-allow", "--remote-deny", "--remote-proxy",
+			"--plugins", "--plugin-timeout", "--policy", "--ach-profile", "--mappings", "--templates":
+		default:
+			rest = append(rest, flag)
+			continue
+		}
+		if i+1 >= len(args) {
+			fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", flag)
+			os.Exit(1)
+		}
+		value := args[i+1]
+		i++
+		switch flag {
+		case "--remote-images":
+			mode, err := formats.ParseRemoteMode(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			policy.Mode = mode
+			modeSet = true
+		case "--remote-allow":
+			policy.AllowHosts = append(policy.AllowHosts, splitList(value)...)
+		case "--remote-deny":
+			policy.DenyHosts = append(policy.DenyHosts, splitList(value)...)
+		case "--remote-proxy":
+			policy.ProxyURL = value
+			if !modeSet {
+				policy.Mode = formats.RemoteProxy
+			}
+		case "--policy":
+			p, err := formats.LoadPolicy(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: policy: %v\n", err)
+				os.Exit(1)
+			}
+			formats.SetPolicy(p)
+		case "--ach-profile":
+			data, err := os.ReadFile(value)
+			var p *bank.ACHProfile
+			if err == nil {
+				p, err = bank.LoadACHProfile(data)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			bank.SetACHProfile(p)
+		case "--mappings":
+			store, err := bank.OpenMappings(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			bank.SetMappings(store)
+		case "--templates":
+			// Invalid files are skipped so that one bad template does
+			// not take the others down
+			store, err := bank.OpenTemplates(value)
+			if store == nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: templates: %v\n", err)
+			}
+			bank.SetTemplates(store)
+		case "--plugins":
+			plugins.Dir = value
+		case "--plugin-timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: invalid --plugin-timeout %q: want a duration such as 30s\n", value)
+				os.Exit(1)
+			}
+			plugins.Timeout = d
+		}
+	}
+	if err := formats.SetRemotePolicy(policy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if plugins.Dir != "" {
+		loadPlugins(plugins)
+	}
+	return rest
+}
+
+// loadPlugins registers the plugins in cfg.Dir. Plugins that fail their
+// handshake are reported and skipped; an unreadable directory is fatal.
+func loadPlugins(cfg plugin.Config) {
+	if _, err := os.ReadDir(cfg.Dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: plugins: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := plugin.Load(cfg); err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "Warning: plugin %s\n", line)
+		}
+	}
+}
+
+// takeValueFlag removes "flag value" or "flag=value" from args and returns
+// the value ("" if absent) and the remaining arguments. Exits if the value
+// is missing.
+func takeValueFlag(args []string, flag string) (string, []string) {
+	value := ""
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == flag:
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", flag)
+				os.Exit(1)
+			}
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], flag+"="):
+			value = strings.TrimPrefix(args[i], flag+"=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return value, rest
+}
+
+// takeBoolFlag removes every occurrence of flag from args and reports
+// whether it was present.
+func takeBoolFlag(args []string, flag string) (bool, []string) {
+	found := false
+	var rest []string
+	for _, a := range args {
+		if a == flag {
+			found = true
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return found, rest
+}
+
+// recursiveLimits parses the --max-depth and --max-total values; empty
+// strings select the defaults.
+func recursiveLimits(depth, total string) (formats.RecursiveLimits, error) {
+	limits := formats.RecursiveLimits{MaxDepth: formats.DefaultMaxDepth, MaxTotal: formats.DefaultMaxTotal}
+	if depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n < 1 {
+			return limits, fmt.Errorf("invalid depth %q: want a positive integer", depth)
+		}
+		limits.MaxDepth = n
+	}
+	if total != "" {
+		n, err := parseSize(total)
+		if err != nil {
+			return limits, err
+		}
+		limits.MaxTotal = n
+	}
+	return limits, nil
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix
+// (powers of 1024), e.g. "512M".
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	num := strings.ToUpper(strings.TrimSpace(s))
+	num = strings.TrimSuffix(num, "B")
+	if n := len(num); n > 0 {
+		switch num[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			num = num[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q: want e.g. 100M", s)
+	}
+	return n * mult, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// converterOptionsHelp lists the options of every registered converter for
+// the usage text. The same names are accepted as /api/convert form fields.
+func converterOptionsHelp() string {
+	// Converters sharing a schema (the archive formats) are listed once.
+	var groups [][]formats.Converter
+	for _, c := range formats.All() {
+		opts := formats.OptionsFor(c)
+		if len(opts) == 0 {
+			continue
+		}
+		if n := len(groups); n > 0 && reflect.DeepEqual(formats.OptionsFor(groups[n-1][0]), opts) {
+			groups[n-1] = append(groups[n-1], c)
+			continue
+		}
+		groups = append(groups, []formats.Converter{c})
+	}
+
+	var b strings.Builder
+	for _, group := range groups {
+		var names []string
+		for _, c := range group {
+			names = append(names, c.Name())
+		}
+		fmt.Fprintf(&b, "\n%s options (extract, body, dump):\n", strings.Join(names, ", "))
+		for _, o := range formats.OptionsFor(group[0]) {
+			flag := "--" + o.Name
+			switch o.Type {
+			case formats.OptionBool:
+				if o.Default == "true" {
+					flag += "[=false]"
+				}
+			case formats.OptionEnum:
+				flag += " <choice>"
+			default:
+				flag += " <" + string(o.Type) + ">"
+			}
+			help := o.Description
+			if len(o.Choices) > 0 {
+				help += "; one of " + strings.Join(o.Choices, ", ")
+			}
+			if o.Default != "" && !(o.Type == formats.OptionBool && o.Default == "false") {
+				help += " (default " + o.Default + ")"
+			}
+			indent := strings.Repeat(" ", 27)
+			if len(flag) > 24 {
+				flag += "\n" + indent[:len(indent)-1]
+			}
+			fmt.Fprintf(&b, "  %-24s %s\n", flag, wrapText(help, 52, indent))
+		}
+	}
+	return b.String()
+}
+
+// wrapText breaks s into lines of at most width columns, indenting every
+// line after the first.
+func wrapText(s string, width int, indent string) string {
+	var b strings.Builder
+	n := 0
+	for _, word := range strings.Fields(s) {
+		if n > 0 && n+1+len(word) > width {
+			b.WriteString("\n" + indent)
+			n = 0
+		} else if n > 0 {
+			b.WriteByte(' ')
+			n++
+		}
+		b.WriteString(word)
+		n += len(word)
+	}
+	return b.String()
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 
+	_ "github.com/lgican/File-Converter/formats/archive"
 	_ "github.com/lgican/File-Converter/formats/bank"
 	_ "github.com/lgican/File-Converter/formats/fileconvert"
 	_ "github.com/lgican/File-Converter/formats/tnef"
@@ -26,19 +27,87 @@ Usage:
   converter extract <file> [output_dir] Extract attachments
   converter body    <file> [output_dir] Extract message body
   converter dump    <file> [output_dir] Extract everything
+  converter detect  <file> [--json]     Rank the converters that recognise a
+                                        file and show why
+  converter diff    <a> <b> [--json]    Compare properties, bodies and
+                                        attachments of two TNEF or .msg
+                                        files
+  converter verify  <manifest> [dir]    Check files against a manifest
+                                        written with --manifest
+                                        [--input <file>] [--json]
+  converter bank templates list         List bank templates [--json]
+  converter bank templates show <key>   Show a template's fields
+                                        [--version <n>] [--json]
+  converter bank templates import <file> [--key <key>]
+                                        Store a template (JSON), or a new
+                                        version of an existing one
+  converter bank templates export <key> [file] [--version <n>]
+                                        Write a template's JSON
+  converter bank infer <file> [--csv <sample>] [--name <name>] [--out <file>]
+                                        Propose a template (JSON) for a
+                                        sample fixed-width file, aligned
+                                        with a CSV/Excel of its records
   converter serve   [port] [options]    Start web interface (default port 8080)
   converter help                        Show this help message
 
 Serve options:
-  --base-path <path>  Serve under a URL prefix (e.g. /converter)
+  --base-path <path>   Serve under a URL prefix (e.g. /converter)
+  --admin-token <tok>  Allow creating, changing and deleting bank templates
+                       and mappings through the API with the header
+                       "Authorization: Bearer <tok>" (off by default)
 
+Extraction options (extract, body, dump):
+  --converter <name>       Use this converter instead of the detected one
+                           (name or unique prefix, e.g. tnef, bank)
+  --recursive              Also unpack containers found among the outputs,
+                           e.g. a winmail.dat attached to a winmail.dat
+  --max-depth <n>          Nested container levels to unpack (default 5)
+  --max-total <size>       Stop unpacking once outputs total this size
+                           (default 256M)
+  --manifest               Also write manifest.json with the input's and
+                           every output's size and SHA-256
+
+Conversion options (extract, body, dump, serve):
+  --sanitize-html          Strip scripts, forms, event handlers and unsafe
+                           URLs/CSS from HTML output (adds sanitizer_report.txt)
+  --remote-images <mode>   block (default), fetch, or proxy
+  --remote-allow <hosts>   Comma-separated hosts that may be fetched
+  --remote-deny <hosts>    Comma-separated hosts that are never fetched
+  --remote-proxy <url>     Fetch through this HTTP proxy (implies proxy mode)
+  --policy <file>          Deployment policy (JSON) enabling/disabling
+                           converters, conversions and input sizes
+                           (also for detect)
+  --ach-profile <file>     ACH profile (JSON) with the company and bank
+                           settings for NACHA output (ACH_Payment)
+  --mappings <dir>         Directory of bank column mapping profiles, used
+                           with --mapping <name> and saved through the API
+  --templates <dir>        Directory of custom bank templates (JSON), also
+                           for bank templates; reloaded by serve on change
+  --plugins <dir>          Load converter plugins from this directory
+                           (also for detect)
+  --plugin-timeout <dur>   Kill a plugin conversion after this long
+                           (default 2m)
+%s
 Examples:
   converter view winmail.dat
   converter extract winmail.dat ./output
   converter dump winmail.dat ./output
+  converter diff old/winmail.dat new/winmail.dat --json
+  converter detect export.txt
+  converter dump export.txt ./output --converter bank
+  converter dump winmail.dat ./output --recursive --max-depth 3
+  converter dump winmail.dat ./output --manifest
+  converter verify ./output/manifest.json --input winmail.dat
+  converter dump winmail.dat ./output --remote-images fetch --remote-deny tracker.example
+  converter dump payments.csv ./output --template ACH_Payment --output-format xlsx
+  converter dump client.csv ./output --mappings ./mappings --mapping acme-payroll
+  converter bank infer returns.txt --csv returns.csv --out payroll.json
+  converter bank templates import payroll.json --templates ./templates
+  converter dump payroll.csv ./output --templates ./templates --template payroll
+  converter dump photo.heic ./output --to jpg --quality 80
   converter serve 9090
   converter serve 8080 --base-path /converter
-`, version)
+`, version, converterOptionsHelp())
 }
 
 func main() {
@@ -51,6 +120,11 @@ func main() {
 		args = os.Args[2:]
 	}
 
+	switch cmd {
+	case "extract", "body", "dump", "detect", "bank", "serve", "server", "web":
+		args = applyConversionFlags(args)
+	}
+
 	switch cmd {
 	case "help", "-h", "--help":
 		usage()
@@ -63,25 +137,37 @@ func main() {
 		cmdView(args[0])
 	case "extract":
 		requireFile(args)
-		cmdExtract(args[0], outputDir(args))
+		cmdExtract(args)
 	case "body":
 		requireFile(args)
-		cmdBody(args[0], outputDir(args))
+		cmdBody(args)
 	case "dump":
 		requireFile(args)
-		cmdDump(args[0], outputDir(args))
+		cmdDump(args)
+	case "diff":
+		cmdDiff(args)
+	case "detect":
+		cmdDetect(args)
+	case "verify":
+		cmdVerify(args)
+	case "bank":
+		cmdBank(args)
 	case "serve", "server", "web":
 		port := "8080"
 		basePath := ""
+		adminToken := ""
 		for i := 0; i < len(args); i++ {
 			if args[i] == "--base-path" && i+1 < len(args) {
 				basePath = args[i+1]
 				i++
+			} else if args[i] == "--admin-token" && i+1 < len(args) {
+				adminToken = args[i+1]
+				i++
 			} else {
 				port = args[i]
 			}
 		}
-		cmdServe(port, basePath)
+		cmdServe(port, basePath, adminToken)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", cmd)
 		usage()
@@ -97,11 +183,3 @@ func requireFile(args []string) {
 		os.Exit(1)
 	}
 }
-
-// outputDir returns the output directory from args, defaulting to ".".
-func outputDir(args []string) string {
-	if len(args) >= 2 {
-		return args[1]
-	}
-	return "."
-}
@@ -9,16 +9,20 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
+	"mime"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -42,10 +46,63 @@ type session struct {
 
 // extractedFile is a single file produced by conversion.
 type extractedFile struct {
-	Name string `json:"name"`
-	Size int    `json:"size"`
-	Type string `json:"type"`
-	data []byte
+	Name       string           `json:"name"`
+	Size       int              `json:"size"`
+	Type       string           `json:"type"` // icon class, from fileType
+	MimeType   string           `json:"mimeType"`
+	Category   formats.Category `json:"category,omitempty"`
+	SHA256     string           `json:"sha256"`
+	ModTime    *time.Time       `json:"modTime,omitempty"`
+	ContentID  string           `json:"contentId,omitempty"`
+	Source     formats.Source   `json:"source"`
+	Provenance []formats.Origin `json:"provenance,omitempty"` // set by recursive extraction
+	data       []byte
+}
+
+// appendManifest appends a manifest.json describing the conversion of in
+// to files when the request's "manifest" form field is true. Files must
+// be finalized; c is nil if the input was stored unconverted.
+func appendManifest(r *http.Request, files []formats.ConvertedFile, in formats.Input, c formats.Converter, opts formats.Options, limits *formats.RecursiveLimits) []formats.ConvertedFile {
+	if want, _ := strconv.ParseBool(r.FormValue("manifest")); !want {
+		return files
+	}
+	m := formats.NewManifest(in, c, version, opts, files)
+	m.Recursive = limits
+	return append(files, formats.Finalize([]formats.ConvertedFile{m.File()}, nil, "")...)
+}
+
+// sessionFiles describes finalized converted files for a session, in
+// order.
+func sessionFiles(groups ...[]formats.ConvertedFile) []extractedFile {
+	var files []extractedFile
+	for _, g := range groups {
+		for _, f := range g {
+			files = append(files, newExtractedFile(f))
+		}
+	}
+	return files
+}
+
+// newExtractedFile describes a finalized converted file for a session.
+func newExtractedFile(f formats.ConvertedFile) extractedFile {
+	e := extractedFile{
+		Name:      f.Name,
+		Size:      len(f.Data),
+		Type:      fileType(f.MimeType),
+		MimeType:  f.MimeType,
+		Category:  f.Category,
+		SHA256:    f.SHA256,
+		ContentID: f.ContentID,
+		Source:    f.Source,
+		data:      f.Data,
+	}
+	if !f.ModTime.IsZero() {
+		e.ModTime = &f.ModTime
+	}
+	if len(f.Provenance) > 1 {
+		e.Provenance = f.Provenance
+	}
+	return e
 }
 
 // sessionStore manages in-memory conversion results.
@@ -241,7 +298,9 @@ func verifyToken(token, fingerprint string, key []byte) (string, bool) {
 
 // cmdServe starts the web interface on the given port. If basePath is
 // non-empty, all routes are served under that prefix (e.g. "/converter").
-func cmdServe(port, basePath string) {
+// Templates and mappings can be changed through the API only with
+// adminToken, and not at all if it is empty.
+func cmdServe(port, basePath, adminToken string) {
 	basePath = normalizeBasePath(basePath)
 
 	// Structured JSON logger for machine-readable, searchable logs.
@@ -269,8 +328,11 @@ func cmdServe(port, basePath string) {
 	mux.HandleFunc("/", handleIndex(basePath))
 	mux.HandleFunc("/api/info", handleInfo)
 	mux.HandleFunc("/api/convert", handleConvert(store, limiter, hmacKey))
+	mux.HandleFunc("/api/detect", handleDetect(limiter))
 	mux.HandleFunc("/api/bank/convert", handleBankConvert(store, limiter, hmacKey))
-	mux.HandleFunc("/api/bank/templates", handleBankTemplates)
+	mux.HandleFunc("/api/bank/templates", handleBankTemplates(limiter, adminToken))
+	mux.HandleFunc("/api/bank/templates/infer", handleBankInfer(limiter))
+	mux.HandleFunc("/api/bank/mappings", handleBankMappings(limiter, adminToken))
 	mux.HandleFunc("/api/fileconvert/formats", handleFileConvertFormats)
 	mux.HandleFunc("/api/fileconvert/convert", handleFileConvertConvert(store, limiter, hmacKey))
 	mux.HandleFunc("/api/files/", handleFile(store, hmacKey, fileLimiter))
@@ -283,6 +345,13 @@ func cmdServe(port, basePath string) {
 	// Release-mode features (heartbeat shutdown, etc.) — no-op in dev builds.
 	setupRelease(mux, stop)
 
+	// Pick up template files added or edited in the --templates directory.
+	if templates := bankparser.Templates(); templates != nil {
+		go templates.Watch(2*time.Second, nil, func(err error) {
+			slog.Warn("bank templates reloaded with errors", "error", err)
+		})
+	}
+
 	// Serve embedded static assets (CSS, JS) under /static/ with cache headers.
 	staticContent, _ := fs.Sub(web.StaticFS, "static")
 	mux.Handle("/static/", cacheHeaders(
@@ -319,6 +388,9 @@ func cmdServe(port, basePath string) {
 			"addr", addr,
 			"basePath", basePath,
 			"url", url,
+			"remoteImages", formats.CurrentRemotePolicy().Mode,
+			"sanitizeHTML", formats.SanitizeEnabled(),
+			"converters", len(formats.All()),
 		)
 		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
 			slog.Error("server failed", "error", err)
@@ -418,7 +490,7 @@ func handleIndex(basePath string) http.HandlerFunc {
 		w.Header().Set("Cache-Control", "no-store")
 		w.Header().Set("Content-Security-Policy",
 			"default-src 'none'; script-src 'self'; style-src 'self'; "+
-				"connect-src 'self'; img-src 'self' data:; base-uri 'self'; "+
+				"connect-src 'self'; img-src 'self' data:; frame-src 'self'; base-uri 'self'; "+
 				"form-action 'self'; frame-ancestors 'none'")
 		data, err := web.StaticFS.ReadFile("static/index.html")
 		if err != nil {
@@ -442,19 +514,63 @@ func handleIndex(basePath string) http.HandlerFunc {
 	}
 }
 
-// handleInfo returns the server version as JSON.
+// converterInfo describes a registered converter and the form fields
+// /api/convert accepts for it.
+type converterInfo struct {
+	Name       string           `json:"name"`
+	Version    string           `json:"version,omitempty"` // plugins only
+	Extensions []string         `json:"extensions"`
+	Options    []formats.Option `json:"options,omitempty"`
+	MaxSize    int64            `json:"maxSize,omitempty"` // bytes, from the deployment policy
+}
+
+// handleInfo returns the server version and the converters the deployment
+// policy allows as JSON.
 func handleInfo(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store")
-	json.NewEncoder(w).Encode(map[string]string{"version": version})
+	var convs []converterInfo
+	for _, c := range formats.All() {
+		info := converterInfo{
+			Name:       c.Name(),
+			Extensions: c.Extensions(),
+			Options:    formats.OptionsFor(c),
+			MaxSize:    formats.CurrentPolicy().MaxSize("", c.Name()),
+		}
+		if v, ok := c.(formats.Versioned); ok {
+			info.Version = v.Version()
+		}
+		convs = append(convs, info)
+	}
+	json.NewEncoder(w).Encode(struct {
+		Version    string          `json:"version"`
+		Converters []converterInfo `json:"converters"`
+	}{version, convs})
 }
 
 // convertResponse is the JSON returned after a successful conversion.
 type convertResponse struct {
 	SessionToken string          `json:"sessionToken"`
 	Files        []extractedFile `json:"files"`
+	Preview      string          `json:"preview,omitempty"` // file to show by default
+
+	// ValidationErrors lists the bank records that broke their template's
+	// rules when an error workbook was returned instead of the output.
+	ValidationErrors []bankparser.FieldError `json:"validationErrors,omitempty"`
 }
 
+// Recursive extraction limits for /api/convert. Sessions are held in
+// memory, so the total is capped well below the CLI default; clients may
+// only lower the depth.
+const (
+	serverMaxDepth = formats.DefaultMaxDepth
+	serverMaxTotal = 100 << 20
+)
+
+// previewFile is the rendered message view produced by message converters;
+// the web UI shows it by default when present.
+const previewFile = "message.html"
+
 // handleConvert processes an uploaded file, auto-detecting its format.
 func handleConvert(store *sessionStore, limiter *rateLimiter, hmacKey []byte) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -487,30 +603,73 @@ func handleConvert(store *sessionStore, limiter *rateLimiter, hmacKey []byte) ht
 			return
 		}
 
+		// The "converter" field overrides detection (see /api/detect).
 		conv := formats.Detect(header.Filename, data)
+		if name := r.FormValue("converter"); name != "" {
+			if conv, err = formats.Lookup(name); err != nil {
+				jsonError(w, err.Error(), policyStatus(err, http.StatusBadRequest))
+				return
+			}
+		}
 		if conv == nil {
 			jsonError(w, "Unsupported file format", http.StatusBadRequest)
 			return
 		}
 
-		items, err := conv.Convert(data)
+		// Converter options arrive as form fields named after the
+		// converter's option schema; other fields are ignored.
+		cc := formats.Adapt(conv)
+		raw := make(map[string]string)
+		for _, o := range cc.Options() {
+			if v, ok := r.MultipartForm.Value[o.Name]; ok && len(v) > 0 {
+				raw[o.Name] = v[0]
+			}
+		}
+		opts, err := formats.ParseOptions(cc.Options(), raw)
+		if err != nil {
+			jsonError(w, "Invalid option: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Archive output is held in the session; keep it within the
+		// server's own limit whatever the client asks for.
+		if mb := int(serverMaxTotal >> 20); opts.Int("max-total-mb") > mb {
+			opts["max-total-mb"] = strconv.Itoa(mb)
+		}
+
+		in := formats.Input{Name: header.Filename, Data: data}
+		if err := formats.CurrentPolicy().Check(conv, in); err != nil {
+			jsonError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		var items []formats.ConvertedFile
+		var limits *formats.RecursiveLimits
+		if recursive, _ := strconv.ParseBool(r.FormValue("recursive")); recursive {
+			limits = &formats.RecursiveLimits{MaxDepth: serverMaxDepth, MaxTotal: serverMaxTotal}
+			if d, err := strconv.Atoi(r.FormValue("max-depth")); err == nil && d > 0 && d < limits.MaxDepth {
+				limits.MaxDepth = d
+			}
+			items, err = formats.ExtractRecursive(r.Context(), conv, in, opts, *limits)
+		} else {
+			items, err = cc.ConvertContext(r.Context(), in, opts)
+		}
 		if err != nil {
 			jsonError(w, "Conversion failed: "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		items = formats.Finalize(formats.SanitizeFiles(items), conv, header.Filename)
 
 		if len(items) == 0 {
 			jsonError(w, "No content found in file", http.StatusUnprocessableEntity)
 			return
 		}
+		items = appendManifest(r, items, in, conv, opts, limits)
 
 		files := make([]extractedFile, len(items))
+		preview := ""
 		for i, item := range items {
-			files[i] = extractedFile{
-				Name: item.Name,
-				Size: len(item.Data),
-				Type: guessType(item.Name),
-				data: item.Data,
+			files[i] = newExtractedFile(item)
+			if item.Name == previewFile {
+				preview = previewFile
 			}
 		}
 
@@ -529,10 +688,49 @@ func handleConvert(store *sessionStore, limiter *rateLimiter, hmacKey []byte) ht
 		json.NewEncoder(w).Encode(convertResponse{
 			SessionToken: token,
 			Files:        files,
+			Preview:      preview,
 		})
 	}
 }
 
+// handleDetect ranks the converters for an uploaded file and returns each
+// candidate's score, reasons and options, best first. Nothing is stored.
+func handleDetect(limiter *rateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		if !limiter.allow() {
+			w.Header().Set("Retry-After", "1")
+			slog.Warn("rate limit exceeded", "remote", r.RemoteAddr)
+			jsonError(w, "Too many requests -- try again shortly", http.StatusTooManyRequests)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, 50<<20)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			jsonError(w, "No file uploaded", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			jsonError(w, "Failed to read file", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "private, no-store")
+		json.NewEncoder(w).Encode(struct {
+			Filename   string          `json:"filename"`
+			Size       int             `json:"size"`
+			Candidates []candidateInfo `json:"candidates"`
+		}{header.Filename, len(data), candidateInfos(formats.Rank(header.Filename, data))})
+	}
+}
+
 // handleFile serves a single extracted file by session token and filename.
 func handleFile(store *sessionStore, hmacKey []byte, limiter *rateLimiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -571,15 +769,24 @@ func handleFile(store *sessionStore, hmacKey []byte, limiter *rateLimiter) http.
 
 		for _, f := range sess.files {
 			if f.Name == name {
-				ct := contentType(f.Name, f.Type)
-				w.Header().Set("Content-Type", ct)
-				w.Header().Set("Content-Disposition", safeDisposition(f.Name))
+				// The MIME type comes from the sender or a plugin, so
+				// only types a browser shows without running script are
+				// served inline; the rest (SVG, XML, ...) are downloads.
+				// Extracted HTML may contain malicious scripts; it is
+				// served inline for the UI's sandboxed same-origin
+				// preview iframe, with a CSP that blocks execution.
+				media, _, _ := mime.ParseMediaType(f.MimeType)
+				isHTML := formats.IsHTMLType(f.MimeType)
+				w.Header().Set("Content-Type", f.MimeType)
+				w.Header().Set("Content-Disposition", safeDisposition(f.Name, isHTML || inlineTypes[media]))
 				w.Header().Set("Cache-Control", "private, no-store")
-				// Extracted HTML may contain malicious scripts;
-				// block execution with a strict CSP.
-				if f.Type == "html" {
+				switch {
+				case isHTML:
+					w.Header().Set("X-Frame-Options", "SAMEORIGIN")
 					w.Header().Set("Content-Security-Policy",
-						"default-src 'none'; style-src 'unsafe-inline'; img-src data:; frame-ancestors 'none'")
+						"default-src 'none'; style-src 'unsafe-inline'; img-src data:; frame-ancestors 'self'")
+				case media != "application/pdf":
+					w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self' data:; media-src 'self'; sandbox")
 				}
 				w.Write(f.data)
 				return
@@ -625,9 +832,14 @@ func handleZip(store *sessionStore, hmacKey []byte, limiter *rateLimiter) http.H
 		w.Header().Set("Cache-Control", "private, no-store")
 
 		// Stream zip directly to the response writer (no buffering).
+		// Members keep their original modification time when known.
 		zw := zip.NewWriter(w)
 		for _, f := range sess.files {
-			fw, err := zw.Create(f.Name)
+			hdr := &zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: sess.created}
+			if f.ModTime != nil {
+				hdr.Modified = *f.ModTime
+			}
+			fw, err := zw.CreateHeader(hdr)
 			if err != nil {
 				break
 			}
@@ -646,80 +858,64 @@ func jsonError(w http.ResponseWriter, msg string, code int) {
 	json.NewEncoder(w).Encode(map[string]string{"error": msg})
 }
 
-// guessType returns a short type category string based on file extension.
-func guessType(name string) string {
-	lower := strings.ToLower(name)
+// fileType returns the short type category the web UI uses for icons
+// and previews, from a MIME type.
+func fileType(mimeType string) string {
+	media, _, _ := mime.ParseMediaType(mimeType)
 	switch {
-	case strings.HasSuffix(lower, ".html") || strings.HasSuffix(lower, ".htm"):
+	case formats.IsHTMLType(mimeType):
 		return "html"
-	case strings.HasSuffix(lower, ".txt"):
+	case media == "text/plain", media == "text/csv":
 		return "text"
-	case strings.HasSuffix(lower, ".rtf"):
+	case media == "application/rtf", media == "text/rtf":
 		return "rtf"
-	case strings.HasSuffix(lower, ".png"):
-		return "image"
-	case strings.HasSuffix(lower, ".jpg") || strings.HasSuffix(lower, ".jpeg"):
-		return "image"
-	case strings.HasSuffix(lower, ".gif"):
+	case strings.HasPrefix(media, "image/"):
 		return "image"
-	case strings.HasSuffix(lower, ".pdf"):
+	case media == "application/pdf":
 		return "pdf"
-	case strings.HasSuffix(lower, ".doc") || strings.HasSuffix(lower, ".docx"):
+	case media == "application/msword", strings.Contains(media, "wordprocessingml"),
+		media == "application/vnd.oasis.opendocument.text":
 		return "document"
-	case strings.HasSuffix(lower, ".xls") || strings.HasSuffix(lower, ".xlsx"):
+	case media == "application/vnd.ms-excel", strings.Contains(media, "spreadsheetml"):
 		return "spreadsheet"
 	default:
 		return "file"
 	}
 }
 
-// contentType maps a type category from guessType to a MIME content type.
-func contentType(name, fileType string) string {
-	switch fileType {
-	case "html":
-		return "text/html; charset=utf-8"
-	case "text":
-		return "text/plain; charset=utf-8"
-	case "rtf":
-		return "application/rtf"
-	case "image":
-		return imageMIME(name)
-	case "pdf":
-		return "application/pdf"
-	default:
-		return "application/octet-stream"
+// policyStatus returns 403 Forbidden for errors caused by the deployment
+// policy and status otherwise.
+func policyStatus(err error, status int) int {
+	if errors.Is(err, formats.ErrNotAllowed) {
+		return http.StatusForbidden
 	}
+	return status
 }
 
-// imageMIME returns the MIME type for an image file based on its extension.
-func imageMIME(name string) string {
-	lower := strings.ToLower(name)
-	switch {
-	case strings.HasSuffix(lower, ".jpg"), strings.HasSuffix(lower, ".jpeg"):
-		return "image/jpeg"
-	case strings.HasSuffix(lower, ".gif"):
-		return "image/gif"
-	case strings.HasSuffix(lower, ".bmp"):
-		return "image/bmp"
-	case strings.HasSuffix(lower, ".webp"):
-		return "image/webp"
-	case strings.HasSuffix(lower, ".svg"):
-		return "image/svg+xml"
-	default:
-		return "image/png"
-	}
+// inlineTypes are the media types /api/files serves inline besides HTML:
+// types browsers display without running script. The PDF viewer does not
+// work under a sandbox CSP, so PDFs are served without one.
+var inlineTypes = map[string]bool{
+	"text/plain": true, "text/csv": true,
+	"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true, "image/bmp": true,
+	"audio/mpeg": true, "audio/wav": true, "audio/ogg": true, "video/mp4": true, "video/webm": true,
+	"application/pdf": true,
 }
 
-// safeDisposition returns a Content-Disposition header value with the
-// filename sanitized to prevent header injection.
-func safeDisposition(name string) string {
+// safeDisposition returns a Content-Disposition header value, inline or
+// attachment, with the filename sanitized to prevent header injection.
+func safeDisposition(name string, inline bool) string {
 	safe := strings.Map(func(r rune) rune {
 		if r < 0x20 || r == 0x7f || r == '"' || r == '\\' {
 			return '_'
 		}
 		return r
 	}, name)
-	return fmt.Sprintf(`inline; filename="%s"`, safe)
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+	return fmt.Sprintf(`%s; filename="%s"`, disposition, safe)
 }
 
 // normalizeBasePath ensures basePath has a leading slash and no trailing
@@ -735,13 +931,277 @@ func normalizeBasePath(s string) string {
 	return s
 }
 
-// handleBankTemplates returns the list of available bank file templates.
-func handleBankTemplates(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Cache-Control", "public, max-age=3600")
+// handleBankTemplates lists, returns, creates, updates and deletes bank
+// file templates with their full field definitions. GET lists the
+// built-in and stored templates (or returns one with ?key=, and an older
+// version with &version=). POST creates the template in the JSON body
+// under its "key"; PUT ?key= replaces it, and the body's "version" must
+// be the current one; DELETE ?key=&version= removes it. Built-in
+// templates are read-only, and changes need the --templates directory
+// and the admin token (see allowWrite).
+func handleBankTemplates(limiter *rateLimiter, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodDelete:
+			if !allowWrite(w, r, limiter, adminToken) {
+				return
+			}
+		}
+		key := r.URL.Query().Get("key")
+		store := bankparser.Templates()
+
+		switch r.Method {
+		case http.MethodGet:
+			var v any = bankparser.ListTemplates()
+			if key != "" {
+				t, err := findTemplate(key, r.URL.Query().Get("version"))
+				if err != nil {
+					templateError(w, err)
+					return
+				}
+				v = t
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			json.NewEncoder(w).Encode(v)
+		case http.MethodPost, http.MethodPut:
+			if store == nil {
+				jsonError(w, "No template directory is configured (--templates)", http.StatusNotFound)
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err != nil {
+				jsonError(w, "Failed to read template", http.StatusBadRequest)
+				return
+			}
+			var body bankparser.StoredTemplate
+			if err := json.Unmarshal(data, &body); err != nil {
+				jsonError(w, "Invalid template JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			var t *bankparser.StoredTemplate
+			status := http.StatusOK
+			if r.Method == http.MethodPost {
+				t, err = store.Create(body.Key, body.Template)
+				status = http.StatusCreated
+			} else {
+				if key == "" {
+					key = body.Key
+				}
+				t, err = store.Update(key, body.Template, body.Version)
+			}
+			if err != nil {
+				templateError(w, err)
+				return
+			}
+			slog.Info("bank template saved", "key", t.Key, "version", t.Version)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(t)
+		case http.MethodDelete:
+			if store == nil {
+				jsonError(w, "No template directory is configured (--templates)", http.StatusNotFound)
+				return
+			}
+			version := 0
+			if v := r.URL.Query().Get("version"); v != "" {
+				var err error
+				if version, err = strconv.Atoi(v); err != nil {
+					jsonError(w, "Invalid version", http.StatusBadRequest)
+					return
+				}
+			}
+			if err := store.Delete(key, version); err != nil {
+				templateError(w, err)
+				return
+			}
+			slog.Info("bank template deleted", "key", key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "GET, POST, PUT or DELETE required", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// allowWrite checks a request that changes stored templates or mappings,
+// which other users' conversions depend on. Changes are off unless serve
+// was given --admin-token, must carry that token as a bearer token, are
+// rate limited like conversions, and must send JSON, which a cross-site
+// form cannot. It writes the error and returns false if any check fails.
+func allowWrite(w http.ResponseWriter, r *http.Request, limiter *rateLimiter, adminToken string) bool {
+	if adminToken == "" {
+		jsonError(w, "Changes through the API are disabled (start serve with --admin-token)", http.StatusForbidden)
+		return false
+	}
+	if !limiter.allow() {
+		w.Header().Set("Retry-After", "1")
+		slog.Warn("rate limit exceeded", "remote", r.RemoteAddr)
+		jsonError(w, "Too many requests -- try again shortly", http.StatusTooManyRequests)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		slog.Warn("unauthorized change rejected", "path", r.URL.Path, "remote", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		jsonError(w, "Admin token required", http.StatusUnauthorized)
+		return false
+	}
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		if media, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || media != "application/json" {
+			jsonError(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+			return false
+		}
+	}
+	return true
+}
+
+// templateError writes a template store error with its status: 404 for
+// unknown templates, 409 for version conflicts and existing keys, 403 for
+// built-in templates and 400 for invalid templates.
+func templateError(w http.ResponseWriter, err error) {
+	code := http.StatusBadRequest
+	switch {
+	case errors.Is(err, bankparser.ErrTemplateNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, bankparser.ErrVersionConflict), errors.Is(err, bankparser.ErrTemplateExists):
+		code = http.StatusConflict
+	case errors.Is(err, bankparser.ErrBuiltinTemplate):
+		code = http.StatusForbidden
+	}
+	jsonError(w, err.Error(), code)
+}
+
+// handleBankInfer proposes a template for an uploaded fixed-width "file",
+// helped by an optional "sample" CSV or Excel file of the same records,
+// and returns it with notes for the template editor to review. Nothing is
+// stored: the UI saves the result through /api/bank/templates.
+func handleBankInfer(limiter *rateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		if !limiter.allow() {
+			w.Header().Set("Retry-After", "1")
+			slog.Warn("rate limit exceeded", "remote", r.RemoteAddr)
+			jsonError(w, "Too many requests -- try again shortly", http.StatusTooManyRequests)
+			return
+		}
+		if err := r.ParseMultipartForm(10 << 20); err != nil { // 10 MB limit
+			jsonError(w, "Failed to parse form data", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			jsonError(w, "No file uploaded", http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			jsonError(w, "Failed to read file", http.StatusBadRequest)
+			return
+		}
+		var sample []byte
+		if sf, _, err := r.FormFile("sample"); err == nil {
+			sample, err = io.ReadAll(sf)
+			sf.Close()
+			if err != nil {
+				jsonError(w, "Failed to read sample", http.StatusBadRequest)
+				return
+			}
+		}
+		name := r.FormValue("name")
+		if name == "" {
+			name = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+		}
 
-	templates := bankparser.GetTemplateList()
-	json.NewEncoder(w).Encode(templates)
+		inf, err := bankparser.InferTemplate(data, sample, name)
+		if err != nil {
+			jsonError(w, "Failed to infer a template: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info("bank template inferred",
+			"filename", header.Filename,
+			"sample", sample != nil,
+			"fields", len(inf.Template.Fields),
+		)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(inf)
+	}
+}
+
+// handleBankMappings lists, returns, saves and deletes column mapping
+// profiles in the --mappings directory: GET lists them (or returns one
+// with ?name=), POST saves the JSON body after validating it against its
+// template, and DELETE ?name= removes one. Changes need the admin token
+// (see allowWrite).
+func handleBankMappings(limiter *rateLimiter, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodDelete:
+			if !allowWrite(w, r, limiter, adminToken) {
+				return
+			}
+		}
+		mappings := bankparser.Mappings()
+		if mappings == nil {
+			if r.Method == http.MethodGet && r.URL.Query().Get("name") == "" {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte("[]\n"))
+				return
+			}
+			jsonError(w, "No mapping directory is configured (--mappings)", http.StatusNotFound)
+			return
+		}
+		name := r.URL.Query().Get("name")
+
+		switch r.Method {
+		case http.MethodGet:
+			var v any
+			var err error
+			if name != "" {
+				v, err = mappings.Get(name)
+			} else {
+				v, err = mappings.List()
+			}
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			json.NewEncoder(w).Encode(v)
+		case http.MethodPost:
+			data, err := io.ReadAll(io.LimitReader(r.Body, 256<<10))
+			if err != nil {
+				jsonError(w, "Failed to read mapping", http.StatusBadRequest)
+				return
+			}
+			m, err := bankparser.LoadMapping(data)
+			if err == nil {
+				err = mappings.Save(m)
+			}
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			slog.Info("bank mapping saved", "name", m.Name, "template", m.Template)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(m)
+		case http.MethodDelete:
+			if err := mappings.Delete(name); err != nil {
+				jsonError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			slog.Info("bank mapping deleted", "name", name)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "GET, POST or DELETE required", http.StatusMethodNotAllowed)
+		}
+	}
 }
 
 // handleBankConvert processes CSV/Excel data and converts it to the selected output format.
@@ -766,8 +1226,21 @@ func handleBankConvert(store *sessionStore, limiter *rateLimiter, hmacKey []byte
 			return
 		}
 
-		// Get template selection
+		// Get template selection; a mapping profile brings its own
 		template := r.FormValue("template")
+		var mapping *bankparser.Mapping
+		if name := r.FormValue("mapping"); name != "" {
+			var err error
+			if mapping, err = bankparser.GetMapping(name); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if template != "" && template != mapping.Template {
+				jsonError(w, fmt.Sprintf("mapping %s is for template %s", name, mapping.Template), http.StatusBadRequest)
+				return
+			}
+			template = mapping.Template
+		}
 		if template == "" {
 			template = "ACH_Payment" // default template
 		}
@@ -792,6 +1265,16 @@ func handleBankConvert(store *sessionStore, limiter *rateLimiter, hmacKey []byte
 			return
 		}
 
+		in := formats.Input{Name: header.Filename, Data: data}
+		bankConv, err := formats.Lookup("Bank File (CSV)")
+		if err == nil {
+			err = formats.CurrentPolicy().Check(bankConv, in)
+		}
+		if err != nil {
+			jsonError(w, err.Error(), policyStatus(err, http.StatusInternalServerError))
+			return
+		}
+
 		// Parse input (auto-detect CSV vs Excel)
 		bankFile, err := bankparser.DecodeAuto(data, template)
 		if err != nil {
@@ -799,56 +1282,78 @@ func handleBankConvert(store *sessionStore, limiter *rateLimiter, hmacKey []byte
 			return
 		}
 
+		// An uploaded ACH profile replaces the server's for NACHA output.
+		if pf, _, err := r.FormFile("achProfile"); err == nil {
+			pdata, err := io.ReadAll(io.LimitReader(pf, 64<<10))
+			pf.Close()
+			if err == nil {
+				bankFile.Profile, err = bankparser.LoadACHProfile(pdata)
+			}
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
 		// Format output based on selected format
 		var formatted []byte
-		var outputName string
-		var outputType string
+		var outputName, failure string
+		status := http.StatusInternalServerError
 
-		switch outputFormat {
-		case "xlsx":
-			formatted, err = bankFile.FormatAsExcel()
-			if err != nil {
-				jsonError(w, "Failed to create Excel output: "+err.Error(), http.StatusInternalServerError)
-				return
+		if mapping != nil {
+			err = bankFile.ApplyMapping(mapping)
+			failure, status = "Failed to map columns: ", http.StatusBadRequest
+		}
+		if err == nil {
+			switch outputFormat {
+			case "xlsx":
+				formatted, err = bankFile.FormatAsExcel()
+				outputName, failure = template+"_formatted.xlsx", "Failed to create Excel output: "
+			case "csv":
+				formatted, err = bankFile.FormatAsCSV()
+				outputName, failure = template+"_formatted.csv", "Failed to create CSV output: "
+			default: // "txt" — fixed-width bank format
+				formatted, err = bankFile.FormatAsFixedWidth()
+				outputName, failure = template+"_formatted.txt", "Failed to create bank file: "
+				status = http.StatusBadRequest
 			}
-			outputName = template + "_formatted.xlsx"
-			outputType = "spreadsheet"
-		case "csv":
-			formatted, err = bankFile.FormatAsCSV()
-			if err != nil {
-				jsonError(w, "Failed to create CSV output: "+err.Error(), http.StatusInternalServerError)
+		}
+
+		// Records that break the template's rules produce no output: the
+		// errors are returned, or with errorWorkbook=true an annotated
+		// copy of the input to fix them in.
+		var verr *bankparser.ValidationError
+		category := formats.CategoryAttachment
+		if errors.As(err, &verr) {
+			if r.FormValue("errorWorkbook") != "true" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(map[string]any{
+					"error":            verr.Error(),
+					"validationErrors": verr.Errors,
+				})
 				return
 			}
-			outputName = template + "_formatted.csv"
-			outputType = "text"
-		default: // "txt" — fixed-width bank format
-			formatted = bankFile.FormatAsFixedWidth()
-			outputName = template + "_formatted.txt"
-			outputType = "text"
+			formatted, err = bankFile.ErrorWorkbook(verr.Errors)
+			outputName, failure = template+"_errors.xlsx", "Failed to create error workbook: "
+			category, status = formats.CategoryReport, http.StatusInternalServerError
 		}
-
-		// Determine original file name/type
-		origName := header.Filename
-		origType := "text"
-		if strings.HasSuffix(strings.ToLower(origName), ".xlsx") || strings.HasSuffix(strings.ToLower(origName), ".xls") {
-			origType = "spreadsheet"
+		if err != nil {
+			jsonError(w, failure+err.Error(), status)
+			return
 		}
 
 		// Create session with both original and formatted output
-		files := []extractedFile{
-			{
-				Name: "original_" + origName,
-				Size: len(data),
-				Type: origType,
-				data: data,
-			},
-			{
-				Name: outputName,
-				Size: len(formatted),
-				Type: outputType,
-				data: formatted,
-			},
+		outputs := append(
+			formats.Finalize([]formats.ConvertedFile{{Name: "original_" + header.Filename, Data: data}}, nil, ""),
+			formats.Finalize([]formats.ConvertedFile{{Name: outputName, Data: formatted, Category: category}}, bankConv, header.Filename)...,
+		)
+		opts := formats.Options{"template": template, "output-format": outputFormat}
+		if mapping != nil {
+			opts["mapping"] = mapping.Name
 		}
+		outputs = appendManifest(r, outputs, in, bankConv, opts, nil)
+		files := sessionFiles(outputs)
 
 		sid := store.create(files)
 		token := signToken(sid, clientFingerprint(r), hmacKey)
@@ -864,14 +1369,19 @@ func handleBankConvert(store *sessionStore, limiter *rateLimiter, hmacKey []byte
 
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Cache-Control", "private, no-store")
-		json.NewEncoder(w).Encode(convertResponse{
+		resp := convertResponse{
 			SessionToken: token,
 			Files:        files,
-		})
+		}
+		if verr != nil {
+			resp.ValidationErrors = verr.Errors
+		}
+		json.NewEncoder(w).Encode(resp)
 	}
 }
 
-// handleFileConvertFormats returns the list of supported file conversion formats.
+// handleFileConvertFormats returns the list of supported file conversion
+// formats, limited to the conversions the deployment policy allows.
 func handleFileConvertFormats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -962,41 +1472,35 @@ func handleFileConvertConvert(store *sessionStore, limiter *rateLimiter, hmacKey
 
 		// If no output format selected, just store the original file
 		if toFormat == "" {
-			files = []extractedFile{
-				{
-					Name: originalName,
-					Size: len(data),
-					Type: guessType(originalName),
-					data: data,
-				},
-			}
+			outputs := formats.Finalize([]formats.ConvertedFile{{Name: originalName, Data: data}}, nil, "")
+			outputs = appendManifest(r, outputs, formats.Input{Name: originalName, Data: data}, nil, nil, nil)
+			files = sessionFiles(outputs)
 
 			slog.Info("file uploaded without conversion", "filename", header.Filename, "bytes", len(data))
 		} else {
 			// Perform conversion (all in memory)
-			result, err := fileconvertformat.ConvertFile(data, fromFormat, toFormat, quality)
+			result, err := fileconvertformat.ConvertFileContext(r.Context(), data, fromFormat, toFormat, quality)
 			if err != nil {
-				jsonError(w, "Conversion failed: "+err.Error(), http.StatusBadRequest)
+				jsonError(w, "Conversion failed: "+err.Error(), policyStatus(err, http.StatusBadRequest))
 				return
 			}
 
 			// Create session with both original and converted file
 			convertedName := strings.TrimSuffix(originalName, fromFormat) + toFormat
 
-			files = []extractedFile{
-				{
-					Name: "original_" + originalName,
-					Size: len(data),
-					Type: guessType(originalName),
-					data: data,
-				},
-				{
-					Name: convertedName,
-					Size: len(result.Data),
-					Type: guessType(convertedName),
-					data: result.Data,
-				},
-			}
+			// Pandoc and PDF HTML output goes through the same sanitiser
+			// as extracted message bodies (the original upload does not).
+			fileConv, _ := formats.Lookup("File Converter")
+			outputs := formats.SanitizeFiles([]formats.ConvertedFile{
+				{Name: convertedName, Data: result.Data, Category: formats.CategoryBody},
+			})
+			outputs = append(
+				formats.Finalize([]formats.ConvertedFile{{Name: "original_" + originalName, Data: data}}, nil, ""),
+				formats.Finalize(outputs, fileConv, originalName)...,
+			)
+			outputs = appendManifest(r, outputs, formats.Input{Name: originalName, Data: data}, fileConv,
+				formats.Options{"from": fromFormat, "to": toFormat, "quality": strconv.Itoa(quality)}, nil)
+			files = sessionFiles(outputs)
 
 			slog.Info("file conversion complete",
 				"session", "",
@@ -0,0 +1,94 @@
+// verify.go implements the CLI "verify" command that checks a directory of
+// converted files against the manifest written with --manifest.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lgican/File-Converter/formats"
+)
+
+// cmdVerify checks the outputs listed in a manifest against a directory
+// (default: the manifest's own) and, with --input, the original input.
+// Files in the directory that the manifest does not list are reported but
+// do not fail verification. It exits 0 when everything matches, 1 when a
+// file is missing or changed, and 2 on error.
+func cmdVerify(args []string) {
+	input, args := takeValueFlag(args, "--input")
+	asJSON, args := takeBoolFlag(args, "--json")
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "Error: verify requires a manifest and optionally a directory")
+		usage()
+		os.Exit(2)
+	}
+	manifestPath := args[0]
+	dir := filepath.Dir(manifestPath)
+	if len(args) == 2 {
+		dir = args[1]
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	m, err := formats.ParseManifest(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", manifestPath, err)
+		os.Exit(2)
+	}
+
+	skip := []string{filepath.Base(manifestPath), m.Input.Name}
+	checks, err := m.Verify(dir, skip...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if input != "" {
+		c := formats.CheckFile(input, m.Input)
+		c.Name = "input " + c.Name
+		checks = append([]formats.ManifestCheck{c}, checks...)
+	}
+
+	failed := false
+	for _, c := range checks {
+		if c.Status == formats.CheckMissing || c.Status == formats.CheckChanged {
+			failed = true
+		}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			Manifest string                  `json:"manifest"`
+			Dir      string                  `json:"dir"`
+			Verified bool                    `json:"verified"`
+			Checks   []formats.ManifestCheck `json:"checks"`
+		}{manifestPath, dir, !failed, checks})
+	} else {
+		if m.Converter != nil {
+			fmt.Printf("%s (%d bytes) converted by %s %s at %s\n", m.Input.Name, m.Input.Size,
+				m.Converter.Name, m.Converter.Version, m.Created.Format("2006-01-02 15:04:05 MST"))
+		}
+		for _, c := range checks {
+			line := fmt.Sprintf("  %-8s %s", c.Status, c.Name)
+			if c.Detail != "" {
+				line += " (" + c.Detail + ")"
+			}
+			fmt.Println(line)
+		}
+		if failed {
+			fmt.Println("Verification FAILED.")
+		} else {
+			fmt.Printf("Verified %d outputs.\n", len(m.Outputs))
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -26,7 +26,7 @@ func cmdView(path string) {
 		os.Exit(1)
 	}
 	if fi, err := os.Stat(path); err == nil {
-		fmt.Printf("File:        %s (%s)\n", filepath.Base(path), humanSize(int(fi.Size())))
+		fmt.Printf("File:        %s (%s)\n", filepath.Base(path), formats.HumanSize(int(fi.Size())))
 	} else {
 		fmt.Printf("File:        %s\n", filepath.Base(path))
 	}
@@ -73,16 +73,16 @@ func printMessage(msg *tnef.Message, indent string) {
 		}
 	}
 	if len(msg.Body) > 0 {
-		fmt.Printf("%sBody:        Plain text (%s)\n", indent, humanSize(len(msg.Body)))
+		fmt.Printf("%sBody:        Plain text (%s)\n", indent, formats.HumanSize(len(msg.Body)))
 	}
 	if len(msg.BodyHTML) > 0 {
-		fmt.Printf("%sBody HTML:   Yes (%s)\n", indent, humanSize(len(msg.BodyHTML)))
+		fmt.Printf("%sBody HTML:   Yes (%s)\n", indent, formats.HumanSize(len(msg.BodyHTML)))
 	}
 	if len(msg.BodyRTF) > 0 {
 		if len(msg.BodyRTFHTML) > 0 {
-			fmt.Printf("%sBody RTF:    Yes (%s, encapsulated HTML: %s)\n", indent, humanSize(len(msg.BodyRTF)), humanSize(len(msg.BodyRTFHTML)))
+			fmt.Printf("%sBody RTF:    Yes (%s, encapsulated HTML: %s)\n", indent, formats.HumanSize(len(msg.BodyRTF)), formats.HumanSize(len(msg.BodyRTFHTML)))
 		} else {
-			fmt.Printf("%sBody RTF:    Yes (%s)\n", indent, humanSize(len(msg.BodyRTF)))
+			fmt.Printf("%sBody RTF:    Yes (%s)\n", indent, formats.HumanSize(len(msg.BodyRTF)))
 		}
 	}
 	if len(msg.Attachments) == 0 {
@@ -93,7 +93,7 @@ func printMessage(msg *tnef.Message, indent string) {
 	fmt.Println(divider)
 	for i, att := range msg.Attachments {
 		name := att.Filename()
-		fmt.Printf("%s  %d. %-36s %8s  [%s]\n", indent, i+1, name, humanSize(len(att.Data)), methodStr(att.Method))
+		fmt.Printf("%s  %d. %-36s %8s  [%s]\n", indent, i+1, name, formats.HumanSize(len(att.Data)), methodStr(att.Method))
 		if att.EmbeddedMsg != nil {
 			fmt.Printf("%s     └─ Embedded message:\n", indent)
 			printMessage(att.EmbeddedMsg, indent+"        ")
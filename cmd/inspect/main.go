@@ -1,244 +1,482 @@
 // Inspect is a low-level diagnostic tool that dumps the raw TNEF attribute
-// and MAPI property structure of a winmail.dat file.
+// and MAPI property structure of a winmail.dat file, with byte offsets,
+// property names from the MS-OXPROPS dictionary, optional hex dumps, and
+// JSON output for scripted comparisons.
 package main
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lgican/File-Converter/parsers/tnef"
 )
 
-// tnefSig is the TNEF file format magic number.
-const tnefSig = 0x223e9f78
+const usageText = `usage: inspect [options] <file>
+
+Options:
+  --json        Print the structure as JSON
+  --hex         Include hex dumps of attribute and property values
+  --limit <n>   Bytes of each value to decode or dump (default 256, 0 = all)
+`
+
+// options holds the parsed command-line flags.
+type options struct {
+	json  bool
+	hex   bool
+	limit int
+}
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintln(os.Stderr, "usage: inspect <file>")
-		os.Exit(1)
+	opts := options{limit: 256}
+	var path string
+	args := os.Args[1:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--json":
+			opts.json = true
+		case "--hex":
+			opts.hex = true
+		case "--limit":
+			if i+1 >= len(args) {
+				fail("--limit requires a value")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 {
+				fail("invalid --limit value: " + args[i+1])
+			}
+			opts.limit = n
+			i++
+		case "-h", "--help":
+			fmt.Print(usageText)
+			return
+		default:
+			if strings.HasPrefix(args[i], "--") || path != "" {
+				fail("unexpected argument: " + args[i])
+			}
+			path = args[i]
+		}
 	}
-	data, err := os.ReadFile(os.Args[1])
+	if path == "" {
+		fail("file path required")
+	}
+
+	data, err := os.ReadFile(path)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	if len(data) < 6 || binary.LittleEndian.Uint32(data[0:4]) != tnefSig {
-		fmt.Fprintln(os.Stderr, "not a TNEF file")
+	rep, err := inspect(path, data, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	fmt.Printf("TNEF file: %s (%d bytes)\n\n", os.Args[1], len(data))
-	offset := 6
-	attNum := 0
-	for offset+9 <= len(data) {
-		lv := data[offset]
-		id := binary.LittleEndian.Uint16(data[offset+1 : offset+3])
-		tp := binary.LittleEndian.Uint16(data[offset+3 : offset+5])
-		ln := int(binary.LittleEndian.Uint32(data[offset+5 : offset+9]))
-		end := offset + 9 + ln + 2
-		if end > len(data) {
-			fmt.Printf("  [TRUNCATED at offset %d]\n", offset)
-			break
+	if opts.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+		return
+	}
+	printReport(rep, opts)
+}
+
+// fail prints msg and the usage text, then exits.
+func fail(msg string) {
+	fmt.Fprintf(os.Stderr, "inspect: %s\n\n%s", msg, usageText)
+	os.Exit(2)
+}
+
+// report is the full structure of a TNEF file.
+type report struct {
+	File       string       `json:"file,omitempty"`
+	Size       int          `json:"size"`
+	Key        string       `json:"key"`
+	Attributes []attrReport `json:"attributes"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// attrReport describes one TNEF attribute record.
+type attrReport struct {
+	Level      string         `json:"level"`
+	Attachment int            `json:"attachment,omitempty"` // 1-based, for attachment-level records
+	ID         string         `json:"id"`
+	Name       string         `json:"name,omitempty"`
+	Type       string         `json:"type"`
+	Offset     int            `json:"offset"`
+	DataOffset int            `json:"dataOffset"`
+	Length     int            `json:"length"`
+	Checksum   string         `json:"checksum"`
+	ChecksumOK bool           `json:"checksumOK"`
+	Value      any            `json:"value,omitempty"`
+	Hex        string         `json:"hex,omitempty"`
+	Properties []propReport   `json:"properties,omitempty"`
+	Recipients [][]propReport `json:"recipients,omitempty"`
+	PropError  string         `json:"propertyError,omitempty"`
+
+	data []byte
+}
+
+// propReport describes one MAPI property.
+type propReport struct {
+	Tag          string        `json:"tag"`
+	ID           string        `json:"id"`
+	Name         string        `json:"name,omitempty"`
+	Type         string        `json:"type"`
+	ExpectedType string        `json:"expectedType,omitempty"` // dictionary type, when it differs
+	MultiValued  bool          `json:"multiValued,omitempty"`
+	Named        *namedReport  `json:"named,omitempty"`
+	Offset       int           `json:"offset"`
+	Length       int           `json:"length"`
+	Values       []valueReport `json:"values"`
+	Embedded     *report       `json:"embedded,omitempty"` // nested TNEF message
+}
+
+// namedReport identifies a named property.
+type namedReport struct {
+	GUID string `json:"guid"`
+	Set  string `json:"set,omitempty"`
+	LID  string `json:"lid,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// valueReport is one property value.
+type valueReport struct {
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Value  any    `json:"value,omitempty"`
+	Hex    string `json:"hex,omitempty"`
+
+	data []byte
+}
+
+// inspect builds the report for a TNEF stream. A truncated stream is
+// reported in the Error field rather than failing.
+func inspect(path string, data []byte, opts options) (*report, error) {
+	return inspectAt(path, data, 0, opts)
+}
+
+// inspectAt builds a report for a TNEF stream located at base within the
+// outer file, so nested messages show absolute offsets.
+func inspectAt(path string, data []byte, base int, opts options) (*report, error) {
+	attrs, err := tnef.Walk(data)
+	if err != nil && attrs == nil {
+		return nil, err
+	}
+	rep := &report{
+		File:       path,
+		Size:       len(data),
+		Key:        fmt.Sprintf("0x%04X", binary.LittleEndian.Uint16(data[4:6])),
+		Attributes: []attrReport{},
+	}
+	if err != nil {
+		rep.Error = err.Error()
+	}
+
+	attachment := 0
+	for i := range attrs {
+		a := &attrs[i]
+		if a.Level == tnef.LevelAttachment && a.ID == 0x9002 { // attAttachRendData starts each attachment
+			attachment++
+		}
+		ar := attrReport{
+			Level:      levelName(a.Level),
+			ID:         fmt.Sprintf("0x%04X", a.ID),
+			Name:       tnef.AttributeName(a.ID),
+			Type:       tnef.AttributeTypeName(a.Type),
+			Offset:     base + a.Offset,
+			DataOffset: base + a.DataOffset(),
+			Length:     len(a.Data),
+			Checksum:   fmt.Sprintf("0x%04X", a.Checksum),
+			ChecksumOK: a.ChecksumOK(),
+			Value:      attrValue(a, opts.limit),
+			data:       a.Data,
 		}
-		payload := data[offset+9 : offset+9+ln]
-		offset = end
-		lvStr := "MSG"
-		if lv == 0x02 {
-			lvStr = "ATT"
+		if a.Level == tnef.LevelAttachment {
+			ar.Attachment = attachment
 		}
-		fmt.Printf("[%s] attr=0x%04X %-22s type=0x%04X  size=%d\n", lvStr, id, aName(id), tp, ln)
-		if id == 0x9002 {
-			attNum++
-			fmt.Printf("       >>> Attachment #%d\n", attNum)
+		if opts.hex {
+			ar.Hex = hexString(a.Data, opts.limit)
 		}
-		if id == 0x8010 {
-			fmt.Printf("       Title: %q\n", noNull(string(payload)))
+		for _, p := range a.Props {
+			ar.Properties = append(ar.Properties, propertyReport(p, base, opts))
 		}
-		if id == 0x800F {
-			fmt.Printf("       AttachData: %d bytes\n", ln)
+		for _, row := range a.Rows {
+			var props []propReport
+			for _, p := range row {
+				props = append(props, propertyReport(p, base, opts))
+			}
+			ar.Recipients = append(ar.Recipients, props)
 		}
-		if id == 0x9003 || id == 0x9005 {
-			dumpMAPI(payload, "       ")
+		if a.PropErr != nil {
+			ar.PropError = a.PropErr.Error()
 		}
+		rep.Attributes = append(rep.Attributes, ar)
 	}
+	return rep, nil
 }
 
-// dumpMAPI parses and prints all MAPI properties in a raw property stream.
-func dumpMAPI(data []byte, ind string) {
-	if len(data) < 4 {
-		return
+// propertyReport describes p; base is added to every offset.
+func propertyReport(p tnef.Property, base int, opts options) propReport {
+	pr := propReport{
+		Tag:         fmt.Sprintf("0x%08X", p.Tag()),
+		ID:          fmt.Sprintf("0x%04X", p.ID),
+		Type:        tnef.PropTypeName(p.Type),
+		MultiValued: p.MultiValued,
+		Offset:      base + p.Offset,
+		Length:      p.Length,
+		Values:      []valueReport{},
 	}
-	count := int(binary.LittleEndian.Uint32(data[0:4]))
-	fmt.Printf("%sMAPI props: %d\n", ind, count)
-	off := 4
-	for i := 0; i < count && off+4 <= len(data); i++ {
-		pt := int(binary.LittleEndian.Uint16(data[off : off+2]))
-		pid := int(binary.LittleEndian.Uint16(data[off+2 : off+4]))
-		off += 4
-		mv := (pt & 0x1000) != 0
-		bt := pt & 0xEFFF
-		fs := fSize(bt)
-		if fs < 0 {
-			mv = true
-		}
-		if pid >= 0x8000 && pid <= 0xFFFE {
-			if off+16 > len(data) {
-				break
-			}
-			off += 16
-			if off+4 > len(data) {
-				break
-			}
-			kind := int(binary.LittleEndian.Uint32(data[off : off+4]))
-			off += 4
-			if kind == 0 {
-				if off+4 > len(data) {
-					break
-				}
-				off += 4
-			} else {
-				if off+4 > len(data) {
-					break
-				}
-				nl := int(binary.LittleEndian.Uint32(data[off : off+4]))
-				off += 4 + nl + p4(nl)
-			}
+	if p.Named != nil {
+		n := &namedReport{GUID: p.Named.GUID.String(), Set: tnef.PropertySetName(p.Named.GUID)}
+		if p.Named.Kind == 0 {
+			n.LID = fmt.Sprintf("0x%04X", p.Named.LID)
 		}
-		vc := 1
-		if mv {
-			if off+4 > len(data) {
-				break
-			}
-			vc = int(binary.LittleEndian.Uint32(data[off : off+4]))
-			off += 4
-		}
-		if vc < 0 || vc > 4096 {
-			break
-		}
-		total := 0
-		var samp []byte
-		ok := true
-		for v := 0; v < vc; v++ {
-			l := fs
-			if fs < 0 {
-				if off+4 > len(data) {
-					ok = false
-					break
-				}
-				l = int(binary.LittleEndian.Uint32(data[off : off+4]))
-				off += 4
+		n.Name = tnef.NamedPropName(p.Named)
+		pr.Named = n
+		pr.Name = n.Name
+	} else if info, ok := tnef.LookupProp(p.ID); ok {
+		pr.Name = info.Name
+		if typeFamily(info.Type) != typeFamily(p.Type) {
+			pr.ExpectedType = tnef.PropTypeName(info.Type)
+		}
+	}
+	for _, v := range p.Values {
+		vr := valueReport{
+			Offset: base + v.Offset,
+			Length: len(v.Data),
+			Value:  propValue(p.Type, v.Data, opts.limit),
+			data:   v.Data,
+		}
+		if opts.hex {
+			vr.Hex = hexString(v.Data, opts.limit)
+		}
+		pr.Values = append(pr.Values, vr)
+	}
+
+	// Embedded messages (PidTagAttachDataObject) are TNEF streams of their
+	// own, optionally preceded by a 16-byte interface ID.
+	if p.ID == tnef.MAPIAttachDataObj && len(p.Values) == 1 {
+		v := p.Values[0]
+		for _, skip := range []int{0, 16} {
+			if len(v.Data) < skip+6 || binary.LittleEndian.Uint32(v.Data[skip:]) != 0x223e9f78 {
+				continue
 			}
-			if l < 0 || off+l > len(data) {
-				ok = false
+			if sub, err := inspectAt("", v.Data[skip:], base+v.Offset+skip, opts); err == nil {
+				sub.File = ""
+				pr.Embedded = sub
 				break
 			}
-			total += l
-			if v == 0 && l <= 200 {
-				samp = data[off : off+l]
-			}
-			off += l + p4(l)
 		}
-		if !ok {
-			fmt.Printf("%s  [PARSE ERROR at prop %d]\n", ind, i)
-			break
+	}
+	return pr
+}
+
+// typeFamily folds property types that writers use interchangeably
+// (PT_STRING8/PT_UNICODE, PT_BINARY/PT_OBJECT) so only real mismatches
+// are flagged.
+func typeFamily(t int) int {
+	switch t {
+	case tnef.PtString8:
+		return tnef.PtUnicode
+	case tnef.PtObject:
+		return tnef.PtBinary
+	}
+	return t
+}
+
+// levelName returns a readable name for an attribute level.
+func levelName(l int) string {
+	switch l {
+	case tnef.LevelMessage:
+		return "message"
+	case tnef.LevelAttachment:
+		return "attachment"
+	}
+	return fmt.Sprintf("0x%02X", l)
+}
+
+// attrValue decodes the payload of a TNEF attribute according to its type.
+func attrValue(a *tnef.Attribute, limit int) any {
+	d := a.Data
+	switch a.Type {
+	case 0x0001, 0x0002: // atpString, atpText
+		return truncate(strings.TrimRight(string(d), "\x00"), limit)
+	case 0x0003: // atpDate: year, month, day, hour, minute, second, weekday
+		if len(d) >= 12 {
+			u := func(i int) int { return int(binary.LittleEndian.Uint16(d[i*2:])) }
+			return time.Date(u(0), time.Month(u(1)), u(2), u(3), u(4), u(5), 0, time.UTC).Format(time.RFC3339)
 		}
-		fmt.Printf("%s  0x%04X %-30s %-10s %d", ind, pid, pName(pid), tName(bt), total)
-		if bt == 0x001E || bt == 0x001F {
-			fmt.Printf("  %q", noNull(string(samp)))
-		} else if bt == 0x0003 && len(samp) >= 4 {
-			fmt.Printf("  val=%d", binary.LittleEndian.Uint32(samp))
-		} else if bt == 0x000B && len(samp) >= 4 {
-			v := binary.LittleEndian.Uint32(samp)
-			fmt.Printf("  val=%v", v != 0)
+	case 0x0004, 0x0007: // atpShort, atpWord
+		if len(d) >= 2 {
+			return binary.LittleEndian.Uint16(d)
+		}
+	case 0x0005, 0x0008: // atpLong, atpDword
+		if len(d) >= 4 {
+			return binary.LittleEndian.Uint32(d)
 		}
-		fmt.Println()
 	}
+	return nil
 }
 
-// fSize returns the fixed byte size of a MAPI property type, or -1 for variable-length.
-func fSize(t int) int {
-	switch t {
-	case 0x0002, 0x000B:
-		return 4
-	case 0x0003, 0x0004, 0x000A:
-		return 4
-	case 0x0005, 0x0006, 0x0007, 0x0014, 0x0040:
-		return 8
-	case 0x0048:
-		return 16
-	case 0x001E, 0x001F, 0x000D, 0x0102:
-		return -1
+// propValue decodes a single MAPI property value for the report. Times
+// are formatted as RFC 3339 and strings are cut to limit bytes.
+func propValue(t int, d []byte, limit int) any {
+	switch v := tnef.DecodeValue(t, d).(type) {
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case string:
+		return truncate(v, limit)
 	default:
-		return 4
+		return v
 	}
 }
 
-// p4 returns the padding needed to align n to a 4-byte boundary.
-func p4(n int) int { return (4 - n%4) % 4 }
+// truncate shortens s to limit bytes (0 = no limit) on a rune boundary.
+func truncate(s string, limit int) string {
+	if limit == 0 || len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !isRuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit] + "…"
+}
 
-// noNull strips null bytes from a string.
-func noNull(s string) string {
-	o := make([]byte, 0, len(s))
-	for i := range s {
-		if s[i] != 0 {
-			o = append(o, s[i])
-		}
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
+
+// hexString returns up to limit bytes of d as lowercase hex.
+func hexString(d []byte, limit int) string {
+	if limit > 0 && len(d) > limit {
+		d = d[:limit]
 	}
-	return string(o)
+	return fmt.Sprintf("%x", d)
 }
 
-// aName returns the symbolic name of a TNEF attribute ID.
-func aName(id uint16) string {
-	m := map[uint16]string{
-		0x8005: "attDateSent", 0x8006: "attDateRecd", 0x8008: "attMessageClass",
-		0x8009: "attMessageID", 0x800C: "attBody", 0x800D: "attPriority",
-		0x800F: "attAttachData", 0x8010: "attAttachTitle", 0x8011: "attAttachMetaFile",
-		0x8012: "attAttachCreateDate", 0x8013: "attAttachModifyDate",
-		0x8020: "attDateModified", 0x9001: "attFrom", 0x9002: "attAttachRendData",
-		0x9003: "attMAPIProps", 0x9004: "attRecipTable", 0x9005: "attAttachment",
-		0x9006: "attTnefVersion", 0x9007: "attOemCodepage",
+// printReport writes the human-readable form of rep.
+func printReport(rep *report, opts options) {
+	fmt.Printf("TNEF file: %s (%d bytes, key %s)\n\n", rep.File, rep.Size, rep.Key)
+	printAttributes(rep, opts, "")
+}
+
+func printAttributes(rep *report, opts options, ind string) {
+	for _, a := range rep.Attributes {
+		lv := "MSG"
+		if a.Level == "attachment" {
+			lv = fmt.Sprintf("ATT%d", a.Attachment)
+		}
+		sum := "ok"
+		if !a.ChecksumOK {
+			sum = "BAD " + a.Checksum
+		}
+		fmt.Printf("%s%08x  %-4s %s %-27s %-10s len=%-8d sum=%s",
+			ind, a.Offset, lv, a.ID, a.Name, a.Type, a.Length, sum)
+		if a.Value != nil {
+			fmt.Printf("  %s", formatValue(a.Value))
+		}
+		fmt.Println()
+		if opts.hex {
+			hexDump(a.data, a.DataOffset, opts.limit, ind+"          ")
+		}
+		if len(a.Properties) > 0 {
+			fmt.Printf("%s          MAPI properties: %d\n", ind, len(a.Properties))
+			printProperties(a.Properties, opts, ind+"            ")
+		}
+		for i, row := range a.Recipients {
+			fmt.Printf("%s          Recipient %d: %d properties\n", ind, i+1, len(row))
+			printProperties(row, opts, ind+"            ")
+		}
+		if a.PropError != "" {
+			fmt.Printf("%s          [PARSE ERROR: %s]\n", ind, a.PropError)
+		}
 	}
-	if n, ok := m[id]; ok {
-		return n
+	if rep.Error != "" {
+		fmt.Printf("%s[ERROR: %s]\n", ind, rep.Error)
 	}
-	return ""
 }
 
-// pName returns the symbolic name of a MAPI property ID.
-func pName(pid int) string {
-	m := map[int]string{
-		0x001A: "PR_MESSAGE_CLASS", 0x0037: "PR_SUBJECT", 0x003D: "PR_SUBJECT_PREFIX",
-		0x0042: "PR_SENT_REPRESENTING_NAME", 0x0065: "PR_SENT_REPRESENTING_EMAIL",
-		0x0070: "PR_CONVERSATION_TOPIC", 0x0071: "PR_CONVERSATION_INDEX",
-		0x0C1A: "PR_SENDER_NAME", 0x0C1E: "PR_SENDER_ADDRTYPE",
-		0x0C1F: "PR_SENDER_EMAIL_ADDRESS", 0x0E03: "PR_DISPLAY_CC",
-		0x0E04: "PR_DISPLAY_TO", 0x0E06: "PR_MESSAGE_DELIVERY_TIME",
-		0x0E07: "PR_MESSAGE_FLAGS", 0x0E08: "PR_MESSAGE_SIZE",
-		0x0E1D: "PR_SUBJECT_NORMALIZED", 0x0FF9: "PR_RECORD_KEY",
-		0x1000: "PR_BODY", 0x1009: "PR_RTF_COMPRESSED", 0x1013: "PR_BODY_HTML",
-		0x1035: "PR_INTERNET_MESSAGE_ID", 0x1039: "PR_INTERNET_CPID",
-		0x3001: "PR_DISPLAY_NAME", 0x3007: "PR_CREATION_TIME",
-		0x3008: "PR_LAST_MODIFICATION_TIME", 0x300B: "PR_SEARCH_KEY",
-		0x3701: "PR_ATTACH_DATA_OBJ", 0x3702: "PR_ATTACH_ENCODING",
-		0x3703: "PR_ATTACH_EXTENSION", 0x3704: "PR_ATTACH_FILENAME",
-		0x3705: "PR_ATTACH_METHOD", 0x3707: "PR_ATTACH_LONG_FILENAME",
-		0x3709: "PR_ATTACH_RENDERING", 0x370B: "PR_RENDERING_POSITION",
-		0x370E: "PR_ATTACH_MIME_TAG", 0x3712: "PR_ATTACH_CONTENT_ID",
-		0x3714: "PR_ATTACH_FLAGS", 0x0002: "PR_ALTERNATE_RECIPIENT",
+func printProperties(props []propReport, opts options, ind string) {
+	for _, p := range props {
+		name := p.Name
+		if p.Named != nil {
+			set := p.Named.Set
+			if set == "" {
+				set = p.Named.GUID
+			}
+			key := p.Named.LID
+			if p.Named.Name != "" {
+				key = p.Named.Name
+			}
+			name = set + "/" + key
+		}
+		typ := p.Type
+		if p.MultiValued {
+			typ = "MV_" + typ
+		}
+		if p.ExpectedType != "" {
+			typ += " (expected " + p.ExpectedType + ")"
+		}
+		fmt.Printf("%s%08x  %s %-40s %-12s len=%d", ind, p.Offset, p.Tag, name, typ, p.Length)
+		if len(p.Values) == 1 && p.Values[0].Value != nil {
+			fmt.Printf("  %s", formatValue(p.Values[0].Value))
+		} else if len(p.Values) > 1 {
+			fmt.Printf("  [%d values]", len(p.Values))
+		}
+		fmt.Println()
+		for i, v := range p.Values {
+			if len(p.Values) > 1 && v.Value != nil {
+				fmt.Printf("%s  [%d] %s\n", ind, i, formatValue(v.Value))
+			}
+			if opts.hex {
+				hexDump(v.data, v.Offset, opts.limit, ind+"  ")
+			}
+		}
+		if p.Embedded != nil {
+			fmt.Printf("%s  Embedded message (%d bytes):\n", ind, p.Embedded.Size)
+			printAttributes(p.Embedded, opts, ind+"    ")
+		}
 	}
-	if n, ok := m[pid]; ok {
-		return n
+}
+
+// formatValue renders a decoded value for the text output.
+func formatValue(v any) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
 	}
-	return ""
+	return fmt.Sprint(v)
 }
 
-// tName returns the symbolic name of a MAPI property type.
-func tName(t int) string {
-	m := map[int]string{
-		0x0002: "PT_SHORT", 0x0003: "PT_LONG", 0x000B: "PT_BOOLEAN",
-		0x001E: "PT_STRING8", 0x001F: "PT_UNICODE", 0x0040: "PT_SYSTIME",
-		0x0048: "PT_CLSID", 0x0102: "PT_BINARY", 0x000D: "PT_OBJECT",
+// hexDump prints up to limit bytes of d, 16 per line, labelled with
+// absolute offsets starting at base.
+func hexDump(d []byte, base, limit int, ind string) {
+	total := len(d)
+	if limit > 0 && len(d) > limit {
+		d = d[:limit]
+	}
+	for i := 0; i < len(d); i += 16 {
+		end := min(i+16, len(d))
+		var hexPart, text strings.Builder
+		for j := i; j < i+16; j++ {
+			if j < end {
+				fmt.Fprintf(&hexPart, "%02x ", d[j])
+				if c := d[j]; c >= 0x20 && c < 0x7F {
+					text.WriteByte(c)
+				} else {
+					text.WriteByte('.')
+				}
+			} else {
+				hexPart.WriteString("   ")
+			}
+			if j == i+7 {
+				hexPart.WriteByte(' ')
+			}
+		}
+		fmt.Printf("%s%08x  %s |%s|\n", ind, base+i, hexPart.String(), text.String())
 	}
-	if n, ok := m[t]; ok {
-		return n
+	if total > len(d) {
+		fmt.Printf("%s... %d more bytes\n", ind, total-len(d))
 	}
-	return fmt.Sprintf("0x%04X", t)
 }
@@ -0,0 +1,266 @@
+// Package archive implements converters that unpack ZIP, TAR, TAR.GZ and
+// GZIP files and list 7-Zip archives. Members are extracted as attachments
+// with flattened, sanitised names, under entry-count and size limits that
+// protect against decompression bombs. With the convert-entries option,
+// members that another registered converter recognises (a winmail.dat in
+// a ZIP, say) are converted as well. It is automatically registered with
+// the formats registry on import.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/lgican/File-Converter/formats"
+)
+
+func init() {
+	formats.Register(&zipConverter{})
+	formats.Register(&tarConverter{})
+	formats.Register(&gzipConverter{})
+	formats.Register(&sevenZipConverter{})
+}
+
+// Detection scores: a signature is near-certain, an extension alone is a
+// hint, and a ZIP-based document (.docx, .epub) is left to the document
+// converters.
+const (
+	scoreSignature = 90
+	scoreExtension = 20
+	scoreDocument  = 15
+)
+
+// maxRatio is the largest expansion ratio accepted for a compressed
+// member once it exceeds ratioFloor bytes.
+const (
+	maxRatio   = 200
+	ratioFloor = 1 << 20
+)
+
+// errLimit stops unpacking once the archive limits are reached.
+var errLimit = errors.New("archive limit reached")
+
+// archiveOptions returns the options shared by the unpacking converters.
+func archiveOptions() []formats.Option {
+	return []formats.Option{
+		{Name: "convert-entries", Type: formats.OptionBool, Default: "false",
+			Description: "Also convert members recognised by another converter (e.g. winmail.dat)"},
+		{Name: "max-entries", Type: formats.OptionInt, Default: "10000", Min: 1, Max: 1000000,
+			Description: "Stop after this many members"},
+		{Name: "max-total-mb", Type: formats.OptionInt, Default: "512", Min: 1, Max: 8192,
+			Description: "Stop once the unpacked members total this many megabytes"},
+	}
+}
+
+// defaultOptions returns the archive options with their defaults, for
+// Convert.
+func defaultOptions() formats.Options {
+	opts, _ := formats.ParseOptions(archiveOptions(), nil)
+	return opts
+}
+
+// unpacker collects the members of one archive, enforcing the limits and
+// building the listing report.
+type unpacker struct {
+	ctx        context.Context
+	kind       string // "ZIP", "TAR", ...
+	input      string // the archive's file name
+	maxEntries int
+	maxTotal   int64
+	convert    bool
+
+	files   []formats.ConvertedFile
+	listing []string
+	skipped []string
+	names   map[string]int
+	total   int64
+	stopped string
+}
+
+func newUnpacker(ctx context.Context, kind string, in formats.Input, opts formats.Options) *unpacker {
+	return &unpacker{
+		ctx:        ctx,
+		kind:       kind,
+		input:      in.Name,
+		maxEntries: opts.Int("max-entries"),
+		maxTotal:   int64(opts.Int("max-total-mb")) << 20,
+		convert:    opts.Bool("convert-entries"),
+		names:      make(map[string]int),
+	}
+}
+
+// add reads one member. compressed is the member's stored size, or 0 if
+// unknown or uncompressed, and bounds the expansion ratio. It returns
+// errLimit once no more members may be added, and ctx.Err() if the
+// conversion was cancelled.
+func (u *unpacker) add(name string, modTime time.Time, compressed int64, r io.Reader) error {
+	if err := u.ctx.Err(); err != nil {
+		return err
+	}
+	if len(u.files) >= u.maxEntries {
+		u.stopped = fmt.Sprintf("entry limit of %d reached", u.maxEntries)
+		return errLimit
+	}
+	remaining := u.maxTotal - u.total
+	limit := remaining
+	if compressed > 0 {
+		limit = min(limit, max(compressed*maxRatio, ratioFloor))
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, limit+1))
+	if err != nil {
+		u.skip(name, err.Error())
+		return nil
+	}
+	if n > limit {
+		if limit == remaining {
+			u.stopped = fmt.Sprintf("%s: total size limit of %d MB reached", name, u.maxTotal>>20)
+			return errLimit
+		}
+		u.skip(name, fmt.Sprintf("expands more than %dx (possible decompression bomb)", maxRatio))
+		return nil
+	}
+	u.total += n
+
+	out := u.uniqueName(flatten(name))
+	f := formats.ConvertedFile{Name: out, Data: buf.Bytes(), Category: formats.CategoryAttachment, ModTime: modTime}
+	if out != name {
+		f.Source.Path = name
+	}
+	u.files = append(u.files, f)
+	line := fmt.Sprintf("%12d  %s", n, name)
+	if out != name {
+		line += "  -> " + out
+	}
+	u.listing = append(u.listing, line)
+	return nil
+}
+
+// skip records a member that was not extracted.
+func (u *unpacker) skip(name, reason string) {
+	u.skipped = append(u.skipped, fmt.Sprintf("  %s: %s", name, reason))
+}
+
+// uniqueName numbers repeated output names ("a.txt", "a (2).txt"), which
+// flattening can produce from different directories.
+func (u *unpacker) uniqueName(name string) string {
+	u.names[name]++
+	n := u.names[name]
+	if n == 1 {
+		return name
+	}
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+}
+
+// finish converts recognised members if requested and appends the
+// listing report.
+func (u *unpacker) finish() ([]formats.ConvertedFile, error) {
+	files := u.files
+	if u.convert {
+		var converted []formats.ConvertedFile
+		for _, f := range u.files {
+			out, err := u.convertEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			converted = append(converted, out...)
+		}
+		files = append(files, converted...)
+	}
+	return append(files, formats.ConvertedFile{
+		Name:     "archive_listing.txt",
+		Data:     u.report(),
+		Category: formats.CategoryReport,
+	}), nil
+}
+
+// convertEntry converts one member with the converter that recognises it
+// by content, if any other than an archive converter does.
+func (u *unpacker) convertEntry(f formats.ConvertedFile) ([]formats.ConvertedFile, error) {
+	cands := formats.Rank(f.Name, f.Data)
+	if len(cands) == 0 || cands[0].Score < formats.ScoreSignature || isArchive(cands[0].Converter) {
+		return nil, nil
+	}
+	conv := cands[0].Converter
+	in := formats.Input{Name: f.Name, Data: f.Data, Prefix: f.Name + "_"}
+	if err := formats.CurrentPolicy().Check(conv, in); err != nil {
+		u.skip(f.Name, "not converted: "+err.Error())
+		return nil, nil
+	}
+	out, err := formats.ConvertContext(u.ctx, conv, in, nil)
+	if err != nil {
+		if ctxErr := u.ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		u.skip(f.Name, "not converted: "+err.Error())
+		return nil, nil
+	}
+	for i := range out {
+		out[i].Name = in.Prefix + out[i].Name
+		if out[i].Source.Converter == "" {
+			out[i].Source.Converter = conv.Name()
+		}
+		out[i].Source.Parent = f.Name
+		if u.input != "" {
+			out[i].Source.Parent = u.input + " > " + f.Name
+		}
+	}
+	u.listing = append(u.listing, fmt.Sprintf("%12s  %s: converted as %s (%d files)", "", f.Name, conv.Name(), len(out)))
+	return out, nil
+}
+
+// report renders the listing of extracted and skipped members.
+func (u *unpacker) report() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s archive: %d members extracted, %d bytes\n\n", u.kind, len(u.files), u.total)
+	for _, l := range u.listing {
+		b.WriteString(l + "\n")
+	}
+	if len(u.skipped) > 0 {
+		b.WriteString("\nSkipped:\n")
+		for _, l := range u.skipped {
+			b.WriteString(l + "\n")
+		}
+	}
+	if u.stopped != "" {
+		b.WriteString("\nStopped early: " + u.stopped + "\n")
+	}
+	return b.Bytes()
+}
+
+// flatten turns a member path into a single file name. Separators become
+// underscores through SanitizeFilename, so "../" components cannot escape
+// the output directory, and leading "./" and "/" are dropped first.
+func flatten(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.TrimLeft(path.Clean("/"+name), "/")
+	return formats.SanitizeFilename(name)
+}
+
+// isArchive reports whether c is one of this package's converters.
+func isArchive(c formats.Converter) bool {
+	switch c.(type) {
+	case *zipConverter, *tarConverter, *gzipConverter, *sevenZipConverter:
+		return true
+	}
+	return false
+}
+
+// matchExtension reports whether filename ends with one of exts, which
+// may have more than one dot (".tar.gz").
+func matchExtension(filename string, exts []string) string {
+	lower := strings.ToLower(filename)
+	for _, e := range exts {
+		if strings.HasSuffix(lower, e) {
+			return e
+		}
+	}
+	return ""
+}
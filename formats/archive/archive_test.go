@@ -0,0 +1,299 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/lgican/File-Converter/formats"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// fakeMessage stands in for a converter that recognises archive members.
+type fakeMessage struct{}
+
+func (fakeMessage) Name() string           { return "Fake Message" }
+func (fakeMessage) Extensions() []string   { return []string{".fake"} }
+func (fakeMessage) Match(data []byte) bool { return bytes.HasPrefix(data, []byte("FAKE")) }
+func (fakeMessage) Convert(data []byte) ([]formats.ConvertedFile, error) {
+	return []formats.ConvertedFile{{Name: "body.txt", Data: data[4:], Category: formats.CategoryBody}}, nil
+}
+
+func init() {
+	formats.Register(fakeMessage{})
+}
+
+func names(files []formats.ConvertedFile) string {
+	var out []string
+	for _, f := range files {
+		out = append(out, f.Name)
+	}
+	return strings.Join(out, ",")
+}
+
+func convert(t *testing.T, c formats.Converter, name string, data []byte, raw map[string]string) []formats.ConvertedFile {
+	t.Helper()
+	files, err := formats.ConvertContext(context.Background(), c, formats.Input{Name: name, Data: data}, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(h *zip.FileHeader, body string) {
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	mtime := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	add(&zip.FileHeader{Name: "docs/readme.txt", Method: zip.Deflate, Modified: mtime}, "hello")
+	add(&zip.FileHeader{Name: "../../etc/passwd"}, "root")
+	add(&zip.FileHeader{Name: "caf\x82.txt", NonUTF8: true}, "cp437") // "café" in CP437
+	extra := []byte{0x75, 0x70, 0, 0, 1, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(extra[5:], crc32.ChecksumIEEE([]byte("legacy.txt")))
+	extra = append(extra, "日本.txt"...)
+	binary.LittleEndian.PutUint16(extra[2:], uint16(len(extra)-4))
+	add(&zip.FileHeader{Name: "legacy.txt", Extra: extra, NonUTF8: true}, "unicode path")
+	add(&zip.FileHeader{Name: "inbox/winmail.fake"}, "FAKEmessage body")
+	add(&zip.FileHeader{Name: "docs/"}, "")
+	zw.Close()
+
+	c := &zipConverter{}
+	if score, _ := c.Score("upload.bin", buf.Bytes()); score != scoreSignature {
+		t.Errorf("ZIP signature scored %d", score)
+	}
+	if score, _ := c.Score("report.docx", buf.Bytes()); score >= scoreExtension {
+		t.Errorf(".docx scored %d as an archive", score)
+	}
+
+	files := convert(t, c, "a.zip", buf.Bytes(), map[string]string{"convert-entries": "true"})
+	want := "docs_readme.txt,etc_passwd,café.txt,日本.txt,inbox_winmail.fake,inbox_winmail.fake_body.txt,archive_listing.txt"
+	if got := names(files); got != want {
+		t.Fatalf("files = %s\nwant    %s", got, want)
+	}
+	if string(files[5].Data) != "message body" {
+		t.Errorf("converted member = %q", files[5].Data)
+	}
+	if !files[0].ModTime.Equal(mtime) || files[0].Source.Path != "docs/readme.txt" || !files[1].ModTime.IsZero() {
+		t.Errorf("member metadata: %v %q, %v", files[0].ModTime, files[0].Source.Path, files[1].ModTime)
+	}
+	if src := files[5].Source; src.Converter != "Fake Message" || src.Parent != "a.zip > inbox_winmail.fake" {
+		t.Errorf("converted member source = %+v", src)
+	}
+	listing := string(files[len(files)-1].Data)
+	if !strings.Contains(listing, "5 members extracted") || !strings.Contains(listing, "converted as Fake Message") {
+		t.Errorf("listing:\n%s", listing)
+	}
+
+	// Without convert-entries members are only extracted.
+	if got := names(convert(t, c, "a.zip", buf.Bytes(), nil)); strings.Contains(got, "body.txt") {
+		t.Errorf("members converted by default: %s", got)
+	}
+}
+
+func TestZipLimits(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		w, _ := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		w.Write(make([]byte, 600<<10))
+	}
+	zw.Close()
+	c := &zipConverter{}
+
+	files := convert(t, c, "bomb.zip", buf.Bytes(), map[string]string{"max-total-mb": "1"})
+	listing := string(files[len(files)-1].Data)
+	if names(files) != "a.bin,archive_listing.txt" || !strings.Contains(listing, "total size limit of 1 MB reached") {
+		t.Errorf("size limit: %s\n%s", names(files), listing)
+	}
+
+	files = convert(t, c, "many.zip", buf.Bytes(), map[string]string{"max-entries": "2"})
+	if names(files) != "a.bin,b.bin,archive_listing.txt" {
+		t.Errorf("entry limit: %s", names(files))
+	}
+
+	// 4 MB of zeros compresses far beyond the ratio limit.
+	buf.Reset()
+	zw = zip.NewWriter(&buf)
+	w, _ := zw.CreateHeader(&zip.FileHeader{Name: "zeros", Method: zip.Deflate})
+	w.Write(make([]byte, 4<<20))
+	zw.Close()
+	files = convert(t, c, "ratio.zip", buf.Bytes(), nil)
+	if !strings.Contains(string(files[len(files)-1].Data), "zeros: expands more than") {
+		t.Errorf("ratio limit not applied: %s", names(files))
+	}
+}
+
+func TestTarAndGzip(t *testing.T) {
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755})
+	tw.WriteHeader(&tar.Header{Name: "dir/a.txt", Typeflag: tar.TypeReg, Size: 3, Mode: 0o644})
+	tw.Write([]byte("abc"))
+	tw.WriteHeader(&tar.Header{Name: "/abs/../../b.txt", Typeflag: tar.TypeReg, Size: 1, Mode: 0o644})
+	tw.Write([]byte("b"))
+	tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.Close()
+
+	files := convert(t, &tarConverter{}, "x.tar", tarBuf.Bytes(), nil)
+	if got := names(files); got != "dir_a.txt,b.txt,archive_listing.txt" {
+		t.Errorf("tar files = %s", got)
+	}
+	if !strings.Contains(string(files[2].Data), "link: link to /etc/passwd") {
+		t.Errorf("tar listing:\n%s", files[2].Data)
+	}
+
+	gz := func(name string, data []byte) []byte {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		zw.Name = name
+		zw.Write(data)
+		zw.Close()
+		return b.Bytes()
+	}
+	files = convert(t, &gzipConverter{}, "x.tar.gz", gz("", tarBuf.Bytes()), nil)
+	if got := names(files); got != "dir_a.txt,b.txt,archive_listing.txt" || !strings.HasPrefix(string(files[2].Data), "TAR.GZ archive") {
+		t.Errorf("tar.gz files = %s", got)
+	}
+	files = convert(t, &gzipConverter{}, "notes.txt.gz", gz("", []byte("plain")), nil)
+	if names(files) != "notes.txt,archive_listing.txt" || string(files[0].Data) != "plain" {
+		t.Errorf("gzip files = %s", names(files))
+	}
+	files = convert(t, &gzipConverter{}, "upload", gz("orig.csv", []byte("a,b")), nil)
+	if files[0].Name != "orig.csv" {
+		t.Errorf("gzip header name ignored: %s", files[0].Name)
+	}
+}
+
+func TestSevenZipListing(t *testing.T) {
+	utf16z := func(s string) []byte {
+		var b []byte
+		for _, u := range append(utf16.Encode([]rune(s)), 0) {
+			b = binary.LittleEndian.AppendUint16(b, u)
+		}
+		return b
+	}
+	var nameBlock []byte
+	for _, n := range []string{"a.txt", "b.txt", "dir"} {
+		nameBlock = append(nameBlock, utf16z(n)...)
+	}
+	hdr := []byte{
+		k7zHeader, k7zMainStreamsInfo,
+		k7zPackInfo, 0, 1, k7zSize, 5, k7zEnd,
+		k7zUnpackInfo, k7zFolder, 1, 0, 1, 0x01, 0x00, k7zCodersUnpackSize, 5, k7zEnd,
+		k7zSubStreamsInfo, k7zNumUnpackStream, 2, k7zSize, 2, k7zEnd,
+		k7zEnd,
+		k7zFilesInfo, 3,
+		k7zEmptyStream, 1, 0x20,
+		k7zName, byte(1 + len(nameBlock)), 0,
+	}
+	hdr = append(hdr, nameBlock...)
+	hdr = append(hdr, k7zEnd, k7zEnd)
+
+	data := append([]byte{}, sevenZipSignature...)
+	data = append(data, 0, 4, 0, 0, 0, 0)
+	data = binary.LittleEndian.AppendUint64(data, 5)
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(hdr)))
+	data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(hdr))
+	binary.LittleEndian.PutUint32(data[8:], crc32.ChecksumIEEE(data[12:32]))
+	data = append(data, "hello"...)
+	data = append(data, hdr...)
+
+	files := convert(t, &sevenZipConverter{}, "x.7z", data, nil)
+	listing := string(files[0].Data)
+	for _, want := range []string{"format version 0.4", "2  ", "a.txt", "3  ", "b.txt", "dir  (directory)", "3 members, 5 bytes"} {
+		if !strings.Contains(listing, want) {
+			t.Errorf("listing missing %q:\n%s", want, listing)
+		}
+	}
+
+	// 7-Zip's default: the header compressed with LZMA after the members,
+	// located by an encoded header.
+	var packed bytes.Buffer
+	w, err := lzma.WriterConfig{DictCap: lzma.MinDictCap, Size: int64(len(hdr))}.NewWriter(&packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(hdr)
+	w.Close()
+	props, stream := packed.Bytes()[:5], packed.Bytes()[13:]
+	encoded := func(coder ...byte) []byte {
+		enc := []byte{
+			k7zEncodedHeader,
+			k7zPackInfo, 5, 1, k7zSize, byte(len(stream)), k7zEnd,
+			k7zUnpackInfo, k7zFolder, 1, 0, 1, 0x20 | byte(len(coder)),
+		}
+		enc = append(enc, coder...)
+		enc = append(append(enc, 5), props...)
+		enc = append(enc, k7zCodersUnpackSize, byte(len(hdr)), k7zEnd, k7zEnd)
+		out := append(data[:32+5:32+5], stream...)
+		out = append(out, enc...)
+		binary.LittleEndian.PutUint64(out[12:], uint64(5+len(stream)))
+		binary.LittleEndian.PutUint64(out[20:], uint64(len(enc)))
+		binary.LittleEndian.PutUint32(out[28:], crc32.ChecksumIEEE(enc))
+		binary.LittleEndian.PutUint32(out[8:], crc32.ChecksumIEEE(out[12:32]))
+		return out
+	}
+	files = convert(t, &sevenZipConverter{}, "x.7z", encoded(0x03, 0x01, 0x01), nil)
+	if got := string(files[0].Data); got != listing {
+		t.Errorf("encoded header listing:\n%s\nwant\n%s", got, listing)
+	}
+
+	// An encrypted header is identified but not listed.
+	files = convert(t, &sevenZipConverter{}, "x.7z", encoded(0x06, 0xF1, 0x07, 0x01), nil)
+	if !strings.Contains(string(files[0].Data), "header is encrypted") {
+		t.Errorf("encrypted header listing:\n%s", files[0].Data)
+	}
+}
+
+// A small header whose stream counts multiply out to millions is refused
+// without allocating for them.
+func TestSevenZipCraftedCounts(t *testing.T) {
+	const folders = 5000
+	hdr := []byte{k7zHeader, k7zMainStreamsInfo, k7zUnpackInfo, k7zFolder, 0x93, 0x88, 0} // 5000 folders
+	for i := 0; i < folders; i++ {
+		hdr = append(hdr, 1, 0x01, 0x00) // one copy coder
+	}
+	hdr = append(hdr, k7zCodersUnpackSize)
+	for i := 0; i < folders; i++ {
+		hdr = append(hdr, 5)
+	}
+	hdr = append(hdr, k7zEnd, k7zSubStreamsInfo, k7zNumUnpackStream)
+	for i := 0; i < folders; i++ {
+		hdr = append(hdr, 0xBF, 0xFF) // 16383 streams each
+	}
+	hdr = append(hdr, k7zSize, k7zEnd, k7zEnd, k7zEnd)
+
+	data := append([]byte{}, sevenZipSignature...)
+	data = append(data, 0, 4, 0, 0, 0, 0)
+	data = binary.LittleEndian.AppendUint64(data, 0)
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(hdr)))
+	data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(hdr))
+	binary.LittleEndian.PutUint32(data[8:], crc32.ChecksumIEEE(data[12:32]))
+	data = append(data, hdr...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := list7z(data)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Error("crafted header listed")
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 16<<20 {
+		t.Errorf("crafted header allocated %d bytes", n)
+	}
+}
@@ -0,0 +1,36 @@
+// cp437.go decodes legacy ZIP entry names, which use the IBM PC code page
+// when the UTF-8 flag is not set.
+
+package archive
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// cp437High maps bytes 0x80-0xFF of code page 437 to Unicode.
+var cp437High = []rune("ÇüéâäàåçêëèïîìÄÅÉæÆôöòûùÿÖÜ¢£¥₧ƒ" +
+	"áíóúñÑªº¿⌐¬½¼¡«»░▒▓│┤╡╢╖╕╣║╗╝╜╛┐" +
+	"└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀" +
+	"αßΓπΣσµτΦΘΩδ∞φε∩≡±≥≤⌠⌡÷≈°∙·√ⁿ²■" + "\u00a0")
+
+// decodeCP437 converts a code page 437 string to UTF-8.
+func decodeCP437(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x80 {
+			b.WriteByte(c)
+		} else {
+			b.WriteRune(cp437High[c-0x80])
+		}
+	}
+	return b.String()
+}
+
+// looksUTF8 reports whether s is valid UTF-8 with at least one multi-byte
+// sequence. Many tools store UTF-8 names without setting the flag, and
+// CP437 text almost never forms valid multi-byte UTF-8 by accident.
+func looksUTF8(s string) bool {
+	return utf8.ValidString(s) && utf8.RuneCountInString(s) < len(s)
+}
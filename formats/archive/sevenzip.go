@@ -0,0 +1,677 @@
+// sevenzip.go lists the contents of 7-Zip archives, including those whose
+// header is itself compressed with LZMA or LZMA2 (the 7-Zip default).
+// Members are not extracted.
+
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+	"unicode/utf16"
+
+	"github.com/lgican/File-Converter/formats"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+var sevenZipSignature = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+
+// 7z header property IDs.
+const (
+	k7zEnd                   = 0x00
+	k7zHeader                = 0x01
+	k7zArchiveProperties     = 0x02
+	k7zAdditionalStreamsInfo = 0x03
+	k7zMainStreamsInfo       = 0x04
+	k7zFilesInfo             = 0x05
+	k7zPackInfo              = 0x06
+	k7zUnpackInfo            = 0x07
+	k7zSubStreamsInfo        = 0x08
+	k7zSize                  = 0x09
+	k7zCRC                   = 0x0A
+	k7zFolder                = 0x0B
+	k7zCodersUnpackSize      = 0x0C
+	k7zNumUnpackStream       = 0x0D
+	k7zEmptyStream           = 0x0E
+	k7zEmptyFile             = 0x0F
+	k7zName                  = 0x11
+	k7zMTime                 = 0x14
+	k7zWinAttributes         = 0x15
+	k7zEncodedHeader         = 0x17
+)
+
+// errBad7z reports a structurally invalid 7z header.
+var errBad7z = errors.New("invalid 7z header")
+
+// max7zHeader bounds the unpacked size of a compressed header, and
+// max7zItems the streams, coders and members it may describe.
+const (
+	max7zHeader = 64 << 20
+	max7zItems  = 1000000
+)
+
+// Coder IDs of compressed headers.
+var (
+	coder7zLZMA  = []byte{0x03, 0x01, 0x01}
+	coder7zLZMA2 = []byte{0x21}
+	coder7zAES   = []byte{0x06, 0xF1, 0x07, 0x01}
+)
+
+type sevenZipConverter struct{}
+
+func (c *sevenZipConverter) Name() string { return "7-Zip Archive (listing)" }
+
+func (c *sevenZipConverter) Extensions() []string { return []string{".7z"} }
+
+func (c *sevenZipConverter) Match(data []byte) bool {
+	return bytes.HasPrefix(data, sevenZipSignature)
+}
+
+func (c *sevenZipConverter) Score(filename string, data []byte) (int, []string) {
+	if c.Match(data) {
+		return scoreSignature, []string{"7z signature", "members are listed, not extracted"}
+	}
+	if formats.MatchExtension(c, filename) != "" {
+		return scoreExtension, []string{"file extension .7z, but no 7z signature"}
+	}
+	return 0, nil
+}
+
+// Convert returns archive_listing.txt describing the archive.
+func (c *sevenZipConverter) Convert(data []byte) ([]formats.ConvertedFile, error) {
+	return c.ConvertContext(context.Background(), formats.Input{Data: data}, nil)
+}
+
+func (c *sevenZipConverter) Options() []formats.Option { return nil }
+
+func (c *sevenZipConverter) ConvertContext(ctx context.Context, in formats.Input, _ formats.Options) ([]formats.ConvertedFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	listing, err := list7z(in.Data)
+	if err != nil {
+		return nil, err
+	}
+	return []formats.ConvertedFile{{Name: "archive_listing.txt", Data: listing, Category: formats.CategoryMetadata}}, nil
+}
+
+// sevenZipEntry is one member from the FilesInfo block.
+type sevenZipEntry struct {
+	name  string
+	size  uint64
+	dir   bool
+	mtime time.Time
+}
+
+// list7z renders the listing for a 7z archive.
+func list7z(data []byte) ([]byte, error) {
+	if len(data) < 32 || !bytes.HasPrefix(data, sevenZipSignature) {
+		return nil, errBad7z
+	}
+	major, minor := data[6], data[7]
+	if crc32.ChecksumIEEE(data[12:32]) != binary.LittleEndian.Uint32(data[8:]) {
+		return nil, fmt.Errorf("%w: start header checksum mismatch", errBad7z)
+	}
+	off := binary.LittleEndian.Uint64(data[12:])
+	size := binary.LittleEndian.Uint64(data[20:])
+	avail := uint64(len(data) - 32)
+	if off > avail || size > avail-off {
+		return nil, fmt.Errorf("%w: next header outside file", errBad7z)
+	}
+	hdr := data[32+off : 32+off+size]
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "7-Zip archive, format version %d.%d\n\n", major, minor)
+	if len(hdr) == 0 {
+		b.WriteString("Empty archive.\n")
+		return b.Bytes(), nil
+	}
+	// 7-Zip may compress the header more than once
+	for i := 0; i < 4 && len(hdr) > 0 && hdr[0] == k7zEncodedHeader; i++ {
+		var err error
+		if hdr, err = decode7zHeader(data, hdr); errors.Is(err, errEncrypted7z) {
+			b.WriteString("The archive header is encrypted, so members cannot be listed.\n")
+			return b.Bytes(), nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	entries, err := parse7zHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	var total uint64
+	for _, e := range entries {
+		kind := ""
+		if e.dir {
+			kind = "  (directory)"
+		}
+		stamp := "                   "
+		if !e.mtime.IsZero() {
+			stamp = e.mtime.UTC().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(&b, "%12d  %s  %s%s\n", e.size, stamp, e.name, kind)
+		total += e.size
+	}
+	fmt.Fprintf(&b, "\n%d members, %d bytes unpacked. Members are listed only; extract them with 7-Zip.\n", len(entries), total)
+	return b.Bytes(), nil
+}
+
+// errEncrypted7z reports a header encrypted with a password.
+var errEncrypted7z = errors.New("7z header is encrypted")
+
+// decode7zHeader unpacks an encoded header: a StreamsInfo block locating
+// the packed header in data and the single coder it was compressed with.
+func decode7zHeader(data, hdr []byte) ([]byte, error) {
+	r := &sevenZipReader{b: hdr, pos: 1}
+	var pos, size, unpacked uint64
+	var coder, props []byte
+	if r.byte() != k7zPackInfo {
+		return nil, errBad7z
+	}
+	pos = r.number()
+	if r.count() != 1 {
+		return nil, fmt.Errorf("%w: header in more than one stream", errBad7z)
+	}
+	for r.err == nil {
+		id := r.byte()
+		if id == k7zEnd {
+			break
+		}
+		switch id {
+		case k7zSize:
+			size = r.number()
+		case k7zCRC:
+			r.digests(1)
+		default:
+			r.skip(r.number())
+		}
+	}
+	if r.byte() != k7zUnpackInfo || r.byte() != k7zFolder || r.count() != 1 || r.byte() != 0 || r.count() != 1 {
+		return nil, fmt.Errorf("%w: header is not in a single-coder folder", errBad7z)
+	}
+	flags := r.byte()
+	coder = r.bytes(int(flags & 0x0F))
+	if flags&0x10 != 0 {
+		return nil, fmt.Errorf("%w: header coder has several streams", errBad7z)
+	}
+	if flags&0x20 != 0 {
+		props = r.bytes(r.count())
+	}
+	if r.byte() != k7zCodersUnpackSize {
+		return nil, errBad7z
+	}
+	unpacked = r.number()
+	if r.err != nil {
+		return nil, errBad7z
+	}
+	avail := uint64(len(data) - 32)
+	if pos > avail || size > avail-pos {
+		return nil, fmt.Errorf("%w: packed header outside file", errBad7z)
+	}
+	if unpacked > max7zHeader {
+		return nil, fmt.Errorf("%w: header of %d bytes is too large", errBad7z, unpacked)
+	}
+	packed := bytes.NewReader(data[32+pos : 32+pos+size])
+
+	// The dictionary never needs to be larger than the header itself
+	dict := max(int(unpacked), lzma.MinDictCap)
+	var dec io.Reader
+	var err error
+	switch {
+	case bytes.Equal(coder, coder7zLZMA) && len(props) == 5:
+		dict = max(min(dict, int(binary.LittleEndian.Uint32(props[1:]))), lzma.MinDictCap)
+		head := append([]byte{props[0]}, binary.LittleEndian.AppendUint32(nil, uint32(dict))...)
+		head = binary.LittleEndian.AppendUint64(head, unpacked)
+		dec, err = lzma.NewReader(io.MultiReader(bytes.NewReader(head), packed))
+	case bytes.Equal(coder, coder7zLZMA2):
+		dec, err = lzma.Reader2Config{DictCap: dict}.NewReader2(packed)
+	case bytes.Equal(coder, coder7zAES):
+		return nil, errEncrypted7z
+	default:
+		return nil, fmt.Errorf("%w: header coder %x is not supported", errBad7z, coder)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBad7z, err)
+	}
+	var out bytes.Buffer
+	if _, err := out.ReadFrom(io.LimitReader(dec, int64(unpacked))); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBad7z, err)
+	}
+	if uint64(out.Len()) != unpacked {
+		return nil, fmt.Errorf("%w: compressed header is truncated", errBad7z)
+	}
+	return out.Bytes(), nil
+}
+
+// sevenZipReader decodes the 7z header encoding.
+type sevenZipReader struct {
+	b     []byte
+	pos   int
+	err   error
+	items int // streams, coders and members counted so far (see claim)
+}
+
+func (r *sevenZipReader) byte() byte {
+	if r.pos >= len(r.b) {
+		r.err = errBad7z
+		return 0
+	}
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+// number reads a 7z variable-length integer: the count of leading one
+// bits in the first byte gives the number of extra little-endian bytes.
+func (r *sevenZipReader) number() uint64 {
+	first := r.byte()
+	var v uint64
+	mask := byte(0x80)
+	for i := 0; i < 8; i++ {
+		if first&mask == 0 {
+			return v | uint64(first&(mask-1))<<(8*i)
+		}
+		v |= uint64(r.byte()) << (8 * i)
+		mask >>= 1
+	}
+	return v
+}
+
+// count reads a number used as an element count, bounding it by the
+// remaining header bytes so corrupt input cannot force huge allocations.
+func (r *sevenZipReader) count() int {
+	n := r.number()
+	if n > uint64(len(r.b)) {
+		r.err = errBad7z
+		return 0
+	}
+	return int(n)
+}
+
+// claim counts n more streams, coders or members. Each takes at least a
+// byte of header, so their total is bounded by its length (and by
+// max7zItems); a larger total marks the header invalid, so that crafted
+// counts cannot force huge allocations.
+func (r *sevenZipReader) claim(n int) int {
+	if r.items += n; r.err != nil || r.items > min(len(r.b), max7zItems) {
+		r.err = errBad7z
+		return 0
+	}
+	return n
+}
+
+// bytes returns the next n bytes.
+func (r *sevenZipReader) bytes(n int) []byte {
+	start := r.pos
+	r.skip(uint64(n))
+	if r.err != nil {
+		return nil
+	}
+	return r.b[start:r.pos]
+}
+
+func (r *sevenZipReader) skip(n uint64) {
+	if n > uint64(len(r.b)-r.pos) {
+		r.err = errBad7z
+		r.pos = len(r.b)
+		return
+	}
+	r.pos += int(n)
+}
+
+func (r *sevenZipReader) bits(n int) []bool {
+	out := make([]bool, n)
+	var c byte
+	for i := range out {
+		if i%8 == 0 {
+			c = r.byte()
+		}
+		out[i] = c&(0x80>>(i%8)) != 0
+	}
+	return out
+}
+
+// optionalBits reads an "all defined" byte followed, if zero, by a bit
+// vector.
+func (r *sevenZipReader) optionalBits(n int) []bool {
+	if r.byte() != 0 {
+		out := make([]bool, n)
+		for i := range out {
+			out[i] = true
+		}
+		return out
+	}
+	return r.bits(n)
+}
+
+// parse7zHeader walks a plain (uncompressed) header and returns the
+// members with their sizes.
+func parse7zHeader(hdr []byte) ([]sevenZipEntry, error) {
+	r := &sevenZipReader{b: hdr}
+	if r.byte() != k7zHeader {
+		return nil, errBad7z
+	}
+	var sizes []uint64
+	id := r.byte()
+	if id == k7zArchiveProperties {
+		for r.err == nil {
+			if r.byte() == k7zEnd {
+				break
+			}
+			r.skip(r.number())
+		}
+		id = r.byte()
+	}
+	if id == k7zAdditionalStreamsInfo {
+		r.streamsInfo()
+		id = r.byte()
+	}
+	if id == k7zMainStreamsInfo {
+		sizes = r.streamsInfo()
+		id = r.byte()
+	}
+	var entries []sevenZipEntry
+	if id == k7zFilesInfo {
+		entries = r.filesInfo(sizes)
+		id = r.byte()
+	}
+	if r.err != nil || id != k7zEnd {
+		return nil, errBad7z
+	}
+	return entries, nil
+}
+
+// streamsInfo parses PackInfo, UnpackInfo and SubStreamsInfo and returns
+// the unpacked size of every stream in order.
+func (r *sevenZipReader) streamsInfo() []uint64 {
+	var folderSizes []uint64
+	var perFolder []int
+	var sizes []uint64
+	for r.err == nil {
+		switch r.byte() {
+		case k7zEnd:
+			if sizes == nil {
+				sizes = folderSizes
+			}
+			return sizes
+		case k7zPackInfo:
+			r.number() // pack position
+			n := r.claim(r.count())
+			for r.err == nil {
+				id := r.byte()
+				if id == k7zEnd {
+					break
+				}
+				switch id {
+				case k7zSize:
+					for i := 0; i < n; i++ {
+						r.number()
+					}
+				case k7zCRC:
+					r.digests(n)
+				default:
+					r.skip(r.number())
+				}
+			}
+		case k7zUnpackInfo:
+			folderSizes = r.unpackInfo()
+			perFolder = make([]int, len(folderSizes))
+			for i := range perFolder {
+				perFolder[i] = 1
+			}
+		case k7zSubStreamsInfo:
+			sizes = r.subStreamsInfo(folderSizes, perFolder)
+		default:
+			r.err = errBad7z
+		}
+	}
+	return nil
+}
+
+// unpackInfo parses the folders and returns each folder's final unpacked
+// size.
+func (r *sevenZipReader) unpackInfo() []uint64 {
+	if r.byte() != k7zFolder {
+		r.err = errBad7z
+		return nil
+	}
+	n := r.claim(r.count())
+	if r.byte() != 0 {
+		r.err = errBad7z // external folders are not used by 7-Zip
+		return nil
+	}
+	outStreams := make([]int, n)
+	finalOut := make([]int, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		outStreams[i], finalOut[i] = r.folder()
+	}
+	if r.byte() != k7zCodersUnpackSize {
+		r.err = errBad7z
+		return nil
+	}
+	sizes := make([]uint64, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		for j := 0; j < outStreams[i] && r.err == nil; j++ {
+			if s := r.number(); j == finalOut[i] {
+				sizes[i] = s
+			}
+		}
+	}
+	for r.err == nil {
+		id := r.byte()
+		if id == k7zEnd {
+			break
+		}
+		if id == k7zCRC {
+			r.digests(n)
+		} else {
+			r.skip(r.number())
+		}
+	}
+	return sizes
+}
+
+// folder parses one coder chain and returns its number of output streams
+// and the index of the one not consumed by a bind pair (the folder's
+// output).
+func (r *sevenZipReader) folder() (outs, final int) {
+	coders := r.claim(r.count())
+	var ins int
+	for i := 0; i < coders && r.err == nil; i++ {
+		flags := r.byte()
+		r.skip(uint64(flags & 0x0F)) // codec ID
+		in, out := 1, 1
+		if flags&0x10 != 0 {
+			in, out = r.claim(r.count()), r.claim(r.count())
+		}
+		if flags&0x20 != 0 {
+			r.skip(r.number()) // coder properties
+		}
+		ins += in
+		outs += out
+	}
+	bound := make(map[int]bool)
+	for i := 0; i < outs-1 && r.err == nil; i++ {
+		r.number() // in index
+		bound[int(r.number())] = true
+	}
+	if packed := ins - (outs - 1); packed > 1 {
+		for i := 0; i < packed && r.err == nil; i++ {
+			r.number()
+		}
+	}
+	for final = 0; final < outs && bound[final]; final++ {
+	}
+	return outs, final
+}
+
+// subStreamsInfo splits each folder into its files' streams.
+func (r *sevenZipReader) subStreamsInfo(folderSizes []uint64, perFolder []int) []uint64 {
+	id := r.byte()
+	if id == k7zNumUnpackStream {
+		for i := range perFolder {
+			perFolder[i] = r.claim(r.count())
+		}
+		id = r.byte()
+	}
+	var sizes []uint64
+	hasSizes := id == k7zSize
+	for i, n := range perFolder {
+		if r.err != nil {
+			return nil
+		}
+		if n == 0 {
+			continue
+		}
+		var sum uint64
+		for j := 0; j < n-1 && hasSizes && r.err == nil; j++ {
+			s := r.number()
+			sizes = append(sizes, s)
+			sum += s
+		}
+		if i < len(folderSizes) && folderSizes[i] >= sum {
+			sizes = append(sizes, folderSizes[i]-sum)
+		} else {
+			r.err = errBad7z
+		}
+	}
+	if hasSizes {
+		id = r.byte()
+	}
+	for r.err == nil && id != k7zEnd {
+		if id == k7zCRC {
+			r.digests(len(sizes)) // at most one per stream
+		} else {
+			r.skip(r.number())
+		}
+		id = r.byte()
+	}
+	return sizes
+}
+
+// digests skips a CRC table of n entries.
+func (r *sevenZipReader) digests(n int) {
+	for _, defined := range r.optionalBits(n) {
+		if defined {
+			r.skip(4)
+		}
+	}
+}
+
+// filesInfo parses the member names, empty-stream flags, attributes and
+// modification times. Non-empty members take their sizes, in order, from
+// the streams.
+func (r *sevenZipReader) filesInfo(sizes []uint64) []sevenZipEntry {
+	n := r.claim(r.count())
+	entries := make([]sevenZipEntry, n)
+	var emptyStream, emptyFile []bool
+	for r.err == nil {
+		id := r.byte()
+		if id == k7zEnd {
+			break
+		}
+		size := r.number()
+		end := r.pos + int(min(size, uint64(len(r.b)-r.pos)))
+		switch id {
+		case k7zEmptyStream:
+			emptyStream = r.bits(n)
+		case k7zEmptyFile:
+			emptyFile = r.bits(countTrue(emptyStream))
+		case k7zName:
+			if r.byte() != 0 {
+				r.err = errBad7z
+				break
+			}
+			names := decodeUTF16Names(r.b[r.pos:end])
+			for i := 0; i < n && i < len(names); i++ {
+				entries[i].name = names[i]
+			}
+		case k7zMTime:
+			defined := r.optionalBits(n)
+			if r.byte() != 0 {
+				r.err = errBad7z
+				break
+			}
+			for i, ok := range defined {
+				if ok && r.pos+8 <= end {
+					entries[i].mtime = filetime(binary.LittleEndian.Uint64(r.b[r.pos:]))
+					r.pos += 8
+				}
+			}
+		case k7zWinAttributes:
+			defined := r.optionalBits(n)
+			if r.byte() != 0 {
+				r.err = errBad7z
+				break
+			}
+			for i, ok := range defined {
+				if ok && r.pos+4 <= end {
+					if binary.LittleEndian.Uint32(r.b[r.pos:])&0x10 != 0 {
+						entries[i].dir = true
+					}
+					r.pos += 4
+				}
+			}
+		}
+		if r.err == nil {
+			r.pos = end
+		}
+	}
+
+	stream, empty := 0, 0
+	for i := range entries {
+		if i < len(emptyStream) && emptyStream[i] {
+			if empty >= len(emptyFile) || !emptyFile[empty] {
+				entries[i].dir = true
+			}
+			empty++
+			continue
+		}
+		if stream < len(sizes) {
+			entries[i].size = sizes[stream]
+		}
+		stream++
+	}
+	return entries
+}
+
+// decodeUTF16Names splits a block of NUL-terminated UTF-16LE names.
+func decodeUTF16Names(b []byte) []string {
+	var names []string
+	var cur []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		u := binary.LittleEndian.Uint16(b[i:])
+		if u == 0 {
+			names = append(names, string(utf16.Decode(cur)))
+			cur = cur[:0]
+			continue
+		}
+		cur = append(cur, u)
+	}
+	return names
+}
+
+func countTrue(bs []bool) int {
+	n := 0
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// filetime converts a Windows FILETIME (100ns ticks since 1601) to a time.
+func filetime(ft uint64) time.Time {
+	const epochDiff = 116444736000000000 // 1601-01-01 to 1970-01-01 in ticks
+	if ft < epochDiff {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ft-epochDiff)*100)
+}
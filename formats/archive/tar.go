@@ -0,0 +1,169 @@
+// tar.go implements the TAR and GZIP converters; a gzip stream holding a
+// TAR archive is unpacked as TAR.GZ.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/lgican/File-Converter/formats"
+)
+
+type tarConverter struct{}
+
+func (c *tarConverter) Name() string { return "TAR Archive" }
+
+func (c *tarConverter) Extensions() []string { return []string{".tar"} }
+
+func (c *tarConverter) Match(data []byte) bool { return isTar(data) }
+
+func (c *tarConverter) Unpacks() bool { return true }
+
+func (c *tarConverter) Score(filename string, data []byte) (int, []string) {
+	if isTar(data) {
+		return scoreSignature, []string{`"ustar" magic at offset 257`}
+	}
+	if formats.MatchExtension(c, filename) != "" {
+		// Pre-POSIX archives have no magic.
+		return scoreExtension, []string{"file extension .tar"}
+	}
+	return 0, nil
+}
+
+func (c *tarConverter) Options() []formats.Option { return archiveOptions() }
+
+func (c *tarConverter) Convert(data []byte) ([]formats.ConvertedFile, error) {
+	return c.ConvertContext(context.Background(), formats.Input{Data: data}, defaultOptions())
+}
+
+func (c *tarConverter) ConvertContext(ctx context.Context, in formats.Input, opts formats.Options) ([]formats.ConvertedFile, error) {
+	u := newUnpacker(ctx, "TAR", in, opts)
+	if err := unpackTar(u, bytes.NewReader(in.Data)); err != nil {
+		return nil, err
+	}
+	return u.finish()
+}
+
+// unpackTar extracts the regular files of a TAR stream. Links, devices
+// and other special entries are listed as skipped.
+func unpackTar(u *unpacker, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if len(u.files) == 0 && len(u.skipped) == 0 {
+				return err
+			}
+			u.stopped = "corrupt archive: " + err.Error()
+			return nil
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeSymlink, tar.TypeLink:
+			u.skip(hdr.Name, "link to "+hdr.Linkname)
+			continue
+		default:
+			u.skip(hdr.Name, fmt.Sprintf("special file (type %q)", hdr.Typeflag))
+			continue
+		}
+		err = u.add(hdr.Name, hdr.ModTime, 0, tr)
+		if errors.Is(err, errLimit) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// isTar reports whether data starts with a POSIX or GNU tar header.
+func isTar(data []byte) bool {
+	return len(data) >= 262 && bytes.Equal(data[257:262], []byte("ustar"))
+}
+
+type gzipConverter struct{}
+
+func (c *gzipConverter) Name() string { return "GZIP / TAR.GZ" }
+
+func (c *gzipConverter) Extensions() []string { return []string{".gz", ".tgz"} }
+
+func (c *gzipConverter) Match(data []byte) bool {
+	return len(data) >= 3 && data[0] == 0x1f && data[1] == 0x8b && data[2] == 8
+}
+
+func (c *gzipConverter) Unpacks() bool { return true }
+
+func (c *gzipConverter) Score(filename string, data []byte) (int, []string) {
+	if c.Match(data) {
+		return scoreSignature, []string{"gzip signature 1F 8B, deflate"}
+	}
+	if formats.MatchExtension(c, filename) != "" {
+		return scoreExtension, []string{"file extension " + path.Ext(strings.ToLower(filename)) + ", but no gzip signature"}
+	}
+	return 0, nil
+}
+
+func (c *gzipConverter) Options() []formats.Option { return archiveOptions() }
+
+func (c *gzipConverter) Convert(data []byte) ([]formats.ConvertedFile, error) {
+	return c.ConvertContext(context.Background(), formats.Input{Data: data}, defaultOptions())
+}
+
+// ConvertContext decompresses the stream. A TAR inside is unpacked;
+// anything else becomes a single member named after the gzip header or
+// the input file without its extension.
+func (c *gzipConverter) ConvertContext(ctx context.Context, in formats.Input, opts formats.Options) ([]formats.ConvertedFile, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(in.Data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	// Peek far enough to see a tar header's magic.
+	br := newPeekReader(zr, 512)
+	if isTar(br.peeked) {
+		u := newUnpacker(ctx, "TAR.GZ", in, opts)
+		if err := unpackTar(u, br); err != nil {
+			return nil, err
+		}
+		return u.finish()
+	}
+
+	u := newUnpacker(ctx, "GZIP", in, opts)
+	name := zr.Name
+	if name == "" {
+		name = strings.TrimSuffix(in.Name, path.Ext(in.Name))
+		if name == "" || name == in.Name {
+			name = "decompressed"
+		}
+	}
+	if err := u.add(name, zr.ModTime, int64(len(in.Data)), br); err != nil && !errors.Is(err, errLimit) {
+		return nil, err
+	}
+	return u.finish()
+}
+
+// peekReader replays its first bytes before the rest of the stream.
+type peekReader struct {
+	peeked []byte
+	io.Reader
+}
+
+func newPeekReader(r io.Reader, n int) *peekReader {
+	buf := make([]byte, n)
+	n, _ = io.ReadFull(r, buf)
+	return &peekReader{peeked: buf[:n], Reader: io.MultiReader(bytes.NewReader(buf[:n]), r)}
+}
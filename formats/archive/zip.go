@@ -0,0 +1,137 @@
+// zip.go implements the ZIP converter, including ZIP64 archives and
+// legacy code page 437 or Info-ZIP Unicode Path member names.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"time"
+
+	"github.com/lgican/File-Converter/formats"
+)
+
+// zipDocumentExts are ZIP-based formats that are documents rather than
+// archives; the ZIP converter only claims them as a last resort.
+var zipDocumentExts = []string{
+	".docx", ".xlsx", ".pptx", ".odt", ".ods", ".odp", ".epub", ".jar", ".apk",
+}
+
+// unicodePathExtraID is the Info-ZIP Unicode Path extra field.
+const unicodePathExtraID = 0x7075
+
+type zipConverter struct{}
+
+func (c *zipConverter) Name() string { return "ZIP Archive" }
+
+func (c *zipConverter) Extensions() []string { return []string{".zip"} }
+
+func (c *zipConverter) Match(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06"))
+}
+
+func (c *zipConverter) Unpacks() bool { return true }
+
+func (c *zipConverter) Score(filename string, data []byte) (int, []string) {
+	doc := matchExtension(filename, zipDocumentExts)
+	switch {
+	case c.Match(data) && doc != "":
+		return scoreDocument, []string{"ZIP signature, but " + doc + " is a document format"}
+	case c.Match(data):
+		return scoreSignature, []string{"ZIP local file header signature"}
+	case formats.MatchExtension(c, filename) != "":
+		return scoreExtension, []string{"file extension .zip, but no ZIP signature"}
+	}
+	return 0, nil
+}
+
+func (c *zipConverter) Options() []formats.Option { return archiveOptions() }
+
+func (c *zipConverter) Convert(data []byte) ([]formats.ConvertedFile, error) {
+	return c.ConvertContext(context.Background(), formats.Input{Data: data}, defaultOptions())
+}
+
+// ConvertContext extracts every regular member. Encrypted members and
+// members with unsupported compression are listed as skipped.
+func (c *zipConverter) ConvertContext(ctx context.Context, in formats.Input, opts formats.Options) ([]formats.ConvertedFile, error) {
+	zr, err := zip.NewReader(bytes.NewReader(in.Data), int64(len(in.Data)))
+	if err != nil {
+		return nil, err
+	}
+	u := newUnpacker(ctx, "ZIP", in, opts)
+	for _, f := range zr.File {
+		name := zipName(f)
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.Flags&0x1 != 0 {
+			u.skip(name, "encrypted")
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			u.skip(name, err.Error())
+			continue
+		}
+		err = u.add(name, zipModTime(f), int64(f.CompressedSize64), rc)
+		rc.Close()
+		if errors.Is(err, errLimit) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return u.finish()
+}
+
+// zipModTime returns a member's modification time, or the zero time for
+// the empty MS-DOS date that writers store when none is known.
+func zipModTime(f *zip.File) time.Time {
+	if f.Modified.Year() < 1980 {
+		return time.Time{}
+	}
+	return f.Modified
+}
+
+// zipName returns a member's name as UTF-8. The Unicode Path extra field
+// wins when it matches the stored name; otherwise names without the UTF-8
+// flag are decoded as code page 437 unless they are valid multi-byte
+// UTF-8.
+func zipName(f *zip.File) string {
+	if name, ok := unicodePath(f); ok {
+		return name
+	}
+	if f.NonUTF8 && !looksUTF8(f.Name) {
+		return decodeCP437(f.Name)
+	}
+	return f.Name
+}
+
+// unicodePath returns the name from the Info-ZIP Unicode Path extra field
+// if present and its CRC matches the stored name.
+func unicodePath(f *zip.File) (string, bool) {
+	extra := f.Extra
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			break
+		}
+		field := extra[4 : 4+size]
+		extra = extra[4+size:]
+		if id != unicodePathExtraID || len(field) < 5 || field[0] != 1 {
+			continue
+		}
+		if binary.LittleEndian.Uint32(field[1:]) != crc32.ChecksumIEEE([]byte(f.Name)) {
+			return "", false // the name was changed after the field was written
+		}
+		return strings.ToValidUTF8(string(field[5:]), "�"), true
+	}
+	return "", false
+}
@@ -3,6 +3,12 @@
 package bank
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/lgican/File-Converter/formats"
@@ -23,59 +29,208 @@ func (c *converter) Extensions() []string {
 	return []string{".csv"}
 }
 
+// Match reports whether data sniffs as delimited data with a consistent
+// column count.
 func (c *converter) Match(data []byte) bool {
-	// Check if it looks like a CSV file (has comma-separated values)
-	s := string(data)
-	if len(s) < 10 {
-		return false
+	score, _ := c.Score("", data)
+	return score >= csvScore
+}
+
+// Score rates how much data looks like a CSV of bank records: a
+// consistent column count, a header naming template fields, and the
+// extension each add confidence.
+func (c *converter) Score(filename string, data []byte) (int, []string) {
+	var score int
+	var reasons []string
+	rows, cols, ok := sniffCSV(data)
+	if ok {
+		score += csvScore
+		reasons = append(reasons, fmt.Sprintf("%d sampled rows with a consistent %d columns", rows, cols))
+		if key, matched, total := bestHeaderMatch(data); matched > 0 {
+			score += 35 * matched / total
+			reasons = append(reasons, fmt.Sprintf("header matches %d of %d %s fields", matched, total, key))
+		}
+	}
+	if ext := formats.MatchExtension(c, filename); ext != "" {
+		score += 10
+		reasons = append(reasons, "file extension "+ext)
+	}
+	if !ok && score > 0 {
+		reasons = append(reasons, "content is not consistent CSV")
 	}
+	return score, reasons
+}
+
+// csvScore is the confidence for data that parses as consistent CSV.
+const csvScore = 50
 
-	// Look for CSV patterns in the first few lines
-	lines := strings.Split(s, "\n")
-	if len(lines) < 2 {
-		return false
+// sniffSample bounds how much of the input detection reads.
+const (
+	sniffBytes = 16 << 10
+	sniffRows  = 20
+)
+
+// sniffCSV parses the start of data as CSV and reports the number of rows
+// sampled and their column count. ok is false for binary data, fewer than
+// two rows, fewer than two columns, or rows whose column counts disagree.
+func sniffCSV(data []byte) (rows, cols int, ok bool) {
+	sample := data[:min(len(data), sniffBytes)]
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return 0, 0, false
+	}
+	if len(sample) < len(data) {
+		// Drop the partial last line.
+		if i := bytes.LastIndexByte(sample, '\n'); i > 0 {
+			sample = sample[:i]
+		}
+	}
+	r := csv.NewReader(bytes.NewReader(sample))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	for rows < sniffRows {
+		rec, err := r.Read()
+		if err != nil {
+			break
+		}
+		if rows == 0 {
+			cols = len(rec)
+		} else if len(rec) != cols {
+			return rows, cols, false
+		}
+		rows++
 	}
+	return rows, cols, rows >= 2 && cols >= 2
+}
 
-	// Check first line has commas and second line also has commas
-	firstLine := lines[0]
-	if strings.Count(firstLine, ",") < 1 {
-		return false
+// bestHeaderMatch compares the first row with each template's field names
+// and returns the template with the most matches.
+func bestHeaderMatch(data []byte) (key string, matched, total int) {
+	r := csv.NewReader(bytes.NewReader(data[:min(len(data), sniffBytes)]))
+	r.LazyQuotes = true
+	header, err := r.Read()
+	if err != nil {
+		return "", 0, 0
+	}
+	cells := make(map[string]bool, len(header))
+	for _, h := range header {
+		cells[normalizeHeader(h)] = true
+	}
+	for _, k := range templateKeys() {
+		tpl := parser.GetTemplate(k)
+		n := 0
+		for _, f := range tpl.Fields {
+			if cells[normalizeHeader(f.Name)] {
+				n++
+			}
+		}
+		if n > matched {
+			key, matched, total = k, n, len(tpl.Fields)
+		}
 	}
+	return key, matched, total
+}
 
-	// Basic CSV detection - at least 2 fields separated by commas
-	return strings.Contains(firstLine, ",")
+// normalizeHeader folds case, spaces and underscores so "Account Number"
+// matches "Account_Number".
+func normalizeHeader(s string) string {
+	s = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(s, "\ufeff")))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(s)
 }
 
-func (c *converter) Convert(data []byte) ([]formats.ConvertedFile, error) {
-	// For CSV files, we'll return the original CSV and optionally formatted versions
-	// Using the default template (ACH_Payment) as an example
-	// In a real implementation, template selection would come from the web UI
+// Options selects the template and the output encoding. The template
+// choices come from the bank parser, so new templates need no change here.
+func (c *converter) Options() []formats.Option {
+	return []formats.Option{
+		{Name: "template", Type: formats.OptionEnum, Choices: templateKeys(),
+			Description: "Format with this template only (default: every template that fits)"},
+		{Name: "output-format", Type: formats.OptionEnum, Choices: []string{"txt", "csv", "xlsx"}, Default: "txt",
+			Description: "Fixed-width text, or the formatted records as CSV or Excel"},
+		{Name: "mapping", Type: formats.OptionString,
+			Description: "Map the input's columns with this saved mapping profile (selects its template)"},
+	}
+}
 
-	var files []formats.ConvertedFile
+func (c *converter) Convert(data []byte) ([]formats.ConvertedFile, error) {
+	opts, _ := formats.ParseOptions(c.Options(), nil)
+	return c.ConvertContext(context.Background(), formats.Input{Data: data}, opts)
+}
 
-	// Always include the original CSV
-	files = append(files, formats.ConvertedFile{
+// ConvertContext returns the original CSV followed by the data formatted
+// with the selected template, or with every template it decodes under.
+func (c *converter) ConvertContext(ctx context.Context, in formats.Input, opts formats.Options) ([]formats.ConvertedFile, error) {
+	files := []formats.ConvertedFile{{
 		Name:     "original.csv",
-		Data:     data,
-		Category: "body",
-	})
+		Data:     in.Data,
+		Category: formats.CategoryBody,
+	}}
 
-	// Try to format with available templates
-	templates := []string{"BeanStream_Detail", "ACH_Payment", "Wire_Transfer", "Direct_Deposit"}
+	templates := templateKeys()
+	if key := opts.String("template"); key != "" {
+		templates = []string{key}
+	}
+	format := opts.String("output-format")
+
+	var mapping *parser.Mapping
+	if name := opts.String("mapping"); name != "" {
+		var err error
+		if mapping, err = parser.GetMapping(name); err != nil {
+			return nil, err
+		}
+		if key := opts.String("template"); key != "" && key != mapping.Template {
+			return nil, fmt.Errorf("mapping %s is for template %s, not %s", name, mapping.Template, key)
+		}
+		templates = []string{mapping.Template}
+	}
 
 	for _, templateKey := range templates {
-		bankFile, err := parser.Decode(data, templateKey)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		bankFile, err := parser.DecodeAuto(in.Data, templateKey)
 		if err != nil {
+			if len(templates) == 1 {
+				return nil, fmt.Errorf("template %s: %w", templateKey, err)
+			}
 			continue // Skip templates that don't work with this data
 		}
+		if mapping != nil {
+			if err := bankFile.ApplyMapping(mapping); err != nil {
+				return nil, fmt.Errorf("template %s: %w", templateKey, err)
+			}
+		}
 
-		formatted := bankFile.Format()
+		var formatted []byte
+		switch format {
+		case "csv":
+			formatted, err = bankFile.FormatAsCSV()
+		case "xlsx":
+			formatted, err = bankFile.FormatAsExcel()
+		default:
+			formatted, err = bankFile.FormatAsFixedWidth()
+		}
+		if err != nil {
+			var verr *parser.ValidationError
+			if errors.As(err, &verr) && len(templates) > 1 {
+				continue // The data breaks this template's rules
+			}
+			return nil, fmt.Errorf("template %s: %w", templateKey, err)
+		}
 		files = append(files, formats.ConvertedFile{
-			Name:     templateKey + ".txt",
+			Name:     templateKey + "." + format,
 			Data:     formatted,
-			Category: "attachment",
+			Category: formats.CategoryAttachment,
 		})
 	}
 
 	return files, nil
 }
+
+// templateKeys returns the parser's template keys in sorted order.
+func templateKeys() []string {
+	var keys []string
+	for key := range parser.GetTemplateList() {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
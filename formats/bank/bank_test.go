@@ -0,0 +1,62 @@
+package bank
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	c := &converter{}
+	csv := "Record_Type,Routing_Number,Account_Number,Amount,Name\nA,123456789,111,100,Bob\nA,987654321,222,250,Ann\n"
+
+	score, reasons := c.Score("export.txt", []byte(csv))
+	if score < 70 {
+		t.Errorf("CSV with template header under odd name scored %d: %v", score, reasons)
+	}
+	if !strings.Contains(strings.Join(reasons, "\n"), "header matches 5 of 7 ACH_Payment fields") {
+		t.Errorf("reasons = %v", reasons)
+	}
+
+	for name, data := range map[string]string{
+		"prose":   "Dear Bob, thanks for the note.\nSee you soon.\n",
+		"ragged":  "a,b,c\n1,2\n3,4,5,6\n",
+		"binary":  "a,b\x00,c\n1,2,3\n",
+		"one row": "a,b,c\n",
+	} {
+		if score, reasons := c.Score("notes.txt", []byte(data)); score != 0 {
+			t.Errorf("%s scored %d: %v", name, score, reasons)
+		}
+	}
+	if score, _ := c.Score("notes.csv", []byte("a,b,c\n1,2\n")); score != 10 {
+		t.Errorf("ragged .csv scored %d, want 10", score)
+	}
+}
+
+func TestFixedWidthScore(t *testing.T) {
+	c := &fixedWidthConverter{}
+	line := "E" + "D" + "0000000012" + "0000000050" + "000000000001234" + "0001" + "0" + "JANE DOE                      "
+	data := []byte(line + "\n" + line + "\n")
+
+	score, reasons := c.Score("returns.dat", data)
+	if score < 70 || !strings.Contains(strings.Join(reasons, "\n"), "2 of 2 sampled lines are exactly as long as their BeanStream_Detail record") {
+		t.Errorf("BeanStream lines scored %d: %v", score, reasons)
+	}
+	for name, data := range map[string]string{
+		"csv":    "a,b,c\n1,2,3\n",
+		"prose":  "Dear Bob, thanks for the note.\nSee you soon.\n",
+		"binary": line + "\x00\n",
+	} {
+		if score, reasons := c.Score("notes.txt", []byte(data)); score != 0 {
+			t.Errorf("%s scored %d: %v", name, score, reasons)
+		}
+	}
+
+	files, err := c.Convert(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[1].Name != "BeanStream_Detail.csv" ||
+		!strings.Contains(string(files[1].Data), "E,D,12,50,1234,1,0,JANE DOE\n") {
+		t.Errorf("Convert() = %v", files)
+	}
+}
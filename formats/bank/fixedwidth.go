@@ -0,0 +1,129 @@
+// fixedwidth.go registers the reverse converter: fixed-width bank files
+// (returns, confirmations, NACHA files) read back into CSV or Excel.
+
+package bank
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lgican/File-Converter/formats"
+	parser "github.com/lgican/File-Converter/parsers/bank"
+)
+
+func init() {
+	formats.Register(&fixedWidthConverter{})
+}
+
+type fixedWidthConverter struct{}
+
+func (c *fixedWidthConverter) Name() string {
+	return "Bank File (Fixed-Width)"
+}
+
+func (c *fixedWidthConverter) Extensions() []string {
+	return []string{".txt", ".dat", ".ach"}
+}
+
+// Match reports whether data sniffs as the records of a bank template.
+func (c *fixedWidthConverter) Match(data []byte) bool {
+	score, _ := c.Score("", data)
+	return score >= fixedWidthScore
+}
+
+// fixedWidthScore is the confidence for text whose lines fit a template's
+// records.
+const fixedWidthScore = 40
+
+// Score rates how much data looks like a fixed-width file written with
+// one of the bank templates: lines of the template's record lengths, and
+// record-type codes that match, add confidence.
+func (c *fixedWidthConverter) Score(filename string, data []byte) (int, []string) {
+	var score int
+	var reasons []string
+	sample := data[:min(len(data), sniffBytes)]
+	if len(sample) < len(data) {
+		// Drop the partial last line.
+		if i := bytes.LastIndexByte(sample, '\n'); i > 0 {
+			sample = sample[:i]
+		}
+	}
+	if bytes.IndexByte(sample, 0) < 0 {
+		if matches := parser.SniffTemplates(sample); len(matches) > 0 {
+			m := matches[0]
+			score += fixedWidthScore + 25*m.Exact/m.Lines
+			reasons = append(reasons, fmt.Sprintf("%d of %d sampled lines are exactly as long as their %s record", m.Exact, m.Lines, m.Key))
+			if m.Codes > 0 {
+				score += 20
+				reasons = append(reasons, fmt.Sprintf("%d record-type codes match", m.Codes))
+			}
+			if m.Errors > 0 {
+				score -= 20
+				reasons = append(reasons, fmt.Sprintf("%d values do not read as their field's type", m.Errors))
+			}
+		}
+	}
+	if ext := formats.MatchExtension(c, filename); ext != "" && score > 0 {
+		score += 10
+		reasons = append(reasons, "file extension "+ext)
+	}
+	return max(score, 0), reasons
+}
+
+// Options selects the template, or sniffs it, and the output encoding.
+func (c *fixedWidthConverter) Options() []formats.Option {
+	return []formats.Option{
+		{Name: "template", Type: formats.OptionEnum, Choices: templateKeys(),
+			Description: "Read the records with this template (default: the one the lines fit best)"},
+		{Name: "output-format", Type: formats.OptionEnum, Choices: []string{"csv", "xlsx"}, Default: "csv",
+			Description: "Write the records as CSV or Excel"},
+	}
+}
+
+func (c *fixedWidthConverter) Convert(data []byte) ([]formats.ConvertedFile, error) {
+	opts, _ := formats.ParseOptions(c.Options(), nil)
+	return c.ConvertContext(context.Background(), formats.Input{Data: data}, opts)
+}
+
+// ConvertContext returns the original file followed by its records as CSV
+// or Excel, named after the template that read them.
+func (c *fixedWidthConverter) ConvertContext(ctx context.Context, in formats.Input, opts formats.Options) ([]formats.ConvertedFile, error) {
+	key := opts.String("template")
+	if key == "" {
+		matches := parser.SniffTemplates(in.Data)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no bank template matches the file's records")
+		}
+		key = matches[0].Key
+	}
+	bankFile, err := parser.DecodeFixedWidth(in.Data, key)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", key, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	format := opts.String("output-format")
+	var out []byte
+	if format == "xlsx" {
+		out, err = bankFile.FormatAsExcel()
+	} else {
+		out, err = bankFile.FormatAsCSV()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", key, err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(in.Name))
+	if ext == "" {
+		ext = ".txt"
+	}
+	return []formats.ConvertedFile{
+		{Name: "original" + ext, Data: in.Data, Category: formats.CategoryBody},
+		{Name: key + "." + format, Data: out, Category: formats.CategoryAttachment},
+	}, nil
+}
@@ -0,0 +1,119 @@
+// detect.go ranks the registered converters by how confident each is that
+// it can handle a file, so detection does not depend on registration
+// order and callers can explain or override the choice.
+
+package formats
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Confidence levels used by the default scoring of converters that do not
+// implement Scorer. Scorers should use the same scale: 100 is certain, 0
+// means the converter cannot handle the file.
+const (
+	ScoreSignature = 80 // Match recognised the content
+	ScoreExtension = 20 // only the file extension matched
+)
+
+// Scorer is implemented by converters that grade their own confidence.
+// Score returns a value from 0 to 100 and the reasons behind it; a score
+// of 0 removes the converter from the candidates.
+type Scorer interface {
+	Score(filename string, data []byte) (score int, reasons []string)
+}
+
+// Candidate is one converter's claim on a file.
+type Candidate struct {
+	Converter Converter
+	Score     int
+	Reasons   []string
+}
+
+// Rank scores every registered converter against the file and returns the
+// candidates with a positive score, best first. Equal scores keep
+// registration order. Converters disabled by the policy are not ranked.
+func Rank(filename string, data []byte) []Candidate {
+	var out []Candidate
+	for _, c := range All() {
+		score, reasons := score(c, filename, data)
+		if score > 0 {
+			out = append(out, Candidate{Converter: c, Score: min(score, 100), Reasons: reasons})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
+
+// score asks c for its confidence, falling back to Match and Extensions.
+func score(c Converter, filename string, data []byte) (int, []string) {
+	if s, ok := c.(Scorer); ok {
+		return s.Score(filename, data)
+	}
+	if c.Match(data) {
+		return ScoreSignature, []string{"content signature matched"}
+	}
+	if ext := MatchExtension(c, filename); ext != "" {
+		return ScoreExtension, []string{"file extension " + ext}
+	}
+	return 0, nil
+}
+
+// MatchExtension returns the lower-cased extension of filename if c claims
+// it, or "".
+func MatchExtension(c Converter, filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return ""
+	}
+	for _, e := range c.Extensions() {
+		if ext == e {
+			return ext
+		}
+	}
+	return ""
+}
+
+// Lookup finds a registered converter by name for overriding detection.
+// The name is matched case-insensitively, first exactly and then as a
+// unique prefix, so "tnef" selects "TNEF (winmail.dat)". Converters
+// disabled by the policy are reported as such.
+func Lookup(name string) (Converter, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	var matches []Converter
+	var disabled Converter
+	for _, c := range registry {
+		n := strings.ToLower(c.Name())
+		if n != name && (name == "" || !strings.HasPrefix(n, name)) {
+			continue
+		}
+		if !policy.ConverterAllowed(c.Name()) {
+			if n == name {
+				return nil, fmt.Errorf("converter %q is %w", c.Name(), ErrNotAllowed)
+			}
+			disabled = c
+			continue
+		}
+		if n == name {
+			return c, nil
+		}
+		matches = append(matches, c)
+	}
+	if len(matches) == 0 && disabled != nil {
+		return nil, fmt.Errorf("converter %q is %w", disabled.Name(), ErrNotAllowed)
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no converter named %q", name)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, c := range matches {
+		names[i] = c.Name()
+	}
+	return nil, fmt.Errorf("converter name %q is ambiguous: %s", name, strings.Join(names, ", "))
+}
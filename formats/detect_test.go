@@ -0,0 +1,80 @@
+package formats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type scoredConverter struct {
+	plainConverter
+	name  string
+	score int
+}
+
+func (s *scoredConverter) Name() string { return s.name }
+func (s *scoredConverter) Score(filename string, data []byte) (int, []string) {
+	return s.score, []string{"scored " + s.name}
+}
+
+type magicConverter struct{ plainConverter }
+
+func (m *magicConverter) Name() string           { return "Magic" }
+func (m *magicConverter) Extensions() []string   { return []string{".mg"} }
+func (m *magicConverter) Match(data []byte) bool { return bytes.HasPrefix(data, []byte("MG")) }
+
+func TestRankOrdersByScore(t *testing.T) {
+	saved := registry
+	defer func() { registry = saved }()
+	registry = nil
+	Register(&scoredConverter{name: "Weak", score: 30})
+	Register(&magicConverter{})
+	Register(&scoredConverter{name: "Never", score: 0})
+	Register(&scoredConverter{name: "Strong", score: 90})
+
+	cands := Rank("file.mg", []byte("MG data"))
+	var names []string
+	for _, c := range cands {
+		names = append(names, c.Converter.Name())
+	}
+	if got := strings.Join(names, ","); got != "Strong,Magic,Weak" {
+		t.Fatalf("Rank order = %s", got)
+	}
+	if cands[1].Score != ScoreSignature || cands[1].Reasons[0] != "content signature matched" {
+		t.Errorf("default score = %d %v", cands[1].Score, cands[1].Reasons)
+	}
+	if Detect("file.mg", nil) != registry[3] {
+		t.Error("Detect did not pick the highest score")
+	}
+
+	// Without the signature, the extension alone ranks low.
+	registry = registry[1:2]
+	cands = Rank("file.mg", []byte("other"))
+	if len(cands) != 1 || cands[0].Score != ScoreExtension {
+		t.Errorf("extension-only candidates = %+v", cands)
+	}
+	if Detect("file.txt", []byte("other")) != nil {
+		t.Error("Detect matched an unrecognised file")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	saved := registry
+	defer func() { registry = saved }()
+	registry = nil
+	Register(&scoredConverter{name: "TNEF (winmail.dat)"})
+	Register(&scoredConverter{name: "Text"})
+	Register(&scoredConverter{name: "Text Extra"})
+
+	for name, want := range map[string]string{"tnef": "TNEF (winmail.dat)", "TEXT": "Text", "text e": "Text Extra"} {
+		c, err := Lookup(name)
+		if err != nil || c.Name() != want {
+			t.Errorf("Lookup(%q) = %v, %v; want %s", name, c, err, want)
+		}
+	}
+	for _, name := range []string{"t", "zip", ""} {
+		if _, err := Lookup(name); err == nil {
+			t.Errorf("Lookup(%q) succeeded", name)
+		}
+	}
+}
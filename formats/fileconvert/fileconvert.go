@@ -3,18 +3,32 @@
 package fileconvert
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
 	"github.com/lgican/File-Converter/formats"
 	parser "github.com/lgican/File-Converter/parsers/fileconvert"
 )
 
+// name is the converter's registry name; disabling it in the deployment
+// policy disables file conversion altogether.
+const name = "File Converter"
+
 func init() {
 	formats.Register(&converter{})
+	parser.SetFilter(func(c parser.Converter, from, to string) bool {
+		p := formats.CurrentPolicy()
+		return p.ConverterAllowed(name) && p.ConversionAllowed(c.Name(), from, to)
+	})
 }
 
 type converter struct{}
 
 func (c *converter) Name() string {
-	return "File Converter"
+	return name
 }
 
 func (c *converter) Extensions() []string {
@@ -45,12 +59,80 @@ func (c *converter) Match(data []byte) bool {
 	return false
 }
 
+// Score recognises media by magic bytes and everything else by extension.
+// It stays below the dedicated converters because nothing is produced
+// until a target format is chosen.
+func (c *converter) Score(filename string, data []byte) (int, []string) {
+	var score int
+	var reasons []string
+	if f := parser.DetectFormatFromData(data); f != "" {
+		score += 50
+		reasons = append(reasons, "content looks like "+f)
+	}
+	if ext := formats.MatchExtension(c, filename); ext != "" {
+		score += 25
+		reasons = append(reasons, "file extension "+ext)
+	}
+	if score > 0 {
+		reasons = append(reasons, `converts only with the "to" option`)
+	}
+	return score, reasons
+}
+
 func (c *converter) Convert(data []byte) ([]formats.ConvertedFile, error) {
 	// This converter is used via the API endpoint, not directly
 	// The API endpoint handles the conversion request with format parameters
 	return nil, nil
 }
 
+// Options carries the format parameters of the API endpoint, so a file
+// that reaches this converter by extension can still be converted.
+func (c *converter) Options() []formats.Option {
+	return []formats.Option{
+		{Name: "to", Type: formats.OptionString, Description: "Target format extension (e.g. jpg, mp3, pdf)"},
+		{Name: "from", Type: formats.OptionString, Description: "Source format extension (default: detected)"},
+		{Name: "quality", Type: formats.OptionInt, Default: "90", Min: 1, Max: 100,
+			Description: "Quality for lossy image, audio and video output"},
+	}
+}
+
+// ConvertContext converts the input to the "to" format. Without a target
+// format there is nothing to do, which is reported as an error so the
+// caller can tell the user which option to set.
+func (c *converter) ConvertContext(ctx context.Context, in formats.Input, opts formats.Options) ([]formats.ConvertedFile, error) {
+	to := opts.String("to")
+	if to == "" {
+		return nil, errors.New(`no target format: set the "to" option`)
+	}
+	from := opts.String("from")
+	if from == "" {
+		from = parser.DetectFormatFromData(in.Data)
+	}
+	if from == "" {
+		from = parser.DetectFormatFromFilename(in.Name)
+	}
+	if from == "" {
+		return nil, errors.New(`could not detect the input format: set the "from" option`)
+	}
+
+	result, err := ConvertFileContext(ctx, in.Data, from, to, opts.Int("quality"))
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(in.Name, filepath.Ext(in.Name))
+	if base == "" {
+		base = "converted"
+	}
+	if !strings.HasPrefix(to, ".") {
+		to = "." + to
+	}
+	return []formats.ConvertedFile{{
+		Name:     base + strings.ToLower(to),
+		Data:     result.Data,
+		Category: formats.CategoryBody,
+	}}, nil
+}
+
 // GetSupportedFormats returns all formats grouped by category for the API.
 func GetSupportedFormats() map[parser.FormatCategory][]parser.Format {
 	return parser.GetAllFormats()
@@ -58,16 +140,36 @@ func GetSupportedFormats() map[parser.FormatCategory][]parser.Format {
 
 // ConvertFile performs a file conversion with the specified parameters.
 func ConvertFile(data []byte, fromFormat, toFormat string, quality int) (*parser.ConversionResult, error) {
+	return ConvertFileContext(context.Background(), data, fromFormat, toFormat, quality)
+}
+
+// ConvertFileContext is ConvertFile with a context that cancels the
+// conversion, killing any external tool it started. Conversions and input
+// sizes the deployment policy refuses return an error wrapping
+// formats.ErrNotAllowed.
+func ConvertFileContext(ctx context.Context, data []byte, fromFormat, toFormat string, quality int) (*parser.ConversionResult, error) {
+	p := formats.CurrentPolicy()
+	if !p.ConversionAllowed(name, fromFormat, toFormat) {
+		return nil, fmt.Errorf("conversion from %s to %s is %w", fromFormat, toFormat, formats.ErrNotAllowed)
+	}
 	conv := parser.GetConverter(fromFormat, toFormat)
 	if conv == nil {
 		return nil, parser.ErrUnsupportedConversion
 	}
+	ext := fromFormat
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if max := p.MaxSize(ext, conv.Name(), name); max > 0 && int64(len(data)) > max {
+		return nil, fmt.Errorf("%d-byte %s input exceeds the %d MB limit: %w", len(data), fromFormat, max>>20, formats.ErrNotAllowed)
+	}
 
 	req := parser.ConversionRequest{
 		Data:       data,
 		FromFormat: fromFormat,
 		ToFormat:   toFormat,
 		Quality:    quality,
+		Context:    ctx,
 	}
 
 	return conv.Convert(req)
@@ -1,20 +1,35 @@
 // Package formats defines the Converter interface and a registry for
 // pluggable file format converters. To add a new format, create a
 // package that implements Converter and calls Register from its init
-// function. The registry auto-detects formats by content (magic bytes)
-// first and falls back to file extension matching.
+// function. The registry auto-detects formats by ranking converters on a
+// confidence score: converters implementing Scorer grade themselves, and
+// the rest score high on a content (magic bytes) match and low on a file
+// extension match.
 package formats
 
 import (
-	"path/filepath"
+	"fmt"
 	"strings"
+	"time"
 )
 
 // ConvertedFile is a single output file produced by a conversion.
+// Converters set Name, Data and Category, and whatever else they know
+// from the input; Finalize fills in the rest.
 type ConvertedFile struct {
 	Name     string
 	Data     []byte
-	Category string // "body" or "attachment"
+	Category Category
+
+	MimeType  string    // e.g. "image/png"; the attachment's declared type if known
+	ModTime   time.Time // original modification time; zero if unknown
+	SHA256    string    // hex digest of Data, set by Finalize
+	ContentID string    // MIME Content-ID of an inline part, without brackets
+	Source    Source
+
+	// Provenance lists the files this one was extracted from, outermost
+	// first. It is set by ExtractRecursive.
+	Provenance []Origin
 }
 
 // Converter handles detection and conversion of a specific file format.
@@ -41,28 +56,24 @@ func Register(c Converter) {
 	registry = append(registry, c)
 }
 
-// Detect identifies the correct converter for a file. It checks content
-// (magic bytes) first, then falls back to extension matching.
+// Detect returns the converter most confident that it can handle the
+// file, or nil if none can. See Rank for the full candidate list.
 func Detect(filename string, data []byte) Converter {
-	for _, c := range registry {
-		if c.Match(data) {
-			return c
-		}
-	}
-	ext := strings.ToLower(filepath.Ext(filename))
-	for _, c := range registry {
-		for _, e := range c.Extensions() {
-			if ext == e {
-				return c
-			}
-		}
+	if c := Rank(filename, data); len(c) > 0 {
+		return c[0].Converter
 	}
 	return nil
 }
 
-// All returns every registered converter.
+// All returns every registered converter the deployment policy allows.
 func All() []Converter {
-	return registry
+	var out []Converter
+	for _, c := range registry {
+		if policy.ConverterAllowed(c.Name()) {
+			out = append(out, c)
+		}
+	}
+	return out
 }
 
 // SanitizeFilename replaces characters that are unsafe in file paths
@@ -82,3 +93,17 @@ func SanitizeFilename(name string) string {
 	}
 	return name
 }
+
+// HumanSize formats a byte count as a human-readable string (e.g. "1.2 KB").
+func HumanSize(b int) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := unit, 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -20,6 +20,23 @@ func TestSanitizeFilename(t *testing.T) {
 	}
 }
 
+func TestHumanSize(t *testing.T) {
+	tests := []struct {
+		input int
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{2048, "2.0 KB"},
+		{5 << 20, "5.0 MB"},
+	}
+	for _, tt := range tests {
+		if got := HumanSize(tt.input); got != tt.want {
+			t.Errorf("HumanSize(%d) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
 func TestDetectNilData(t *testing.T) {
 	result := Detect("test.xyz", nil)
 	if result != nil {
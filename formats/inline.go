@@ -1,24 +1,51 @@
-// inline.go fetches external images referenced in HTML and converts them
-// to inline data URIs. All network access goes through an SSRF-safe HTTP
-// client that blocks private, loopback, and link-local addresses.
+// inline.go rewrites external resources referenced by HTML -- <img> src and
+// srcset, background attributes, inline and embedded CSS url() references,
+// and <link rel=stylesheet> -- into inline data URIs and <style> blocks,
+// subject to the process RemotePolicy. Direct fetches go through an SSRF-safe HTTP client that
+// blocks private, loopback, and link-local addresses.
 
 package formats
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
+	"image"
+	_ "image/gif" // register decoders for tracking-pixel detection
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Fetch limits for remote resources.
+const (
+	maxResourceBytes     = 5 << 20  // per resource
+	defaultRemoteBudget  = 20 << 20 // per conversion, unless RemotePolicy.MaxBytes is set
+	remoteFetchWorkers   = 4        // concurrent downloads per Inline call
+	maxStylesheetImports = 64       // url() references followed per stylesheet
 )
 
-// imgSrcRe matches <img src="..."> attributes for URL replacement.
-var imgSrcRe = regexp.MustCompile(`(<img\b[^>]*?\bsrc=")([^"]+)(")`)
+// cssURLRe matches url(...) references in CSS, quoted or unquoted.
+var cssURLRe = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]*))\s*\)`)
+
+// cssRefRe matches either an @import rule (URL in groups 1-5, media list in
+// group 6) or a plain url(...) reference (groups 7-9).
+var cssRefRe = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]*))\s*\)|"([^"]*)"|'([^']*)')([^;{}]*);?` +
+	`|url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]*))\s*\)`)
+
+// styleDimRe matches width/height declarations inside a style attribute.
+var styleDimRe = regexp.MustCompile(`(?i)\b(width|height)\s*:\s*(\d+)(?:px)?\b`)
 
 // ssrfSafeDialer returns a DialContext that resolves DNS and checks every
 // resolved IP against the private/loopback/link-local blocklist BEFORE
@@ -77,7 +104,7 @@ var inlineClient = &http.Client{
 		TLSHandshakeTimeout:   5 * time.Second,
 		ResponseHeaderTimeout: 5 * time.Second,
 		MaxIdleConns:          10,
-		MaxIdleConnsPerHost:   2,
+		MaxIdleConnsPerHost:   remoteFetchWorkers,
 		IdleConnTimeout:       30 * time.Second,
 	},
 	// Validate each redirect target against the SSRF blocklist.
@@ -95,61 +122,536 @@ var inlineClient = &http.Client{
 	},
 }
 
-// InlineExternalImages finds all <img src="https://..."> references in html,
-// fetches the image data, and replaces src with a data URI. Images that
-// fail to download are left as-is. Only http/https URLs are fetched.
-// Each unique URL is fetched only once to avoid rate limiting. Pass a
-// non-nil cache map to share results across multiple calls.
-func InlineExternalImages(html []byte, cache map[string]string) []byte {
-	if cache == nil {
-		cache = make(map[string]string)
+// newProxyClient returns a client that sends every request through proxy.
+// The proxy itself is trusted (it is usually on the private network), so
+// the SSRF dialer is not used; target hostnames are still pre-checked and
+// resolution of the target happens on the proxy.
+func newProxyClient(proxy *url.URL, policy RemotePolicy) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyURL(proxy),
+			DialContext:           (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Second,
+			MaxIdleConns:          10,
+			MaxIdleConnsPerHost:   remoteFetchWorkers,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("too many redirects")
+			}
+			host := req.URL.Hostname()
+			if isBlockedHostname(host) || !policy.HostAllowed(host) {
+				return errors.New("redirect to blocked host")
+			}
+			return nil
+		},
+	}
+}
+
+// resourceKind distinguishes what a URL is expected to return.
+type resourceKind int
+
+const (
+	kindImage resourceKind = iota
+	kindStylesheet
+)
+
+// action is the rewrite applied to a single resource reference.
+type action int
+
+const (
+	actKeep   action = iota // leave the reference untouched
+	actInline               // replace with a data URI
+	actBlock                // neutralise so browsers do not load it
+	actStrip                // remove the element (tracking pixel)
+)
+
+// decision is the outcome for one reference. Inlined stylesheets also
+// carry their rewritten text in css.
+type decision struct {
+	act     action
+	dataURI string
+	css     string
+}
+
+// fetchResult is a cached download outcome for a single URL.
+type fetchResult struct {
+	data       []byte
+	mime       string
+	tracker    bool // image decoded as 1x1
+	overBudget bool // discarded because the byte budget was exhausted
+}
+
+// ImageInliner rewrites external resources in HTML according to a
+// RemotePolicy. A single inliner should be shared by every HTML body of one
+// conversion so each unique URL is fetched at most once, the byte budget
+// covers the whole conversion, and the report covers the whole message.
+type ImageInliner struct {
+	Policy RemotePolicy
+	Report RemoteReport
+
+	ctx       context.Context // cancels fetches
+	client    *http.Client
+	mu        sync.Mutex // guards cache and remaining during fetchAll
+	cache     map[string]*fetchResult
+	remaining int64
+	importing map[string]bool // stylesheets being expanded, to break @import cycles
+}
+
+// NewImageInliner returns an inliner for the given policy.
+func NewImageInliner(p RemotePolicy) *ImageInliner {
+	return NewImageInlinerContext(context.Background(), p)
+}
+
+// NewImageInlinerContext returns an inliner whose fetches are abandoned
+// once ctx is done.
+func NewImageInlinerContext(ctx context.Context, p RemotePolicy) *ImageInliner {
+	in := &ImageInliner{
+		Policy:    p,
+		ctx:       ctx,
+		cache:     make(map[string]*fetchResult),
+		remaining: p.MaxBytes,
+		importing: make(map[string]bool),
+	}
+	if in.remaining <= 0 {
+		in.remaining = defaultRemoteBudget
+	}
+	switch p.Mode {
+	case RemoteFetch:
+		in.client = inlineClient
+	case RemoteProxy:
+		if u, err := url.Parse(p.ProxyURL); err == nil {
+			in.client = newProxyClient(u, p)
+		}
+	}
+	return in
+}
+
+// Inline rewrites every external resource reference in doc. Tracking pixels
+// are removed, references blocked by the policy are neutralised (attributes
+// are renamed to data-blocked-*, CSS url() becomes none), and allowed
+// resources are fetched concurrently and embedded. Stylesheet links become
+// <style> blocks and @import rules are replaced by the imported text, since
+// the viewer's CSP does not load data: stylesheets. Resources that fail to
+// download, or that do not fit in the byte budget, are left as-is. Only
+// http/https URLs are considered.
+func (in *ImageInliner) Inline(doc []byte) []byte {
+	if len(doc) == 0 {
+		return doc
 	}
+	if in.client != nil {
+		// First pass: collect every allowed URL and fetch them in parallel.
+		want := make(map[string]resourceKind)
+		in.walk(doc, func(kind resourceKind, rawURL string) decision {
+			if in.allowed(rawURL) {
+				want[rawURL] = kind
+			}
+			return decision{}
+		})
+		in.fetchAll(want)
 
-	return imgSrcRe.ReplaceAllFunc(html, func(match []byte) []byte {
-		parts := imgSrcRe.FindSubmatch(match)
-		if len(parts) < 4 {
-			return match
+		// Stylesheets can reference images and import further stylesheets;
+		// follow them until no new URLs turn up.
+		for pending := want; len(pending) > 0; {
+			nested := make(map[string]resourceKind)
+			for u, kind := range pending {
+				res := in.cache[u]
+				if kind != kindStylesheet || res == nil || res.data == nil {
+					continue
+				}
+				base, _ := url.Parse(u)
+				found := 0
+				rewriteCSS(string(res.data), base, func(kind resourceKind, rawURL string) decision {
+					if _, seen := in.cache[rawURL]; !seen && found < maxStylesheetImports && in.allowed(rawURL) {
+						nested[rawURL] = kind
+						found++
+					}
+					return decision{}
+				})
+			}
+			in.fetchAll(nested)
+			pending = nested
 		}
-		prefix := parts[1] // <img ... src="
-		rawURL := string(parts[2])
-		suffix := parts[3] // "
+	}
+	return in.walk(doc, in.decide)
+}
 
-		if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
-			return match
+// allowed reports whether rawURL is a remote URL the policy lets us fetch.
+func (in *ImageInliner) allowed(rawURL string) bool {
+	if !isRemoteURL(rawURL) {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	return err == nil && in.client != nil && in.Policy.HostAllowed(u.Hostname())
+}
+
+// decide returns the rewrite for one reference, recording the outcome in
+// the report. It only reads the cache populated by fetchAll.
+func (in *ImageInliner) decide(kind resourceKind, rawURL string) decision {
+	if !isRemoteURL(rawURL) {
+		return decision{}
+	}
+	if !in.allowed(rawURL) {
+		in.Report.Blocked = appendUnique(in.Report.Blocked, rawURL)
+		return decision{act: actBlock}
+	}
+	res := in.cache[rawURL]
+	switch {
+	case res == nil || (res.data == nil && !res.tracker && !res.overBudget):
+		in.Report.Failed = appendUnique(in.Report.Failed, rawURL)
+		return decision{}
+	case res.overBudget:
+		in.Report.OverBudget = appendUnique(in.Report.OverBudget, rawURL)
+		return decision{}
+	case res.tracker:
+		in.Report.Trackers = appendUnique(in.Report.Trackers, rawURL)
+		return decision{act: actStrip}
+	}
+	if kind == kindStylesheet {
+		if in.importing[rawURL] {
+			return decision{act: actStrip}
+		}
+		in.importing[rawURL] = true
+		defer delete(in.importing, rawURL)
+		base, _ := url.Parse(rawURL)
+		css := rewriteCSS(string(res.data), base, in.decide)
+		if strings.Contains(strings.ToLower(css), "</style") {
+			in.Report.Failed = appendUnique(in.Report.Failed, rawURL)
+			return decision{}
 		}
+		return decision{act: actInline, css: css}
+	}
+	return decision{act: actInline, dataURI: "data:" + res.mime + ";base64," + base64.StdEncoding.EncodeToString(res.data)}
+}
+
+// fetchAll downloads every URL in want that is not already cached, using a
+// bounded pool of workers.
+func (in *ImageInliner) fetchAll(want map[string]resourceKind) {
+	// Pick the uncached URLs before any worker writes to the cache.
+	todo := make(map[string]resourceKind, len(want))
+	in.mu.Lock()
+	for rawURL, kind := range want {
+		if _, seen := in.cache[rawURL]; !seen {
+			todo[rawURL] = kind
+		}
+	}
+	in.mu.Unlock()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, remoteFetchWorkers)
+	for rawURL, kind := range todo {
+		wg.Add(1)
+		go func(rawURL string, kind resourceKind) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			res := in.fetch(kind, rawURL)
+			in.mu.Lock()
+			in.cache[rawURL] = res
+			in.mu.Unlock()
+		}(rawURL, kind)
+	}
+	wg.Wait()
+}
+
+// fetch downloads a single resource and charges it against the budget.
+func (in *ImageInliner) fetch(kind resourceKind, rawURL string) *fetchResult {
+	in.mu.Lock()
+	exhausted := in.remaining <= 0
+	in.mu.Unlock()
+	if exhausted {
+		return &fetchResult{overBudget: true}
+	}
+
+	accept := "image/"
+	if kind == kindStylesheet {
+		accept = "text/css"
+	}
+	data, contentType, err := fetchResource(in.ctx, in.client, rawURL, accept)
+	if err != nil || len(data) == 0 {
+		return &fetchResult{}
+	}
+
+	in.mu.Lock()
+	if int64(len(data)) > in.remaining {
+		in.mu.Unlock()
+		return &fetchResult{overBudget: true}
+	}
+	in.remaining -= int64(len(data))
+	in.mu.Unlock()
 
-		if strings.HasPrefix(rawURL, "data:") {
-			return match
+	if kind == kindStylesheet {
+		return &fetchResult{data: data, mime: "text/css"}
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && cfg.Width <= 1 && cfg.Height <= 1 {
+		return &fetchResult{tracker: true}
+	}
+	return &fetchResult{data: data, mime: imageContentType(contentType)}
+}
+
+// walk tokenizes doc and rewrites every resource reference using decide.
+// Unmodified tokens are copied byte-for-byte; only changed tags are
+// re-serialised.
+func (in *ImageInliner) walk(doc []byte, decide func(resourceKind, string) decision) []byte {
+	z := html.NewTokenizer(bytes.NewReader(doc))
+	var out bytes.Buffer
+	out.Grow(len(doc))
+	inStyle := false
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
 		}
+		// Copy before Token(), which lower-cases the buffer in place.
+		raw := append([]byte(nil), z.Raw()...)
 
-		dataURI, seen := cache[rawURL]
-		if !seen {
-			data, contentType, err := fetchImage(rawURL)
-			if err != nil || len(data) == 0 {
-				cache[rawURL] = ""
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data == "style" && tt == html.StartTagToken {
+				inStyle = true
+			}
+			changed, drop, css := in.rewriteTag(&tok, decide)
+			switch {
+			case css != "":
+				out.WriteString(styleElement(tok, css))
+			case drop:
+			case changed:
+				out.WriteString(tok.String())
+			default:
+				out.Write(raw)
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "style" {
+				inStyle = false
+			}
+			out.Write(raw)
+		case html.TextToken:
+			if inStyle {
+				out.WriteString(rewriteCSS(string(raw), nil, decide))
 			} else {
-				mime := imageContentType(contentType)
-				b64 := base64.StdEncoding.EncodeToString(data)
-				dataURI = "data:" + mime + ";base64," + b64
-				cache[rawURL] = dataURI
+				out.Write(raw)
 			}
+		default:
+			out.Write(raw)
 		}
+	}
+	return out.Bytes()
+}
+
+// rewriteTag applies decide to every resource-bearing attribute of tok.
+// It returns whether tok was modified, whether it should be dropped, and
+// for an inlined stylesheet link the CSS that replaces it.
+func (in *ImageInliner) rewriteTag(tok *html.Token, decide func(resourceKind, string) decision) (changed, drop bool, css string) {
+	if tok.Data == "img" && isTrackerAttrs(tok.Attr) {
+		if src := attrValue(tok.Attr, "src"); isRemoteURL(src) {
+			in.Report.Trackers = appendUnique(in.Report.Trackers, src)
+			return false, true, ""
+		}
+	}
+	isStylesheet := tok.Data == "link" &&
+		strings.Contains(strings.ToLower(attrValue(tok.Attr, "rel")), "stylesheet")
+
+	for i := range tok.Attr {
+		a := &tok.Attr[i]
+		switch {
+		case a.Key == "src" && (tok.Data == "img" || tok.Data == "input"),
+			a.Key == "background":
+			d := decide(kindImage, strings.TrimSpace(a.Val))
+			switch d.act {
+			case actStrip:
+				return false, true, ""
+			case actBlock:
+				a.Key = "data-blocked-" + a.Key
+				changed = true
+			case actInline:
+				a.Val = d.dataURI
+				changed = true
+			}
+		case a.Key == "srcset":
+			val, blocked, ok := rewriteSrcset(a.Val, decide)
+			if ok {
+				changed = true
+				a.Val = val
+				if blocked {
+					a.Key = "data-blocked-srcset"
+				}
+			}
+		case a.Key == "style":
+			if val := rewriteCSS(a.Val, nil, decide); val != a.Val {
+				a.Val = val
+				changed = true
+			}
+		case a.Key == "href" && isStylesheet:
+			d := decide(kindStylesheet, strings.TrimSpace(a.Val))
+			switch d.act {
+			case actBlock:
+				a.Key = "data-blocked-href"
+				changed = true
+			case actInline:
+				return false, false, d.css
+			}
+		}
+	}
+	return changed, false, ""
+}
+
+// styleElement returns a <style> block holding css in place of the
+// stylesheet link tok, keeping its media attribute.
+func styleElement(link html.Token, css string) string {
+	if media := attrValue(link.Attr, "media"); media != "" {
+		return `<style media="` + html.EscapeString(media) + `">` + css + "</style>"
+	}
+	return "<style>" + css + "</style>"
+}
+
+// rewriteSrcset rewrites each candidate URL of a srcset attribute. If any
+// candidate is blocked the whole attribute is reported as blocked so the
+// caller can rename it. ok is false when nothing changed.
+func rewriteSrcset(val string, decide func(resourceKind, string) decision) (out string, blocked, ok bool) {
+	candidates := parseSrcset(val)
+	var parts []string
+	for _, c := range candidates {
+		d := decide(kindImage, c.url)
+		switch d.act {
+		case actInline:
+			c.url = d.dataURI
+			ok = true
+		case actBlock:
+			blocked, ok = true, true
+		case actStrip:
+			ok = true
+			continue
+		}
+		parts = append(parts, strings.TrimSpace(c.url+" "+c.descriptor))
+	}
+	if blocked {
+		return val, true, true
+	}
+	return strings.Join(parts, ", "), false, ok
+}
+
+// srcsetCandidate is one "url descriptor" entry of a srcset attribute.
+type srcsetCandidate struct {
+	url        string
+	descriptor string
+}
 
-		if dataURI == "" {
-			return match
+// parseSrcset splits a srcset attribute into candidates. URLs run up to
+// the next whitespace, so data URIs containing commas are handled.
+func parseSrcset(val string) []srcsetCandidate {
+	var out []srcsetCandidate
+	i, n := 0, len(val)
+	for i < n {
+		for i < n && (val[i] == ',' || isSpace(val[i])) {
+			i++
+		}
+		start := i
+		for i < n && !isSpace(val[i]) {
+			i++
+		}
+		u := strings.TrimRight(val[start:i], ",")
+		dStart := i
+		for i < n && val[i] != ',' {
+			i++
+		}
+		if u != "" {
+			out = append(out, srcsetCandidate{url: u, descriptor: strings.TrimSpace(val[dStart:i])})
 		}
+	}
+	return out
+}
 
-		var result []byte
-		result = append(result, prefix...)
-		result = append(result, []byte(dataURI)...)
-		result = append(result, suffix...)
-		return result
+// rewriteCSS rewrites url() references and @import rules in CSS. Relative
+// URLs are resolved against base when it is non-nil (for fetched
+// stylesheets); in HTML they are left alone. Blocked url() references
+// become "none" and blocked imports are removed; an inlined import is
+// replaced by the imported text, wrapped in @media if it had a media list.
+func rewriteCSS(css string, base *url.URL, decide func(resourceKind, string) decision) string {
+	return cssRefRe.ReplaceAllStringFunc(css, func(m string) string {
+		sub := cssRefRe.FindStringSubmatch(m)
+		kind, ref := kindImage, strings.Join(sub[7:10], "")
+		if strings.HasPrefix(m, "@") {
+			kind, ref = kindStylesheet, strings.Join(sub[1:6], "")
+		}
+		ref = strings.TrimSpace(ref)
+		if base != nil && ref != "" && !strings.HasPrefix(ref, "data:") {
+			if u, err := base.Parse(ref); err == nil {
+				ref = u.String()
+			}
+		}
+		d := decide(kind, ref)
+		switch {
+		case d.act == actInline && kind == kindStylesheet:
+			if media := strings.TrimSpace(sub[6]); media != "" {
+				return "@media " + media + " {\n" + d.css + "\n}"
+			}
+			return d.css
+		case d.act == actInline:
+			return `url("` + d.dataURI + `")`
+		case kind == kindStylesheet && (d.act == actBlock || d.act == actStrip):
+			return ""
+		case d.act == actBlock || d.act == actStrip:
+			return "none"
+		}
+		return m
 	})
 }
 
-// fetchImage downloads an image from rawURL and returns the bytes and content type.
-// Returns empty results (without error) for non-image or blocked URLs.
-func fetchImage(rawURL string) ([]byte, string, error) {
+// isTrackerAttrs returns true if an <img> declares itself as a 1x1 (or 0x0)
+// image through attributes or inline style -- the usual shape of an
+// open-tracking pixel.
+func isTrackerAttrs(attrs []html.Attribute) bool {
+	dims := map[string]int{}
+	for _, a := range attrs {
+		switch a.Key {
+		case "width", "height":
+			v := strings.TrimSuffix(strings.TrimSpace(a.Val), "px")
+			if n, err := strconv.Atoi(v); err == nil {
+				dims[a.Key] = n
+			}
+		case "style":
+			for _, m := range styleDimRe.FindAllStringSubmatch(a.Val, -1) {
+				if n, err := strconv.Atoi(m[2]); err == nil {
+					dims[strings.ToLower(m[1])] = n
+				}
+			}
+		}
+	}
+	w, hasW := dims["width"]
+	h, hasH := dims["height"]
+	return hasW && hasH && w <= 1 && h <= 1
+}
+
+// attrValue returns the value of the named attribute, or "".
+func attrValue(attrs []html.Attribute, key string) string {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// isRemoteURL reports whether s is an absolute http or https URL.
+func isRemoteURL(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// isSpace reports whether c is HTML whitespace.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// fetchResource downloads rawURL and returns the bytes and content type.
+// Returns empty results (without error) for blocked URLs, non-200
+// responses, and content types that do not start with accept. Resources
+// larger than maxResourceBytes are rejected rather than truncated.
+func fetchResource(ctx context.Context, client *http.Client, rawURL, accept string) ([]byte, string, error) {
 	// Basic URL validation.
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
@@ -164,7 +666,11 @@ func fetchImage(rawURL string) ([]byte, string, error) {
 		return nil, "", nil
 	}
 
-	resp, err := inlineClient.Get(rawURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, "", err
 	}
@@ -175,15 +681,17 @@ func fetchImage(rawURL string) ([]byte, string, error) {
 	}
 
 	ct := resp.Header.Get("Content-Type")
-	if !strings.HasPrefix(ct, "image/") {
+	if !strings.HasPrefix(strings.ToLower(ct), accept) {
 		return nil, "", nil
 	}
 
-	// Limit to 5 MB per image.
-	data, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResourceBytes+1))
 	if err != nil {
 		return nil, "", err
 	}
+	if len(data) > maxResourceBytes {
+		return nil, "", errors.New("resource too large")
+	}
 	return data, ct, nil
 }
 
@@ -0,0 +1,201 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRemotePolicyHostAllowed(t *testing.T) {
+	p := RemotePolicy{
+		Mode:       RemoteFetch,
+		AllowHosts: []string{"example.com"},
+		DenyHosts:  []string{"track.example.com"},
+	}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"img.example.com", true},
+		{"track.example.com", false},
+		{"a.track.example.com", false},
+		{"notexample.com", false},
+		{"other.org", false},
+	}
+	for _, tt := range tests {
+		if got := p.HostAllowed(tt.host); got != tt.want {
+			t.Errorf("HostAllowed(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+	if (RemotePolicy{Mode: RemoteBlock}).HostAllowed("example.com") {
+		t.Error("block mode must not allow any host")
+	}
+}
+
+func TestRemotePolicyValidate(t *testing.T) {
+	if err := (RemotePolicy{Mode: RemoteProxy}).Validate(); err == nil {
+		t.Error("expected error for proxy mode without URL")
+	}
+	if err := (RemotePolicy{Mode: RemoteProxy, ProxyURL: "http://proxy:3128"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ParseRemoteMode("sometimes"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestInlineBlockMode(t *testing.T) {
+	in := NewImageInliner(RemotePolicy{Mode: RemoteBlock})
+	html := `<p><img src="https://example.com/logo.png" alt="logo">` +
+		`<img width="1" height="1" src="https://t.example.com/open.gif">` +
+		`<img src="data:image/png;base64,AAAA"></p>`
+	got := string(in.Inline([]byte(html)))
+
+	if strings.Contains(got, `<img src="https://example.com/logo.png"`) {
+		t.Errorf("blocked image still has src: %s", got)
+	}
+	if !strings.Contains(got, `data-blocked-src="https://example.com/logo.png"`) {
+		t.Errorf("blocked image URL not preserved: %s", got)
+	}
+	if strings.Contains(got, "open.gif") {
+		t.Errorf("tracking pixel not removed: %s", got)
+	}
+	if !strings.Contains(got, "data:image/png;base64,AAAA") {
+		t.Errorf("data URI image was modified: %s", got)
+	}
+	if len(in.Report.Blocked) != 1 || len(in.Report.Trackers) != 1 {
+		t.Errorf("unexpected report: %+v", in.Report)
+	}
+}
+
+func TestInlineBlockModeAttributes(t *testing.T) {
+	in := NewImageInliner(RemotePolicy{Mode: RemoteBlock})
+	html := `<body background=https://example.com/bg.png>` +
+		`<img src='https://example.com/a.png'>` +
+		`<img srcset="https://example.com/b.png 1x, https://example.com/b2.png 2x">` +
+		`<div style="background-image:url('https://example.com/c.png')">x</div>` +
+		`<link rel="stylesheet" href="https://example.com/s.css">` +
+		`<style>td { background: url(https://example.com/d.png) }</style></body>`
+	got := string(in.Inline([]byte(html)))
+
+	for _, want := range []string{
+		`data-blocked-background="https://example.com/bg.png"`,
+		`data-blocked-src="https://example.com/a.png"`,
+		`data-blocked-srcset="https://example.com/b.png 1x, https://example.com/b2.png 2x"`,
+		`background-image:none`,
+		`data-blocked-href="https://example.com/s.css"`,
+		`td { background: none }`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if len(in.Report.Blocked) != 7 {
+		t.Errorf("expected 7 blocked URLs, got %d: %v", len(in.Report.Blocked), in.Report.Blocked)
+	}
+}
+
+func TestInlineViaProxy(t *testing.T) {
+	pixel := &bytes.Buffer{}
+	png.Encode(pixel, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	logo := &bytes.Buffer{}
+	png.Encode(logo, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+
+	var hits atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(logo.Bytes())
+		case "/open.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pixel.Bytes())
+		case "/s.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte(`@import "print.css" print; body { background: url(logo.png) }`))
+		case "/print.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte(`@import url(s.css); p { color: black }`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer proxy.Close()
+
+	in := NewImageInliner(RemotePolicy{Mode: RemoteProxy, ProxyURL: proxy.URL, DenyHosts: []string{"denied.test"}})
+	html := `<img src="http://img.test/logo.png"><img src="http://img.test/logo.png">` +
+		`<img src="http://img.test/open.png"><img src="http://denied.test/x.png">` +
+		`<link rel=stylesheet href="http://img.test/s.css">`
+	got := string(in.Inline([]byte(html)))
+
+	if strings.Count(got, "data:image/png;base64,") != 3 {
+		t.Errorf("expected logo inlined three times:\n%s", got)
+	}
+	if strings.Contains(got, "open.png") {
+		t.Errorf("1x1 image not stripped:\n%s", got)
+	}
+	// The link becomes a <style> block with the import expanded in place;
+	// the cyclic import back to s.css is dropped.
+	if !strings.Contains(got, "<style>@media print {\n p { color: black }\n} body { background: url(\"data:image/png;base64,") ||
+		strings.Contains(got, "<link") || strings.Contains(got, "@import") {
+		t.Errorf("stylesheet not inlined:\n%s", got)
+	}
+	if n := hits.Load(); n != 4 {
+		t.Errorf("expected 4 proxy requests (unique URLs only), got %d", n)
+	}
+	if len(in.Report.Trackers) != 1 || len(in.Report.Blocked) != 1 {
+		t.Errorf("unexpected report: %+v", in.Report)
+	}
+}
+
+func TestInlineByteBudget(t *testing.T) {
+	logo := &bytes.Buffer{}
+	png.Encode(logo, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(logo.Bytes())
+	}))
+	defer proxy.Close()
+
+	in := NewImageInliner(RemotePolicy{Mode: RemoteProxy, ProxyURL: proxy.URL, MaxBytes: int64(logo.Len()) + 1})
+	in.Inline([]byte(`<img src="http://a.test/1.png"><img src="http://a.test/2.png">`))
+	if len(in.Report.OverBudget) != 1 {
+		t.Errorf("expected one URL over budget, got %+v", in.Report)
+	}
+}
+
+// Many distinct URLs keep the workers writing the cache while fetchAll
+// still ranges over them; run with -race.
+func TestInlineManyURLs(t *testing.T) {
+	logo := &bytes.Buffer{}
+	png.Encode(logo, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(logo.Bytes())
+	}))
+	defer proxy.Close()
+
+	var doc strings.Builder
+	for i := range 50 {
+		fmt.Fprintf(&doc, `<img src="http://a.test/%d.png">`, i)
+	}
+	in := NewImageInliner(RemotePolicy{Mode: RemoteProxy, ProxyURL: proxy.URL})
+	if got := string(in.Inline([]byte(doc.String()))); strings.Count(got, "data:image/png;base64,") != 50 {
+		t.Errorf("expected 50 inlined images, report %+v", in.Report)
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	got := parseSrcset("data:image/png;base64,AA,BB 1x,  https://e.com/b.png 2x")
+	if len(got) != 2 || got[0].url != "data:image/png;base64,AA,BB" || got[1].descriptor != "2x" {
+		t.Errorf("unexpected candidates: %+v", got)
+	}
+}
@@ -0,0 +1,186 @@
+// manifest.go builds and verifies conversion manifests: an audit record
+// of the input, the converter and options used, and every output's hash.
+
+package formats
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ManifestName is the file name manifests are written under.
+const ManifestName = "manifest.json"
+
+// Versioned is implemented by converters that report their own version,
+// such as plugins; the manifest otherwise records the application's.
+type Versioned interface {
+	Version() string
+}
+
+// Manifest records which input produced which outputs.
+type Manifest struct {
+	Created   time.Time          `json:"created"`
+	Input     ManifestFile       `json:"input"`
+	Converter *ManifestConverter `json:"converter,omitempty"` // nil if the input was stored unconverted
+	Options   Options            `json:"options,omitempty"`
+	Recursive *RecursiveLimits   `json:"recursive,omitempty"` // set for recursive extraction
+	Outputs   []ManifestFile     `json:"outputs"`
+}
+
+// ManifestConverter identifies the converter that ran.
+type ManifestConverter struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ManifestFile describes one input or output file.
+type ManifestFile struct {
+	Name     string   `json:"name"`
+	Size     int      `json:"size"`
+	SHA256   string   `json:"sha256"`
+	Category Category `json:"category,omitempty"`
+	MimeType string   `json:"mimeType,omitempty"`
+}
+
+// NewManifest describes the conversion of in by c with opts. files must
+// have been through Finalize. version is reported for converters that do
+// not implement Versioned; c may be nil if nothing was converted.
+func NewManifest(in Input, c Converter, version string, opts Options, files []ConvertedFile) *Manifest {
+	sum := sha256.Sum256(in.Data)
+	m := &Manifest{
+		Created: time.Now().UTC().Truncate(time.Second),
+		Input:   ManifestFile{Name: in.Name, Size: len(in.Data), SHA256: hex.EncodeToString(sum[:])},
+		Options: opts,
+		Outputs: make([]ManifestFile, 0, len(files)),
+	}
+	if c != nil {
+		if v, ok := c.(Versioned); ok && v.Version() != "" {
+			version = v.Version()
+		}
+		m.Converter = &ManifestConverter{Name: c.Name(), Version: version}
+	}
+	for _, f := range files {
+		m.Outputs = append(m.Outputs, ManifestFile{
+			Name:     f.Name,
+			Size:     len(f.Data),
+			SHA256:   f.SHA256,
+			Category: f.Category,
+			MimeType: f.MimeType,
+		})
+	}
+	return m
+}
+
+// File returns the manifest as an indented JSON report file. Its name is
+// ManifestName unless an output already uses it.
+func (m *Manifest) File() ConvertedFile {
+	data, _ := json.MarshalIndent(m, "", "  ")
+	name := ManifestName
+	for i := 2; m.hasOutput(name); i++ {
+		name = fmt.Sprintf("manifest (%d).json", i)
+	}
+	return ConvertedFile{
+		Name:     name,
+		Data:     append(data, '\n'),
+		Category: CategoryReport,
+		MimeType: "application/json",
+	}
+}
+
+func (m *Manifest) hasOutput(name string) bool {
+	for _, o := range m.Outputs {
+		if o.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseManifest decodes a manifest written by File.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.Input.SHA256 == "" {
+		return nil, fmt.Errorf("invalid manifest: no input hash")
+	}
+	return &m, nil
+}
+
+// Results of checking a file against a manifest.
+const (
+	CheckOK      = "ok"
+	CheckMissing = "missing"
+	CheckChanged = "changed"
+	CheckExtra   = "extra" // in the directory but not the manifest
+)
+
+// ManifestCheck is the result of checking one file.
+type ManifestCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Verify checks every output listed in m against the files in dir and
+// lists files in dir that the manifest does not mention; skip names files
+// to leave out of that list, such as the manifest itself.
+func (m *Manifest) Verify(dir string, skip ...string) ([]ManifestCheck, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool)
+	for _, s := range skip {
+		listed[s] = true
+	}
+
+	var checks []ManifestCheck
+	for _, o := range m.Outputs {
+		listed[o.Name] = true
+		if o.Name == "" || filepath.Base(o.Name) != o.Name {
+			checks = append(checks, ManifestCheck{o.Name, CheckChanged, "not a plain file name"})
+			continue
+		}
+		checks = append(checks, CheckFile(filepath.Join(dir, o.Name), o))
+	}
+
+	var extra []string
+	for _, e := range entries {
+		if !e.IsDir() && !listed[e.Name()] {
+			extra = append(extra, e.Name())
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		checks = append(checks, ManifestCheck{Name: name, Status: CheckExtra})
+	}
+	return checks, nil
+}
+
+// CheckFile compares the file at path with its manifest entry.
+func CheckFile(path string, want ManifestFile) ManifestCheck {
+	c := ManifestCheck{Name: want.Name, Status: CheckOK}
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		c.Status = CheckMissing
+	case err != nil:
+		c.Status, c.Detail = CheckMissing, err.Error()
+	case len(data) != want.Size:
+		c.Status, c.Detail = CheckChanged, fmt.Sprintf("size %d, expected %d", len(data), want.Size)
+	default:
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != want.SHA256 {
+			c.Status, c.Detail = CheckChanged, "SHA-256 "+got+", expected "+want.SHA256
+		}
+	}
+	return c
+}
@@ -0,0 +1,66 @@
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type versionedConverter struct{ plainConverter }
+
+func (versionedConverter) Version() string { return "2.1" }
+
+func TestManifest(t *testing.T) {
+	files := Finalize([]ConvertedFile{
+		{Name: "a.txt", Data: []byte("alpha"), Category: CategoryAttachment},
+		{Name: "manifest.json", Data: []byte("{}"), Category: CategoryAttachment},
+	}, &plainConverter{}, "in.dat")
+	in := Input{Name: "in.dat", Data: []byte("input")}
+
+	m := NewManifest(in, &plainConverter{}, "1.0.0", Options{"mode": "x"}, files)
+	if m.Converter.Name != "plain" || m.Converter.Version != "1.0.0" || len(m.Outputs) != 2 || m.Input.Size != 5 {
+		t.Fatalf("manifest = %+v", m)
+	}
+	if v := NewManifest(in, &versionedConverter{}, "1.0.0", nil, nil); v.Converter.Version != "2.1" {
+		t.Errorf("converter version = %s", v.Converter.Version)
+	}
+
+	// The manifest does not overwrite an output of the same name.
+	mf := m.File()
+	if mf.Name != "manifest (2).json" {
+		t.Errorf("manifest written as %s", mf.Name)
+	}
+	parsed, err := ParseManifest(mf.Data)
+	if err != nil || parsed.Input.SHA256 != m.Input.SHA256 || parsed.Options["mode"] != "x" {
+		t.Fatalf("round trip: %+v, %v", parsed, err)
+	}
+
+	dir := t.TempDir()
+	for _, f := range append(files, mf) {
+		os.WriteFile(filepath.Join(dir, f.Name), f.Data, 0o644)
+	}
+	os.WriteFile(filepath.Join(dir, "stray.txt"), nil, 0o644)
+	checks, err := parsed.Verify(dir, mf.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.txt ok", "manifest.json ok", "stray.txt extra"}
+	if len(checks) != len(want) {
+		t.Fatalf("checks = %+v", checks)
+	}
+	for i, c := range checks {
+		if c.Name+" "+c.Status != want[i] {
+			t.Errorf("check %d = %+v, want %s", i, c, want[i])
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alphA"), 0o644)
+	os.Remove(filepath.Join(dir, "manifest.json"))
+	checks, _ = parsed.Verify(dir, mf.Name)
+	if checks[0].Status != CheckChanged || checks[1].Status != CheckMissing {
+		t.Errorf("tampered checks = %+v", checks)
+	}
+	if c := CheckFile(filepath.Join(dir, "nope"), m.Input); c.Status != CheckMissing {
+		t.Errorf("missing input = %+v", c)
+	}
+}
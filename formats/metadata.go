@@ -0,0 +1,119 @@
+// metadata.go defines the descriptive fields of a ConvertedFile (its
+// category, MIME type, digest and origin) and fills in the ones a
+// converter leaves unset.
+
+package formats
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Category says what part of the input a converted file represents.
+type Category string
+
+const (
+	CategoryBody       Category = "body"       // message or document body, or a converted document
+	CategoryAttachment Category = "attachment" // a file packed inside the input
+	CategoryInline     Category = "inline"     // an attachment the body displays, such as a cid: image
+	CategoryMetadata   Category = "metadata"   // a description of the input's structure or properties
+	CategoryReport     Category = "report"     // a note about the conversion itself
+)
+
+// Source records where a converted file came from.
+type Source struct {
+	// Converter is the name of the converter that produced the file.
+	Converter string `json:"converter"`
+
+	// Parent is the container the file came out of: the input's name, or
+	// "a.zip > b.dat" for nested containers.
+	Parent string `json:"parent,omitempty"`
+
+	// Path is the file's original name or path inside Parent, when it
+	// differs from the output name (an archive member's directory path,
+	// an attachment's long filename).
+	Path string `json:"path,omitempty"`
+}
+
+// mimeTypes maps the extensions converters commonly produce to MIME
+// types, so the result does not depend on the host's mime.types.
+var mimeTypes = map[string]string{
+	".html": "text/html; charset=utf-8",
+	".htm":  "text/html; charset=utf-8",
+	".txt":  "text/plain; charset=utf-8",
+	".csv":  "text/csv; charset=utf-8",
+	".json": "application/json",
+	".xml":  "application/xml",
+	".rtf":  "application/rtf",
+	".pdf":  "application/pdf",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".bmp":  "image/bmp",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".ico":  "image/x-icon",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".eml":  "message/rfc822",
+	".msg":  "application/vnd.ms-outlook",
+	".dat":  "application/ms-tnef",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".tar":  "application/x-tar",
+	".7z":   "application/x-7z-compressed",
+}
+
+// MimeTypeOf returns the MIME type of a file, from its extension when it
+// is known and otherwise by sniffing data.
+func MimeTypeOf(name string, data []byte) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if t, ok := mimeTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
+
+// IsHTMLType reports whether the MIME type t is HTML.
+func IsHTMLType(t string) bool {
+	media, _, _ := mime.ParseMediaType(t)
+	return media == "text/html" || media == "application/xhtml+xml"
+}
+
+// Finalize fills in the metadata a converter leaves unset. MimeType is
+// derived from the name and content when empty, malformed or the generic
+// application/octet-stream; Source defaults to converter c and the input
+// named input. SHA256 is always recomputed, so call Finalize after
+// anything that rewrites Data, such as SanitizeFiles. c may be nil for
+// files that were not converted.
+func Finalize(files []ConvertedFile, c Converter, input string) []ConvertedFile {
+	for i := range files {
+		f := &files[i]
+		if media, _, err := mime.ParseMediaType(f.MimeType); err != nil || media == "application/octet-stream" {
+			f.MimeType = MimeTypeOf(f.Name, f.Data)
+		}
+		if f.Source.Converter == "" && c != nil {
+			f.Source.Converter = c.Name()
+		}
+		if f.Source.Parent == "" {
+			f.Source.Parent = input
+		}
+		sum := sha256.Sum256(f.Data)
+		f.SHA256 = hex.EncodeToString(sum[:])
+	}
+	return files
+}
@@ -0,0 +1,32 @@
+package formats
+
+import "testing"
+
+func TestFinalize(t *testing.T) {
+	files := Finalize([]ConvertedFile{
+		{Name: "body.html", Data: []byte("<p>hi</p>")},
+		{Name: "scan", Data: []byte("\x89PNG\r\n\x1a\n"), MimeType: "application/octet-stream"},
+		{Name: "notes.txt", Data: []byte("x"), MimeType: "text/x-declared",
+			Source: Source{Converter: "Inner", Parent: "a.zip > b.dat"}},
+		{Name: "bad", Data: nil, MimeType: "text/html\r\nX-Injected: 1"},
+	}, &plainConverter{}, "input.dat")
+
+	want := []struct{ mime, converter, parent string }{
+		{"text/html; charset=utf-8", "plain", "input.dat"},
+		{"image/png", "plain", "input.dat"},
+		{"text/x-declared", "Inner", "a.zip > b.dat"},
+		{"text/plain; charset=utf-8", "plain", "input.dat"},
+	}
+	for i, w := range want {
+		f := files[i]
+		if f.MimeType != w.mime || f.Source.Converter != w.converter || f.Source.Parent != w.parent {
+			t.Errorf("%s: mime %q, source %+v", f.Name, f.MimeType, f.Source)
+		}
+	}
+	if files[3].SHA256 != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("SHA256 of empty data = %s", files[3].SHA256)
+	}
+	if !IsHTMLType(files[0].MimeType) || IsHTMLType(files[3].MimeType) {
+		t.Error("IsHTMLType")
+	}
+}
@@ -0,0 +1,236 @@
+// options.go defines the context-aware converter interface and the typed
+// option schemas that CLI flags and HTTP form fields are derived from.
+
+package formats
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OptionType is the value type of a converter option.
+type OptionType string
+
+// Option value types.
+const (
+	OptionString OptionType = "string"
+	OptionInt    OptionType = "int"
+	OptionBool   OptionType = "bool"
+	OptionEnum   OptionType = "enum" // one of Option.Choices
+)
+
+// Option describes one setting a converter accepts. Name is used verbatim
+// as the HTTP form field and, with a "--" prefix, as the CLI flag, so it
+// should be lower-case and hyphenated (e.g. "template", "output-format").
+type Option struct {
+	Name        string     `json:"name"`
+	Type        OptionType `json:"type"`
+	Description string     `json:"description"`
+	Default     string     `json:"default,omitempty"`
+	Choices     []string   `json:"choices,omitempty"` // allowed values for OptionEnum
+	Min         int        `json:"min,omitempty"`     // bounds for OptionInt, ignored when Min == Max
+	Max         int        `json:"max,omitempty"`
+	Required    bool       `json:"required,omitempty"`
+}
+
+// Options holds validated option values keyed by Option.Name, as produced
+// by ParseOptions. Values are stored in their string form; use the typed
+// accessors to read them.
+type Options map[string]string
+
+// String returns the value of the named option, or "" if unset.
+func (o Options) String(name string) string {
+	return o[name]
+}
+
+// Int returns the value of the named option as an int, or 0 if unset.
+func (o Options) Int(name string) int {
+	n, _ := strconv.Atoi(o[name])
+	return n
+}
+
+// Bool reports whether the named option is set to true.
+func (o Options) Bool(name string) bool {
+	b, _ := strconv.ParseBool(o[name])
+	return b
+}
+
+// Input is the file handed to a converter.
+type Input struct {
+	Name string // original filename, without directories; may be empty
+	Data []byte
+
+	// Prefix is prepended to every output name by the caller, as nested
+	// containers' outputs are named "outer.zip_inner.txt". Converters
+	// whose outputs refer to each other by name, such as a rendered
+	// message linking its attachments, include it in those references.
+	Prefix string
+}
+
+// ContextConverter is a Converter that accepts options and a context.
+// Converters implement it when they have settings, do slow work (network
+// fetches, subprocesses) that should stop when the caller goes away, or
+// need the input filename. Convert remains for callers that have neither
+// options nor a context.
+type ContextConverter interface {
+	Converter
+
+	// Options returns the schema of the settings ConvertContext accepts.
+	Options() []Option
+
+	// ConvertContext converts in using opts, which the caller has
+	// validated against Options. It should return ctx.Err() promptly
+	// once ctx is done.
+	ConvertContext(ctx context.Context, in Input, opts Options) ([]ConvertedFile, error)
+}
+
+// Adapt returns c as a ContextConverter. Converters that only implement
+// Converter are wrapped: they take no options and check ctx before and
+// after the conversion, since Convert itself cannot be interrupted.
+func Adapt(c Converter) ContextConverter {
+	if cc, ok := c.(ContextConverter); ok {
+		return cc
+	}
+	return legacyConverter{c}
+}
+
+// legacyConverter adapts a plain Converter to ContextConverter.
+type legacyConverter struct {
+	Converter
+}
+
+func (l legacyConverter) Options() []Option { return nil }
+
+func (l legacyConverter) ConvertContext(ctx context.Context, in Input, _ Options) ([]ConvertedFile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	files, err := l.Convert(in.Data)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// OptionsFor returns the option schema of c, or nil if it has none.
+func OptionsFor(c Converter) []Option {
+	return Adapt(c).Options()
+}
+
+// ConvertContext validates raw against c's option schema and runs the
+// conversion. raw maps option names to their unparsed values, as read from
+// CLI flags or form fields; unknown names are rejected.
+func ConvertContext(ctx context.Context, c Converter, in Input, raw map[string]string) ([]ConvertedFile, error) {
+	cc := Adapt(c)
+	opts, err := ParseOptions(cc.Options(), raw)
+	if err != nil {
+		return nil, err
+	}
+	return cc.ConvertContext(ctx, in, opts)
+}
+
+// ParseOptions validates raw values against schema and returns them with
+// defaults applied. Bool values accept anything strconv.ParseBool does and
+// an empty string, which means true (a bare CLI flag or checkbox).
+func ParseOptions(schema []Option, raw map[string]string) (Options, error) {
+	for name := range raw {
+		if findOption(schema, name) == nil {
+			return nil, fmt.Errorf("unknown option %q", name)
+		}
+	}
+	opts := make(Options, len(schema))
+	for _, spec := range schema {
+		v, ok := raw[spec.Name]
+		if !ok {
+			if spec.Required {
+				return nil, fmt.Errorf("option %q is required", spec.Name)
+			}
+			if spec.Default != "" {
+				opts[spec.Name] = spec.Default
+			}
+			continue
+		}
+		v, err := spec.parse(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		opts[spec.Name] = v
+	}
+	return opts, nil
+}
+
+// parse validates one value and returns its canonical form.
+func (o Option) parse(v string) (string, error) {
+	switch o.Type {
+	case OptionInt:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return "", fmt.Errorf("option %q: %q is not an integer", o.Name, v)
+		}
+		if o.Min != o.Max && (n < o.Min || n > o.Max) {
+			return "", fmt.Errorf("option %q: %d is outside %d-%d", o.Name, n, o.Min, o.Max)
+		}
+		return strconv.Itoa(n), nil
+	case OptionBool:
+		if v == "" || strings.EqualFold(v, "on") {
+			return "true", nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", fmt.Errorf("option %q: %q is not true or false", o.Name, v)
+		}
+		return strconv.FormatBool(b), nil
+	case OptionEnum:
+		for _, c := range o.Choices {
+			if strings.EqualFold(v, c) {
+				return c, nil
+			}
+		}
+		return "", fmt.Errorf("option %q: %q is not one of %s", o.Name, v, strings.Join(o.Choices, ", "))
+	}
+	if v == "" && o.Required {
+		return "", fmt.Errorf("option %q is required", o.Name)
+	}
+	return v, nil
+}
+
+// findOption returns the option named name, or nil.
+func findOption(schema []Option, name string) *Option {
+	i := slices.IndexFunc(schema, func(o Option) bool { return o.Name == name })
+	if i < 0 {
+		return nil
+	}
+	return &schema[i]
+}
+
+// SplitOptionArgs separates "--name value", "--name=value" and bare
+// "--name" (bool) flags that appear in schema from the other arguments.
+// Flags not in the schema are returned untouched in rest so callers can
+// report them.
+func SplitOptionArgs(schema []Option, args []string) (raw map[string]string, rest []string, err error) {
+	raw = make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		spec := findOption(schema, name)
+		if !strings.HasPrefix(arg, "--") || spec == nil {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue && spec.Type != OptionBool {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("--%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		raw[name] = value
+	}
+	return raw, rest, nil
+}
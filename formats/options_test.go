@@ -0,0 +1,99 @@
+package formats
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var testSchema = []Option{
+	{Name: "template", Type: OptionEnum, Choices: []string{"ACH", "Wire"}},
+	{Name: "quality", Type: OptionInt, Default: "90", Min: 1, Max: 100},
+	{Name: "preview", Type: OptionBool, Default: "true"},
+	{Name: "to", Type: OptionString},
+}
+
+func TestParseOptions(t *testing.T) {
+	opts, err := ParseOptions(testSchema, map[string]string{"template": "wire", "preview": "false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Options{"template": "Wire", "quality": "90", "preview": "false"}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("ParseOptions = %v, want %v", opts, want)
+	}
+	if opts.Int("quality") != 90 || opts.Bool("preview") || opts.String("to") != "" {
+		t.Errorf("typed accessors: quality=%d preview=%v to=%q", opts.Int("quality"), opts.Bool("preview"), opts.String("to"))
+	}
+
+	for _, raw := range []map[string]string{
+		{"template": "BeanStream"},
+		{"quality": "high"},
+		{"quality": "0"},
+		{"preview": "maybe"},
+		{"colour": "red"},
+	} {
+		if _, err := ParseOptions(testSchema, raw); err == nil {
+			t.Errorf("ParseOptions(%v) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestSplitOptionArgs(t *testing.T) {
+	raw, rest, err := SplitOptionArgs(testSchema, []string{
+		"out", "--template", "ACH", "--preview", "--quality=75", "--unknown",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"template": "ACH", "preview": "", "quality": "75"}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("raw = %v, want %v", raw, want)
+	}
+	if !reflect.DeepEqual(rest, []string{"out", "--unknown"}) {
+		t.Errorf("rest = %v", rest)
+	}
+	opts, err := ParseOptions(testSchema, raw)
+	if err != nil || !opts.Bool("preview") {
+		t.Errorf("bare bool flag: opts=%v err=%v", opts, err)
+	}
+
+	if _, _, err := SplitOptionArgs(testSchema, []string{"--to"}); err == nil {
+		t.Error("missing value accepted")
+	}
+}
+
+type plainConverter struct{ calls int }
+
+func (p *plainConverter) Name() string           { return "plain" }
+func (p *plainConverter) Extensions() []string   { return []string{".plain"} }
+func (p *plainConverter) Match(data []byte) bool { return false }
+func (p *plainConverter) Convert(data []byte) ([]ConvertedFile, error) {
+	p.calls++
+	return []ConvertedFile{{Name: "out.txt", Data: data, Category: "body"}}, nil
+}
+
+func TestAdaptLegacyConverter(t *testing.T) {
+	p := &plainConverter{}
+	if opts := OptionsFor(p); opts != nil {
+		t.Errorf("OptionsFor = %v, want nil", opts)
+	}
+
+	files, err := ConvertContext(context.Background(), p, Input{Data: []byte("x")}, nil)
+	if err != nil || len(files) != 1 || string(files[0].Data) != "x" {
+		t.Fatalf("ConvertContext = %v, %v", files, err)
+	}
+	if _, err := ConvertContext(context.Background(), p, Input{}, map[string]string{"to": "pdf"}); err == nil {
+		t.Error("option accepted by converter without options")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ConvertContext(ctx, p, Input{}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled ConvertContext err = %v", err)
+	}
+	if p.calls != 1 {
+		t.Errorf("Convert called %d times, want 1", p.calls)
+	}
+}
@@ -0,0 +1,238 @@
+// convert.go adapts loaded plugins to the formats and fileconvert
+// converter interfaces and decodes their JSON or multipart output.
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lgican/File-Converter/formats"
+	"github.com/lgican/File-Converter/parsers/fileconvert"
+)
+
+// converter is a plugin registered with the formats registry.
+type converter struct{ p *Plugin }
+
+func (c *converter) Name() string { return c.p.Handshake.Name }
+
+func (c *converter) Extensions() []string { return c.p.Handshake.Extensions }
+
+func (c *converter) Version() string { return c.p.Handshake.Version }
+
+func (c *converter) Options() []formats.Option { return c.p.Handshake.Options }
+
+// Match reports whether data starts with any of the declared signatures.
+func (c *converter) Match(data []byte) bool {
+	for _, m := range c.p.Handshake.Magic {
+		end := m.Offset + len(m.bytes)
+		if end <= len(data) && bytes.Equal(data[m.Offset:end], m.bytes) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *converter) Convert(data []byte) ([]formats.ConvertedFile, error) {
+	opts, err := formats.ParseOptions(c.Options(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.ConvertContext(context.Background(), formats.Input{Data: data}, opts)
+}
+
+// ConvertContext runs "<plugin> convert" with the input on stdin.
+func (c *converter) ConvertContext(ctx context.Context, in formats.Input, opts formats.Options) ([]formats.ConvertedFile, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.p.cfg.Timeout)
+	defer cancel()
+	// The name is attached to its flag, so that a name such as "-x" is
+	// not read as a flag of its own
+	args := append([]string{"convert", "--name=" + in.Name}, optionArgs(opts)...)
+	out, err := c.p.run(ctx, in.Data, args...)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", c.Name(), err)
+	}
+	files, err := decodeOutput(out)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", c.Name(), err)
+	}
+	return files, nil
+}
+
+// containerConverter is a plugin that unpacks its input, so recursive
+// extraction expands what it finds inside other containers.
+type containerConverter struct{ converter }
+
+func (c *containerConverter) Unpacks() bool { return true }
+
+// jsonOutput is the JSON form of a plugin's convert output.
+type jsonOutput struct {
+	Files []struct {
+		Name      string    `json:"name"`
+		Category  string    `json:"category"`
+		Data      []byte    `json:"data"`
+		MimeType  string    `json:"mimeType"`
+		ModTime   time.Time `json:"modTime"`
+		ContentID string    `json:"contentId"`
+		Path      string    `json:"path"`
+	} `json:"files"`
+	Error string `json:"error"`
+}
+
+// decodeOutput parses convert output: JSON if it starts with "{",
+// otherwise a multipart stream opened by its boundary line.
+func decodeOutput(out []byte) ([]formats.ConvertedFile, error) {
+	trimmed := bytes.TrimLeft(out, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return decodeJSON(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("--")):
+		return decodeMultipart(trimmed)
+	case len(trimmed) == 0:
+		return nil, nil
+	}
+	return nil, errors.New("output is neither JSON nor multipart")
+}
+
+func decodeJSON(out []byte) ([]formats.ConvertedFile, error) {
+	var o jsonOutput
+	if err := json.Unmarshal(out, &o); err != nil {
+		return nil, fmt.Errorf("invalid JSON output: %w", err)
+	}
+	if o.Error != "" {
+		return nil, errors.New(o.Error)
+	}
+	files := make([]formats.ConvertedFile, 0, len(o.Files))
+	for i, f := range o.Files {
+		cat, err := parseCategory(f.Category)
+		if err != nil {
+			return nil, fmt.Errorf("file %d: %w", i, err)
+		}
+		files = append(files, formats.ConvertedFile{
+			Name:      formats.SanitizeFilename(f.Name),
+			Data:      f.Data,
+			Category:  cat,
+			MimeType:  f.MimeType,
+			ModTime:   f.ModTime,
+			ContentID: strings.Trim(f.ContentID, "<>"),
+			Source:    formats.Source{Path: f.Path},
+		})
+	}
+	return files, nil
+}
+
+func decodeMultipart(out []byte) ([]formats.ConvertedFile, error) {
+	line, _, _ := bufio.NewReader(bytes.NewReader(out)).ReadLine()
+	boundary := strings.TrimSpace(strings.TrimPrefix(string(line), "--"))
+	if boundary == "" {
+		return nil, errors.New("multipart output has no boundary")
+	}
+	mr := multipart.NewReader(bytes.NewReader(out), boundary)
+	var files []formats.ConvertedFile
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart output: %w", err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart output: %w", err)
+		}
+		name := part.FileName()
+		if name == "" {
+			return nil, fmt.Errorf("part %d has no filename", len(files)+1)
+		}
+		cat, err := parseCategory(part.Header.Get("X-Category"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		f := formats.ConvertedFile{
+			Name:      formats.SanitizeFilename(name),
+			Data:      data,
+			Category:  cat,
+			MimeType:  part.Header.Get("Content-Type"),
+			ContentID: strings.Trim(part.Header.Get("Content-ID"), "<>"),
+		}
+		if lm := part.Header.Get("Last-Modified"); lm != "" {
+			f.ModTime, _ = http.ParseTime(lm)
+		}
+		files = append(files, f)
+	}
+}
+
+// parseCategory validates a plugin's category; empty means attachment.
+func parseCategory(s string) (formats.Category, error) {
+	switch c := formats.Category(strings.ToLower(s)); c {
+	case "":
+		return formats.CategoryAttachment, nil
+	case formats.CategoryBody, formats.CategoryAttachment, formats.CategoryInline,
+		formats.CategoryMetadata, formats.CategoryReport:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown category %q", s)
+}
+
+// fileConverter is a plugin registered with the fileconvert registry.
+type fileConverter struct{ p *Plugin }
+
+func (c *fileConverter) Name() string { return c.p.Handshake.Name }
+
+func (c *fileConverter) SupportedFormats() []fileconvert.Format {
+	var out []fileconvert.Format
+	for _, f := range c.p.Handshake.Formats {
+		out = append(out, fileconvert.Format{
+			Extension:  f.Extension,
+			MimeType:   f.MimeType,
+			Category:   fileconvert.FormatCategory(f.Category),
+			Name:       f.Name,
+			CanConvert: f.CanConvert,
+		})
+	}
+	return out
+}
+
+func (c *fileConverter) CanConvert(from, to string) bool {
+	from, to = normalizeExt(from), normalizeExt(to)
+	for _, f := range c.p.Handshake.Formats {
+		if f.Extension != from {
+			continue
+		}
+		for _, t := range f.CanConvert {
+			if t == to {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Convert runs "<plugin> fileconvert" and returns its stdout as the
+// converted file.
+func (c *fileConverter) Convert(req fileconvert.ConversionRequest) (*fileconvert.ConversionResult, error) {
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.p.cfg.Timeout)
+	defer cancel()
+	to := normalizeExt(req.ToFormat)
+	out, err := c.p.run(ctx, req.Data, "fileconvert",
+		"--from", normalizeExt(req.FromFormat), "--to", to, "--quality", strconv.Itoa(req.Quality))
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", c.Name(), err)
+	}
+	return &fileconvert.ConversionResult{Data: out, MimeType: formats.MimeTypeOf(to, out)}, nil
+}
@@ -0,0 +1,377 @@
+// Package plugin loads external converters: executables in a plugin
+// directory that describe themselves through a JSON handshake and convert
+// files piped to them on stdin. Each plugin is registered as a normal
+// formats.Converter, a fileconvert.Converter, or both, so detection, the
+// CLI and the web server use it like a built-in format.
+//
+// # Protocol (version 1)
+//
+// The handshake runs "<plugin> handshake" and reads a JSON object from
+// stdout:
+//
+//	{
+//	  "protocol": 1,
+//	  "name": "Acme Ledger",
+//	  "version": "1.4.0",
+//	  "extensions": [".ldg"],
+//	  "magic": [{"offset": 0, "text": "LDG1"}, {"offset": 4, "hex": "00ff"}],
+//	  "options": [{"name": "currency", "type": "string", "description": "..."}],
+//	  "unpacks": false,
+//	  "formats": [{"extension": ".ldg", "name": "Ledger", "category": "document",
+//	               "mimeType": "application/x-ledger", "canConvert": [".csv"]}]
+//	}
+//
+// A plugin with extensions or magic is a file converter: "<plugin> convert
+// --name=<input name> [--option name=value]..." gets the input on stdin
+// and writes its outputs to stdout either as JSON,
+//
+//	{"files": [{"name": "a.txt", "category": "body", "data": "<base64>",
+//	            "mimeType": "text/plain", "modTime": "2024-01-02T15:04:05Z",
+//	            "contentId": "", "path": ""}],
+//	 "error": ""}
+//
+// or as a MIME multipart stream whose first line is the boundary
+// ("--frontier"), one part per file with a Content-Disposition filename
+// and optional Content-Type, Content-ID, Last-Modified and X-Category
+// headers. A plugin with formats is a format-to-format converter:
+// "<plugin> fileconvert --from .ldg --to .csv --quality 90" gets the input
+// on stdin and writes the converted file to stdout as-is.
+//
+// A non-zero exit status fails the conversion with the plugin's stderr as
+// the message. Every invocation is killed when it exceeds its timeout or
+// writes more than the output limit.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lgican/File-Converter/formats"
+	"github.com/lgican/File-Converter/parsers/fileconvert"
+)
+
+// ProtocolVersion is the handshake protocol this package speaks.
+const ProtocolVersion = 1
+
+// Defaults for Config fields left zero.
+const (
+	DefaultHandshakeTimeout = 5 * time.Second
+	DefaultTimeout          = 2 * time.Minute
+	DefaultMaxOutput        = 256 << 20
+)
+
+// maxStderr is how much of a plugin's stderr is kept for error messages.
+const maxStderr = 4 << 10
+
+// Config says where plugins live and how far they are trusted.
+type Config struct {
+	Dir              string
+	HandshakeTimeout time.Duration
+	Timeout          time.Duration // per conversion
+	MaxOutput        int64         // bytes of stdout per invocation
+}
+
+func (c Config) withDefaults() Config {
+	if c.HandshakeTimeout <= 0 {
+		c.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.MaxOutput <= 0 {
+		c.MaxOutput = DefaultMaxOutput
+	}
+	return c
+}
+
+// Handshake is a plugin's self-description.
+type Handshake struct {
+	Protocol   int              `json:"protocol"`
+	Name       string           `json:"name"`
+	Version    string           `json:"version"`
+	Extensions []string         `json:"extensions"`
+	Magic      []Magic          `json:"magic"`
+	Options    []formats.Option `json:"options"`
+	Unpacks    bool             `json:"unpacks"`
+	Formats    []Format         `json:"formats"`
+}
+
+// Magic is a byte signature at a fixed offset, given as hex or text.
+type Magic struct {
+	Offset int    `json:"offset"`
+	Hex    string `json:"hex,omitempty"`
+	Text   string `json:"text,omitempty"`
+
+	bytes []byte
+}
+
+// Format is a fileconvert format the plugin converts from.
+type Format struct {
+	Extension  string   `json:"extension"`
+	Name       string   `json:"name"`
+	Category   string   `json:"category"`
+	MimeType   string   `json:"mimeType"`
+	CanConvert []string `json:"canConvert"`
+}
+
+// Plugin is a loaded plugin executable.
+type Plugin struct {
+	Path      string
+	Handshake Handshake
+	cfg       Config
+}
+
+// Load runs the handshake of every executable in cfg.Dir and registers
+// the plugins that pass it. Plugins that fail are skipped and reported in
+// the returned error; the others stay registered.
+func Load(cfg Config) ([]*Plugin, error) {
+	cfg = cfg.withDefaults()
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var loaded []*Plugin
+	var errs []error
+	for _, e := range entries {
+		path := filepath.Join(cfg.Dir, e.Name())
+		if !isExecutable(e) {
+			continue
+		}
+		p, err := Open(path, cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+			continue
+		}
+		if err := p.register(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+			continue
+		}
+		loaded = append(loaded, p)
+	}
+	return loaded, errors.Join(errs...)
+}
+
+// Open runs the handshake of the executable at path and validates it,
+// without registering the plugin.
+func Open(path string, cfg Config) (*Plugin, error) {
+	cfg = cfg.withDefaults()
+	p := &Plugin{Path: path, cfg: cfg}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HandshakeTimeout)
+	defer cancel()
+	out, err := p.run(ctx, nil, "handshake")
+	if err != nil {
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	if err := json.Unmarshal(out, &p.Handshake); err != nil {
+		return nil, fmt.Errorf("handshake: invalid JSON: %w", err)
+	}
+	if err := p.Handshake.validate(); err != nil {
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	return p, nil
+}
+
+// validate checks the handshake and decodes its magic signatures.
+func (h *Handshake) validate() error {
+	if h.Protocol != ProtocolVersion {
+		return fmt.Errorf("protocol %d not supported (want %d)", h.Protocol, ProtocolVersion)
+	}
+	if strings.TrimSpace(h.Name) == "" {
+		return errors.New("no name")
+	}
+	if len(h.Extensions) == 0 && len(h.Magic) == 0 && len(h.Formats) == 0 {
+		return errors.New("declares no extensions, magic or formats")
+	}
+	for i, ext := range h.Extensions {
+		h.Extensions[i] = normalizeExt(ext)
+	}
+	for i := range h.Magic {
+		m := &h.Magic[i]
+		switch {
+		case m.Hex != "":
+			b, err := hex.DecodeString(m.Hex)
+			if err != nil {
+				return fmt.Errorf("magic %d: %w", i, err)
+			}
+			m.bytes = b
+		case m.Text != "":
+			m.bytes = []byte(m.Text)
+		default:
+			return fmt.Errorf("magic %d: no hex or text", i)
+		}
+		if m.Offset < 0 {
+			return fmt.Errorf("magic %d: negative offset", i)
+		}
+	}
+	for _, o := range h.Options {
+		switch o.Type {
+		case formats.OptionString, formats.OptionInt, formats.OptionBool, formats.OptionEnum:
+		default:
+			return fmt.Errorf("option %q: unknown type %q", o.Name, o.Type)
+		}
+		if o.Name == "" || strings.HasPrefix(o.Name, "-") {
+			return fmt.Errorf("option %q: invalid name", o.Name)
+		}
+		if o.Default != "" {
+			if _, err := formats.ParseOptions([]formats.Option{o}, map[string]string{o.Name: o.Default}); err != nil {
+				return fmt.Errorf("default: %w", err)
+			}
+		}
+	}
+	for i := range h.Formats {
+		f := &h.Formats[i]
+		f.Extension = normalizeExt(f.Extension)
+		for j, to := range f.CanConvert {
+			f.CanConvert[j] = normalizeExt(to)
+		}
+		switch fileconvert.FormatCategory(f.Category) {
+		case "":
+			f.Category = string(fileconvert.CategoryDocument)
+		case fileconvert.CategoryImage, fileconvert.CategoryAudio, fileconvert.CategoryVideo, fileconvert.CategoryDocument:
+		default:
+			return fmt.Errorf("format %d: unknown category %q", i, f.Category)
+		}
+		if f.Extension == "." || len(f.CanConvert) == 0 {
+			return fmt.Errorf("format %d: needs an extension and canConvert targets", i)
+		}
+	}
+	return nil
+}
+
+// register adds the plugin to the registries it declared itself for.
+func (p *Plugin) register() error {
+	h := p.Handshake
+	for _, c := range formats.All() {
+		if strings.EqualFold(c.Name(), h.Name) {
+			return fmt.Errorf("name %q is already registered", h.Name)
+		}
+	}
+	if len(h.Extensions) > 0 || len(h.Magic) > 0 {
+		if h.Unpacks {
+			formats.Register(&containerConverter{converter{p}})
+		} else {
+			formats.Register(&converter{p})
+		}
+	}
+	if len(h.Formats) > 0 {
+		fileconvert.Register(&fileConverter{p})
+	}
+	return nil
+}
+
+// run executes the plugin with args, feeding it stdin, and returns its
+// stdout. The process is killed when ctx ends or the output limit is
+// exceeded.
+func (p *Plugin) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.Path, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout := &limitWriter{max: p.cfg.MaxOutput, cancel: cancel}
+	stderr := &tailWriter{max: maxStderr}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	switch {
+	case stdout.exceeded:
+		return nil, fmt.Errorf("output exceeds %d bytes", p.cfg.MaxOutput)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, errors.New("timed out")
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// limitWriter buffers up to max bytes and cancels the process beyond.
+type limitWriter struct {
+	buf      bytes.Buffer
+	max      int64
+	cancel   context.CancelFunc
+	exceeded bool
+}
+
+func (w *limitWriter) Write(b []byte) (int, error) {
+	if int64(w.buf.Len()+len(b)) > w.max {
+		w.exceeded = true
+		w.cancel()
+		return 0, errors.New("output limit exceeded")
+	}
+	return w.buf.Write(b)
+}
+
+// tailWriter keeps the last max bytes written.
+type tailWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *tailWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	if len(w.buf) > w.max {
+		w.buf = w.buf[len(w.buf)-w.max:]
+	}
+	return len(b), nil
+}
+
+func (w *tailWriter) String() string { return string(w.buf) }
+
+// isExecutable reports whether a directory entry looks like a plugin:
+// a regular, non-hidden file that is executable (on Windows, an .exe,
+// .bat or .cmd file).
+func isExecutable(e os.DirEntry) bool {
+	if strings.HasPrefix(e.Name(), ".") {
+		return false
+	}
+	info, err := e.Info()
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+// normalizeExt lower-cases ext and ensures a leading dot.
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// optionArgs renders options as sorted --option name=value arguments.
+func optionArgs(opts formats.Options) []string {
+	names := make([]string, 0, len(opts))
+	for name := range opts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var args []string
+	for _, name := range names {
+		args = append(args, "--option", name+"="+opts[name])
+	}
+	return args
+}
@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lgican/File-Converter/formats"
+	"github.com/lgican/File-Converter/parsers/fileconvert"
+)
+
+const handshake = `{"protocol": 1, "name": "%s", "version": "0.3", "extensions": ["LDG"],
+  "magic": [{"offset": 0, "text": "LDG1"}],
+  "options": [{"name": "currency", "type": "string", "default": "USD", "description": "Currency"}]%s}`
+
+// writePlugin writes a shell script plugin that answers the handshake with
+// hs and runs convert for any other command.
+func writePlugin(t *testing.T, dir, name, hs, convert string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts need a POSIX shell")
+	}
+	script := "#!/bin/sh\nif [ \"$1\" = handshake ]; then\ncat <<'EOF'\n" + hs + "\nEOF\nexit 0\nfi\n" + convert + "\n"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func hs(name, extra string) string {
+	return strings.Replace(strings.Replace(handshake, "%s", name, 1), "%s", extra, 1)
+}
+
+func TestConvertJSONAndMultipart(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := writePlugin(t, dir, "json", hs("JSON Ledger", ""),
+		`cat >/dev/null
+printf '{"files": [{"name": "out/a.txt", "category": "body", "data": "aGVsbG8=", "path": "%s", "modTime": "2024-01-02T15:04:05Z"}]}' "$*"`)
+	p, err := Open(jsonPath, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &converter{p}
+	if !c.Match([]byte("LDG1 rest")) || c.Match([]byte("LDG")) || c.Extensions()[0] != ".ldg" {
+		t.Error("handshake signature or extension not applied")
+	}
+	files, err := formats.ConvertContext(context.Background(), c, formats.Input{Name: "-x.ldg", Data: []byte("LDG1")}, map[string]string{"currency": "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "out_a.txt" || string(files[0].Data) != "hello" || files[0].Category != formats.CategoryBody {
+		t.Fatalf("JSON output = %+v", files)
+	}
+	if files[0].Source.Path != "convert --name=-x.ldg --option currency=EUR" || files[0].ModTime.Year() != 2024 {
+		t.Errorf("arguments or metadata = %q, %v", files[0].Source.Path, files[0].ModTime)
+	}
+
+	mpPath := writePlugin(t, dir, "multipart", hs("Multipart Ledger", `, "unpacks": true`), `printf -- '--XYZ\r\nContent-Disposition: attachment; filename="copy.ldg"\r\nContent-Type: application/x-ledger\r\nX-Category: inline\r\n\r\n'
+cat
+printf -- '\r\n--XYZ--\r\n'`)
+	p, err = Open(mpPath, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err = formats.ConvertContext(context.Background(), &containerConverter{converter{p}}, formats.Input{Data: []byte("LDG1 data")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "copy.ldg" || string(files[0].Data) != "LDG1 data" ||
+		files[0].Category != formats.CategoryInline || files[0].MimeType != "application/x-ledger" {
+		t.Errorf("multipart output = %+v", files)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	dir := t.TempDir()
+	p, err := Open(writePlugin(t, dir, "fail", hs("Failing", ""), `echo "bad ledger header" >&2; exit 3`), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&converter{p}).Convert([]byte("LDG1")); err == nil || !strings.Contains(err.Error(), "bad ledger header") {
+		t.Errorf("exit status error = %v", err)
+	}
+
+	p, err = Open(writePlugin(t, dir, "slow", hs("Slow", ""), `exec sleep 5`), Config{Timeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := (&converter{p}).Convert(nil); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("timeout error = %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("slow plugin was not killed")
+	}
+
+	p, err = Open(writePlugin(t, dir, "big", hs("Big", ""), `head -c 4096 /dev/zero`), Config{MaxOutput: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&converter{p}).Convert(nil); err == nil || !strings.Contains(err.Error(), "exceeds 1000 bytes") {
+		t.Errorf("output limit error = %v", err)
+	}
+
+	for _, bad := range []string{
+		`{"protocol": 2, "name": "Future", "extensions": [".x"]}`,
+		`{"protocol": 1, "name": "Nothing"}`,
+		`{"protocol": 1, "name": "Bad magic", "magic": [{"hex": "zz"}]}`,
+		`not json`,
+	} {
+		if _, err := Open(writePlugin(t, dir, "bad", bad, ""), Config{}); err == nil {
+			t.Errorf("handshake %s accepted", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "ledger", `{"protocol": 1, "name": "Plugin Test Ledger", "version": "2.0",
+  "extensions": [".pltl"],
+  "formats": [{"extension": ".pltl", "name": "Ledger", "canConvert": ["csv"]}]}`, `tr a-z A-Z`)
+	writePlugin(t, dir, "broken", `{"protocol": 2}`, "")
+	os.WriteFile(filepath.Join(dir, "README.txt"), []byte("not a plugin"), 0o644)
+
+	loaded, err := Load(Config{Dir: dir})
+	if len(loaded) != 1 || err == nil || !strings.Contains(err.Error(), "broken: handshake: protocol 2") {
+		t.Fatalf("Load = %d plugins, %v", len(loaded), err)
+	}
+	c, err := formats.Lookup("plugin test ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := c.(formats.Versioned); !ok || v.Version() != "2.0" {
+		t.Error("plugin version not reported")
+	}
+
+	fc := fileconvert.GetConverter(".pltl", ".csv")
+	if fc == nil {
+		t.Fatal("fileconvert plugin not registered")
+	}
+	res, err := fc.Convert(fileconvert.ConversionRequest{Data: []byte("a,b"), FromFormat: ".pltl", ToFormat: ".csv"})
+	if err != nil || string(res.Data) != "A,B" || !strings.HasPrefix(res.MimeType, "text/csv") {
+		t.Errorf("fileconvert = %+v, %v", res, err)
+	}
+
+	// A second load of the same plugin is refused by name.
+	if _, err := Load(Config{Dir: dir}); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("duplicate load: %v", err)
+	}
+}
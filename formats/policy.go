@@ -0,0 +1,211 @@
+// policy.go defines the deployment policy: which converters and which
+// from→to conversions a deployment offers, how large their inputs may be,
+// and how far remote resource fetching may be enabled. It is loaded once
+// at startup, typically from a JSON file given with --policy.
+
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotAllowed is returned (wrapped) for conversions the policy refuses.
+var ErrNotAllowed = errors.New("not allowed by policy")
+
+// Policy enables and disables converters and conversions. The zero value
+// allows everything.
+type Policy struct {
+	// Converters lists converter names from either registry, matched
+	// case-insensitively; a trailing "*" matches a prefix.
+	Converters Rules `json:"converters"`
+
+	// Conversions lists "from>to" extension pairs, such as ".psd>*" or
+	// "*>.pdf". They apply to the File Converter's conversions.
+	Conversions Rules `json:"conversions"`
+
+	// MaxSizeMB limits input sizes in megabytes, keyed by extension
+	// (".psd"), converter name, or "*" for everything else. The most
+	// specific key wins: extension, then converter, then "*".
+	MaxSizeMB map[string]int64 `json:"maxSizeMB,omitempty"`
+
+	// RemoteImages is the most permissive remote resource mode the
+	// deployment may be started with: "block" forbids fetching, "proxy"
+	// allows only the proxy. Empty imposes no restriction.
+	RemoteImages RemoteMode `json:"remoteImages,omitempty"`
+}
+
+// Rules is an allow/deny list. Deny takes precedence; if Allow is
+// non-empty, only what it matches is allowed.
+type Rules struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// allows reports whether the rules admit s, using match to compare each
+// entry with it.
+func (r Rules) allows(match func(entry string) bool) bool {
+	for _, e := range r.Deny {
+		if match(e) {
+			return false
+		}
+	}
+	if len(r.Allow) == 0 {
+		return true
+	}
+	for _, e := range r.Allow {
+		if match(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// policy is the process-wide deployment policy.
+var policy Policy
+
+// SetPolicy replaces the process-wide policy. Call it during startup,
+// before plugins are loaded and before the remote policy is set.
+func SetPolicy(p Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	policy = p
+	return nil
+}
+
+// CurrentPolicy returns the process-wide policy.
+func CurrentPolicy() Policy {
+	return policy
+}
+
+// LoadPolicy reads a policy from a JSON file. Unknown keys are rejected
+// so that a misspelt rule does not silently allow everything.
+func LoadPolicy(path string) (Policy, error) {
+	var p Policy
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return p, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return p, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Validate reports malformed conversion pairs, sizes and remote modes.
+func (p Policy) Validate() error {
+	for _, list := range [][]string{p.Conversions.Allow, p.Conversions.Deny} {
+		for _, e := range list {
+			if _, _, err := parsePair(e); err != nil {
+				return err
+			}
+		}
+	}
+	for key, mb := range p.MaxSizeMB {
+		if mb <= 0 {
+			return fmt.Errorf("maxSizeMB %q must be positive, got %d", key, mb)
+		}
+	}
+	if p.RemoteImages != "" {
+		if _, err := ParseRemoteMode(string(p.RemoteImages)); err != nil {
+			return fmt.Errorf("remoteImages: %w", err)
+		}
+	}
+	return nil
+}
+
+// ConverterAllowed reports whether the converter called name is enabled.
+func (p Policy) ConverterAllowed(name string) bool {
+	name = strings.ToLower(name)
+	return p.Converters.allows(func(e string) bool {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if prefix, ok := strings.CutSuffix(e, "*"); ok {
+			return strings.HasPrefix(name, prefix)
+		}
+		return name == e
+	})
+}
+
+// ConversionAllowed reports whether the converter called name may convert
+// from one extension to another.
+func (p Policy) ConversionAllowed(name, from, to string) bool {
+	if !p.ConverterAllowed(name) {
+		return false
+	}
+	from, to = normalizeExtension(from), normalizeExtension(to)
+	return p.Conversions.allows(func(e string) bool {
+		f, t, _ := parsePair(e)
+		return (f == "*" || f == from) && (t == "*" || t == to)
+	})
+}
+
+// MaxSize returns the input size limit in bytes for a file named filename
+// converted by the named converters, or 0 if there is none.
+func (p Policy) MaxSize(filename string, converters ...string) int64 {
+	keys := []string{strings.ToLower(filepath.Ext(filename))}
+	keys = append(keys, converters...)
+	keys = append(keys, "*")
+	for _, k := range keys {
+		for key, mb := range p.MaxSizeMB {
+			if k != "" && strings.EqualFold(strings.TrimSpace(key), k) {
+				return mb << 20
+			}
+		}
+	}
+	return 0
+}
+
+// Check returns an error wrapping ErrNotAllowed if c is disabled or in is
+// larger than the policy allows for it.
+func (p Policy) Check(c Converter, in Input) error {
+	if !p.ConverterAllowed(c.Name()) {
+		return fmt.Errorf("converter %q is %w", c.Name(), ErrNotAllowed)
+	}
+	if max := p.MaxSize(in.Name, c.Name()); max > 0 && int64(len(in.Data)) > max {
+		return fmt.Errorf("%d-byte input exceeds the %d MB limit for %s: %w", len(in.Data), max>>20, c.Name(), ErrNotAllowed)
+	}
+	return nil
+}
+
+// remoteAllowed reports whether the remote resource mode m is within the
+// policy.
+func (p Policy) remoteAllowed(m RemoteMode) bool {
+	rank := map[RemoteMode]int{RemoteBlock: 0, RemoteProxy: 1, RemoteFetch: 2}
+	return p.RemoteImages == "" || rank[m] <= rank[p.RemoteImages]
+}
+
+// parsePair splits a "from>to" conversion rule into normalised extensions.
+func parsePair(s string) (from, to string, err error) {
+	from, to, ok := strings.Cut(s, ">")
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if !ok || from == "" || to == "" {
+		return "", "", fmt.Errorf("invalid conversion rule %q: want from>to, such as .psd>* or *>.pdf", s)
+	}
+	if from != "*" {
+		from = normalizeExtension(from)
+	}
+	if to != "*" {
+		to = normalizeExtension(to)
+	}
+	return from, to, nil
+}
+
+// normalizeExtension lower-cases ext and gives it a leading dot.
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
@@ -0,0 +1,131 @@
+package formats
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyRules(t *testing.T) {
+	p := Policy{
+		Converters:  Rules{Deny: []string{"imagemagick*", "Pandoc"}},
+		Conversions: Rules{Allow: []string{"*>.pdf", "png>jpg"}, Deny: []string{".html>*"}},
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, from, to string
+		want           bool
+	}{
+		{"Image Converter", ".png", ".jpg", true},
+		{"Image Converter", "PNG", "JPG", true},
+		{"Image Converter", ".png", ".gif", false},
+		{"Document Converter", ".docx", ".pdf", true},
+		{"Document Converter", ".html", ".pdf", false},
+		{"ImageMagick Converter", ".psd", ".pdf", false},
+		{"pandoc", ".md", ".pdf", false},
+	} {
+		if got := p.ConversionAllowed(tc.name, tc.from, tc.to); got != tc.want {
+			t.Errorf("ConversionAllowed(%q, %s, %s) = %v", tc.name, tc.from, tc.to, got)
+		}
+	}
+
+	for _, bad := range []Policy{
+		{Conversions: Rules{Deny: []string{".psd"}}},
+		{MaxSizeMB: map[string]int64{"*": 0}},
+		{RemoteImages: "sometimes"},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
+
+func TestPolicyMaxSize(t *testing.T) {
+	p := Policy{MaxSizeMB: map[string]int64{".PSD": 1, "plain": 2, "*": 3}}
+	for _, tc := range []struct {
+		file string
+		want int64
+	}{
+		{"a.psd", 1 << 20},
+		{"a.dat", 2 << 20},
+		{"a", 2 << 20},
+	} {
+		if got := p.MaxSize(tc.file, "plain"); got != tc.want {
+			t.Errorf("MaxSize(%q) = %d, want %d", tc.file, got, tc.want)
+		}
+	}
+	if got := p.MaxSize("a.dat", "other"); got != 3<<20 {
+		t.Errorf("default MaxSize = %d", got)
+	}
+	if got := (Policy{}).MaxSize("a.dat"); got != 0 {
+		t.Errorf("unlimited MaxSize = %d", got)
+	}
+
+	big := Input{Name: "a.psd", Data: make([]byte, 1<<20+1)}
+	if err := p.Check(&plainConverter{}, big); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("Check over limit = %v", err)
+	}
+	big.Data = big.Data[:1<<20]
+	if err := p.Check(&plainConverter{}, big); err != nil {
+		t.Errorf("Check at limit = %v", err)
+	}
+}
+
+func TestPolicyFiltersRegistry(t *testing.T) {
+	saved, savedPolicy := registry, policy
+	defer func() { registry, policy = saved, savedPolicy }()
+	registry = nil
+	Register(&plainConverter{})
+	Register(&magicConverter{})
+
+	if err := SetPolicy(Policy{Converters: Rules{Deny: []string{"magic"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if all := All(); len(all) != 1 || all[0].Name() != "plain" {
+		t.Errorf("All() = %v", all)
+	}
+	if cands := Rank("file.mg", []byte("MG data")); len(cands) != 0 {
+		t.Errorf("disabled converter ranked: %v", cands)
+	}
+	if _, err := Lookup("Magic"); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("Lookup of disabled converter = %v", err)
+	}
+	if err := (Policy{}).Check(&magicConverter{}, Input{}); err != nil {
+		t.Errorf("empty policy refused: %v", err)
+	}
+}
+
+func TestPolicyRemoteImages(t *testing.T) {
+	savedPolicy, savedRemote := policy, remotePolicy
+	defer func() { policy, remotePolicy = savedPolicy, savedRemote }()
+
+	SetPolicy(Policy{RemoteImages: RemoteProxy})
+	if err := SetRemotePolicy(RemotePolicy{Mode: RemoteFetch}); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("fetch under a proxy-only policy = %v", err)
+	}
+	if err := SetRemotePolicy(RemotePolicy{Mode: RemoteProxy, ProxyURL: "http://proxy:3128"}); err != nil {
+		t.Errorf("proxy under a proxy-only policy = %v", err)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.json")
+	os.WriteFile(path, []byte(`{"converters": {"deny": ["Pandoc*"]}, "maxSizeMB": {".psd": 20}}`), 0o644)
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ConverterAllowed("Pandoc") || p.MaxSize("x.psd") != 20<<20 {
+		t.Errorf("loaded policy = %+v", p)
+	}
+
+	os.WriteFile(path, []byte(`{"converter": {"deny": ["Pandoc"]}}`), 0o644)
+	if _, err := LoadPolicy(path); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("misspelt key: %v", err)
+	}
+}
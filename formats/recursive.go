@@ -0,0 +1,208 @@
+// recursive.go expands containers nested inside a converter's outputs,
+// such as a winmail.dat attached to another winmail.dat.
+
+package formats
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// Container is implemented by converters whose outputs are the files
+// packed inside their input (message attachments, archive members).
+// Recursive extraction only expands files detected as containers.
+type Container interface {
+	Converter
+
+	// Unpacks reports whether the converter unpacks its input.
+	Unpacks() bool
+}
+
+// Origin is one step of a file's provenance: the container it came out
+// of and the converter that unpacked it.
+type Origin struct {
+	Name      string `json:"name"`
+	Converter string `json:"converter"`
+}
+
+// Limits for recursive extraction.
+const (
+	DefaultMaxDepth = 5
+	DefaultMaxTotal = 256 << 20
+
+	// minContainerScore is the detection score a nested file needs before
+	// it is expanded; a bare extension match is not enough.
+	minContainerScore = 50
+)
+
+// RecursiveLimits bounds recursive extraction. Zero values select the
+// defaults.
+type RecursiveLimits struct {
+	MaxDepth int   `json:"maxDepth"` // container levels expanded below the input
+	MaxTotal int64 `json:"maxTotal"` // total bytes of all outputs
+}
+
+// ExtractRecursive converts in with c and then runs detection on every
+// attachment output, expanding those recognised as containers until the
+// depth or total size limit is reached. Expanded containers are kept and
+// followed by their contents, named "<container>_<file>". Every output
+// records its provenance, outermost container first, and the container
+// it came out of as its Source. Nested files are left out once they
+// would take the outputs past the total size limit. Containers left
+// unexpanded because of a limit, and files left out, are listed in
+// recursion_report.txt.
+func ExtractRecursive(ctx context.Context, c Converter, in Input, opts Options, limits RecursiveLimits) ([]ConvertedFile, error) {
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = DefaultMaxDepth
+	}
+	if limits.MaxTotal <= 0 {
+		limits.MaxTotal = DefaultMaxTotal
+	}
+	x := &expander{ctx: ctx, limits: limits, topName: c.Name(), topOpts: opts}
+
+	files, err := Adapt(c).ConvertContext(ctx, in, opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := x.expand(files, []Origin{{Name: in.Name, Converter: c.Name()}}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(x.skipped) > 0 {
+		out = append(out, ConvertedFile{
+			Name:     "recursion_report.txt",
+			Data:     []byte("Nested containers that were not expanded\n\n" + strings.Join(x.skipped, "\n") + "\n"),
+			Category: CategoryReport,
+		})
+	}
+	return out, nil
+}
+
+// expander carries the state of one recursive extraction.
+type expander struct {
+	ctx     context.Context
+	limits  RecursiveLimits
+	topName string
+	topOpts Options
+	total   int64
+	full    bool // a nested file did not fit under MaxTotal
+	skipped []string
+}
+
+// expand records provenance on files and replaces each nested container
+// with itself followed by its expanded contents. The converter's own
+// outputs (depth 1) are always kept; nested ones stop at the first that
+// does not fit under the total size limit.
+func (x *expander) expand(files []ConvertedFile, chain []Origin, depth int) ([]ConvertedFile, error) {
+	var out []ConvertedFile
+	for _, f := range files {
+		if err := x.ctx.Err(); err != nil {
+			return nil, err
+		}
+		f.Provenance = chain
+		if f.Source.Converter == "" {
+			f.Source.Converter = chain[len(chain)-1].Converter
+		}
+		if f.Source.Parent == "" {
+			f.Source.Parent = originPath(chain[:len(chain)-1], chain[len(chain)-1].Name)
+		}
+		if depth > 1 && (x.full || x.total+int64(len(f.Data)) > x.limits.MaxTotal) {
+			x.full = true
+			x.skipped = append(x.skipped, fmt.Sprintf("%s: left out, total size limit %d bytes reached", originPath(chain, f.Name), x.limits.MaxTotal))
+			continue
+		}
+		x.total += int64(len(f.Data))
+		out = append(out, f)
+		if f.Category != CategoryAttachment {
+			continue
+		}
+		conv := nestedContainer(f)
+		if conv == nil {
+			continue
+		}
+		path := originPath(chain, f.Name)
+		if depth > x.limits.MaxDepth {
+			x.skipped = append(x.skipped, fmt.Sprintf("%s: depth limit %d reached", path, x.limits.MaxDepth))
+			continue
+		}
+		if x.full || x.total >= x.limits.MaxTotal {
+			x.skipped = append(x.skipped, fmt.Sprintf("%s: total size limit %d bytes reached", path, x.limits.MaxTotal))
+			continue
+		}
+
+		if err := policy.Check(conv, Input{Name: f.Name, Data: f.Data}); err != nil {
+			x.skipped = append(x.skipped, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+
+		cc := Adapt(conv)
+		in := Input{Name: f.Name, Data: f.Data, Prefix: f.Name + "_"}
+		inner, err := cc.ConvertContext(x.ctx, in, x.nestedOptions(conv, cc.Options()))
+		if err != nil {
+			// A damaged nested container is still delivered as-is.
+			x.skipped = append(x.skipped, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		for i := range inner {
+			inner[i].Name = in.Prefix + inner[i].Name
+		}
+		next := append(chain[:len(chain):len(chain)], Origin{Name: f.Name, Converter: conv.Name()})
+		expanded, err := x.expand(inner, next, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, expanded...)
+	}
+	return out, nil
+}
+
+// nestedOptions returns the options for expanding a nested container with
+// conv: the top-level options when it is the same converter, defaults
+// otherwise. A converter that bounds its own output with max-total-mb is
+// capped at what remains of MaxTotal, so it never unpacks more than the
+// extraction could keep.
+func (x *expander) nestedOptions(conv Converter, schema []Option) Options {
+	var opts Options
+	if conv.Name() == x.topName {
+		opts = maps.Clone(x.topOpts)
+	} else {
+		opts, _ = ParseOptions(schema, nil)
+	}
+	if findOption(schema, "max-total-mb") != nil {
+		mb := int(max((x.limits.MaxTotal-x.total)>>20, 1))
+		if n := opts.Int("max-total-mb"); n == 0 || n > mb {
+			if opts == nil {
+				opts = Options{}
+			}
+			opts["max-total-mb"] = strconv.Itoa(mb)
+		}
+	}
+	return opts
+}
+
+// nestedContainer returns the converter to expand f with, or nil if f is
+// not confidently recognised as a container.
+func nestedContainer(f ConvertedFile) Converter {
+	cands := Rank(f.Name, f.Data)
+	if len(cands) == 0 || cands[0].Score < minContainerScore {
+		return nil
+	}
+	if c, ok := cands[0].Converter.(Container); ok && c.Unpacks() {
+		return c
+	}
+	return nil
+}
+
+// originPath renders a provenance chain and file name as "a > b > name".
+func originPath(chain []Origin, name string) string {
+	var parts []string
+	for _, o := range chain {
+		if o.Name != "" {
+			parts = append(parts, o.Name)
+		}
+	}
+	return strings.Join(append(parts, name), " > ")
+}
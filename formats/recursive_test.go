@@ -0,0 +1,128 @@
+package formats
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// boxConverter unpacks "BOX:" files; each line after the prefix becomes an
+// attachment, with a literal \n standing for a nested line break.
+type boxConverter struct{ plainConverter }
+
+func (b *boxConverter) Name() string           { return "Box" }
+func (b *boxConverter) Extensions() []string   { return []string{".box"} }
+func (b *boxConverter) Match(data []byte) bool { return bytes.HasPrefix(data, []byte("BOX:")) }
+func (b *boxConverter) Unpacks() bool          { return true }
+func (b *boxConverter) Convert(data []byte) ([]ConvertedFile, error) {
+	var files []ConvertedFile
+	for i, line := range strings.Split(strings.TrimPrefix(string(data), "BOX:"), "\n") {
+		line = strings.ReplaceAll(line, `\n`, "\n")
+		name := "item" + string(rune('1'+i))
+		if strings.HasPrefix(line, "BOX:") {
+			name += ".box"
+		}
+		files = append(files, ConvertedFile{Name: name, Data: []byte(line), Category: "attachment"})
+	}
+	return files, nil
+}
+
+func TestExtractRecursive(t *testing.T) {
+	saved := registry
+	defer func() { registry = saved }()
+	registry = nil
+	box := &boxConverter{}
+	Register(box)
+
+	// outer.box holds a text file and a box holding a box.
+	input := "BOX:hello\nBOX:inner\\nBOX:deep"
+	files, err := ExtractRecursive(context.Background(), box, Input{Name: "outer.box", Data: []byte(input)}, nil, RecursiveLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	want := "item1,item2.box,item2.box_item1,item2.box_item2.box,item2.box_item2.box_item1"
+	if got := strings.Join(names, ","); got != want {
+		t.Fatalf("names = %s\nwant    %s", got, want)
+	}
+	deepest := files[len(files)-1]
+	if string(deepest.Data) != "deep" || originPath(deepest.Provenance, deepest.Name) != "outer.box > item2.box > item2.box_item2.box > item2.box_item2.box_item1" {
+		t.Errorf("deepest = %q from %+v", deepest.Data, deepest.Provenance)
+	}
+	if src := deepest.Source; src.Converter != "Box" || src.Parent != "outer.box > item2.box > item2.box_item2.box" {
+		t.Errorf("deepest source = %+v", src)
+	}
+
+	// A depth limit leaves the inner container packed and reports it.
+	files, err = ExtractRecursive(context.Background(), box, Input{Name: "outer.box", Data: []byte(input)}, nil, RecursiveLimits{MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := files[len(files)-1]
+	if len(files) != 5 || last.Name != "recursion_report.txt" || !strings.Contains(string(last.Data), "item2.box_item2.box: depth limit 1 reached") {
+		t.Errorf("depth-limited extraction: %d files, last %s: %s", len(files), last.Name, last.Data)
+	}
+
+	// The size limit stops expansion once the outputs are large enough.
+	files, _ = ExtractRecursive(context.Background(), box, Input{Name: "outer.box", Data: []byte(input)}, nil, RecursiveLimits{MaxTotal: 4})
+	if len(files) != 3 || !strings.Contains(string(files[2].Data), "total size limit 4 bytes reached") {
+		t.Errorf("size-limited extraction returned %d files", len(files))
+	}
+
+	// Nested files that would pass the limit are left out, not just
+	// further expansions: the outputs stay within it.
+	files, _ = ExtractRecursive(context.Background(), box, Input{Name: "outer.box", Data: []byte(input)}, nil, RecursiveLimits{MaxTotal: 30})
+	names = nil
+	var total int
+	for _, f := range files[:len(files)-1] {
+		names = append(names, f.Name)
+		total += len(f.Data)
+	}
+	report := string(files[len(files)-1].Data)
+	if got := strings.Join(names, ","); got != "item1,item2.box,item2.box_item1" || total > 30 ||
+		!strings.Contains(report, "item2.box_item2.box: left out, total size limit 30 bytes reached") {
+		t.Errorf("size-limited extraction: %s (%d bytes), report %s", got, total, report)
+	}
+}
+
+// capConverter records the max-total-mb it was given for each input.
+type capConverter struct {
+	boxConverter
+	got map[string]string
+}
+
+func (c *capConverter) Name() string { return "Cap" }
+func (c *capConverter) Match(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("CAP:"))
+}
+func (c *capConverter) Options() []Option {
+	return []Option{{Name: "max-total-mb", Type: OptionInt, Default: "512", Min: 1, Max: 8192}}
+}
+func (c *capConverter) ConvertContext(_ context.Context, in Input, opts Options) ([]ConvertedFile, error) {
+	c.got[in.Name] = opts.String("max-total-mb")
+	return nil, nil
+}
+
+// A nested converter that bounds its own output is capped at the budget
+// left rather than running with its default.
+func TestExtractRecursiveNestedBudget(t *testing.T) {
+	saved := registry
+	defer func() { registry = saved }()
+	registry = nil
+	box, capc := &boxConverter{}, &capConverter{got: map[string]string{}}
+	Register(box)
+	Register(capc)
+
+	input := "BOX:CAP:a\nhello"
+	if _, err := ExtractRecursive(context.Background(), box, Input{Name: "outer.box", Data: []byte(input)}, nil, RecursiveLimits{MaxTotal: 3 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	if got := capc.got["item1"]; got != "2" {
+		t.Errorf("nested max-total-mb = %q, want 2", got)
+	}
+
+}
@@ -0,0 +1,158 @@
+// remote.go defines the policy that decides whether external resources
+// referenced by extracted HTML may be fetched, and the report that records
+// what was blocked. Fetching a remote image tells the sender that the
+// message was opened (and from which IP), so the default is to never fetch.
+
+package formats
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// RemoteMode selects how external resources are handled.
+type RemoteMode string
+
+const (
+	RemoteBlock RemoteMode = "block" // never fetch (default)
+	RemoteFetch RemoteMode = "fetch" // fetch directly through the SSRF-safe dialer
+	RemoteProxy RemoteMode = "proxy" // fetch through the configured HTTP proxy
+)
+
+// RemotePolicy controls fetching of external resources during conversion.
+type RemotePolicy struct {
+	Mode       RemoteMode
+	AllowHosts []string // if non-empty, only these hosts (and subdomains) are fetched
+	DenyHosts  []string // never fetched; takes precedence over AllowHosts
+	ProxyURL   string   // HTTP(S) proxy used when Mode is RemoteProxy
+	MaxBytes   int64    // total bytes fetched per conversion; 0 means the default (20 MB)
+}
+
+// remotePolicy is the process-wide policy applied by converters. It is set
+// once at startup from CLI or server flags.
+var remotePolicy = RemotePolicy{Mode: RemoteBlock}
+
+// SetRemotePolicy replaces the process-wide remote resource policy. Call it
+// during startup, before any conversion runs.
+func SetRemotePolicy(p RemotePolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if !policy.remoteAllowed(p.Mode) {
+		return fmt.Errorf("remote mode %s is %w (at most %s)", p.Mode, ErrNotAllowed, policy.RemoteImages)
+	}
+	remotePolicy = p
+	return nil
+}
+
+// CurrentRemotePolicy returns the process-wide remote resource policy.
+func CurrentRemotePolicy() RemotePolicy {
+	return remotePolicy
+}
+
+// ParseRemoteMode converts a flag value to a RemoteMode.
+func ParseRemoteMode(s string) (RemoteMode, error) {
+	switch m := RemoteMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case RemoteBlock, RemoteFetch, RemoteProxy:
+		return m, nil
+	case "":
+		return RemoteBlock, nil
+	default:
+		return "", fmt.Errorf("unknown remote mode %q (want block, fetch or proxy)", s)
+	}
+}
+
+// Validate reports whether the policy is internally consistent.
+func (p RemotePolicy) Validate() error {
+	switch p.Mode {
+	case RemoteBlock, RemoteFetch:
+	case RemoteProxy:
+		u, err := url.Parse(p.ProxyURL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("remote proxy mode requires an http(s) proxy URL, got %q", p.ProxyURL)
+		}
+	default:
+		return fmt.Errorf("unknown remote mode %q", p.Mode)
+	}
+	return nil
+}
+
+// HostAllowed reports whether host may be contacted under this policy.
+// Entries match the host itself and any of its subdomains.
+func (p RemotePolicy) HostAllowed(host string) bool {
+	if p.Mode == RemoteBlock {
+		return false
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if matchHost(host, p.DenyHosts) {
+		return false
+	}
+	if len(p.AllowHosts) > 0 {
+		return matchHost(host, p.AllowHosts)
+	}
+	return true
+}
+
+// matchHost returns true if host equals or is a subdomain of any entry.
+func matchHost(host string, list []string) bool {
+	for _, entry := range list {
+		entry = strings.ToLower(strings.Trim(strings.TrimSpace(entry), "."))
+		entry = strings.TrimPrefix(entry, "*.")
+		if entry == "" {
+			continue
+		}
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteReport records external resources that were not inlined.
+type RemoteReport struct {
+	Blocked    []string // not fetched because of the policy
+	Trackers   []string // tracking pixels removed from the HTML
+	Failed     []string // fetch attempted but failed
+	OverBudget []string // skipped because the per-conversion byte budget ran out
+}
+
+// Empty reports whether nothing was recorded.
+func (r *RemoteReport) Empty() bool {
+	return len(r.Blocked) == 0 && len(r.Trackers) == 0 && len(r.Failed) == 0 && len(r.OverBudget) == 0
+}
+
+// Text renders the report as a plain-text listing suitable for a
+// "report" output file.
+func (r *RemoteReport) Text(mode RemoteMode) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Remote resources not loaded (policy: %s)\n\n", mode)
+	section := func(label string, urls []string) {
+		if len(urls) == 0 {
+			return
+		}
+		sorted := append([]string(nil), urls...)
+		sort.Strings(sorted)
+		fmt.Fprintf(&b, "%s (%d):\n", label, len(sorted))
+		for _, u := range sorted {
+			b.WriteString("  " + u + "\n")
+		}
+		b.WriteString("\n")
+	}
+	section("Tracking pixels removed", r.Trackers)
+	section("Blocked by policy", r.Blocked)
+	section("Failed to fetch", r.Failed)
+	section("Skipped (byte budget exhausted)", r.OverBudget)
+	return []byte(b.String())
+}
+
+// appendUnique appends u to list unless it is already present.
+func appendUnique(list []string, u string) []string {
+	for _, existing := range list {
+		if existing == u {
+			return list
+		}
+	}
+	return append(list, u)
+}
@@ -0,0 +1,471 @@
+// sanitize.go implements an allowlist-based HTML sanitiser for extracted
+// message bodies and converter HTML output. It removes scripts, event
+// handlers, forms, embedded objects, meta refreshes, and dangerous URL
+// schemes so the files are safe to open locally, outside the server's CSP.
+
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// sanitizeHTML enables SanitizeFiles. It is set once at startup from CLI
+// or server flags.
+var sanitizeHTML bool
+
+// SetSanitizeHTML enables or disables sanitising of HTML outputs.
+func SetSanitizeHTML(on bool) { sanitizeHTML = on }
+
+// SanitizeEnabled reports whether HTML outputs are sanitised.
+func SanitizeEnabled() bool { return sanitizeHTML }
+
+// allowedTags are kept (with filtered attributes). Tags not listed here
+// and not in droppedTags are removed but their children are kept.
+var allowedTags = map[string]bool{
+	"a": true, "abbr": true, "address": true, "b": true, "bdi": true, "bdo": true,
+	"big": true, "blockquote": true, "body": true, "br": true, "caption": true,
+	"center": true, "cite": true, "code": true, "col": true, "colgroup": true,
+	"dd": true, "del": true, "dfn": true, "div": true, "dl": true, "dt": true,
+	"em": true, "figcaption": true, "figure": true, "font": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "head": true,
+	"hr": true, "html": true, "i": true, "img": true, "ins": true, "kbd": true,
+	"li": true, "mark": true, "meta": true, "ol": true, "p": true, "pre": true,
+	"q": true, "s": true, "samp": true, "small": true, "span": true, "strike": true,
+	"strong": true, "style": true, "sub": true, "sup": true, "table": true,
+	"tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+	"title": true, "tr": true, "tt": true, "u": true, "ul": true, "var": true,
+	"wbr": true,
+}
+
+// droppedTags are removed together with everything inside them. Every
+// element the tokenizer reads as raw text must be here or in allowedTags:
+// its content is markup to a browser once the tag itself is gone.
+var droppedTags = map[string]bool{
+	"applet": true, "audio": true, "base": true, "button": true, "embed": true,
+	"frame": true, "frameset": true, "iframe": true, "input": true, "link": true,
+	"math": true, "noembed": true, "noframes": true, "noscript": true,
+	"object": true, "plaintext": true, "script": true, "select": true,
+	"svg": true, "template": true, "textarea": true, "video": true, "xmp": true,
+}
+
+// allowedAttrs are permitted on any allowed tag.
+var allowedAttrs = map[string]bool{
+	"align": true, "alt": true, "bgcolor": true, "border": true, "cellpadding": true,
+	"cellspacing": true, "class": true, "color": true, "colspan": true, "dir": true,
+	"face": true, "headers": true, "height": true, "hspace": true, "lang": true,
+	"nowrap": true, "rowspan": true, "scope": true, "size": true, "span": true,
+	"start": true, "style": true, "summary": true, "title": true, "type": true,
+	"valign": true, "vspace": true, "width": true,
+}
+
+// urlAttrs are permitted on the given tags after scheme filtering.
+var urlAttrs = map[string]map[string]bool{
+	"a":          {"href": true},
+	"img":        {"src": true},
+	"blockquote": {"cite": true},
+	"q":          {"cite": true},
+	"del":        {"cite": true},
+	"ins":        {"cite": true},
+	"body":       {"background": true},
+	"table":      {"background": true},
+	"td":         {"background": true},
+	"th":         {"background": true},
+}
+
+// allowedSchemes are URL schemes permitted in link targets.
+var allowedSchemes = map[string]bool{
+	"http": true, "https": true, "mailto": true, "tel": true, "cid": true,
+}
+
+// allowedCSS lists CSS property names (or prefixes ending in "-") allowed
+// in style attributes and <style> blocks.
+var allowedCSS = []string{
+	"background", "background-", "border", "border-", "clear", "color",
+	"direction", "display", "float", "font", "font-", "height", "letter-spacing",
+	"line-height", "list-style", "list-style-", "margin", "margin-", "max-height",
+	"max-width", "min-height", "min-width", "padding", "padding-", "table-layout",
+	"text-align", "text-decoration", "text-decoration-", "text-indent",
+	"text-transform", "vertical-align", "white-space", "width", "word-break",
+	"word-spacing", "word-wrap", "overflow-wrap",
+}
+
+// dangerousCSS are substrings that cause a CSS declaration to be dropped.
+var dangerousCSS = []string{
+	"expression(", "javascript:", "vbscript:", "behavior:", "-moz-binding",
+}
+
+// SanitizeReport records what the sanitiser removed.
+type SanitizeReport struct {
+	Elements   map[string]int // removed element name -> count
+	Attributes map[string]int // removed "tag.attr" -> count
+	URLs       []string       // URLs dropped for a disallowed scheme
+	CSS        map[string]int // removed CSS property -> count
+	Comments   int            // removed HTML comments
+}
+
+// Empty reports whether nothing was removed.
+func (r *SanitizeReport) Empty() bool {
+	return len(r.Elements) == 0 && len(r.Attributes) == 0 && len(r.URLs) == 0 &&
+		len(r.CSS) == 0 && r.Comments == 0
+}
+
+// Text renders the report as a plain-text listing. name identifies the
+// HTML file the report applies to.
+func (r *SanitizeReport) Text(name string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:\n", name)
+	counts := func(label string, m map[string]int) {
+		if len(m) == 0 {
+			return
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(&b, "  %s:\n", label)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "    %-30s %d\n", k, m[k])
+		}
+	}
+	counts("Removed elements", r.Elements)
+	counts("Removed attributes", r.Attributes)
+	counts("Removed CSS properties", r.CSS)
+	if len(r.URLs) > 0 {
+		b.WriteString("  Removed URLs:\n")
+		for _, u := range r.URLs {
+			b.WriteString("    " + u + "\n")
+		}
+	}
+	if r.Comments > 0 {
+		fmt.Fprintf(&b, "  Removed comments: %d\n", r.Comments)
+	}
+	return []byte(b.String())
+}
+
+// SanitizeFiles sanitises every HTML file in files, recognised by name or
+// MIME type, when sanitising is enabled and appends a
+// "sanitizer_report.txt" report describing what was removed. Other files
+// are returned unchanged.
+func SanitizeFiles(files []ConvertedFile) []ConvertedFile {
+	if !sanitizeHTML {
+		return files
+	}
+	var report bytes.Buffer
+	for i, f := range files {
+		if !IsHTMLName(f.Name) && !IsHTMLType(f.MimeType) {
+			continue
+		}
+		clean, r := SanitizeHTML(f.Data)
+		files[i].Data = clean
+		if !r.Empty() {
+			report.Write(r.Text(f.Name))
+			report.WriteByte('\n')
+		}
+	}
+	if report.Len() > 0 {
+		files = append(files, ConvertedFile{
+			Name:     "sanitizer_report.txt",
+			Data:     append([]byte("HTML sanitiser removed the following content\n\n"), report.Bytes()...),
+			Category: CategoryReport,
+		})
+	}
+	return files
+}
+
+// IsHTMLName reports whether name has an HTML file extension.
+func IsHTMLName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".html" || ext == ".htm"
+}
+
+// SanitizeHTML returns a copy of doc with everything outside the allowlist
+// removed, and a report of what was dropped.
+func SanitizeHTML(doc []byte) ([]byte, *SanitizeReport) {
+	r := &SanitizeReport{
+		Elements:   map[string]int{},
+		Attributes: map[string]int{},
+		CSS:        map[string]int{},
+	}
+	z := html.NewTokenizer(bytes.NewReader(doc))
+	var out bytes.Buffer
+	out.Grow(len(doc))
+
+	skipTag := ""  // name of the dropped element we are inside
+	skipDepth := 0 // nesting depth of skipTag
+	inStyle := false
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := append([]byte(nil), z.Raw()...)
+		tok := z.Token()
+
+		if skipTag != "" {
+			switch {
+			case tt == html.StartTagToken && tok.Data == skipTag:
+				skipDepth++
+			case tt == html.EndTagToken && tok.Data == skipTag:
+				skipDepth--
+				if skipDepth == 0 {
+					skipTag = ""
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case html.CommentToken:
+			r.Comments++
+		case html.DoctypeToken:
+			out.Write(raw)
+		case html.TextToken:
+			if inStyle {
+				out.WriteString(sanitizeStylesheet(string(raw), r))
+			} else {
+				out.Write(raw)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name := tok.Data
+			if droppedTags[name] {
+				r.Elements[name]++
+				if tt == html.StartTagToken && !isVoidElement(name) {
+					skipTag, skipDepth = name, 1
+				}
+				continue
+			}
+			if !allowedTags[name] || (name == "meta" && !isCharsetMeta(tok.Attr)) {
+				r.Elements[name]++
+				continue
+			}
+			if name == "style" && tt == html.StartTagToken {
+				inStyle = true
+			}
+			tok.Attr = sanitizeAttrs(name, tok.Attr, r)
+			out.WriteString(tok.String())
+		case html.EndTagToken:
+			if !allowedTags[tok.Data] || tok.Data == "meta" {
+				continue
+			}
+			if tok.Data == "style" {
+				inStyle = false
+			}
+			out.Write(raw)
+		}
+	}
+	return out.Bytes(), r
+}
+
+// sanitizeAttrs filters the attributes of an allowed tag.
+func sanitizeAttrs(tag string, attrs []html.Attribute, r *SanitizeReport) []html.Attribute {
+	kept := attrs[:0]
+	for _, a := range attrs {
+		key := a.Key
+		switch {
+		case a.Namespace != "":
+			r.Attributes[tag+"."+key]++
+			continue
+		case tag == "meta":
+			if key != "charset" {
+				continue
+			}
+		case urlAttrs[tag][key]:
+			if !safeURL(tag, a.Val) {
+				r.URLs = appendUnique(r.URLs, a.Val)
+				r.Attributes[tag+"."+key]++
+				continue
+			}
+		case key == "style":
+			a.Val = sanitizeDeclarations(a.Val, r)
+			if strings.TrimSpace(a.Val) == "" {
+				continue
+			}
+		case strings.HasPrefix(key, "data-blocked-"):
+			// Inert marker left by ImageInliner; browsers never load it.
+		case !allowedAttrs[key]:
+			r.Attributes[tag+"."+key]++
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
+
+// safeURL reports whether a URL attribute value may be kept. Relative URLs
+// and fragments are allowed; absolute URLs must use an allowed scheme, and
+// data: URIs are only allowed as image sources.
+func safeURL(tag, val string) bool {
+	// Browsers ignore whitespace and control characters inside the scheme,
+	// so "java\tscript:" must be treated as "javascript:".
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, val)
+	colon := strings.IndexByte(cleaned, ':')
+	if colon < 0 {
+		return true
+	}
+	// A colon after a path, query, or fragment delimiter is not a scheme.
+	if i := strings.IndexAny(cleaned, "/?#"); i >= 0 && i < colon {
+		return true
+	}
+	scheme := strings.ToLower(cleaned[:colon])
+	if scheme == "data" {
+		return tag == "img" && strings.HasPrefix(strings.ToLower(cleaned), "data:image/")
+	}
+	return allowedSchemes[scheme]
+}
+
+// sanitizeDeclarations filters a list of CSS declarations ("a: b; c: d").
+func sanitizeDeclarations(css string, r *SanitizeReport) string {
+	var kept []string
+	for _, decl := range strings.Split(css, ";") {
+		decl = strings.TrimSpace(decl)
+		if decl == "" {
+			continue
+		}
+		colon := strings.IndexByte(decl, ':')
+		if colon <= 0 {
+			continue
+		}
+		prop := strings.ToLower(strings.TrimSpace(decl[:colon]))
+		val := decl[colon+1:]
+		if !cssPropertyAllowed(prop) || !cssValueSafe(val) {
+			r.CSS[prop]++
+			continue
+		}
+		kept = append(kept, prop+":"+strings.TrimSpace(val))
+	}
+	return strings.Join(kept, "; ")
+}
+
+// sanitizeStylesheet filters the declarations inside each rule of a
+// <style> block. At-rules other than @media and @font-face (and any
+// statement-level at-rule such as @import) are dropped.
+func sanitizeStylesheet(css string, r *SanitizeReport) string {
+	lower := strings.ToLower(css)
+	if strings.Contains(lower, "</style") || strings.Contains(css, `\`) {
+		r.Elements["style (unsafe content)"]++
+		return ""
+	}
+	var out strings.Builder
+	for {
+		open := strings.IndexByte(css, '{')
+		if open < 0 {
+			break
+		}
+		selector := css[:open]
+		end := strings.IndexByte(css[open:], '}')
+		if end < 0 {
+			break
+		}
+		body := css[open+1 : open+end]
+		css = css[open+end+1:]
+
+		// Statements such as "@import url(x);" end with a semicolon and
+		// precede the selector of the next rule.
+		if semi := strings.LastIndexByte(selector, ';'); semi >= 0 {
+			dropAtRules(selector[:semi], r)
+			selector = selector[semi+1:]
+		}
+
+		trimmed := strings.ToLower(strings.TrimSpace(selector))
+		if strings.HasPrefix(trimmed, "@") {
+			if strings.HasPrefix(trimmed, "@media") {
+				// Keep the @media wrapper; its first inner rule is in body.
+				inner := strings.IndexByte(body, '{')
+				if inner < 0 {
+					continue
+				}
+				out.WriteString(selector + "{" + body[:inner] + "{" +
+					sanitizeDeclarations(body[inner+1:], r) + "}")
+				continue
+			}
+			if !strings.HasPrefix(trimmed, "@font-face") {
+				r.CSS[strings.Fields(trimmed)[0]]++
+				continue
+			}
+		}
+		out.WriteString(selector + "{" + sanitizeDeclarations(body, r) + "}")
+	}
+	// Whatever follows the last rule can only legitimately be the closing
+	// braces of @media blocks.
+	dropAtRules(css, r)
+	out.WriteString(strings.Map(func(c rune) rune {
+		if c == '}' || c == '\n' || c == ' ' {
+			return c
+		}
+		return -1
+	}, css))
+	return out.String()
+}
+
+// dropAtRules records each statement-level at-rule in s as removed.
+func dropAtRules(s string, r *SanitizeReport) {
+	for _, stmt := range strings.Split(s, ";") {
+		if f := strings.Fields(strings.ToLower(stmt)); len(f) > 0 && strings.HasPrefix(f[0], "@") {
+			r.CSS[f[0]]++
+		}
+	}
+}
+
+// cssPropertyAllowed reports whether prop is on the CSS allowlist.
+func cssPropertyAllowed(prop string) bool {
+	for _, p := range allowedCSS {
+		if prop == p || (strings.HasSuffix(p, "-") && strings.HasPrefix(prop, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cssValueSafe rejects CSS containing script vectors or url() references
+// other than data: images.
+func cssValueSafe(val string) bool {
+	lower := strings.ToLower(strings.ReplaceAll(val, " ", ""))
+	if strings.Contains(lower, `\`) {
+		return false // CSS escapes can hide any of the checks below
+	}
+	for _, bad := range dangerousCSS {
+		if strings.Contains(lower, bad) {
+			return false
+		}
+	}
+	for _, m := range cssURLRe.FindAllStringSubmatch(val, -1) {
+		ref := strings.ToLower(strings.TrimSpace(m[1] + m[2] + m[3]))
+		if !strings.HasPrefix(ref, "data:image/") {
+			return false
+		}
+	}
+	return true
+}
+
+// isCharsetMeta reports whether a <meta> tag only declares a charset.
+func isCharsetMeta(attrs []html.Attribute) bool {
+	for _, a := range attrs {
+		if a.Key == "charset" {
+			return true
+		}
+	}
+	return false
+}
+
+// isVoidElement reports whether name never has a closing tag.
+func isVoidElement(name string) bool {
+	switch name {
+	case "area", "base", "br", "col", "embed", "hr", "img", "input", "link",
+		"meta", "param", "source", "track", "wbr":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	doc := `<html><head><meta http-equiv="refresh" content="0;url=https://evil.test">` +
+		`<meta charset="utf-8"><script>alert(1)</script></head>` +
+		`<body onload="steal()"><!-- note -->` +
+		`<p style="color:red; position:fixed; background:url(https://t.test/x)">Hi</p>` +
+		`<a href="java&#09;script:alert(1)">bad</a><a href="https://ok.test/">good</a>` +
+		`<form action="https://evil.test"><input name="pw"><b>kept</b></form>` +
+		`<img src="data:image/png;base64,AA" onerror="x()"><iframe src="https://x.test"><p>gone</p></iframe>` +
+		`</body></html>`
+
+	got, r := SanitizeHTML([]byte(doc))
+	out := string(got)
+
+	for _, bad := range []string{"<script", "alert(1)", "refresh", "onload", "onerror",
+		"position", "t.test", "javascript", "<form", "<input", "<iframe", "gone", "note"} {
+		if strings.Contains(out, bad) {
+			t.Errorf("output still contains %q:\n%s", bad, out)
+		}
+	}
+	for _, want := range []string{`<meta charset="utf-8">`, `style="color:red"`,
+		`href="https://ok.test/"`, "<b>kept</b>", `src="data:image/png;base64,AA"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if r.Elements["script"] != 1 || r.Elements["iframe"] != 1 || r.Comments != 1 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if r.Attributes["body.onload"] != 1 || len(r.URLs) != 1 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+}
+
+// Raw-text elements must not leave their content behind as live markup.
+func TestSanitizeRawTextElements(t *testing.T) {
+	for _, doc := range []string{
+		`<noembed><img src=x onerror=alert(1)></noembed><p>after</p>`,
+		`<noframes><script>alert(1)</script></noframes><p>after</p>`,
+		`<xmp><img src=x onerror=alert(1)></xmp><p>after</p>`,
+		`<p>after</p><plaintext><img src=x onerror=alert(1)>`,
+	} {
+		out, _ := SanitizeHTML([]byte(doc))
+		if s := string(out); strings.Contains(s, "alert") || strings.Contains(s, "<img") ||
+			strings.Contains(s, "<script") || !strings.Contains(s, "<p>after</p>") {
+			t.Errorf("SanitizeHTML(%q) = %q", doc, s)
+		}
+	}
+}
+
+func TestSanitizeStylesheet(t *testing.T) {
+	r := &SanitizeReport{Elements: map[string]int{}, Attributes: map[string]int{}, CSS: map[string]int{}}
+	got := sanitizeStylesheet(`p { color: blue; behavior: url(x.htc) } @media screen { td { padding: 2px } }`, r)
+	if strings.Contains(got, "behavior") || !strings.Contains(got, "color:blue") || !strings.Contains(got, "padding:2px") {
+		t.Errorf("unexpected stylesheet: %s", got)
+	}
+	if got := sanitizeStylesheet(`@import url(https://evil.test/x.css);`, r); strings.TrimSpace(got) != "" {
+		t.Errorf("expected @import to be dropped, got %q", got)
+	}
+}
+
+func TestSanitizeFilesDisabled(t *testing.T) {
+	files := []ConvertedFile{{Name: "body.html", Data: []byte("<script>x</script>")}}
+	got := SanitizeFiles(files)
+	if len(got) != 1 || string(got[0].Data) != "<script>x</script>" {
+		t.Errorf("sanitiser ran while disabled: %+v", got)
+	}
+}
+
+func TestSanitizeFilesReport(t *testing.T) {
+	SetSanitizeHTML(true)
+	defer SetSanitizeHTML(false)
+	files := []ConvertedFile{
+		{Name: "body.html", Data: []byte("<p onclick=x()>hi</p>"), Category: "body"},
+		{Name: "body.txt", Data: []byte("<script>plain text</script>"), Category: "body"},
+	}
+	got := SanitizeFiles(files)
+	if len(got) != 3 || got[2].Name != "sanitizer_report.txt" || got[2].Category != "report" {
+		t.Fatalf("expected report file, got %+v", got)
+	}
+	if string(got[0].Data) != "<p>hi</p>" {
+		t.Errorf("html not sanitised: %s", got[0].Data)
+	}
+	if string(got[1].Data) != "<script>plain text</script>" {
+		t.Errorf("text file modified: %s", got[1].Data)
+	}
+}
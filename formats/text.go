@@ -0,0 +1,408 @@
+// text.go renders HTML as readable plain text for consumers that cannot
+// use markup (search indexers, ticketing systems, the `body` command).
+// Block elements become line breaks, lists become bullets, links keep
+// their target as "text <url>", and tables are laid out as aligned columns.
+
+package formats
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLToText converts an HTML document or fragment to plain text. Entities
+// are decoded; scripts, styles and other non-visible content are dropped.
+// The result uses "\n" line endings and ends with a single newline, or is
+// empty if the document has no visible text.
+func HTMLToText(doc []byte) []byte {
+	root, err := html.Parse(bytes.NewReader(doc))
+	if err != nil {
+		return nil
+	}
+	r := &textRenderer{}
+	r.node(root)
+	r.flush()
+	out := strings.TrimSpace(r.out.String())
+	if out == "" {
+		return nil
+	}
+	return []byte(out + "\n")
+}
+
+// textBlocks are elements that start and end on their own line.
+var textBlocks = map[atom.Atom]bool{
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Nav: true, atom.Aside: true, atom.Main: true,
+	atom.Address: true, atom.Figure: true, atom.Figcaption: true, atom.Center: true,
+	atom.Form: true, atom.Fieldset: true, atom.Dt: true, atom.Tr: true,
+	atom.Caption: true,
+}
+
+// textParagraphs are block elements separated from their neighbours by a
+// blank line.
+var textParagraphs = map[atom.Atom]bool{
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Dl: true, atom.Table: true,
+}
+
+// textSkipped are elements whose content is never visible.
+var textSkipped = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Title: true,
+	atom.Noscript: true, atom.Template: true, atom.Select: true, atom.Object: true,
+	atom.Iframe: true, atom.Svg: true,
+}
+
+// textIndent is one level of line prefix (a list item or quotation).
+// first is used for the first line written at this level, rest for the
+// lines after it.
+type textIndent struct {
+	first, rest string
+	used        bool
+}
+
+// textList tracks numbering for an open <ul> or <ol>.
+type textList struct {
+	ordered bool
+	next    int
+}
+
+// textRenderer accumulates output line by line.
+type textRenderer struct {
+	out       strings.Builder
+	line      strings.Builder // inline content of the current line
+	space     bool            // whitespace seen since the last word on this line
+	needBlank bool            // emit a blank line before the next line
+	pre       int             // depth of <pre> elements
+	indents   []textIndent
+	lists     []textList
+}
+
+// prefix returns the line prefix for the next line and marks list markers
+// as used so continuation lines are indented instead.
+func (r *textRenderer) prefix() string {
+	var b strings.Builder
+	for i := range r.indents {
+		if r.indents[i].used {
+			b.WriteString(r.indents[i].rest)
+		} else {
+			b.WriteString(r.indents[i].first)
+			r.indents[i].used = true
+		}
+	}
+	return b.String()
+}
+
+// emit writes one finished line with the current prefix.
+func (r *textRenderer) emit(s string) {
+	if r.needBlank && r.out.Len() > 0 {
+		blank := ""
+		for _, in := range r.indents {
+			if in.used {
+				blank += in.rest
+			}
+		}
+		r.out.WriteString(strings.TrimRight(blank, " ") + "\n")
+	}
+	r.needBlank = false
+	r.out.WriteString(strings.TrimRight(r.prefix()+s, " \t") + "\n")
+}
+
+// flush ends the current line if it has content.
+func (r *textRenderer) flush() {
+	if r.line.Len() > 0 {
+		r.emit(r.line.String())
+		r.line.Reset()
+	}
+	r.space = false
+}
+
+// paragraph ends the current line and requests a blank line before the
+// next one.
+func (r *textRenderer) paragraph() {
+	r.flush()
+	if r.out.Len() > 0 {
+		r.needBlank = true
+	}
+}
+
+// text appends inline text, collapsing whitespace outside <pre>. Only
+// ASCII whitespace collapses, so &nbsp; runs survive as spaces.
+func (r *textRenderer) text(s string) {
+	if r.pre > 0 {
+		lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+		for i, l := range lines {
+			if i > 0 {
+				r.emit(r.line.String())
+				r.line.Reset()
+			}
+			r.line.WriteString(strings.ReplaceAll(l, "\u00a0", " "))
+		}
+		return
+	}
+	for _, c := range s {
+		switch c {
+		case ' ', '\t', '\n', '\r', '\f':
+			r.space = true
+			continue
+		case '\u00a0':
+			c = ' '
+		}
+		if r.space && r.line.Len() > 0 {
+			r.line.WriteByte(' ')
+		}
+		r.space = false
+		r.line.WriteRune(c)
+	}
+}
+
+func (r *textRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.node(c)
+	}
+}
+
+func (r *textRenderer) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.DocumentNode:
+		r.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	a := n.DataAtom
+	switch {
+	case textSkipped[a]:
+		return
+	case a == atom.Br:
+		if r.pre > 0 || r.line.Len() > 0 {
+			r.emit(r.line.String())
+			r.line.Reset()
+		} else {
+			r.emit("")
+		}
+		r.space = false
+		return
+	case a == atom.Hr:
+		r.paragraph()
+		r.emit(strings.Repeat("-", 40))
+		r.paragraph()
+		return
+	case a == atom.Img:
+		if alt := strings.TrimSpace(attrValue(n.Attr, "alt")); alt != "" {
+			r.text("[" + alt + "]")
+		}
+		return
+	case a == atom.A:
+		r.link(n)
+		return
+	case a == atom.Table:
+		r.table(n)
+		return
+	case a == atom.Ul || a == atom.Ol:
+		r.list(n, a == atom.Ol)
+		return
+	case a == atom.Li:
+		r.item(n)
+		return
+	case a == atom.Blockquote:
+		r.paragraph()
+		r.indents = append(r.indents, textIndent{first: "> ", rest: "> "})
+		r.children(n)
+		r.flush()
+		r.indents = r.indents[:len(r.indents)-1]
+		r.paragraph()
+		return
+	case a == atom.Pre:
+		r.paragraph()
+		r.pre++
+		r.children(n)
+		r.pre--
+		r.paragraph()
+		return
+	case a == atom.Dd:
+		r.flush()
+		r.indents = append(r.indents, textIndent{first: "    ", rest: "    "})
+		r.children(n)
+		r.flush()
+		r.indents = r.indents[:len(r.indents)-1]
+		return
+	case textParagraphs[a]:
+		r.paragraph()
+		r.children(n)
+		r.paragraph()
+		return
+	case textBlocks[a]:
+		r.flush()
+		r.children(n)
+		r.flush()
+		return
+	case a == atom.Td || a == atom.Th:
+		// Cells outside a table we laid out ourselves (malformed markup).
+		r.space = true
+		r.children(n)
+		r.space = true
+		return
+	}
+	r.children(n)
+}
+
+// link renders an anchor as "text <url>". The URL is omitted when it is
+// not an external link or when the text already shows it.
+func (r *textRenderer) link(n *html.Node) {
+	r.children(n)
+	href := strings.TrimSpace(attrValue(n.Attr, "href"))
+	lower := strings.ToLower(href)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") &&
+		!strings.HasPrefix(lower, "mailto:") {
+		return
+	}
+	label := inlineText(n)
+	shown := href
+	if strings.HasPrefix(lower, "mailto:") {
+		shown = href[len("mailto:"):]
+	}
+	if label == "" || label == href || label == shown ||
+		strings.TrimSuffix(label, "/") == strings.TrimSuffix(shown, "/") {
+		if label == "" {
+			r.text(shown)
+		}
+		return
+	}
+	r.text(" <" + shown + ">")
+}
+
+// list renders <ul> and <ol>; nested lists are indented under their item.
+func (r *textRenderer) list(n *html.Node, ordered bool) {
+	if len(r.lists) == 0 {
+		r.paragraph()
+	} else {
+		r.flush()
+	}
+	start := 1
+	if v, err := strconv.Atoi(attrValue(n.Attr, "start")); err == nil {
+		start = v
+	}
+	r.lists = append(r.lists, textList{ordered: ordered, next: start})
+	r.children(n)
+	r.flush()
+	r.lists = r.lists[:len(r.lists)-1]
+	if len(r.lists) == 0 {
+		r.paragraph()
+	}
+}
+
+// item renders an <li> with a bullet or number; continuation lines are
+// aligned with the item text.
+func (r *textRenderer) item(n *html.Node) {
+	r.flush()
+	marker := "• "
+	if len(r.lists) > 0 {
+		l := &r.lists[len(r.lists)-1]
+		if l.ordered {
+			marker = strconv.Itoa(l.next) + ". "
+			l.next++
+		}
+	}
+	indent := ""
+	if len(r.lists) > 1 {
+		indent = "  "
+	}
+	pad := strings.Repeat(" ", utf8.RuneCountInString(marker))
+	r.indents = append(r.indents, textIndent{first: indent + marker, rest: indent + pad})
+	r.children(n)
+	r.flush()
+	r.indents = r.indents[:len(r.indents)-1]
+}
+
+// table lays out a table as aligned columns. Single-column tables, which
+// HTML mail uses for layout, are rendered as ordinary blocks instead.
+func (r *textRenderer) table(n *html.Node) {
+	var rows [][]string
+	var cells [][]*html.Node
+	columns := 0
+	var collect func(*html.Node)
+	collect = func(p *html.Node) {
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(c)
+			case atom.Tr:
+				var row []*html.Node
+				for td := c.FirstChild; td != nil; td = td.NextSibling {
+					if td.Type == html.ElementNode && (td.DataAtom == atom.Td || td.DataAtom == atom.Th) {
+						row = append(row, td)
+					}
+				}
+				if len(row) > columns {
+					columns = len(row)
+				}
+				cells = append(cells, row)
+			}
+		}
+	}
+	collect(n)
+
+	r.paragraph()
+	if columns <= 1 {
+		for _, row := range cells {
+			for _, td := range row {
+				r.flush()
+				r.children(td)
+				r.flush()
+			}
+		}
+		r.paragraph()
+		return
+	}
+
+	widths := make([]int, columns)
+	for _, row := range cells {
+		texts := make([]string, len(row))
+		for i, td := range row {
+			texts[i] = inlineText(td)
+			if w := utf8.RuneCountInString(texts[i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+		rows = append(rows, texts)
+	}
+	for _, row := range rows {
+		empty := true
+		var line strings.Builder
+		for i, cell := range row {
+			if cell != "" {
+				empty = false
+			}
+			line.WriteString(cell)
+			if i < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2))
+			}
+		}
+		if !empty {
+			r.emit(line.String())
+		}
+	}
+	r.paragraph()
+}
+
+// inlineText renders the children of n on a single line.
+func inlineText(n *html.Node) string {
+	sub := &textRenderer{}
+	sub.children(n)
+	sub.flush()
+	return strings.Join(strings.Fields(strings.ReplaceAll(sub.out.String(), "\u00a0", " ")), " ")
+}
@@ -0,0 +1,46 @@
+package formats
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	doc := `<html><head><title>T</title><style>p{color:red}</style></head><body>
+<h1>Invoice&nbsp;#42</h1>
+<p>Dear   customer,<br>thanks &amp; regards.</p>
+<ul><li>First</li><li>Second<ol><li>a</li><li>b</li></ol></li></ul>
+<p>See <a href="https://example.com/pay">the portal</a> or <a href="mailto:help@example.com">help@example.com</a>.</p>
+<table><tr><th>Item</th><th>Qty</th></tr><tr><td>Widget</td><td>10</td></tr></table>
+<blockquote>quoted<br>text</blockquote>
+<script>alert(1)</script></body></html>`
+
+	want := "Invoice #42\n" +
+		"\n" +
+		"Dear customer,\n" +
+		"thanks & regards.\n" +
+		"\n" +
+		"• First\n" +
+		"• Second\n" +
+		"    1. a\n" +
+		"    2. b\n" +
+		"\n" +
+		"See the portal <https://example.com/pay> or help@example.com.\n" +
+		"\n" +
+		"Item    Qty\n" +
+		"Widget  10\n" +
+		"\n" +
+		"> quoted\n" +
+		"> text\n"
+	if got := string(HTMLToText([]byte(doc))); got != want {
+		t.Errorf("HTMLToText mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHTMLToTextLayoutTable(t *testing.T) {
+	doc := `<table><tr><td><p>Header</p></td></tr><tr><td>Body <b>text</b></td></tr></table>`
+	want := "Header\n\nBody text\n"
+	if got := string(HTMLToText([]byte(doc))); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := HTMLToText([]byte(`<html><body><img src="x.png"></body></html>`)); got != nil {
+		t.Errorf("expected nil for document without text, got %q", got)
+	}
+}
@@ -0,0 +1,174 @@
+// message.go renders a decoded TNEF message as a standalone message.html:
+// a header block (subject, from, to, cc, date), the best available body,
+// a list of attachments linking to the extracted files, and embedded
+// messages rendered recursively as nested sections.
+
+package tnef
+
+import (
+	"bytes"
+	"fmt"
+	stdhtml "html"
+	"net/url"
+	"strings"
+
+	"github.com/lgican/File-Converter/formats"
+	parser "github.com/lgican/File-Converter/parsers/tnef"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// messageCSS styles the header block and attachment list. Class names are
+// prefixed so they do not collide with the message's own stylesheet.
+const messageCSS = `
+.fc-msg { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; }
+.fc-header { border-bottom: 1px solid #ccc; margin-bottom: 1em; padding-bottom: .5em; }
+.fc-subject { font-size: 1.4em; margin: 0 0 .4em; }
+.fc-fields th { text-align: left; padding-right: 1em; color: #555; font-weight: 600; vertical-align: top; }
+.fc-fields td { padding: 0; }
+.fc-text { white-space: pre-wrap; font-family: inherit; }
+.fc-attachments { border-top: 1px solid #ccc; margin-top: 1.5em; padding-top: .5em; }
+.fc-attachments h2, .fc-embedded > h2 { font-size: 1em; }
+.fc-embedded { border-left: 3px solid #ccc; margin: 1.5em 0 0; padding-left: 1em; }
+@media print { .fc-embedded { page-break-before: always; } }
+`
+
+// renderMessage returns a complete HTML document for msg. It must run after
+// collectAll so the bodies already have content-IDs resolved and the remote
+// image policy applied, and so names holds the output names to link to.
+// base is the caller's Input.Prefix, which the links need to match the
+// names the files are finally written under.
+func renderMessage(msg *parser.Message, names *outputNames, base string) []byte {
+	var b strings.Builder
+	subject := msg.GetAttrString(parser.MAPISubject)
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">\n")
+	b.WriteString("<title>" + stdhtml.EscapeString(subject) + "</title>\n")
+	b.WriteString("<style>" + messageCSS + "</style>\n")
+	b.WriteString("</head><body>\n")
+	writeMessage(&b, msg, names, base)
+	b.WriteString("</body></html>\n")
+	return []byte(b.String())
+}
+
+// writeMessage writes the header, body, attachments, and embedded messages
+// of msg, linking attachments to the names collectAll gave them.
+func writeMessage(b *strings.Builder, msg *parser.Message, names *outputNames, base string) {
+	esc := stdhtml.EscapeString
+	b.WriteString("<div class=\"fc-msg\">\n<div class=\"fc-header\">\n")
+	subject := msg.GetAttrString(parser.MAPISubject)
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	b.WriteString("<h1 class=\"fc-subject\">" + esc(subject) + "</h1>\n")
+
+	from := msg.GetAttrString(parser.MAPISenderName)
+	if email := msg.GetAttrString(parser.MAPISenderEmail); email != "" && email != from {
+		if from != "" {
+			from += " <" + email + ">"
+		} else {
+			from = email
+		}
+	}
+	date := ""
+	if t := msg.SentTime(); !t.IsZero() {
+		date = t.Format("Mon, 02 Jan 2006 15:04:05 MST")
+	}
+	b.WriteString("<table class=\"fc-fields\">\n")
+	for _, f := range []struct{ label, value string }{
+		{"From", from},
+		{"To", msg.GetAttrString(parser.MAPIDisplayTo)},
+		{"Cc", msg.GetAttrString(parser.MAPIDisplayCc)},
+		{"Date", date},
+	} {
+		if f.value != "" {
+			b.WriteString("<tr><th>" + f.label + "</th><td>" + esc(f.value) + "</td></tr>\n")
+		}
+	}
+	b.WriteString("</table>\n</div>\n")
+
+	b.WriteString("<div class=\"fc-body\">\n")
+	switch {
+	case len(msg.BodyHTML) > 0:
+		b.WriteString(bodyFragment(msg.BodyHTML))
+	case len(msg.BodyRTFHTML) > 0:
+		b.WriteString(bodyFragment(msg.BodyRTFHTML))
+	case len(msg.Body) > 0:
+		b.WriteString("<pre class=\"fc-text\">" + esc(string(msg.Body)) + "</pre>")
+	case len(msg.BodyRTF) > 0:
+		b.WriteString("<p><em>This message has an RTF body only; see " +
+			esc(base+names.rtf[msg]) + ".</em></p>")
+	}
+	b.WriteString("\n</div>\n")
+
+	var files []*parser.Attachment
+	var embedded []*parser.Attachment
+	for _, att := range msg.Attachments {
+		if att.EmbeddedMsg != nil {
+			embedded = append(embedded, att)
+		} else if len(att.Data) > 0 {
+			files = append(files, att)
+		}
+	}
+	if len(files) > 0 {
+		fmt.Fprintf(b, "<div class=\"fc-attachments\">\n<h2>Attachments (%d)</h2>\n<ul>\n", len(files))
+		for _, att := range files {
+			fmt.Fprintf(b, "<li><a href=\"%s\" target=\"_blank\">%s</a> (%s)</li>\n",
+				esc(url.PathEscape(base+names.atts[att])), esc(att.Filename()), formats.HumanSize(len(att.Data)))
+		}
+		b.WriteString("</ul>\n</div>\n")
+	}
+	for _, att := range embedded {
+		b.WriteString("<section class=\"fc-embedded\">\n<h2>Attached message: " + esc(att.Filename()) + "</h2>\n")
+		writeMessage(b, att.EmbeddedMsg, names, base)
+		b.WriteString("</section>\n")
+	}
+	b.WriteString("</div>\n")
+}
+
+// bodyFragment extracts the <style> elements and the <body> contents of an
+// HTML document so it can be embedded inside the message view.
+func bodyFragment(doc []byte) string {
+	root, err := html.Parse(bytes.NewReader(doc))
+	if err != nil {
+		return "<pre class=\"fc-text\">" + stdhtml.EscapeString(string(doc)) + "</pre>"
+	}
+	var out bytes.Buffer
+	var body *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Style:
+				if body == nil || !isDescendant(n, body) {
+					html.Render(&out, n)
+				}
+				return
+			case atom.Body:
+				body = n
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	if body != nil {
+		for c := body.FirstChild; c != nil; c = c.NextSibling {
+			html.Render(&out, c)
+		}
+	}
+	return out.String()
+}
+
+// isDescendant reports whether n is inside ancestor.
+func isDescendant(n, ancestor *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
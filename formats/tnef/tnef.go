@@ -3,8 +3,12 @@
 package tnef
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/binary"
+	"fmt"
+	"path"
 	"strings"
 
 	"github.com/lgican/File-Converter/formats"
@@ -34,19 +38,94 @@ func (c *converter) Match(data []byte) bool {
 	return binary.LittleEndian.Uint32(data[0:4]) == tnefSignature
 }
 
+// Unpacks marks TNEF as a container: its attachments may themselves be
+// TNEF files or archives.
+func (c *converter) Unpacks() bool { return true }
+
+// Score is certain on the TNEF signature; the extension alone is weak
+// evidence because ".dat" is used by many unrelated formats.
+func (c *converter) Score(filename string, data []byte) (int, []string) {
+	if c.Match(data) {
+		reasons := []string{"TNEF signature 0x223E9F78 at offset 0"}
+		if ext := formats.MatchExtension(c, filename); ext != "" {
+			reasons = append(reasons, "file extension "+ext)
+		}
+		return 100, reasons
+	}
+	if ext := formats.MatchExtension(c, filename); ext != "" {
+		return 10, []string{"file extension " + ext + ", but no TNEF signature"}
+	}
+	return 0, nil
+}
+
+// Options lets callers skip the rendered message view and the synthesised
+// plain-text body.
+func (c *converter) Options() []formats.Option {
+	return []formats.Option{
+		{Name: "message-view", Type: formats.OptionBool, Default: "true",
+			Description: "Render message.html with the header block and attachment list"},
+		{Name: "text-fallback", Type: formats.OptionBool, Default: "true",
+			Description: "Synthesise body.txt from the HTML or RTF body when there is no text body"},
+	}
+}
+
 func (c *converter) Convert(data []byte) ([]formats.ConvertedFile, error) {
-	msg, err := parser.Decode(data)
+	opts, _ := formats.ParseOptions(c.Options(), nil)
+	return c.ConvertContext(context.Background(), formats.Input{Data: data}, opts)
+}
+
+// ConvertContext decodes the message and extracts its bodies and
+// attachments. ctx bounds remote resource fetches.
+func (c *converter) ConvertContext(ctx context.Context, in formats.Input, opts formats.Options) ([]formats.ConvertedFile, error) {
+	msg, err := parser.Decode(in.Data)
 	if err != nil {
 		return nil, err
 	}
-	return collectAll(msg, ""), nil
+	policy := formats.CurrentRemotePolicy()
+	inliner := formats.NewImageInlinerContext(ctx, policy)
+	names := newOutputNames("message.html", "remote_resources.txt")
+	files := collectAll(msg, "", names, inliner, opts.Bool("text-fallback"))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// The rendered message view goes first so it is the default preview.
+	if opts.Bool("message-view") {
+		files = append([]formats.ConvertedFile{{
+			Name:     "message.html",
+			Data:     renderMessage(msg, names, in.Prefix),
+			Category: formats.CategoryBody,
+		}}, files...)
+	}
+
+	if !inliner.Report.Empty() {
+		files = append(files, formats.ConvertedFile{
+			Name:     "remote_resources.txt",
+			Data:     inliner.Report.Text(policy.Mode),
+			Category: formats.CategoryReport,
+		})
+	}
+	return files, nil
 }
 
 // collectAll recursively extracts all bodies and attachments from a decoded
-// TNEF message, resolving content-IDs and inlining external images.
-func collectAll(msg *parser.Message, prefix string) []formats.ConvertedFile {
+// TNEF message, resolving content-IDs and applying the remote image policy.
+// Output names are assigned through names, which numbers repeats and
+// remembers them for the message view. textFallback enables the
+// synthesised body.txt.
+func collectAll(msg *parser.Message, prefix string, names *outputNames, inliner *formats.ImageInliner, textFallback bool) []formats.ConvertedFile {
 	var files []formats.ConvertedFile
 
+	// Attachments the body displays through cid: references are inline
+	// parts; note them before the references are replaced.
+	inline := make(map[*parser.Attachment]bool)
+	for _, att := range msg.Attachments {
+		if att.ContentID != "" {
+			cid := []byte("cid:" + att.ContentID)
+			inline[att] = bytes.Contains(msg.BodyHTML, cid) || bytes.Contains(msg.BodyRTFHTML, cid)
+		}
+	}
+
 	if len(msg.BodyHTML) > 0 || len(msg.BodyRTFHTML) > 0 {
 		msg.ResolveContentIDs(func(att *parser.Attachment) string {
 			if len(att.Data) == 0 {
@@ -58,65 +137,134 @@ func collectAll(msg *parser.Message, prefix string) []formats.ConvertedFile {
 		})
 	}
 
-	// Fetch and embed any remaining external images so the HTML is
-	// fully self-contained and viewable offline. Share the cache so
-	// duplicate URLs across bodies are only fetched once.
-	imgCache := make(map[string]string)
-	msg.BodyHTML = formats.InlineExternalImages(msg.BodyHTML, imgCache)
-	msg.BodyRTFHTML = formats.InlineExternalImages(msg.BodyRTFHTML, imgCache)
+	// Embed, block or strip the remaining external images according to
+	// the remote policy. The inliner is shared across bodies and embedded
+	// messages so duplicate URLs are only fetched once.
+	msg.BodyHTML = inliner.Inline(msg.BodyHTML)
+	msg.BodyRTFHTML = inliner.Inline(msg.BodyRTFHTML)
+
+	// Text consumers (indexers, ticketing) need body.txt even when the
+	// sender only supplied HTML or RTF, so synthesise it.
+	if len(msg.Body) == 0 && textFallback {
+		msg.Body = plainTextBody(msg)
+	}
 
 	if len(msg.Body) > 0 {
 		files = append(files, formats.ConvertedFile{
-			Name:     prefixed(prefix, "body.txt"),
+			Name:     names.unique(prefixed(prefix, "body.txt")),
 			Data:     msg.Body,
-			Category: "body",
+			Category: formats.CategoryBody,
 		})
 	}
 	if len(msg.BodyHTML) > 0 {
 		files = append(files, formats.ConvertedFile{
-			Name:     prefixed(prefix, "body.html"),
+			Name:     names.unique(prefixed(prefix, "body.html")),
 			Data:     msg.BodyHTML,
-			Category: "body",
+			Category: formats.CategoryBody,
 		})
 	}
 	if len(msg.BodyRTF) > 0 {
+		names.rtf[msg] = names.unique(prefixed(prefix, "body.rtf"))
 		files = append(files, formats.ConvertedFile{
-			Name:     prefixed(prefix, "body.rtf"),
+			Name:     names.rtf[msg],
 			Data:     msg.BodyRTF,
-			Category: "body",
+			Category: formats.CategoryBody,
 		})
 	}
 	if len(msg.BodyRTFHTML) > 0 {
 		files = append(files, formats.ConvertedFile{
-			Name:     prefixed(prefix, "body_from_rtf.html"),
+			Name:     names.unique(prefixed(prefix, "body_from_rtf.html")),
 			Data:     msg.BodyRTFHTML,
-			Category: "body",
+			Category: formats.CategoryBody,
 		})
 	}
 
 	for _, att := range msg.Attachments {
 		if att.EmbeddedMsg != nil {
-			sub := formats.SanitizeFilename(att.Filename())
-			if prefix != "" {
-				sub = prefix + "_" + sub
-			}
-			files = append(files, collectAll(att.EmbeddedMsg, sub)...)
+			files = append(files, collectAll(att.EmbeddedMsg, names.assign(prefix, att), names, inliner, textFallback)...)
 		} else if len(att.Data) > 0 {
-			name := formats.SanitizeFilename(att.Filename())
-			if prefix != "" {
-				name = prefix + "_" + name
+			f := formats.ConvertedFile{
+				Name:      names.assign(prefix, att),
+				Data:      att.Data,
+				Category:  formats.CategoryAttachment,
+				MimeType:  att.MimeType,
+				ModTime:   att.ModTime,
+				ContentID: att.ContentID,
 			}
-			files = append(files, formats.ConvertedFile{
-				Name:     name,
-				Data:     att.Data,
-				Category: "attachment",
-			})
+			if inline[att] {
+				f.Category = formats.CategoryInline
+			}
+			if f.Name != att.Filename() {
+				f.Source.Path = att.Filename()
+			}
+			files = append(files, f)
 		}
 	}
 
 	return files
 }
 
+// plainTextBody renders the best available formatted body as plain text,
+// preferring the HTML body, then HTML recovered from RTF, then native RTF.
+func plainTextBody(msg *parser.Message) []byte {
+	switch {
+	case len(msg.BodyHTML) > 0:
+		return formats.HTMLToText(msg.BodyHTML)
+	case len(msg.BodyRTFHTML) > 0:
+		return formats.HTMLToText(msg.BodyRTFHTML)
+	case len(msg.BodyRTF) > 0:
+		return parser.RTFToText(msg.BodyRTF)
+	}
+	return nil
+}
+
+// outputNames hands out the output names of one conversion. Repeated
+// names are numbered ("a.txt", "a (2).txt") so two attachments never
+// overwrite each other, and the names given to attachments and RTF bodies
+// are kept so the message view links to the files actually written.
+type outputNames struct {
+	used map[string]int
+	atts map[*parser.Attachment]string // extracted file, or embedded-message prefix
+	rtf  map[*parser.Message]string    // body.rtf written for each message
+}
+
+// newOutputNames returns an outputNames with reserved already taken.
+func newOutputNames(reserved ...string) *outputNames {
+	n := &outputNames{
+		used: make(map[string]int),
+		atts: make(map[*parser.Attachment]string),
+		rtf:  make(map[*parser.Message]string),
+	}
+	for _, name := range reserved {
+		n.used[name] = 1
+	}
+	return n
+}
+
+// unique returns name, numbered if it was handed out before.
+func (n *outputNames) unique(name string) string {
+	for {
+		n.used[name]++
+		c := n.used[name]
+		if c == 1 {
+			return name
+		}
+		ext := path.Ext(name)
+		numbered := fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), c, ext)
+		if n.used[numbered] == 0 {
+			n.used[numbered] = 1
+			return numbered
+		}
+	}
+}
+
+// assign gives att its output name under prefix and records it.
+func (n *outputNames) assign(prefix string, att *parser.Attachment) string {
+	name := n.unique(prefixed(prefix, formats.SanitizeFilename(att.Filename())))
+	n.atts[att] = name
+	return name
+}
+
 // prefixed prepends a prefix to a filename with an underscore separator.
 func prefixed(prefix, name string) string {
 	if prefix != "" {
@@ -2,9 +2,12 @@ package tnef
 
 import (
 	"encoding/binary"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/lgican/File-Converter/formats"
+	parser "github.com/lgican/File-Converter/parsers/tnef"
 )
 
 func TestConverterName(t *testing.T) {
@@ -58,3 +61,93 @@ func TestConvertInvalidData(t *testing.T) {
 		t.Fatal("expected error converting invalid data")
 	}
 }
+
+func TestRenderMessage(t *testing.T) {
+	sent := make([]byte, 8)
+	binary.LittleEndian.PutUint64(sent, 133000000000000000) // 2022-06-18
+	msg := &parser.Message{
+		BodyHTML: []byte(`<html><head><style>p{color:red}</style></head><body><p>Hello <b>world</b></p></body></html>`),
+		Attributes: []parser.MAPIAttr{
+			{Name: parser.MAPISubject, Data: []byte("Quarterly <report>\x00")},
+			{Name: parser.MAPISenderName, Data: []byte("Alice")},
+			{Name: parser.MAPISenderEmail, Data: []byte("alice@example.com")},
+			{Name: parser.MAPIClientSubmit, Data: sent},
+		},
+		Attachments: []*parser.Attachment{
+			{LongName: "q3 figures.xlsx", Data: make([]byte, 2048)},
+			{LongName: "fwd.msg", EmbeddedMsg: &parser.Message{Body: []byte("inner text")}},
+		},
+	}
+	names := newOutputNames()
+	collectAll(msg, "", names, formats.NewImageInliner(formats.RemotePolicy{Mode: formats.RemoteBlock}), false)
+	got := string(renderMessage(msg, names, ""))
+
+	for _, want := range []string{
+		"<title>Quarterly &lt;report&gt;</title>",
+		"Alice &lt;alice@example.com&gt;",
+		"<th>Date</th><td>Sat, 18 Jun 2022",
+		"<style>p{color:red}</style>",
+		"<p>Hello <b>world</b></p>",
+		`href="q3%20figures.xlsx"`,
+		"(2.0 KB)",
+		"Attached message: fwd.msg",
+		`<pre class="fc-text">inner text</pre>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "<body>") != 1 {
+		t.Errorf("message body not unwrapped:\n%s", got)
+	}
+}
+
+// Attachments sharing a name are written under numbered names, and the
+// message view links to those, with the caller's prefix, rather than to
+// the name they share.
+func TestRenderMessageLinks(t *testing.T) {
+	msg := &parser.Message{
+		Body: []byte("see attached"),
+		Attachments: []*parser.Attachment{
+			{LongName: "scan.pdf", Data: []byte("first")},
+			{LongName: "scan.pdf", Data: []byte("second")},
+			{LongName: "fwd.msg", EmbeddedMsg: &parser.Message{
+				BodyRTF:     []byte(`{\rtf1 inner}`),
+				Attachments: []*parser.Attachment{{LongName: "scan.pdf", Data: []byte("third")}},
+			}},
+		},
+	}
+	names := newOutputNames("message.html")
+	files := collectAll(msg, "", names, formats.NewImageInliner(formats.RemotePolicy{Mode: formats.RemoteBlock}), false)
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Name)
+	}
+	want := []string{"body.txt", "scan.pdf", "scan (2).pdf", "fwd.msg_body.rtf", "fwd.msg_scan.pdf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("names = %q, want %q", got, want)
+	}
+
+	page := string(renderMessage(msg, names, "mail.dat_"))
+	for _, link := range []string{
+		`href="mail.dat_scan.pdf"`,
+		`href="mail.dat_scan%20%282%29.pdf"`,
+		`href="mail.dat_fwd.msg_scan.pdf"`,
+		"see mail.dat_fwd.msg_body.rtf.",
+	} {
+		if !strings.Contains(page, link) {
+			t.Errorf("message view missing %q:\n%s", link, page)
+		}
+	}
+}
+
+func TestPlainTextBodySynthesised(t *testing.T) {
+	msg := &parser.Message{BodyHTML: []byte(`<p>Hello <a href="https://example.com">there</a></p>`)}
+	files := collectAll(msg, "", newOutputNames(), formats.NewImageInliner(formats.RemotePolicy{Mode: formats.RemoteBlock}), true)
+	if len(files) < 1 || files[0].Name != "body.txt" {
+		t.Fatalf("expected body.txt first, got %+v", files)
+	}
+	if got := string(files[0].Data); got != "Hello there <https://example.com>\n" {
+		t.Errorf("unexpected body.txt: %q", got)
+	}
+}
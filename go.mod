@@ -3,17 +3,18 @@ module github.com/lgican/File-Converter
 go 1.25.6
 
 require (
+	github.com/richardlehane/mscfb v1.0.4
+	github.com/ulikunitz/xz v0.5.15
 	github.com/xuri/excelize/v2 v2.10.0
 	golang.org/x/image v0.36.0
+	golang.org/x/net v0.46.0
 )
 
 require (
-	github.com/richardlehane/mscfb v1.0.4 // indirect
 	github.com/richardlehane/msoleps v1.0.4 // indirect
 	github.com/tiendc/go-deepcopy v1.7.1 // indirect
 	github.com/xuri/efp v0.0.1 // indirect
 	github.com/xuri/nfp v0.0.2-0.20250530014748-2ddeb826f9a9 // indirect
 	golang.org/x/crypto v0.43.0 // indirect
-	golang.org/x/net v0.46.0 // indirect
 	golang.org/x/text v0.34.0 // indirect
 )
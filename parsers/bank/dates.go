@@ -0,0 +1,272 @@
+// dates.go reads dates as they appear in spreadsheets and CSV exports —
+// ISO, US and European numeric dates, Excel serial numbers and named
+// months — and writes them in the layouts bank formats expect, including
+// the Julian YYDDD and CYYDDD forms.
+
+package bank
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Date input layouts selectable with Field.DateInput. Any other value is
+// a pattern such as "DD.MM.YYYY" or "DD-MMM-YY" (see dateLayout).
+const (
+	DateAuto  = ""      // detect the layout from the value
+	DateISO   = "iso"   // 2024-03-15 (year, month, day)
+	DateUS    = "us"    // 03/15/2024, 3/15/24 (month, day, year)
+	DateEU    = "eu"    // 15/03/2024, 15.03.24 (day, month, year)
+	DateExcel = "excel" // 45366: days since 1899-12-30
+)
+
+// DefaultDateFormat is the output layout of date fields without a
+// DateFormat.
+const DefaultDateFormat = "YYYYMMDD"
+
+// excelEpoch is day 0 of Excel's 1900 date system; starting it on the
+// 30th absorbs Excel's phantom 29 February 1900.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// parseDate reads a date in the given input layout, or detects it:
+// YYYY-MM-DD and YYYYMMDD, MM/DD/YYYY or DD/MM/YYYY where the day is
+// over 12 (either separator, two- or four-digit years), Excel serial
+// numbers, and dates with a named month such as 15-Mar-2024 or
+// March 15, 2024. The other layouts also accept serial numbers, as
+// spreadsheets hold dates that way (see sheetRows). A time of day after
+// the date is ignored.
+func parseDate(value, input string) (time.Time, error) {
+	s := strings.TrimSpace(value)
+	if i := strings.IndexAny(s, "T "); i > 0 && strings.Contains(s[i:], ":") {
+		s = strings.TrimSpace(s[:i])
+	}
+	var t time.Time
+	var err error
+	switch {
+	case input == DateAuto:
+		t, err = detectDate(s)
+	case input == DateExcel || (isSerial(s) && (input == DateISO || input == DateUS || input == DateEU)):
+		// Spreadsheet cells without a date format hold the serial number
+		t, err = excelDate(s)
+	case input == DateISO:
+		t, err = numericDate(s, "ymd")
+	case input == DateUS:
+		t, err = numericDate(s, "mdy")
+	case input == DateEU:
+		t, err = numericDate(s, "dmy")
+	default:
+		if t, err = time.Parse(dateLayout(input), s); err != nil && isSerial(s) {
+			t, err = excelDate(s)
+		}
+	}
+	if err != nil {
+		if _, ok := err.(ambiguousDateError); ok {
+			return time.Time{}, err
+		}
+		return time.Time{}, fmt.Errorf("%q is not a valid date", value)
+	}
+	return t, nil
+}
+
+// ambiguousDateError reports a date that reads as both US and European.
+type ambiguousDateError string
+
+func (e ambiguousDateError) Error() string {
+	return fmt.Sprintf("%q is ambiguous: set the field's dateInput to %q or %q", string(e), DateUS, DateEU)
+}
+
+// detectDate works out the layout of s from its shape and values.
+func detectDate(s string) (time.Time, error) {
+	if strings.IndexFunc(s, unicode.IsLetter) >= 0 {
+		return namedMonthDate(s)
+	}
+	if isSerial(s) {
+		return excelDate(s)
+	}
+	if isDigits(s) {
+		switch len(s) {
+		case 8:
+			return numericDate(s[:4]+"-"+s[4:6]+"-"+s[6:], "ymd")
+		case 6:
+			return time.Time{}, ambiguousDateError(s)
+		}
+		return time.Time{}, fmt.Errorf("bad date")
+	}
+	parts := dateParts(s)
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("bad date")
+	}
+	if len(parts[0]) == 4 {
+		return numericDate(s, "ymd")
+	}
+	a, _ := strconv.Atoi(parts[0])
+	b, _ := strconv.Atoi(parts[1])
+	switch {
+	case a > 12:
+		return numericDate(s, "dmy")
+	case b > 12 || a == b:
+		return numericDate(s, "mdy")
+	}
+	return time.Time{}, ambiguousDateError(s)
+}
+
+// dateParts splits a numeric date on its separators.
+func dateParts(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == '-' || r == '/' || r == '.' || r == ' '
+	})
+}
+
+// numericDate reads three numbers in the given order of y, m and d. A
+// two-digit year falls in 1969-2068.
+func numericDate(s, order string) (time.Time, error) {
+	parts := dateParts(s)
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("bad date")
+	}
+	var y, m, d string
+	for i, c := range order {
+		switch c {
+		case 'y':
+			y = parts[i]
+		case 'm':
+			m = parts[i]
+		case 'd':
+			d = parts[i]
+		}
+	}
+	return makeDate(y, m, d)
+}
+
+// makeDate validates and builds a date from its digits.
+func makeDate(y, m, d string) (time.Time, error) {
+	if !isDigits(y+m+d) || len(m) > 2 || len(d) > 2 || (len(y) != 2 && len(y) != 4) {
+		return time.Time{}, fmt.Errorf("bad date")
+	}
+	year, _ := strconv.Atoi(y)
+	month, _ := strconv.Atoi(m)
+	day, _ := strconv.Atoi(d)
+	if len(y) == 2 {
+		year += 1900
+		if year < 1969 {
+			year += 100
+		}
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return time.Time{}, fmt.Errorf("bad date")
+	}
+	return t, nil
+}
+
+// isSerial reports whether s looks like an Excel serial date: up to five
+// digits, optionally with a fraction.
+func isSerial(s string) bool {
+	whole, frac, _ := strings.Cut(s, ".")
+	return len(whole) <= 5 && isDigits(whole) && (frac == "" || isDigits(frac))
+}
+
+// excelDate reads an Excel serial date; a fraction (the time) is dropped.
+func excelDate(s string) (time.Time, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	n, err := strconv.Atoi(whole)
+	if err != nil || n < 1 || (frac != "" && !isDigits(frac)) {
+		return time.Time{}, fmt.Errorf("bad date")
+	}
+	return excelEpoch.AddDate(0, 0, n), nil
+}
+
+// namedMonthDate reads a date whose month is spelled out: 15 Mar 2024,
+// 15-Mar-24, March 15th, 2024 or Fri, 15 March 2024. The day comes
+// before the year, which is the four-digit number or else the last one.
+func namedMonthDate(s string) (time.Time, error) {
+	tokens := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var month int
+	var numbers []string
+	year := ""
+	for _, tok := range tokens {
+		lower := strings.ToLower(tok)
+		if digits := strings.TrimRight(lower, "stndrh"); digits != "" && isDigits(digits) {
+			if len(digits) == 4 && year == "" {
+				year = digits
+			} else {
+				numbers = append(numbers, digits)
+			}
+			continue
+		}
+		if m := monthNumber(lower); m > 0 && month == 0 {
+			month = m
+			continue
+		}
+		if !isWeekday(lower) {
+			return time.Time{}, fmt.Errorf("bad date")
+		}
+	}
+	if year == "" && len(numbers) == 2 {
+		year, numbers = numbers[1], numbers[:1]
+	}
+	if month == 0 || year == "" || len(numbers) != 1 {
+		return time.Time{}, fmt.Errorf("bad date")
+	}
+	return makeDate(year, strconv.Itoa(month), numbers[0])
+}
+
+// monthNumber returns the month named or abbreviated (to at least three
+// letters) by s, or 0.
+func monthNumber(s string) int {
+	if len(s) < 3 {
+		return 0
+	}
+	for m := time.January; m <= time.December; m++ {
+		if strings.HasPrefix(strings.ToLower(m.String()), s) {
+			return int(m)
+		}
+	}
+	return 0
+}
+
+// isWeekday reports whether s names or abbreviates a day of the week.
+func isWeekday(s string) bool {
+	if len(s) < 3 {
+		return false
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.HasPrefix(strings.ToLower(d.String()), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDate writes t in a YYYY/YY/MM/DD/DDD pattern (see dateLayout). A
+// leading C is the century digit of the IBM CYYDDD and CYYMMDD layouts:
+// 0 for the 1900s, 1 for the 2000s.
+func formatDate(t time.Time, pattern string) string {
+	if pattern == "" {
+		pattern = DefaultDateFormat
+	}
+	if rest, ok := strings.CutPrefix(pattern, "C"); ok {
+		return strconv.Itoa(t.Year()/100-19) + t.Format(dateLayout(rest))
+	}
+	return t.Format(dateLayout(pattern))
+}
+
+// checkDateRules reports date layouts that cannot work.
+func checkDateRules(f Field) error {
+	switch f.DateInput {
+	case DateAuto, DateISO, DateUS, DateEU, DateExcel:
+	default:
+		if !strings.Contains(f.DateInput, "YY") {
+			return fmt.Errorf("dateInput %q is not iso, us, eu, excel or a pattern with a year", f.DateInput)
+		}
+	}
+	if f.DateFormat != "" && !strings.Contains(f.DateFormat, "YY") {
+		return fmt.Errorf("dateFormat %q has no year", f.DateFormat)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package bank
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	march15 := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		value, input string
+		want         time.Time
+	}{
+		{"2024-03-15", DateAuto, march15},
+		{"2024/3/15", DateAuto, march15},
+		{"20240315", DateAuto, march15},
+		{"2024-03-15T10:30:00Z", DateAuto, march15},
+		{"2024-03-15 10:30", DateAuto, march15},
+		{"03/15/2024", DateAuto, march15},
+		{"3/15/24", DateAuto, march15},
+		{"15/03/2024", DateAuto, march15},
+		{"15.03.2024", DateAuto, march15},
+		{"03/03/2024", DateAuto, time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"45366", DateAuto, march15},
+		{"45366.75", DateAuto, march15},
+		{"15-Mar-2024", DateAuto, march15},
+		{"15-Mar-24", DateAuto, march15},
+		{"March 15th, 2024", DateAuto, march15},
+		{"Fri, 15 March 2024", DateAuto, march15},
+		{"sept 1 2024", DateAuto, time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"04/03/2024", DateUS, time.Date(2024, 4, 3, 0, 0, 0, 0, time.UTC)},
+		{"04/03/2024", DateEU, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"24-03-15", DateISO, march15},
+		{"45366", DateExcel, march15},
+		{"15.03.24", "DD.MM.YY", march15},
+		{"240315", "YYMMDD", march15},
+		{"45366", "DD.MM.YY", march15},
+		{"1 Jan 1970", DateAuto, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"1/13/68", DateAuto, time.Date(2068, 1, 13, 0, 0, 0, 0, time.UTC)},
+	} {
+		got, err := parseDate(tc.value, tc.input)
+		if err != nil || !got.Equal(tc.want) {
+			t.Errorf("parseDate(%q, %q) = %v, %v; want %v", tc.value, tc.input, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct{ value, input, want string }{
+		{"04/03/2024", DateAuto, `"04/03/2024" is ambiguous: set the field's dateInput to "us" or "eu"`},
+		{"240315", DateAuto, `"240315" is ambiguous`},
+		{"02/30/2024", DateAuto, `"02/30/2024" is not a valid date`},
+		{"03152024", DateAuto, `is not a valid date`},
+		{"2024-13-01", DateAuto, `is not a valid date`},
+		{"15/03/2024", DateUS, `is not a valid date`},
+		{"Smarch 15 2024", DateAuto, `is not a valid date`},
+		{"Mar 2024", DateAuto, `is not a valid date`},
+		{"soon", DateAuto, `is not a valid date`},
+	} {
+		if got, err := parseDate(tc.value, tc.input); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("parseDate(%q, %q) = %v, %v; want error %q", tc.value, tc.input, got, err, tc.want)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	d := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	for pattern, want := range map[string]string{
+		"":          "20240315",
+		"YYMMDD":    "240315",
+		"YYDDD":     "24075",
+		"CYYDDD":    "124075",
+		"CYYMMDD":   "1240315",
+		"DD-MMM-YY": "15-Mar-24",
+	} {
+		if got := formatDate(d, pattern); got != want {
+			t.Errorf("formatDate(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+	if got := formatDate(time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC), "CYYDDD"); got != "099365" {
+		t.Errorf("CYYDDD in 1999 = %q", got)
+	}
+}
+
+func TestDateFields(t *testing.T) {
+	bf, err := DecodeWithTemplate([]byte("Employee_ID,Bank_Routing,Pay_Date\n1,021000021,03/15/2024\n2,021000021,45366\n3,021000021,04/03/2024\n"), *GetTemplate("Direct_Deposit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := bf.Validate()
+	if len(errs) != 1 || errs[0].Row != 4 || errs[0].Rule != "date" || !strings.Contains(errs[0].Message, "ambiguous") {
+		t.Fatalf("Validate() = %v", errs)
+	}
+	bf.Template.Fields[6].DateInput = DateUS
+	out, err := bf.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(out), "\n")
+	for i, want := range []string{"20240315", "20240315", "20240403"} {
+		if got := lines[i][83:91]; got != want {
+			t.Errorf("row %d Pay_Date = %q, want %q", i+2, got, want)
+		}
+	}
+
+	for _, f := range []string{
+		`{"name": "X", "length": 8, "type": "date", "dateInput": "american"}`,
+		`{"name": "X", "length": 8, "type": "date", "dateFormat": "MMDD"}`,
+	} {
+		if _, err := LoadCustomTemplate([]byte(`{"fields": [` + f + `]}`)); err == nil {
+			t.Errorf("%s accepted", f)
+		}
+	}
+}
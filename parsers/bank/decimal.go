@@ -0,0 +1,257 @@
+// decimal.go reads amounts as typed in spreadsheets and CSV exports and
+// writes them as the implied-decimal digit strings bank formats expect:
+// "$1,234.5" with scale 2 is written 123450, "(12.50)" is negative, and
+// COBOL-style fields can carry the sign in an overpunched last digit.
+
+package bank
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rounding modes selectable with Field.Rounding, applied when a value has
+// more decimals than the field's Scale.
+const (
+	RoundHalfUp   = "half-up"   // default: 0.5 rounds away from zero
+	RoundHalfEven = "half-even" // 0.5 rounds to the even digit (banker's rounding)
+	RoundDown     = "down"      // truncate toward zero
+	RoundUp       = "up"        // away from zero
+	RoundNone     = "none"      // dropping a non-zero decimal is an error
+)
+
+// Sign modes selectable with Field.Sign for writing negative values.
+const (
+	SignLeading   = "leading"   // default: -123
+	SignTrailing  = "trailing"  // 123-
+	SignOverpunch = "overpunch" // the last digit carries the sign: 12C is +123, 12L is -123
+	SignNone      = "none"      // negative values are an error
+)
+
+// Parts selectable with Field.Part to split one amount over two fields.
+const (
+	PartWhole    = "whole"    // the units: 12 of 12.34
+	PartFraction = "fraction" // the decimals at Scale: 34 of 12.34
+)
+
+// Overpunched last digits 0-9 of positive and negative values.
+const (
+	overpunchPositive = "{ABCDEFGHI"
+	overpunchNegative = "}JKLMNOPQR"
+)
+
+// decimal is a parsed amount: its digits before and after the decimal
+// separator, without leading zeros in whole.
+type decimal struct {
+	neg   bool
+	whole string
+	frac  string
+}
+
+// parseDecimal reads an amount. Currency symbols, spaces and thousands
+// separators are ignored; the sign may be a leading or trailing + or -,
+// parentheses, or, when sign is SignOverpunch, an overpunched last digit.
+// sep is the decimal separator ("." or ","); the other one, spaces and
+// apostrophes group thousands.
+func parseDecimal(v, sep, sign string) (decimal, error) {
+	bad := func() (decimal, error) { return decimal{}, fmt.Errorf("%q is not a number", v) }
+	if sep == "" {
+		sep = "."
+	}
+	s := strings.TrimSpace(v)
+	var d decimal
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		d.neg, s = true, strings.TrimSpace(s[1:len(s)-1])
+	}
+	s = strings.TrimSpace(strings.Trim(s, "$€£¥"))
+	signs := 0
+	for _, sign := range []byte{'-', '+'} {
+		if rest, ok := strings.CutPrefix(s, string(sign)); ok {
+			s, signs, d.neg = rest, signs+1, d.neg || sign == '-'
+		}
+		if rest, ok := strings.CutSuffix(s, string(sign)); ok {
+			s, signs, d.neg = rest, signs+1, d.neg || sign == '-'
+		}
+	}
+	s = strings.TrimSpace(strings.Trim(s, "$€£¥"))
+	if signs > 1 {
+		return bad()
+	}
+	if n := len(s); sign == SignOverpunch && n > 1 && signs == 0 && !d.neg {
+		if i := strings.IndexByte(overpunchPositive, s[n-1]); i >= 0 {
+			s = s[:n-1] + string(rune('0'+i))
+		} else if i := strings.IndexByte(overpunchNegative, s[n-1]); i >= 0 {
+			s, d.neg = s[:n-1]+string(rune('0'+i)), true
+		}
+	}
+
+	group := ","
+	if sep == "," {
+		group = "."
+	}
+	whole, frac, _ := strings.Cut(s, sep)
+	whole = strings.NewReplacer(group, "", " ", "", "'", "", "\u00a0", "").Replace(whole)
+	if whole+frac == "" || !isDigits(whole+frac) {
+		return bad()
+	}
+	d.whole = strings.TrimLeft(whole, "0")
+	d.frac = frac
+	return d, nil
+}
+
+// float returns d as a float64, for range checks.
+func (d decimal) float() float64 {
+	var f float64
+	for _, c := range d.whole {
+		f = f*10 + float64(c-'0')
+	}
+	scale := 1.0
+	for _, c := range d.frac {
+		scale /= 10
+		f += float64(c-'0') * scale
+	}
+	if d.neg {
+		f = -f
+	}
+	return f
+}
+
+// scaled returns d's digits with scale implied decimals, rounding dropped
+// decimals with mode.
+func (d decimal) scaled(scale int, mode string) (string, error) {
+	frac := d.frac
+	for len(frac) < scale {
+		frac += "0"
+	}
+	digits, dropped := d.whole+frac[:scale], frac[scale:]
+	if strings.Trim(dropped, "0") != "" {
+		up := false
+		switch mode {
+		case "", RoundHalfUp:
+			up = dropped[0] >= '5'
+		case RoundHalfEven:
+			last := byte('0')
+			if digits != "" {
+				last = digits[len(digits)-1]
+			}
+			up = dropped[0] > '5' || (dropped[0] == '5' && (strings.Trim(dropped[1:], "0") != "" || (last-'0')%2 == 1))
+		case RoundDown:
+		case RoundUp:
+			up = true
+		case RoundNone:
+			return "", fmt.Errorf("has more than %d decimals", scale)
+		default:
+			return "", fmt.Errorf("unknown rounding mode %q", mode)
+		}
+		if up {
+			digits = increment(digits)
+		}
+	}
+	if digits = strings.TrimLeft(digits, "0"); digits == "" {
+		digits = "0"
+	}
+	return digits, nil
+}
+
+// increment adds one to a string of decimal digits.
+func increment(digits string) string {
+	b := []byte(digits)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < '9' {
+			b[i]++
+			return string(b)
+		}
+		b[i] = '0'
+	}
+	return "1" + string(b)
+}
+
+// numericText returns the digits a numeric field writes for value: the
+// amount at the field's Scale, the Part it selects, and its sign. A field
+// without a Scale writes whole numbers (a split amount has 2 decimals)
+// and rejects decimals unless it sets Rounding. An empty value is zero.
+func numericText(value string, f Field) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		value = "0"
+	}
+	d, err := parseDecimal(value, f.Decimal, f.Sign)
+	if err != nil {
+		return "", err
+	}
+	scale, mode := 0, f.Rounding
+	if f.Scale != nil {
+		scale = *f.Scale
+	} else if f.Part != "" {
+		scale = 2
+	} else if mode == "" && strings.Trim(d.frac, "0") != "" {
+		return "", fmt.Errorf("%q has decimals but the field has no scale", value)
+	}
+	digits, err := d.scaled(scale, mode)
+	if err != nil {
+		return "", fmt.Errorf("%q %w", value, err)
+	}
+	switch f.Part {
+	case "":
+	case PartWhole:
+		if digits = strings.TrimLeft(digits[:max(len(digits)-scale, 0)], "0"); digits == "" {
+			digits = "0"
+		}
+	case PartFraction:
+		digits = strings.Repeat("0", max(scale-len(digits), 0)) + digits
+		return digits[len(digits)-scale:], nil
+	default:
+		return "", fmt.Errorf("unknown part %q", f.Part)
+	}
+	if !d.neg || digits == "0" {
+		if f.Sign == SignOverpunch {
+			return overpunch(digits, overpunchPositive), nil
+		}
+		return digits, nil
+	}
+	switch f.Sign {
+	case "", SignLeading:
+		return "-" + digits, nil
+	case SignTrailing:
+		return digits + "-", nil
+	case SignOverpunch:
+		return overpunch(digits, overpunchNegative), nil
+	case SignNone:
+		return "", fmt.Errorf("%q is negative", value)
+	default:
+		return "", fmt.Errorf("unknown sign mode %q", f.Sign)
+	}
+}
+
+// overpunch replaces the last digit with its signed form from table.
+func overpunch(digits, table string) string {
+	n := len(digits) - 1
+	return digits[:n] + string(table[digits[n]-'0'])
+}
+
+// checkNumericRules reports numeric settings that are not recognized.
+func checkNumericRules(f Field) error {
+	switch f.Rounding {
+	case "", RoundHalfUp, RoundHalfEven, RoundDown, RoundUp, RoundNone:
+	default:
+		return fmt.Errorf("unknown rounding mode %q", f.Rounding)
+	}
+	switch f.Sign {
+	case "", SignLeading, SignTrailing, SignOverpunch, SignNone:
+	default:
+		return fmt.Errorf("unknown sign mode %q", f.Sign)
+	}
+	switch f.Part {
+	case "", PartWhole, PartFraction:
+	default:
+		return fmt.Errorf("unknown part %q", f.Part)
+	}
+	switch f.Decimal {
+	case "", ".", ",":
+	default:
+		return fmt.Errorf("decimal separator %q must be \".\" or \",\"", f.Decimal)
+	}
+	if f.Scale != nil && (*f.Scale < 0 || *f.Scale > 18) {
+		return fmt.Errorf("scale %d is out of range 0-18", *f.Scale)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package bank
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNumericText(t *testing.T) {
+	two, zero := 2, 0
+	for _, tc := range []struct {
+		value string
+		field Field
+		want  string
+	}{
+		{"12.5", Field{Scale: &two}, "1250"},
+		{"12.50", Field{Scale: &two}, "1250"},
+		{"$1,234.5", Field{Scale: &two}, "123450"},
+		{"12.00", Field{}, "12"}, // unset scale writes whole numbers
+		{"12.5", Field{Rounding: RoundDown}, "12"},
+		{"", Field{Scale: &two}, "0"},
+		{"1.234,56", Field{Scale: &two, Decimal: ","}, "123456"},
+		{"1 234,5 €", Field{Scale: &two, Decimal: ","}, "123450"},
+		{"-12.34", Field{Scale: &two}, "-1234"},
+		{"12.34-", Field{Scale: &two}, "-1234"},
+		{"(12.34)", Field{Scale: &two}, "-1234"},
+		{"$-12.34", Field{Scale: &two, Sign: SignTrailing}, "1234-"},
+		{"-12.34", Field{Scale: &two, Sign: SignOverpunch}, "123M"},
+		{"12.34", Field{Scale: &two, Sign: SignOverpunch}, "123D"},
+		{"123M", Field{Scale: &zero, Sign: SignOverpunch}, "123M"},
+		{"-120", Field{Sign: SignOverpunch}, "12}"},
+		{"2.345", Field{Scale: &two}, "235"},
+		{"2.345", Field{Scale: &two, Rounding: RoundHalfEven}, "234"},
+		{"2.355", Field{Scale: &two, Rounding: RoundHalfEven}, "236"},
+		{"2.3451", Field{Scale: &two, Rounding: RoundHalfEven}, "235"},
+		{"2.349", Field{Scale: &two, Rounding: RoundDown}, "234"},
+		{"2.341", Field{Scale: &two, Rounding: RoundUp}, "235"},
+		{"9.999", Field{Scale: &two}, "1000"},
+		{"2.340", Field{Scale: &two, Rounding: RoundNone}, "234"},
+		{"1234.56", Field{Part: PartWhole}, "1234"},
+		{"1234.56", Field{Part: PartFraction}, "56"},
+		{"0.5", Field{Part: PartFraction}, "50"},
+		{"-9.996", Field{Part: PartWhole}, "-10"},
+		{"-9.996", Field{Part: PartFraction}, "00"},
+	} {
+		got, err := numericText(tc.value, tc.field)
+		if err != nil || got != tc.want {
+			t.Errorf("numericText(%q, %+v) = %q, %v; want %q", tc.value, tc.field, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		value string
+		field Field
+		want  string
+	}{
+		{"n/a", Field{}, `"n/a" is not a number`},
+		{"1.234,56", Field{}, `"1.234,56" is not a number`},
+		{"-12-", Field{}, `"-12-" is not a number`},
+		{"2.345", Field{Scale: &two, Rounding: RoundNone}, `"2.345" has more than 2 decimals`},
+		{"-1", Field{Sign: SignNone}, `"-1" is negative`},
+		{"12.5", Field{}, `"12.5" has decimals but the field has no scale`},
+		{"12A", Field{}, `"12A" is not a number`},
+		{"1234B", Field{Scale: &two}, `"1234B" is not a number`},
+	} {
+		if got, err := numericText(tc.value, tc.field); err == nil || err.Error() != tc.want {
+			t.Errorf("numericText(%q) = %q, %v; want error %q", tc.value, got, err, tc.want)
+		}
+	}
+}
+
+func TestAmountParts(t *testing.T) {
+	tpl, err := LoadCustomTemplate([]byte(`{
+  "name": "Split amounts",
+  "fields": [
+    {"name": "Amount_1", "source": "Amount", "part": "whole", "position": 0, "length": 6, "type": "numeric", "padding": "0", "align": "right"},
+    {"name": "Amount_2", "source": "Amount", "part": "fraction", "scale": 2, "position": 6, "length": 2, "type": "numeric"},
+    {"name": "Net", "position": 8, "length": 6, "type": "numeric", "scale": 2, "padding": "0", "align": "right"}
+  ],
+  "footer": {"fields": [
+    {"name": "Total", "position": 0, "length": 8, "type": "numeric", "padding": "0", "align": "right", "value": "{sum(Net)}"}
+  ]}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf, err := DecodeWithTemplate([]byte("Amount,Net\n\"$1,234.56\",10\n0.07,-2.5\n"), *tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := bf.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "00123456001000\n00000007-00250\n00000750"
+	if string(out) != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", out, want)
+	}
+
+	bf.Records[1]["Net"] = "ten"
+	if _, err := bf.Format(); err == nil || !strings.Contains(err.Error(), `row 3, Net: "ten" is not a number`) {
+		t.Errorf("non-numeric input: %v", err)
+	}
+
+	for _, f := range []string{
+		`{"name": "X", "length": 5, "type": "numeric", "rounding": "nearest"}`,
+		`{"name": "X", "length": 5, "type": "numeric", "sign": "suffix"}`,
+		`{"name": "X", "length": 5, "type": "numeric", "part": "cents"}`,
+		`{"name": "X", "length": 5, "type": "numeric", "decimal": ";"}`,
+	} {
+		if _, err := LoadCustomTemplate([]byte(`{"fields": [` + f + `]}`)); err == nil {
+			t.Errorf("%s accepted", f)
+		}
+	}
+}
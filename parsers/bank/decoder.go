@@ -15,7 +15,12 @@ func Decode(data []byte, templateKey string) (*BankFile, error) {
 	if tpl == nil {
 		return nil, fmt.Errorf("template not found: %s", templateKey)
 	}
-	return DecodeWithTemplate(data, *tpl)
+	bf, err := DecodeWithTemplate(data, *tpl)
+	if err != nil {
+		return nil, err
+	}
+	bf.TemplateKey = templateKey
+	return bf, nil
 }
 
 // DecodeWithTemplate parses CSV data using a specific template.
@@ -61,21 +66,40 @@ func DecodeWithTemplate(data []byte, template Template) (*BankFile, error) {
 	}, nil
 }
 
-// Format converts the BankFile to fixed-width formatted output.
-func (bf *BankFile) Format() []byte {
+// Format converts the BankFile to fixed-width formatted output: one line
+// per record, or the file the template's Format generator writes.
+func (bf *BankFile) Format() ([]byte, error) {
+	if bf.parsed != nil {
+		return nil, fmt.Errorf("records read from a fixed-width file can only be written as CSV or Excel")
+	}
+	switch bf.Template.Format {
+	case FormatFixedWidth:
+	case FormatNACHA:
+		if _, err := bf.checkedRows(false); err != nil {
+			return nil, err
+		}
+		return bf.formatNACHA()
+	default:
+		return nil, fmt.Errorf("unknown template format %q", bf.Template.Format)
+	}
+	rows, err := bf.checkedRows(true)
+	if err != nil {
+		return nil, err
+	}
 	var lines []string
-	for _, record := range bf.Records {
-		line := formatRecord(record, bf.Template)
+	for _, row := range rows {
+		line := formatRecord(row.record, row.fields)
 		lines = append(lines, line)
 	}
-	return []byte(strings.Join(lines, "\n"))
+	return []byte(strings.Join(lines, "\n")), nil
 }
 
-// formatRecord formats a single record according to the template.
-func formatRecord(record Record, template Template) string {
+// formatRecord formats a single record according to the fields of the
+// template or of a header, trailer or batch record.
+func formatRecord(record Record, fields []Field) string {
 	// Calculate total line length
 	maxPosition := 0
-	for _, field := range template.Fields {
+	for _, field := range fields {
 		endPos := field.Position + field.Length
 		if endPos > maxPosition {
 			maxPosition = endPos
@@ -89,8 +113,8 @@ func formatRecord(record Record, template Template) string {
 	}
 
 	// Format each field
-	for _, field := range template.Fields {
-		value := getFieldValue(record, field.Name)
+	for _, field := range fields {
+		value := fieldValue(record, field)
 		formatted := formatValue(value, field)
 
 		// Place formatted value in line
@@ -104,6 +128,15 @@ func formatRecord(record Record, template Template) string {
 	return string(line)
 }
 
+// fieldValue retrieves the value of field's column, or of its Source
+// column if it has one.
+func fieldValue(record Record, field Field) string {
+	if field.Source != "" {
+		return getFieldValue(record, field.Source)
+	}
+	return getFieldValue(record, field.Name)
+}
+
 // getFieldValue retrieves a field value from the record (case-insensitive).
 func getFieldValue(record Record, fieldName string) string {
 	// Try exact match first
@@ -125,26 +158,8 @@ func getFieldValue(record Record, fieldName string) string {
 
 // formatValue formats a value according to field specifications.
 func formatValue(value string, field Field) string {
-	// Process based on type
-	switch field.Type {
-	case "numeric":
-		// Remove currency symbols, commas, and decimal points
-		value = strings.ReplaceAll(value, "$", "")
-		value = strings.ReplaceAll(value, ",", "")
-		value = strings.ReplaceAll(value, ".", "")
-		value = strings.TrimSpace(value)
-		if value == "" {
-			value = "0"
-		}
-
-	case "date":
-		// Format date as YYYYMMDD (remove separators)
-		value = strings.ReplaceAll(value, "-", "")
-		value = strings.ReplaceAll(value, "/", "")
-		if len(value) > 8 {
-			value = value[:8]
-		}
-	}
+	// Validate reports values that do not convert or fit as errors first
+	value, _ = fieldText(value, field)
 
 	// Truncate if too long
 	if len(value) > field.Length {
@@ -158,6 +173,10 @@ func formatValue(value string, field Field) string {
 	}
 
 	if field.Align == "right" {
+		if rest, ok := strings.CutPrefix(value, "-"); ok && padding == "0" {
+			// Keep a leading minus sign ahead of the zeros
+			return "-" + padLeft(rest, field.Length-1, '0')
+		}
 		value = padLeft(value, field.Length, padding[0])
 	} else {
 		value = padRight(value, field.Length, padding[0])
@@ -166,6 +185,27 @@ func formatValue(value string, field Field) string {
 	return value
 }
 
+// fieldText converts a value to the text its field type writes, before
+// padding. Numeric values and dates that do not parse are an error.
+func fieldText(value string, field Field) (string, error) {
+	// Process based on type
+	switch field.Type {
+	case "numeric":
+		return numericText(value, field)
+
+	case "date":
+		if strings.TrimSpace(value) == "" {
+			return "", nil
+		}
+		t, err := parseDate(value, field.DateInput)
+		if err != nil {
+			return "", err
+		}
+		return formatDate(t, field.DateFormat), nil
+	}
+	return value, nil
+}
+
 // padLeft pads a string on the left to reach the specified length.
 func padLeft(s string, length int, pad byte) string {
 	if len(s) >= length {
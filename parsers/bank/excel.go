@@ -17,7 +17,12 @@ func DecodeExcel(data []byte, templateKey string) (*BankFile, error) {
 	if tpl == nil {
 		return nil, fmt.Errorf("template not found: %s", templateKey)
 	}
-	return DecodeExcelWithTemplate(data, *tpl)
+	bf, err := DecodeExcelWithTemplate(data, *tpl)
+	if err != nil {
+		return nil, err
+	}
+	bf.TemplateKey = templateKey
+	return bf, nil
 }
 
 // DecodeExcelWithTemplate parses Excel data using a specific template.
@@ -34,7 +39,7 @@ func DecodeExcelWithTemplate(data []byte, template Template) (*BankFile, error)
 		return nil, fmt.Errorf("no sheets found in Excel file")
 	}
 
-	rows, err := f.GetRows(sheetName)
+	rows, err := sheetRows(f, sheetName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read Excel rows: %w", err)
 	}
@@ -67,6 +72,75 @@ func DecodeExcelWithTemplate(data []byte, template Template) (*BankFile, error)
 	}, nil
 }
 
+// sheetRows returns the cells of a sheet as displayed, except that cells
+// with a date number format hold their serial number: displayed dates
+// follow the reader's locale, such as 03-04-24 for the default format,
+// while the serial reads unambiguously as a date.
+func sheetRows(f *excelize.File, sheet string) ([][]string, error) {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+	dates := make(map[int]bool) // per style: whether it formats dates
+	for i, row := range rows {
+		for j, v := range row {
+			if i >= len(raw) || j >= len(raw[i]) || raw[i][j] == v || !isSerial(raw[i][j]) {
+				continue
+			}
+			cell, err := excelize.CoordinatesToCellName(j+1, i+1)
+			if err != nil {
+				return nil, err
+			}
+			id, err := f.GetCellStyle(sheet, cell)
+			if err != nil {
+				return nil, err
+			}
+			date, ok := dates[id]
+			if !ok {
+				style, err := f.GetStyle(id)
+				date = err == nil && isDateFormat(style)
+				dates[id] = date
+			}
+			if date {
+				row[j] = raw[i][j]
+			}
+		}
+	}
+	return rows, nil
+}
+
+// isDateFormat reports whether a cell style's number format shows a date:
+// one of Excel's built-in date formats, or a custom format with a day or
+// year outside quoted text, such as dd/mm/yyyy.
+func isDateFormat(style *excelize.Style) bool {
+	switch n := style.NumFmt; {
+	case n >= 14 && n <= 17, n == 22, n >= 27 && n <= 31, n >= 34 && n <= 36, n >= 50 && n <= 58:
+		return true
+	}
+	if style.CustomNumFmt == nil {
+		return false
+	}
+	quoted, bracketed := false, false
+	for _, c := range strings.ToLower(*style.CustomNumFmt) {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			bracketed = true
+		case c == ']':
+			bracketed = false
+		case !bracketed && (c == 'd' || c == 'y'):
+			return true
+		}
+	}
+	return false
+}
+
 // DecodeAuto detects the file type (CSV or Excel) and parses accordingly.
 func DecodeAuto(data []byte, templateKey string) (*BankFile, error) {
 	if isExcelFile(data) {
@@ -91,8 +165,15 @@ func isExcelFile(data []byte) bool {
 	return false
 }
 
-// FormatAsCSV converts the BankFile records back to CSV format.
+// FormatAsCSV converts the BankFile records back to CSV format. The first
+// row names the template's fields; header, trailer and batch records are
+// written in place as rows of their own fields.
 func (bf *BankFile) FormatAsCSV() ([]byte, error) {
+	rows, err := bf.checkedRows(true)
+	if err != nil {
+		return nil, err
+	}
+
 	var buf bytes.Buffer
 	w := csv.NewWriter(&buf)
 
@@ -106,14 +187,8 @@ func (bf *BankFile) FormatAsCSV() ([]byte, error) {
 	}
 
 	// Write data rows (apply the same formatting rules as fixed-width output)
-	for _, record := range bf.Records {
-		var row []string
-		for _, field := range bf.Template.Fields {
-			raw := getFieldValue(record, field.Name)
-			formatted := strings.TrimSpace(formatValue(raw, field))
-			row = append(row, formatted)
-		}
-		if err := w.Write(row); err != nil {
+	for _, r := range rows {
+		if err := w.Write(r.values()); err != nil {
 			return nil, fmt.Errorf("failed to write CSV row: %w", err)
 		}
 	}
@@ -122,8 +197,25 @@ func (bf *BankFile) FormatAsCSV() ([]byte, error) {
 	return buf.Bytes(), w.Error()
 }
 
+// values returns the row's formatted field values without padding.
+func (r outputRow) values() []string {
+	var out []string
+	for _, field := range r.fields {
+		raw := fieldValue(r.record, field)
+		out = append(out, strings.TrimSpace(formatValue(raw, field)))
+	}
+	return out
+}
+
 // FormatAsExcel converts the BankFile records to an Excel (.xlsx) file.
+// Header, trailer and batch records are written in italics between the
+// detail rows.
 func (bf *BankFile) FormatAsExcel() ([]byte, error) {
+	rows, err := bf.checkedRows(true)
+	if err != nil {
+		return nil, err
+	}
+
 	f := excelize.NewFile()
 	defer f.Close()
 
@@ -143,14 +235,21 @@ func (bf *BankFile) FormatAsExcel() ([]byte, error) {
 		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
 		f.SetCellStyle(sheetName, cell, cell, style)
 	}
+	recordStyle, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Italic: true},
+	})
 
 	// Write data rows (apply the same formatting rules as fixed-width output)
-	for rowIdx, record := range bf.Records {
-		for colIdx, field := range bf.Template.Fields {
+	for rowIdx, r := range rows {
+		values := r.values()
+		for colIdx, v := range values {
 			cell, _ := excelize.CoordinatesToCellName(colIdx+1, rowIdx+2)
-			raw := getFieldValue(record, field.Name)
-			formatted := strings.TrimSpace(formatValue(raw, field))
-			f.SetCellValue(sheetName, cell, formatted)
+			f.SetCellValue(sheetName, cell, v)
+		}
+		if !r.detail && len(values) > 0 {
+			first, _ := excelize.CoordinatesToCellName(1, rowIdx+2)
+			last, _ := excelize.CoordinatesToCellName(len(values), rowIdx+2)
+			f.SetCellStyle(sheetName, first, last, recordStyle)
 		}
 	}
 
@@ -172,6 +271,6 @@ func (bf *BankFile) FormatAsExcel() ([]byte, error) {
 }
 
 // FormatAsFixedWidth converts to fixed-width formatted output (same as Format()).
-func (bf *BankFile) FormatAsFixedWidth() []byte {
+func (bf *BankFile) FormatAsFixedWidth() ([]byte, error) {
 	return bf.Format()
 }
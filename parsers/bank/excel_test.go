@@ -0,0 +1,59 @@
+package bank
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Date cells are read as their serial number rather than as displayed,
+// which for Excel's default date format is an ambiguous 03-04-24.
+func TestDecodeExcelDates(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := f.GetSheetName(0)
+	iso, padded := `yyyy\-mm\-dd`, "0000000000"
+	for cell, v := range map[string]any{
+		"A1": "Employee_ID", "B1": "Pay_Date",
+		"A2": 42, "B2": 45355,
+		"A3": 7, "B3": 45356.5,
+	} {
+		if err := f.SetCellValue(sheet, cell, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for cell, style := range map[string]*excelize.Style{
+		"A2": {CustomNumFmt: &padded},
+		"B2": {NumFmt: 14},
+		"B3": {CustomNumFmt: &iso},
+	} {
+		id, err := f.NewStyle(style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.SetCellStyle(sheet, cell, cell, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	bf, err := DecodeExcel(buf.Bytes(), "Direct_Deposit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := bf.Records[0]; r["Employee_ID"] != "0000000042" || r["Pay_Date"] != "45355" {
+		t.Errorf("Records[0] = %v", r)
+	}
+	if bf.Records[1]["Pay_Date"] != "45356.5" {
+		t.Errorf("Records[1] = %v", bf.Records[1])
+	}
+	for i, want := range []string{"20240304", "20240305"} {
+		if got, err := fieldText(bf.Records[i]["Pay_Date"], GetTemplate("Direct_Deposit").Fields[6]); err != nil || got != want {
+			t.Errorf("Pay_Date %d = %q, %v; want %s", i, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+// fixedwidth.go reads fixed-width bank files — returns and confirmations
+// from the bank, or files written by Format — back into records. Each
+// line is matched to the template's detail, header, trailer or batch
+// record by its length and record-type codes, sliced by field position,
+// and its values are unpadded, with implied decimals restored and dates
+// written as YYYY-MM-DD, ready for FormatAsCSV and FormatAsExcel.
+
+package bank
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// layout is one kind of record a fixed-width file can hold.
+type layout struct {
+	what   string // "detail", "header", "trailer", "batch header", "batch trailer" or a record layout's name
+	detail bool   // the template's detail record or a layout used instead of it
+	fields []Field
+	length int
+	keys   []Field // fields with a constant Value or Allowed values, which identify the record
+	merge  bool    // the record's fields extend the detail record before it (NACHA addenda)
+}
+
+// newLayout describes a record made of fields.
+func newLayout(what string, fields []Field) layout {
+	l := layout{what: what, fields: fields, detail: what == "detail"}
+	for _, f := range fields {
+		l.length = max(l.length, f.Position+f.Length)
+		if isConstant(f.Value) || len(f.Allowed) > 0 {
+			l.keys = append(l.keys, f)
+		}
+	}
+	return l
+}
+
+// isConstant reports whether a field Value is plain text rather than an
+// {expression}.
+func isConstant(value string) bool {
+	return value != "" && !strings.Contains(value, "{")
+}
+
+// layouts returns the kinds of record t writes, the detail record first
+// and its other record layouts next. Records written after a detail
+// record extend it, like NACHA addenda.
+func (t Template) layouts() []layout {
+	if t.Format == FormatNACHA {
+		return achLayouts
+	}
+	out := []layout{newLayout("detail", t.Fields)}
+	for _, r := range t.Records {
+		l := newLayout(r.Name, r.Fields)
+		l.detail = r.Emit == "" || r.Emit == EmitInstead
+		l.merge = r.Emit == EmitAfter
+		out = append(out, l)
+	}
+	if t.Header != nil {
+		out = append(out, newLayout("header", t.Header.Fields))
+	}
+	if t.Footer != nil {
+		out = append(out, newLayout("trailer", t.Footer.Fields))
+	}
+	if t.Batch != nil && t.Batch.Header != nil {
+		out = append(out, newLayout("batch header", t.Batch.Header.Fields))
+	}
+	if t.Batch != nil && t.Batch.Footer != nil {
+		out = append(out, newLayout("batch trailer", t.Batch.Footer.Fields))
+	}
+	return out
+}
+
+// match reports whether line can be a record of l, and how strongly: each
+// record-type code matched counts 3, an exact line length 2, and a header
+// on the first line, a trailer on the last or a detail record between
+// them 1. Lines may be shorter than the record, as trailing spaces are
+// often stripped.
+func (l layout) match(line string, first, last bool) (int, bool) {
+	if len(line) > l.length {
+		return 0, false
+	}
+	score := 0
+	for _, k := range l.keys {
+		v := strings.TrimSpace(slice(line, k))
+		if isConstant(k.Value) {
+			if !strings.EqualFold(v, strings.TrimSpace(formatValue(k.Value, k))) {
+				return 0, false
+			}
+		} else if !allowed(v, k.Allowed) {
+			return 0, false
+		}
+		score += 3
+	}
+	if len(line) == l.length {
+		score += 2
+	}
+	switch {
+	case l.what == "header" && first, l.what == "trailer" && last,
+		l.detail && !first && !last:
+		score++
+	}
+	return score, true
+}
+
+// allowed reports whether v is one of values, ignoring case.
+func allowed(v string, values []string) bool {
+	for _, a := range values {
+		if strings.EqualFold(v, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// pickLayout returns the layout line fits best; ties go to the earlier
+// layout.
+func pickLayout(layouts []layout, line string, first, last bool) (layout, bool) {
+	best, bestScore, found := layout{}, -1, false
+	for _, l := range layouts {
+		if score, ok := l.match(line, first, last); ok && score > bestScore {
+			best, bestScore, found = l, score, true
+		}
+	}
+	return best, found
+}
+
+// slice returns the part of line under field f, padded with spaces if the
+// line is short.
+func slice(line string, f Field) string {
+	end := f.Position + f.Length
+	if len(line) < end {
+		line += strings.Repeat(" ", end-len(line))
+	}
+	return line[f.Position:end]
+}
+
+// fixedLine is one non-blank line of a fixed-width file.
+type fixedLine struct {
+	number int // 1-based line number in the file
+	text   string
+}
+
+// fixedLines splits data into its non-blank lines.
+func fixedLines(data []byte) []fixedLine {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	var out []fixedLine
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			out = append(out, fixedLine{i + 1, line})
+		}
+	}
+	return out
+}
+
+// DecodeFixedWidth reads a fixed-width file written with the template
+// templateKey, or with the template SniffTemplates ranks first if
+// templateKey is empty.
+func DecodeFixedWidth(data []byte, templateKey string) (*BankFile, error) {
+	if templateKey == "" {
+		matches := SniffTemplates(data)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no template matches the file's records")
+		}
+		templateKey = matches[0].Key
+	}
+	tpl := GetTemplate(templateKey)
+	if tpl == nil {
+		return nil, fmt.Errorf("template not found: %s", templateKey)
+	}
+	bf, err := DecodeFixedWidthWithTemplate(data, *tpl)
+	if err != nil {
+		return nil, err
+	}
+	bf.TemplateKey = templateKey
+	return bf, nil
+}
+
+// DecodeFixedWidthWithTemplate reads a fixed-width file written with
+// template. The result's Template lists the detail fields as plain text
+// columns and its Records hold the detail records; header, trailer and
+// batch records keep their place in CSV and Excel output, which write the
+// records as read. Values that do not read as their field's type are
+// returned as a *ValidationError.
+func DecodeFixedWidthWithTemplate(data []byte, template Template) (*BankFile, error) {
+	lines := fixedLines(data)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no records")
+	}
+	layouts := template.layouts()
+	detail := readableFields(layouts)
+	bf := &BankFile{Template: Template{Name: template.Name, Description: template.Description, Fields: detail}}
+	bf.parsed = []outputRow{}
+
+	var errs []FieldError
+	var last Record
+	for i, line := range lines {
+		if template.Format == FormatNACHA && strings.Trim(line.text, "9") == "" {
+			continue // block filler
+		}
+		l, ok := pickLayout(layouts, line.text, i == 0, i == len(lines)-1)
+		if !ok {
+			return nil, fmt.Errorf("line %d (%d characters) matches no record of template %s", line.number, len(line.text), template.Name)
+		}
+		where := fmt.Sprintf("line %d", line.number)
+		rec := make(Record, len(l.fields))
+		for _, f := range l.fields {
+			raw := slice(line.text, f)
+			v, err := readValue(raw, f)
+			if err != nil {
+				errs = append(errs, FieldError{Record: where, Column: f.Name, Value: strings.TrimSpace(raw), Rule: f.Type, Message: err.Error()})
+			}
+			rec[f.Name] = v
+		}
+
+		if l.merge {
+			if last == nil {
+				return nil, fmt.Errorf("%s: %s record without a detail record before it", where, l.what)
+			}
+			for _, f := range l.fields {
+				if !isConstant(f.Value) {
+					last[f.Name] = rec[f.Name]
+				}
+			}
+			continue
+		}
+		if l.detail {
+			last = rec
+			bf.Records = append(bf.Records, rec)
+			bf.parsed = append(bf.parsed, outputRow{fields: detail, record: rec, detail: true, row: line.number})
+			continue
+		}
+		bf.parsed = append(bf.parsed, outputRow{fields: readable(l.fields), record: rec, name: l.what + " (" + where + ")"})
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return bf, nil
+}
+
+// readableFields returns the columns of decoded detail records: the
+// detail fields, then those that other detail layouts and merged records
+// add to them.
+func readableFields(layouts []layout) []Field {
+	var fields []Field
+	seen := make(map[string]bool)
+	for _, l := range layouts {
+		switch {
+		case l.what == "detail":
+			fields = append(readable(l.fields), fields...)
+			for _, f := range l.fields {
+				seen[f.Name] = true
+			}
+		case l.detail || l.merge:
+			for _, f := range l.fields {
+				if !isConstant(f.Value) && !seen[f.Name] {
+					seen[f.Name] = true
+					fields = append(fields, readable([]Field{f})...)
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// readable returns text fields, without padding or rules, wide enough for
+// the values readValue returns for fields.
+func readable(fields []Field) []Field {
+	out := make([]Field, len(fields))
+	for i, f := range fields {
+		out[i] = Field{Name: f.Name, Position: f.Position, Length: f.Length, Type: "text", Description: f.Description}
+		switch f.Type {
+		case "numeric":
+			out[i].Length += 2 // sign and decimal point
+		case "date":
+			out[i].Length = max(f.Length, len("2006-01-02"))
+		}
+	}
+	return out
+}
+
+// readValue reverses formatValue for the text under a field. Padding is
+// stripped from the left of right-aligned fields only, since zeros
+// filling a left-aligned field cannot be told from trailing zeros of the
+// value; spaces are always trimmed. Numeric fields get their implied
+// decimals back and dates are written YYYY-MM-DD.
+func readValue(raw string, f Field) (string, error) {
+	switch f.Type {
+	case "numeric":
+		return readNumber(strings.TrimSpace(raw), f)
+	case "date":
+		return readDate(strings.TrimSpace(raw), f.DateFormat)
+	}
+	if f.Align == "right" && f.Padding != "" {
+		raw = strings.TrimLeft(raw, f.Padding)
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+// readNumber reads the digits of a numeric field, with its sign in any
+// of the forms numericText writes, and restores the field's implied
+// decimals. The whole and fraction parts of a split amount are returned
+// as written.
+func readNumber(s string, f Field) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	d, err := parseDecimal(s, ".", f.Sign)
+	if err != nil {
+		return "", err
+	}
+	if f.Part == PartFraction {
+		return s, nil
+	}
+	if f.Part == "" && f.Scale != nil && *f.Scale > 0 && d.frac == "" {
+		digits := padLeft(d.whole, *f.Scale, '0')
+		d.whole, d.frac = strings.TrimLeft(digits[:len(digits)-*f.Scale], "0"), digits[len(digits)-*f.Scale:]
+	}
+	v := d.whole
+	if v == "" {
+		v = "0"
+	}
+	if d.frac != "" {
+		v += "." + d.frac
+	}
+	if d.neg && strings.Trim(v, "0.") != "" {
+		v = "-" + v
+	}
+	return v, nil
+}
+
+// readDate reads a date written in pattern (see formatDate) and returns
+// it as YYYY-MM-DD. Blank and all-zero dates are empty.
+func readDate(s, pattern string) (string, error) {
+	if strings.Trim(s, "0") == "" {
+		return "", nil
+	}
+	if pattern == "" {
+		pattern = DefaultDateFormat
+	}
+	century := -1
+	if rest, ok := strings.CutPrefix(pattern, "C"); ok {
+		if s[0] < '0' || s[0] > '9' {
+			return "", fmt.Errorf("%q is not a valid %s date", s, pattern)
+		}
+		century, s, pattern = int(s[0]-'0'), s[1:], rest
+	}
+	t, err := time.Parse(dateLayout(pattern), s)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid %s date", s, pattern)
+	}
+	if century >= 0 {
+		t = time.Date(1900+100*century+t.Year()%100, 1, t.YearDay(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+// TemplateMatch is how well a fixed-width file fits a template.
+type TemplateMatch struct {
+	Key    string `json:"key"`
+	Score  int    `json:"score"`
+	Lines  int    `json:"lines"`  // lines sampled
+	Exact  int    `json:"exact"`  // lines as long as their record
+	Codes  int    `json:"codes"`  // record-type codes matched
+	Errors int    `json:"errors"` // values that do not read as their field's type
+}
+
+// sniffLines bounds how many lines SniffTemplates samples.
+const sniffLines = 200
+
+// SniffTemplates ranks the templates a fixed-width file may have been
+// written with, best first. A template is a candidate if each sampled
+// line fits one of its records and at least half of them have the
+// record's exact length; record-type codes and exact lengths raise its
+// score and values that do not read as their field's type lower it.
+func SniffTemplates(data []byte) []TemplateMatch {
+	lines := fixedLines(data)
+	lines = lines[:min(len(lines), sniffLines)]
+	if len(lines) == 0 {
+		return nil
+	}
+	var out []TemplateMatch
+	for _, t := range ListTemplates() {
+		m := TemplateMatch{Key: t.Key}
+		layouts := t.layouts()
+		fits := true
+		for i, line := range lines {
+			if t.Format == FormatNACHA && strings.Trim(line.text, "9") == "" {
+				continue
+			}
+			l, ok := pickLayout(layouts, line.text, i == 0, i == len(lines)-1)
+			if !ok {
+				fits = false
+				break
+			}
+			m.Lines++
+			m.Codes += len(l.keys)
+			if len(line.text) == l.length {
+				m.Exact++
+			}
+			for _, f := range l.fields {
+				if _, err := readValue(slice(line.text, f), f); err != nil {
+					m.Errors++
+				}
+			}
+		}
+		if !fits || m.Lines == 0 || 2*m.Exact < m.Lines {
+			continue
+		}
+		m.Score = 3*m.Codes + 2*m.Exact - 4*m.Errors
+		out = append(out, m)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
@@ -0,0 +1,170 @@
+package bank
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeFixedWidth(t *testing.T) {
+	bf := decodeBatched(t)
+	out, err := bf.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := DecodeFixedWidthWithTemplate(out, bf.Template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Records) != 3 || read.Records[2]["Branch"] != "0002" {
+		t.Errorf("Records = %v", read.Records)
+	}
+	csv, err := read.FormatAsCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Type,Seq,Branch,Amount\n" +
+		"H,20240203,24034\n" +
+		"B,1,BR0001\n" +
+		"D,1,0001,10.00\n" +
+		"D,2,0001,2.25\n" +
+		"C,2,1225\n" +
+		"B,2,BR0002\n" +
+		"D,3,0002,1000.50\n" +
+		"C,1,100050\n" +
+		"T,2,9,101275\n"
+	if string(csv) != want {
+		t.Errorf("FormatAsCSV() =\n%s\nwant\n%s", csv, want)
+	}
+	if _, err := read.Format(); err == nil {
+		t.Error("decoded records written as fixed-width")
+	}
+
+	if _, err := DecodeFixedWidthWithTemplate([]byte("X0010001000010000\n"), bf.Template); err == nil || !strings.Contains(err.Error(), "line 1 (17 characters) matches no record") {
+		t.Errorf("unknown record: %v", err)
+	}
+}
+
+func TestDecodeFixedWidthSniff(t *testing.T) {
+	bf, err := DecodeWithTemplate([]byte("Employee_ID,First_Name,Last_Name,Bank_Routing,Account_Number,Amount,Pay_Date\n"+
+		"42,Jane,Doe,021000021,111,1250.5,2024-03-15\n7,John,Roe,011000015,222,-3,2024-03-16\n"), *GetTemplate("Direct_Deposit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := bf.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := SniffTemplates(out); len(m) == 0 || m[0].Key != "Direct_Deposit" || m[0].Exact != 2 {
+		t.Fatalf("SniffTemplates() = %+v", m)
+	}
+	read, err := DecodeFixedWidth(out, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	csv, _ := read.FormatAsCSV()
+	want := "Employee_ID,First_Name,Last_Name,Bank_Routing,Account_Number,Amount,Pay_Date\n" +
+		"42,Jane,Doe,021000021,111,1250.50,2024-03-15\n" +
+		"7,John,Roe,011000015,222,-3.00,2024-03-16\n"
+	if string(csv) != want {
+		t.Errorf("FormatAsCSV() =\n%s\nwant\n%s", csv, want)
+	}
+
+	bad := []byte(strings.Replace(string(out), "000000125050", "0000001250X0", 1))
+	_, err = DecodeFixedWidth(bad, "Direct_Deposit")
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors[0].Error() != `line 1, Amount: "0000001250X0" is not a number` {
+		t.Errorf("bad amount: %v", err)
+	}
+	if m := SniffTemplates([]byte("Dear Bob,\nthanks for the note.\n")); len(m) != 0 {
+		t.Errorf("prose sniffed as %+v", m)
+	}
+}
+
+func TestDecodeNACHA(t *testing.T) {
+	csv := "Routing_Number,Account_Number,Amount,Name,Transaction_Code,ID_Number,Addenda\n" +
+		"021000021,111222,\"$1,250.50\",Jane Doe,credit,E1,Bonus\n" +
+		"011000015,333444,99,John Roe,debit,E2,\n"
+	bf, err := DecodeWithTemplate([]byte(csv), *GetTemplate("ACH_Payment"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf.Profile = testProfile()
+	bf.Created = time.Date(2024, 2, 28, 9, 30, 0, 0, time.UTC)
+	out, err := bf.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m := SniffTemplates(out); len(m) == 0 || m[0].Key != "ACH_Payment" {
+		t.Fatalf("SniffTemplates() = %+v", m)
+	}
+	read, err := DecodeFixedWidth(out, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Records) != 2 {
+		t.Fatalf("Records = %v", read.Records)
+	}
+	for field, want := range map[string]string{
+		"Transaction_Code": "22", "Routing_Number": "021000021", "Account_Number": "111222",
+		"Amount": "1250.50", "Name": "JANE DOE", "ID_Number": "E1", "Addenda": "BONUS",
+		"Trace_Number": "091000010000001",
+	} {
+		if got := read.Records[0][field]; got != want {
+			t.Errorf("%s = %q, want %q", field, got, want)
+		}
+	}
+	if got := read.Records[1]["Addenda"]; got != "" {
+		t.Errorf("second entry Addenda = %q", got)
+	}
+	rows, err := read.rows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 6 || rows[0].record["File_Creation_Date"] != "2024-02-28" ||
+		rows[1].record["Effective_Date"] != "2024-02-29" || rows[4].record["Total_Debit"] != "99.00" ||
+		rows[5].name != "trailer (line 7)" || rows[5].record["Total_Credit"] != "1250.50" {
+		for _, r := range rows {
+			t.Logf("%s %v", r.name, r.record)
+		}
+		t.Error("unexpected header, batch or trailer records")
+	}
+}
+
+func TestReadValue(t *testing.T) {
+	scale := 2
+	for _, tc := range []struct {
+		raw  string
+		f    Field
+		want string
+	}{
+		{"0000012550", Field{Type: "numeric", Scale: &scale}, "125.50"},
+		{"0000000000", Field{Type: "numeric", Scale: &scale}, "0.00"},
+		{"-000000125", Field{Type: "numeric", Scale: &scale}, "-1.25"},
+		{"00000125- ", Field{Type: "numeric", Scale: &scale}, "-1.25"},
+		{"00000012L", Field{Type: "numeric", Scale: &scale, Sign: SignOverpunch}, "-1.23"},
+		{"0000012.50", Field{Type: "numeric"}, "12.50"},
+		{"   ", Field{Type: "numeric", Scale: &scale}, ""},
+		{"05", Field{Type: "numeric", Part: PartFraction}, "05"},
+		{"0000000042", Field{Type: "text", Padding: "0", Align: "right"}, "42"},
+		{"021000020", Field{Type: "text", Padding: "0", Align: "left"}, "021000020"},
+		{"Jane      ", Field{Type: "text", Align: "left"}, "Jane"},
+		{"20240315", Field{Type: "date"}, "2024-03-15"},
+		{"24075", Field{Type: "date", DateFormat: "YYDDD"}, "2024-03-15"},
+		{"124075", Field{Type: "date", DateFormat: "CYYDDD"}, "2024-03-15"},
+		{"099365", Field{Type: "date", DateFormat: "CYYDDD"}, "1999-12-31"},
+		{"00000000", Field{Type: "date"}, ""},
+	} {
+		if got, err := readValue(tc.raw, tc.f); err != nil || got != tc.want {
+			t.Errorf("readValue(%q, %+v) = %q, %v; want %q", tc.raw, tc.f, got, err, tc.want)
+		}
+	}
+	if _, err := readValue("20241315", Field{Type: "date"}); err == nil {
+		t.Error("month 13 accepted")
+	}
+	if _, err := readValue("0000012A", Field{Type: "numeric", Scale: &scale}); err == nil {
+		t.Error("overpunch read from a field without sign overpunch")
+	}
+}
@@ -0,0 +1,572 @@
+// infer.go proposes a template for a sample fixed-width file. Field
+// boundaries come from the characters in each column across the lines —
+// where values start after padding, where digits give way to letters —
+// and, given a CSV or Excel file of the same records, from where each
+// column's values appear in the lines, which also names the fields and
+// tells amounts and dates apart from plain digits.
+
+package bank
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Inference is a proposed template and notes on what could not be worked
+// out, for a person to review before storing it.
+type Inference struct {
+	Template Template `json:"template"`
+	Notes    []string `json:"notes,omitempty"`
+}
+
+// Sample bounds: inference reads the first inferLines lines and aligns
+// the first alignRows rows of the sample with them.
+const (
+	inferLines = 1000
+	alignRows  = 200
+)
+
+// InferTemplate proposes a template named name for the fixed-width
+// records in data. sample, if not nil, is a CSV or Excel file holding the
+// same records in the same order, with a header row naming the columns.
+// The template covers every character of the lines and passes Validate.
+func InferTemplate(data, sample []byte, name string) (*Inference, error) {
+	lines := fixedLines(data)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no records")
+	}
+	lines = lines[:min(len(lines), inferLines)]
+	width := 0
+	for _, l := range lines {
+		width = max(width, len(l.text))
+	}
+	text := make([]string, len(lines))
+	for i, l := range lines {
+		text[i] = padRight(l.text, width, ' ')
+	}
+
+	inf := &Inference{}
+	var located []Field
+	if sample != nil {
+		header, rows, err := readSample(sample)
+		if err != nil {
+			return nil, fmt.Errorf("sample: %w", err)
+		}
+		if len(rows) != len(text) {
+			inf.Notes = append(inf.Notes, fmt.Sprintf("the sample has %d rows for %d lines; rows and lines are paired in order", len(rows), len(text)))
+		}
+		n := min(len(rows), len(text), alignRows)
+		var notes []string
+		located, notes = alignSample(text[:n], header, rows[:n], width)
+		inf.Notes = append(inf.Notes, notes...)
+	} else {
+		inf.Notes = append(inf.Notes, "without a sample, neighbouring values of the same kind (two numbers, two words) read as one field; check the boundaries")
+	}
+
+	// Whatever the sample did not account for is split by its characters
+	var fields []Field
+	pos := 0
+	for _, f := range append(located, Field{Position: width}) {
+		if f.Position > pos {
+			fields = append(fields, segment(text, pos, f.Position)...)
+		}
+		if f.Length > 0 {
+			fields = append(fields, f)
+		}
+		pos = f.Position + f.Length
+	}
+	nameFields(fields)
+
+	if name == "" {
+		name = "Inferred template"
+	}
+	inf.Template = Template{
+		Name:        name,
+		Description: fmt.Sprintf("Inferred from %d lines of %d characters", len(text), width),
+		Fields:      fields,
+	}
+	if err := inf.Template.Validate(); err != nil {
+		return nil, fmt.Errorf("inferred template: %w", err)
+	}
+	return inf, nil
+}
+
+// readSample reads the header and rows of a CSV or Excel file.
+func readSample(data []byte) ([]string, [][]string, error) {
+	var rows [][]string
+	if isExcelFile(data) {
+		f, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open Excel file: %w", err)
+		}
+		defer f.Close()
+		if rows, err = sheetRows(f, f.GetSheetName(0)); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		var err error
+		if rows, err = r.ReadAll(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(rows) < 2 {
+		return nil, nil, fmt.Errorf("need a header row and at least one record")
+	}
+	header := rows[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+	return header, rows[1:], nil
+}
+
+// column is what the lines hold at one character position.
+type column struct {
+	blank, digits, zeros, signs int
+	first                       byte
+	same                        bool // every line has the same character
+}
+
+// columnAt summarizes position c of the lines.
+func columnAt(text []string, c int) column {
+	col := column{first: text[0][c], same: true}
+	for _, l := range text {
+		switch ch := l[c]; {
+		case ch == ' ':
+			col.blank++
+		case ch == '-' || ch == '+':
+			col.signs++
+		case ch >= '0' && ch <= '9':
+			col.digits++
+			if ch == '0' {
+				col.zeros++
+			}
+		}
+		col.same = col.same && l[c] == col.first
+	}
+	return col
+}
+
+// kind classes a column as blank, digits and signs (ignoring blanks) or
+// text.
+func (c column) kind(lines int) byte {
+	switch {
+	case c.blank == lines:
+		return 'b'
+	case c.blank+c.digits+c.signs == lines:
+		return 'd'
+	}
+	return 't'
+}
+
+// segment splits positions from-to into fields. A field starts where a
+// value follows padding, where digits give way to other characters or
+// back, and where three or more zeros (the padding of a right-aligned
+// number) follow other digits; blank columns stay with the field before them,
+// as the padding of a left-aligned value. A constant first character on
+// every line, other than a zero, is taken to be a record-type code.
+func segment(text []string, from, to int) []Field {
+	n := len(text)
+	cols := make([]column, to-from)
+	for c := from; c < to; c++ {
+		cols[c-from] = columnAt(text, c)
+	}
+	at := func(c int) column { return cols[c-from] }
+	allZero := func(c int) bool { return c < to && at(c).zeros == n }
+
+	var fields []Field
+	start := from
+	for c := from + 1; c < to; c++ {
+		prev, cur := at(c-1).kind(n), at(c).kind(n)
+		split := false
+		switch {
+		case c == 1 && n > 1 && at(0).same && at(0).first != '0' && prev != 'b':
+			split = true // record-type code
+		case prev == 'b' && cur != 'b':
+			split = true
+		case prev != 'b' && cur != 'b' && prev != cur:
+			split = true
+		case prev == 'd' && cur == 'd' && allZero(c) && allZero(c+1) && allZero(c+2) && at(c-1).digits > at(c-1).zeros:
+			split = true
+		}
+		if split {
+			fields = append(fields, inferField(text, start, c))
+			start = c
+		}
+	}
+	return append(fields, inferField(text, start, to))
+}
+
+// inferField types the field at positions start-end from its values.
+func inferField(text []string, start, end int) Field {
+	f := Field{Position: start, Length: end - start, Type: "text", Padding: " ", Align: "left"}
+	var values []string
+	leading, trailing, zeroPadded := false, false, false
+	for _, l := range text {
+		raw := l[start:end]
+		v := strings.TrimSpace(raw)
+		if v == "" {
+			continue
+		}
+		values = append(values, v)
+		leading = leading || raw[0] == ' '
+		trailing = trailing || raw[len(raw)-1] == ' '
+		zeroPadded = zeroPadded || (len(v) > 1 && v[0] == '0')
+	}
+	if len(values) == 0 {
+		return f // filler
+	}
+	f.Description = fmt.Sprintf("e.g. %q", values[0])
+	if start == 0 && end == 1 && len(text) > 1 && columnAt(text, 0).same && values[0] != "0" {
+		f.Value = values[0]
+		return f
+	}
+
+	numeric := true
+	for _, v := range values {
+		numeric = numeric && isDigits(strings.TrimPrefix(v, "-"))
+	}
+	switch {
+	case numeric && !leading && datesIn(values, "YYYYMMDD"):
+		f.Type = "date"
+	case numeric && !leading && datesIn(values, "YYMMDD"):
+		f.Type, f.DateFormat = "date", "YYMMDD"
+	case numeric && !trailing:
+		f.Type, f.Align = "numeric", "right"
+		if zeroPadded {
+			f.Padding = "0"
+		}
+	case leading && !trailing:
+		f.Align = "right"
+	}
+	return f
+}
+
+// datesIn reports whether every value is a plausible date in pattern:
+// as long as the pattern and in 1950-2079.
+func datesIn(values []string, pattern string) bool {
+	for _, v := range values {
+		if len(v) != len(pattern) {
+			return false
+		}
+		d, err := readDate(v, pattern)
+		if err != nil || d == "" || d < "1950" || d >= "2080" {
+			return false
+		}
+	}
+	return true
+}
+
+// nameFields names the fields the sample did not: Record_Type for a
+// record-type code, Filler_N for blank ones and Field_N for the rest,
+// numbered by their place in the template.
+func nameFields(fields []Field) {
+	used := make(map[string]bool)
+	for _, f := range fields {
+		used[f.Name] = true
+	}
+	for i := range fields {
+		f := &fields[i]
+		if f.Name != "" {
+			continue
+		}
+		base := fmt.Sprintf("Field_%d", i+1)
+		switch {
+		case f.Position == 0 && f.Value != "":
+			base = "Record_Type"
+		case f.Description == "":
+			base = fmt.Sprintf("Filler_%d", i+1)
+		}
+		f.Name = base
+		for n := 2; used[f.Name]; n++ {
+			f.Name = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[f.Name] = true
+	}
+}
+
+// form is one way a sample value may have been written: as text, as the
+// digits of an amount at a scale, or as a date in a pattern.
+type form struct {
+	kind    string // "text", "numeric" or "date"
+	right   bool   // anchored by its end (right-aligned) rather than its start
+	scale   int
+	pattern string
+}
+
+// spelled returns v written in form f, or "" if it cannot be.
+func (f form) spelled(v string) string {
+	switch f.kind {
+	case "numeric":
+		d, err := parseDecimal(v, ".", "")
+		if err != nil {
+			return ""
+		}
+		digits, err := d.scaled(f.scale, RoundNone)
+		if err != nil {
+			return ""
+		}
+		return digits
+	case "date":
+		if isDigits(v) && len(v) != 8 {
+			return "" // a plain number, not a date
+		}
+		t, err := parseDate(v, DateAuto)
+		if err != nil {
+			return ""
+		}
+		return formatDate(t, f.pattern)
+	}
+	return upperASCII(v)
+}
+
+// upperASCII upper-cases the ASCII letters of s byte for byte, so that
+// positions in the result are positions in s whatever its encoding.
+func upperASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'a' <= c && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+// sampleForms are the forms tried for each sample column, preferred in
+// this order.
+var sampleForms = func() []form {
+	forms := []form{{kind: "text"}, {kind: "text", right: true}}
+	for _, p := range []string{"YYYYMMDD", "YYMMDD", "MMDDYYYY", "DDMMYYYY", "MMDDYY", "CYYDDD", "YYDDD", "YYYY-MM-DD"} {
+		forms = append(forms, form{kind: "date", pattern: p})
+	}
+	// Most decimals first: fewer would also line up, a digit short
+	for scale := 4; scale >= 0; scale-- {
+		forms = append(forms, form{kind: "numeric", right: true, scale: scale})
+	}
+	return forms
+}()
+
+// placed is a sample column found in the lines.
+type placed struct {
+	name     string
+	form     form
+	anchor   int // start of a left-anchored form, end of a right-anchored one
+	min, max int // extent of the values across the lines
+}
+
+// alignSample finds where each column of the sample appears in the lines
+// and returns fields for those found, in position order.
+func alignSample(text []string, header []string, rows [][]string, width int) ([]Field, []string) {
+	var notes []string
+	upper := make([]string, len(text))
+	for i, l := range text {
+		upper[i] = upperASCII(l)
+	}
+
+	// Long values are the least ambiguous, so they are placed first
+	order := make([]int, len(header))
+	length := make([]int, len(header))
+	for j := range header {
+		order[j] = j
+		for _, r := range rows {
+			if j < len(r) {
+				length[j] += len(strings.TrimSpace(r[j]))
+			}
+		}
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return length[b] - length[a] })
+
+	var found []placed
+	for _, j := range order {
+		if header[j] == "" || length[j] == 0 {
+			continue
+		}
+		p, ok := placeColumn(upper, rows, j, found)
+		if !ok {
+			notes = append(notes, fmt.Sprintf("column %q was not found in the lines", header[j]))
+			continue
+		}
+		p.name = strings.ReplaceAll(header[j], " ", "_")
+		found = append(found, p)
+	}
+	slices.SortFunc(found, func(a, b placed) int { return a.min - b.min })
+	return resolveBounds(text, found, width), notes
+}
+
+// placeColumn finds the first form in which column j's values all sit at
+// one anchor, clear of the columns already placed. Values found as text
+// just after zeros, or after blanks that start the line, are more likely
+// right-aligned over that padding, so a later form is preferred for them.
+func placeColumn(upper []string, rows [][]string, j int, taken []placed) (placed, bool) {
+	var padded *placed
+	for _, f := range sampleForms {
+		var anchors []int // anchors common to the rows seen so far
+		first := true
+		lo, hi := 0, 0
+		spans := make(map[int][2]int) // per anchor: min start, max end
+		for i, r := range rows {
+			if j >= len(r) || strings.TrimSpace(r[j]) == "" {
+				continue
+			}
+			s := f.spelled(strings.TrimSpace(r[j]))
+			if s == "" {
+				anchors = nil
+				break
+			}
+			var here []int
+			for k := 0; ; {
+				idx := strings.Index(upper[i][k:], s)
+				if idx < 0 {
+					break
+				}
+				start := k + idx
+				a := start
+				if f.right {
+					a = start + len(s)
+				}
+				if first || slices.Contains(anchors, a) {
+					here = append(here, a)
+					sp, ok := spans[a]
+					if !ok {
+						sp = [2]int{start, start + len(s)}
+					}
+					spans[a] = [2]int{min(sp[0], start), max(sp[1], start+len(s))}
+				}
+				k = start + 1
+			}
+			anchors, first = here, false
+			if len(anchors) == 0 {
+				break
+			}
+		}
+		for _, a := range anchors {
+			lo, hi = spans[a][0], spans[a][1]
+			if overlaps(lo, hi, taken) {
+				continue
+			}
+			p := placed{form: f, anchor: a, min: lo, max: hi}
+			if f.kind == "text" && !f.right && paddedBefore(upper, lo) {
+				if padded == nil {
+					padded = &p
+				}
+				break
+			}
+			return p, true
+		}
+	}
+	if padded != nil {
+		return *padded, true
+	}
+	return placed{}, false
+}
+
+// paddedBefore reports whether every line has a zero just before
+// position c, or only blanks before it.
+func paddedBefore(text []string, c int) bool {
+	if c == 0 {
+		return false
+	}
+	zeros, blanks := true, true
+	for _, l := range text {
+		zeros = zeros && l[c-1] == '0'
+		blanks = blanks && strings.TrimLeft(l[:c], " ") == ""
+	}
+	return zeros || blanks
+}
+
+// overlaps reports whether positions lo-hi overlap a placed column.
+func overlaps(lo, hi int, taken []placed) bool {
+	for _, p := range taken {
+		if lo < p.max && p.min < hi {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveBounds turns placed columns into contiguous fields. A
+// left-anchored value runs on over its padding up to where the next value
+// or its padding begins; a right-anchored one reaches back over blanks,
+// zeros and signs to the field before it. Positions no column claims are left for
+// segment.
+func resolveBounds(text []string, found []placed, width int) []Field {
+	anyInk := func(c int) bool {
+		for _, l := range text {
+			if l[c] != ' ' {
+				return true
+			}
+		}
+		return false
+	}
+	padAt := func(c int) bool {
+		for _, l := range text {
+			if !strings.ContainsRune(" 0-+", rune(l[c])) {
+				return false
+			}
+		}
+		return true
+	}
+
+	fields := make([]Field, len(found))
+	prevEnd := 0
+	for i, p := range found {
+		start, end := p.min, p.max
+		if p.form.right {
+			end = p.anchor
+			for start > prevEnd && padAt(start-1) {
+				start--
+			}
+		} else {
+			start = p.anchor
+			limit := width
+			if i+1 < len(found) {
+				limit = found[i+1].min
+				if !found[i+1].form.right {
+					limit = found[i+1].anchor
+				}
+			}
+			for end < limit && !anyInk(end) {
+				end++
+			}
+		}
+		prevEnd = end
+		fields[i] = placedField(text, p, start, end)
+	}
+	return fields
+}
+
+// placedField types a field from the form its sample values were found
+// in.
+func placedField(text []string, p placed, start, end int) Field {
+	f := inferField(text, start, end)
+	f.Name, f.Value = p.name, ""
+	switch p.form.kind {
+	case "text":
+		f.Type, f.Align, f.Padding = "text", "left", " "
+		if p.form.right {
+			f.Align = "right"
+			if columnAt(text, start).zeros > 0 {
+				f.Padding = "0"
+			}
+		}
+	case "date":
+		f.Type, f.Align, f.Padding, f.DateFormat = "date", "left", " ", p.form.pattern
+		if p.form.pattern == DefaultDateFormat {
+			f.DateFormat = ""
+		}
+	case "numeric":
+		f.Type, f.Align = "numeric", "right"
+		if p.form.scale > 0 {
+			scale := p.form.scale
+			f.Scale = &scale
+		}
+	}
+	return f
+}
@@ -0,0 +1,177 @@
+package bank
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const inferCSV = "Employee_ID,First_Name,Last_Name,Bank_Routing,Account_Number,Amount,Pay_Date\n" +
+	"42,Jane,Doe,021000021,111,1250.5,2024-03-15\n" +
+	"7,John,Roe,011000015,222,-3,2024-03-15\n" +
+	"1234,Alexander,Smithson,026009593,98765432,19.99,2024-04-01\n"
+
+func inferSample(t *testing.T) []byte {
+	t.Helper()
+	bf, err := DecodeWithTemplate([]byte(inferCSV), *GetTemplate("Direct_Deposit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := bf.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestInferTemplateWithSample(t *testing.T) {
+	data := inferSample(t)
+	inf, err := InferTemplate(data, []byte(inferCSV), "Payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inf.Notes) != 0 {
+		t.Errorf("Notes = %q", inf.Notes)
+	}
+	want := GetTemplate("Direct_Deposit").Fields
+	got := inf.Template.Fields
+	if len(got) != len(want) {
+		t.Fatalf("Fields = %+v", got)
+	}
+	for i, f := range got {
+		w := want[i]
+		if f.Name != w.Name || f.Position != w.Position || f.Length != w.Length || f.Type != w.Type || f.Align != w.Align {
+			t.Errorf("field %d = %s %d+%d %s %s, want %s %d+%d %s %s", i,
+				f.Name, f.Position, f.Length, f.Type, f.Align, w.Name, w.Position, w.Length, w.Type, w.Align)
+		}
+	}
+	if s := got[5].Scale; s == nil || *s != 2 {
+		t.Errorf("Amount scale = %v", s)
+	}
+
+	// The JSON reads back as a template that reads the file
+	b, err := json.Marshal(inf.Template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl, err := LoadCustomTemplate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := DecodeFixedWidthWithTemplate(data, *tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csv, _ := read.FormatAsCSV()
+	back := "Employee_ID,First_Name,Last_Name,Bank_Routing,Account_Number,Amount,Pay_Date\n" +
+		"42,Jane,Doe,021000021,111,1250.50,2024-03-15\n" +
+		"7,John,Roe,011000015,222,-3.00,2024-03-15\n" +
+		"1234,Alexander,Smithson,026009593,98765432,19.99,2024-04-01\n"
+	if string(csv) != back {
+		t.Errorf("read back =\n%s\nwant\n%s", csv, back)
+	}
+
+	inf, err = InferTemplate(data, []byte("Employee_ID,Nickname\n42,JD\n7,JR\n1234,AL\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inf.Notes) != 1 || inf.Notes[0] != `column "Nickname" was not found in the lines` || inf.Template.Fields[0].Name != "Employee_ID" {
+		t.Errorf("Notes = %q, Fields = %+v", inf.Notes, inf.Template.Fields)
+	}
+}
+
+func TestInferTemplateWithoutSample(t *testing.T) {
+	inf, err := InferTemplate(inferSample(t), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type bound struct {
+		pos, length int
+		typ, align  string
+	}
+	want := []bound{
+		{0, 10, "numeric", "right"},
+		{10, 15, "text", "left"},
+		{25, 20, "text", "left"},
+		{45, 26, "text", "left"}, // routing and account run together
+		{71, 20, "numeric", "right"},
+	}
+	got := inf.Template.Fields
+	if len(got) != len(want) {
+		t.Fatalf("Fields = %+v", got)
+	}
+	for i, f := range got {
+		if w := want[i]; f.Position != w.pos || f.Length != w.length || f.Type != w.typ || f.Align != w.align {
+			t.Errorf("field %d = %d+%d %s %s, want %+v", i, f.Position, f.Length, f.Type, f.Align, w)
+		}
+	}
+
+	inf, err = InferTemplate([]byte("E20240315  JANE      \nE20240316  JOHN      \n"), nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = inf.Template.Fields
+	if len(got) != 3 || got[0].Name != "Record_Type" || got[0].Value != "E" ||
+		got[1].Type != "date" || got[1].Length != 10 || got[2].Name != "Field_3" {
+		t.Errorf("Fields = %+v", got)
+	}
+	if _, err := InferTemplate([]byte("\n\n"), nil, ""); err == nil {
+		t.Error("empty file inferred")
+	}
+}
+
+// IDs of one length, written right-aligned over zeros, are found with
+// their padding rather than as text after it.
+func TestInferTemplateZeroPadded(t *testing.T) {
+	csv := "Employee_ID,First_Name,Last_Name,Bank_Routing,Account_Number,Amount,Pay_Date\n" +
+		"1001,Jane,Doe,021000021,111,1250.5,2024-03-15\n" +
+		"1002,John,Roe,011000015,222,-3,2024-03-15\n" +
+		"1003,Alexander,Smithson,026009593,98765432,19.99,2024-04-01\n"
+	bf, err := DecodeWithTemplate([]byte(csv), *GetTemplate("Direct_Deposit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bf.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inf, err := InferTemplate(data, []byte(csv), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GetTemplate("Direct_Deposit").Fields
+	got := inf.Template.Fields
+	if len(inf.Notes) != 0 || len(got) != len(want) {
+		t.Fatalf("Notes = %q, Fields = %+v", inf.Notes, got)
+	}
+	for i, f := range got {
+		w := want[i]
+		if f.Name != w.Name || f.Position != w.Position || f.Length != w.Length || f.Type != w.Type || f.Align != w.Align {
+			t.Errorf("field %d = %s %d+%d %s %s, want %s %d+%d %s %s", i,
+				f.Name, f.Position, f.Length, f.Type, f.Align, w.Name, w.Position, w.Length, w.Type, w.Align)
+		}
+	}
+	if got[0].Padding != "0" {
+		t.Errorf("Employee_ID padding = %q", got[0].Padding)
+	}
+}
+
+// Positions found in the lines must hold for bytes that are not UTF-8,
+// such as Latin-1 text.
+func TestInferTemplateLatin1(t *testing.T) {
+	data := []byte("Ren\xc9     0001250\nJos\xc9     0000300\n")
+	inf, err := InferTemplate(data, []byte("Name,Amount\nRen\xc9,12.50\nJos\xc9,3.00\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inf.Notes) != 0 || len(inf.Template.Fields) != 2 || inf.Template.Fields[1].Name != "Amount" || inf.Template.Fields[1].Position != 9 {
+		t.Errorf("Notes = %q, Fields = %+v", inf.Notes, inf.Template.Fields)
+	}
+}
+
+func FuzzInferTemplate(f *testing.F) {
+	f.Add([]byte("\xf00"), []byte("0\n0"))
+	f.Add([]byte("E20240315  JANE      \n"), []byte("Name\nJane\n"))
+	f.Fuzz(func(t *testing.T, data, sample []byte) {
+		InferTemplate(data, sample, "")
+	})
+}
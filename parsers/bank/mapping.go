@@ -0,0 +1,651 @@
+// mapping.go maps a client's spreadsheet columns onto a template's
+// fields, so that files need not be renamed to match the template: a
+// Mapping names the source column (or its aliases) of each field, or
+// builds the value from constants, defaults, concatenation, substrings,
+// lookup tables and arithmetic over columns. Mappings are saved as JSON
+// files in a MappingStore.
+
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mapping maps source columns onto the fields of one template.
+type Mapping struct {
+	Name        string `json:"name"`
+	Template    string `json:"template"` // template key
+	Description string `json:"description,omitempty"`
+
+	// Fields maps template field names to their source. Fields without
+	// an entry still read the column of the same name.
+	Fields map[string]FieldMap `json:"fields"`
+
+	// Lookups are named tables for FieldMap.Lookup. A "*" key supplies
+	// the value for anything not in the table.
+	Lookups map[string]map[string]string `json:"lookups,omitempty"`
+}
+
+// FieldMap is the source of one field: exactly one of Column (with its
+// Aliases), Value, Concat or Expr, then Substr, Lookup and Default
+// applied in that order.
+type FieldMap struct {
+	Column  string   `json:"column,omitempty"`  // source column
+	Aliases []string `json:"aliases,omitempty"` // other names for Column; the first present is read
+	Value   string   `json:"value,omitempty"`   // constant text
+
+	Concat    []string `json:"concat,omitempty"`    // columns joined with Separator
+	Separator string   `json:"separator,omitempty"` // for Concat
+
+	// Expr is arithmetic over columns and numbers with + - * / and
+	// parentheses, e.g. "Gross - Fees" or "{Unit Price} * Quantity";
+	// empty columns count as 0.
+	Expr string `json:"expr,omitempty"`
+
+	Substr  []int  `json:"substr,omitempty"`  // [start] or [start, length] of the value
+	Lookup  string `json:"lookup,omitempty"`  // table in Mapping.Lookups to translate the value
+	Default string `json:"default,omitempty"` // used when the value is empty
+}
+
+// LoadMapping parses a JSON mapping and validates it against its template.
+func LoadMapping(data []byte) (*Mapping, error) {
+	var m Mapping
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("mapping: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// mappingName restricts mapping names to what is safe as a file name.
+var mappingName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// Validate checks that the mapping names a known template and only its
+// fields, that every field has exactly one source, and that lookups,
+// substrings and expressions are well formed.
+func (m *Mapping) Validate() error {
+	if !mappingName.MatchString(m.Name) || strings.Contains(m.Name, "..") {
+		return fmt.Errorf("mapping name %q: use letters, digits, '.', '_' and '-'", m.Name)
+	}
+	tpl := GetTemplate(m.Template)
+	if tpl == nil {
+		return fmt.Errorf("mapping %s: template not found: %s", m.Name, m.Template)
+	}
+	known := make(map[string]bool, len(tpl.Fields))
+	for _, f := range tpl.inputFields() {
+		known[f.Name] = true
+	}
+	names := make([]string, 0, len(m.Fields))
+	for name := range m.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := m.checkField(name, m.Fields[name], known); err != nil {
+			return fmt.Errorf("mapping %s: field %s: %w", m.Name, name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Mapping) checkField(name string, fm FieldMap, known map[string]bool) error {
+	if !known[name] {
+		return fmt.Errorf("not a field of template %s", m.Template)
+	}
+	sources := 0
+	for _, set := range []bool{fm.Column != "" || len(fm.Aliases) > 0, fm.Value != "", len(fm.Concat) > 0, fm.Expr != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("set only one of column/aliases, value, concat and expr")
+	}
+	if sources == 0 && fm.Default == "" {
+		return errors.New("no source: set column, aliases, value, concat, expr or default")
+	}
+	if fm.Expr != "" {
+		if _, err := parseExpr(fm.Expr); err != nil {
+			return err
+		}
+	}
+	if n := len(fm.Substr); n > 2 || (n > 0 && fm.Substr[0] < 0) || (n == 2 && fm.Substr[1] < 0) {
+		return errors.New("substr is [start] or [start, length] with non-negative numbers")
+	}
+	if fm.Lookup != "" {
+		if _, ok := m.Lookups[fm.Lookup]; !ok {
+			return fmt.Errorf("lookup table %q is not defined", fm.Lookup)
+		}
+	}
+	return nil
+}
+
+// ApplyMapping rewrites the records so that each mapped field holds its
+// mapped value. Values that cannot be mapped, such as a lookup miss or a
+// non-numeric operand, are left empty and returned as a
+// *ValidationError; a template mismatch or a source column missing from
+// every record is an error. Templates are matched by key, so bf must come
+// from a decoder that takes one.
+func (bf *BankFile) ApplyMapping(m *Mapping) error {
+	if bf.TemplateKey == "" || bf.TemplateKey != m.Template {
+		return fmt.Errorf("mapping %s is for template %s", m.Name, m.Template)
+	}
+	type compiled struct {
+		name string
+		fm   FieldMap
+		expr exprNode
+	}
+	var fields []compiled
+	for _, f := range bf.Template.inputFields() {
+		fm, ok := m.Fields[f.Name]
+		if !ok {
+			continue
+		}
+		c := compiled{name: f.Name, fm: fm}
+		if fm.Expr != "" {
+			var err error
+			if c.expr, err = parseExpr(fm.Expr); err != nil {
+				return fmt.Errorf("mapping %s: field %s: %w", m.Name, f.Name, err)
+			}
+		}
+		if len(bf.Records) > 0 {
+			if err := checkColumns(bf.Records, fm, c.expr); err != nil {
+				return fmt.Errorf("mapping %s: field %s: %w", m.Name, f.Name, err)
+			}
+		}
+		fields = append(fields, c)
+	}
+
+	var errs []FieldError
+	mapped := make([]Record, len(bf.Records))
+	for i, r := range bf.Records {
+		out := make(Record, len(r)+len(fields))
+		for k, v := range r {
+			out[k] = v
+		}
+		for _, c := range fields {
+			v, err := m.value(r, c.fm, c.expr)
+			if err != nil {
+				errs = append(errs, FieldError{Row: i + 2, Column: c.name, Value: v, Rule: "mapping", Message: err.Error()})
+				v = ""
+			}
+			// Drop source columns that fold to the field's name so they
+			// cannot shadow the mapped value.
+			for k := range out {
+				if k != c.name && foldName(k) == foldName(c.name) {
+					delete(out, k)
+				}
+			}
+			out[c.name] = v
+		}
+		mapped[i] = out
+	}
+	bf.Records = mapped
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// value computes one mapped value from record r.
+func (m *Mapping) value(r Record, fm FieldMap, expr exprNode) (string, error) {
+	var v string
+	switch {
+	case fm.Value != "":
+		v = fm.Value
+	case len(fm.Concat) > 0:
+		parts := make([]string, 0, len(fm.Concat))
+		for _, col := range fm.Concat {
+			if p := getFieldValue(r, col); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		v = strings.Join(parts, fm.Separator)
+	case expr != nil:
+		n, scale, err := expr.eval(r)
+		if err != nil {
+			return "", err
+		}
+		v = n.FloatString(scale)
+	default:
+		v = columnValue(r, fm)
+	}
+
+	if len(fm.Substr) > 0 {
+		runes := []rune(v)
+		start := min(fm.Substr[0], len(runes))
+		end := len(runes)
+		if len(fm.Substr) == 2 {
+			end = min(start+fm.Substr[1], end)
+		}
+		v = string(runes[start:end])
+	}
+	if fm.Lookup != "" && v != "" {
+		table := m.Lookups[fm.Lookup]
+		if to, ok := lookupFold(table, v); ok {
+			v = to
+		} else if to, ok := table["*"]; ok {
+			v = to
+		} else {
+			return v, fmt.Errorf("%q is not in lookup table %s", v, fm.Lookup)
+		}
+	}
+	if strings.TrimSpace(v) == "" {
+		v = fm.Default
+	}
+	return v, nil
+}
+
+// columnValue reads the first of the mapping's column and aliases that
+// the record has.
+func columnValue(r Record, fm FieldMap) string {
+	for _, col := range append([]string{fm.Column}, fm.Aliases...) {
+		if col != "" && hasColumn(r, col) {
+			return getFieldValue(r, col)
+		}
+	}
+	return ""
+}
+
+// checkColumns reports a source column of fm that no record has; for a
+// column with aliases, one of them must be present.
+func checkColumns(records []Record, fm FieldMap, expr exprNode) error {
+	present := func(col string) bool {
+		for _, r := range records {
+			if hasColumn(r, col) {
+				return true
+			}
+		}
+		return false
+	}
+	if fm.Column != "" || len(fm.Aliases) > 0 {
+		names := append([]string{fm.Column}, fm.Aliases...)
+		if !slices.ContainsFunc(names, func(c string) bool { return c != "" && present(c) }) {
+			return fmt.Errorf("none of the columns %q found", names)
+		}
+	}
+	cols := fm.Concat
+	if expr != nil {
+		cols = append(cols[:len(cols):len(cols)], expr.columns()...)
+	}
+	for _, col := range cols {
+		if !present(col) {
+			return fmt.Errorf("column %q not found", col)
+		}
+	}
+	return nil
+}
+
+// hasColumn reports whether the record has the column, matched the way
+// getFieldValue matches it.
+func hasColumn(r Record, col string) bool {
+	for k := range r {
+		if k == col || foldName(k) == foldName(col) {
+			return true
+		}
+	}
+	return false
+}
+
+// foldName folds case and treats "_" as a space, as getFieldValue does.
+func foldName(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, "_", " "))
+}
+
+func lookupFold(table map[string]string, v string) (string, bool) {
+	if to, ok := table[v]; ok {
+		return to, true
+	}
+	for from, to := range table {
+		if strings.EqualFold(strings.TrimSpace(from), strings.TrimSpace(v)) {
+			return to, true
+		}
+	}
+	return "", false
+}
+
+// exprNode is a parsed arithmetic expression. eval returns the value and
+// the number of decimals to print it with: the most of any operand, or
+// more where a product or quotient needs them (see places).
+type exprNode interface {
+	eval(r Record) (*big.Rat, int, error)
+	columns() []string
+}
+
+type numberNode struct {
+	n     *big.Rat
+	scale int
+}
+
+type columnNode string
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+type negNode struct{ x exprNode }
+
+func (n numberNode) eval(Record) (*big.Rat, int, error) { return n.n, n.scale, nil }
+func (n numberNode) columns() []string                  { return nil }
+
+func (c columnNode) eval(r Record) (*big.Rat, int, error) {
+	v := getFieldValue(r, string(c))
+	if strings.TrimSpace(v) == "" {
+		return new(big.Rat), 0, nil
+	}
+	d, err := parseDecimal(v, ".", "")
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", string(c), err)
+	}
+	return d.rat(), len(d.frac), nil
+}
+
+func (c columnNode) columns() []string { return []string{string(c)} }
+
+func (b binaryNode) eval(r Record) (*big.Rat, int, error) {
+	x, xs, err := b.left.eval(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	y, ys, err := b.right.eval(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	z := new(big.Rat)
+	switch b.op {
+	case '+':
+		z.Add(x, y)
+	case '-':
+		z.Sub(x, y)
+	case '*':
+		z.Mul(x, y)
+	case '/':
+		if y.Sign() == 0 {
+			return nil, 0, errors.New("division by zero")
+		}
+		z.Quo(x, y)
+	}
+	scale := max(xs, ys)
+	if b.op == '*' || b.op == '/' {
+		scale = max(scale, places(z))
+	}
+	return z, scale, nil
+}
+
+// maxPlaces is the most decimals a quotient is printed with when it has
+// no exact decimal form, such as 1/3; the target field's Scale and
+// Rounding then round it.
+const maxPlaces = 12
+
+// places returns the number of decimals that write z exactly, or
+// maxPlaces if that is not possible.
+func places(z *big.Rat) int {
+	p, ten, rem := big.NewInt(1), big.NewInt(10), new(big.Int)
+	for n := 0; n < maxPlaces; n++ {
+		if rem.Rem(p, z.Denom()).Sign() == 0 {
+			return n
+		}
+		p.Mul(p, ten)
+	}
+	return maxPlaces
+}
+
+func (b binaryNode) columns() []string { return append(b.left.columns(), b.right.columns()...) }
+
+func (n negNode) eval(r Record) (*big.Rat, int, error) {
+	x, s, err := n.x.eval(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return new(big.Rat).Neg(x), s, nil
+}
+
+func (n negNode) columns() []string { return n.x.columns() }
+
+// rat returns d as an exact rational.
+func (d decimal) rat() *big.Rat {
+	s := d.whole
+	if s == "" {
+		s = "0"
+	}
+	if d.frac != "" {
+		s += "." + d.frac
+	}
+	if d.neg {
+		s = "-" + s
+	}
+	r, _ := new(big.Rat).SetString(s)
+	return r
+}
+
+// exprParser is a recursive-descent parser for Expr:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = number | column | "{" column name "}" | "(" expr ")" | "-" factor
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func parseExpr(s string) (exprNode, error) {
+	p := &exprParser{s: s}
+	n, err := p.expr()
+	if err != nil {
+		return nil, fmt.Errorf("expr %q: %w", s, err)
+	}
+	if p.skip(); p.pos < len(p.s) {
+		return nil, fmt.Errorf("expr %q: unexpected %q", s, p.s[p.pos:])
+	}
+	return n, nil
+}
+
+func (p *exprParser) skip() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) expr() (exprNode, error) {
+	left, err := p.term()
+	for err == nil {
+		p.skip()
+		if p.pos >= len(p.s) || (p.s[p.pos] != '+' && p.s[p.pos] != '-') {
+			return left, nil
+		}
+		op := p.s[p.pos]
+		p.pos++
+		var right exprNode
+		if right, err = p.term(); err == nil {
+			left = binaryNode{op, left, right}
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) term() (exprNode, error) {
+	left, err := p.factor()
+	for err == nil {
+		p.skip()
+		if p.pos >= len(p.s) || (p.s[p.pos] != '*' && p.s[p.pos] != '/') {
+			return left, nil
+		}
+		op := p.s[p.pos]
+		p.pos++
+		var right exprNode
+		if right, err = p.factor(); err == nil {
+			left = binaryNode{op, left, right}
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) factor() (exprNode, error) {
+	p.skip()
+	if p.pos >= len(p.s) {
+		return nil, errors.New("unexpected end")
+	}
+	switch c := p.s[p.pos]; {
+	case c == '-':
+		p.pos++
+		x, err := p.factor()
+		return negNode{x}, err
+	case c == '(':
+		p.pos++
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.skip(); p.pos >= len(p.s) || p.s[p.pos] != ')' {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return x, nil
+	case c == '{':
+		end := strings.IndexByte(p.s[p.pos:], '}')
+		if end < 0 {
+			return nil, errors.New("missing }")
+		}
+		name := strings.TrimSpace(p.s[p.pos+1 : p.pos+end])
+		p.pos += end + 1
+		if name == "" {
+			return nil, errors.New("empty column name")
+		}
+		return columnNode(name), nil
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+			p.pos++
+		}
+		d, err := parseDecimal(p.s[start:p.pos], ".", "")
+		if err != nil {
+			return nil, err
+		}
+		return numberNode{d.rat(), len(d.frac)}, nil
+	case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] == '_' || p.s[p.pos] >= 'A' && p.s[p.pos] <= 'Z' ||
+			p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z' || p.s[p.pos] >= '0' && p.s[p.pos] <= '9') {
+			p.pos++
+		}
+		return columnNode(p.s[start:p.pos]), nil
+	}
+	return nil, fmt.Errorf("unexpected %q", p.s[p.pos:])
+}
+
+// MappingStore keeps mappings as <name>.json files in a directory.
+type MappingStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenMappings returns the store in dir, creating the directory if needed.
+func OpenMappings(dir string) (*MappingStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mappings: %w", err)
+	}
+	return &MappingStore{dir: dir}, nil
+}
+
+// List returns the stored mappings sorted by name. Files that no longer
+// validate, for example after their template changed, are skipped.
+func (s *MappingStore) List() ([]*Mapping, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var out []*Mapping
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if m, err := LoadMapping(data); err == nil {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// Get returns the mapping called name.
+func (s *MappingStore) Get(name string) (*Mapping, error) {
+	if !mappingName.MatchString(name) || strings.Contains(name, "..") {
+		return nil, fmt.Errorf("mapping not found: %s", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(filepath.Join(s.dir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("mapping not found: %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return LoadMapping(data)
+}
+
+// Save validates m against its template and writes it, replacing any
+// mapping of the same name.
+func (s *MappingStore) Save(m *Mapping) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeJSON(filepath.Join(s.dir, m.Name+".json"), m)
+}
+
+// Delete removes the mapping called name.
+func (s *MappingStore) Delete(name string) error {
+	if !mappingName.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("mapping not found: %s", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(filepath.Join(s.dir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("mapping not found: %s", name)
+	}
+	return err
+}
+
+// mappings is the process-wide store; nil until SetMappings is called.
+var mappings *MappingStore
+
+// SetMappings installs the store that mapping names are looked up in.
+// Call it during startup.
+func SetMappings(s *MappingStore) {
+	mappings = s
+}
+
+// Mappings returns the installed store, or nil if none is configured.
+func Mappings() *MappingStore {
+	return mappings
+}
+
+// GetMapping looks up a mapping by name in the installed store.
+func GetMapping(name string) (*Mapping, error) {
+	s := Mappings()
+	if s == nil {
+		return nil, fmt.Errorf("mapping %s: no mapping directory is configured", name)
+	}
+	return s.Get(name)
+}
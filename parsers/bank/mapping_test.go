@@ -0,0 +1,162 @@
+package bank
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const clientMapping = `{
+  "name": "acme-payments",
+  "template": "ACH_Payment",
+  "fields": {
+    "Record_Type": {"value": "6"},
+    "Routing_Number": {"column": "Routing", "aliases": ["ABA", "Bank"]},
+    "Account_Number": {"column": "Acct"},
+    "Amount": {"expr": "Gross - {Bank Fees}"},
+    "Name": {"concat": ["First", "Last"], "separator": " "},
+    "Transaction_Code": {"column": "Type", "lookup": "codes"},
+    "ID_Number": {"column": "Ref", "substr": [4], "default": "NONE"}
+  },
+  "lookups": {"codes": {"D": "27", "C": "22"}}
+}`
+
+const clientCSV = "Type,Bank,Acct,Gross,Bank Fees,First,Last,Ref\n" +
+	"D,021000021,111,100.00,2.50,Jane,Doe,INV-2024-0001\n" +
+	"c,011000015,222,50,0,John,Roe,\n"
+
+func TestApplyMapping(t *testing.T) {
+	m, err := LoadMapping([]byte(clientMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf, err := Decode([]byte(clientCSV), "ACH_Payment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bf.ApplyMapping(m); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bf.FormatAsCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Record_Type,Routing_Number,Account_Number,Amount,Name,Transaction_Code,ID_Number\n" +
+		"6,021000021,111,0000009750,Jane Doe,27,2024-0001\n" +
+		"6,011000015,222,0000005000,John Roe,22,NONE\n"
+	if string(out) != want {
+		t.Errorf("FormatAsCSV() =\n%s\nwant\n%s", out, want)
+	}
+
+	bf, _ = Decode([]byte(strings.Replace(clientCSV, "c,", "X,", 1)), "ACH_Payment")
+	err = bf.ApplyMapping(m)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors[0].Error() != `row 3, Transaction_Code: "X" is not in lookup table codes` {
+		t.Errorf("lookup miss: %v", err)
+	}
+
+	bf, _ = Decode([]byte("Type,Bank,Acct,Gross,First,Last,Ref\nD,021000021,1,5,A,B,C\n"), "ACH_Payment")
+	if err := bf.ApplyMapping(m); err == nil || !strings.Contains(err.Error(), `field Amount: column "Bank Fees" not found`) {
+		t.Errorf("missing column: %v", err)
+	}
+	bf, _ = Decode([]byte(clientCSV), "Wire_Transfer")
+	if err := bf.ApplyMapping(m); err == nil {
+		t.Error("mapping applied to another template")
+	}
+
+	// A stored template that copies the built-in's display name is still
+	// another template.
+	store, err := OpenTemplates(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create("acme_ach", *GetTemplate("ACH_Payment")); err != nil {
+		t.Fatal(err)
+	}
+	SetTemplates(store)
+	defer SetTemplates(nil)
+	bf, _ = Decode([]byte(clientCSV), "acme_ach")
+	if err := bf.ApplyMapping(m); err == nil {
+		t.Error("mapping applied to a template with the same name")
+	}
+}
+
+func TestMappingValidate(t *testing.T) {
+	for _, tc := range []struct{ json, want string }{
+		{`{"name": "x", "template": "Nope", "fields": {}}`, "template not found"},
+		{`{"name": "../x", "template": "ACH_Payment", "fields": {}}`, "mapping name"},
+		{`{"name": "x", "template": "ACH_Payment", "fields": {"Colour": {"column": "C"}}}`, "Colour: not a field of template"},
+		{`{"name": "x", "template": "ACH_Payment", "fields": {"Name": {"column": "C", "value": "v"}}}`, "only one of"},
+		{`{"name": "x", "template": "ACH_Payment", "fields": {"Name": {}}}`, "no source"},
+		{`{"name": "x", "template": "ACH_Payment", "fields": {"Name": {"column": "C", "lookup": "t"}}}`, `lookup table "t" is not defined`},
+		{`{"name": "x", "template": "ACH_Payment", "fields": {"Amount": {"expr": "Gross * (Rate"}}}`, "missing )"},
+		{`{"name": "x", "template": "ACH_Payment", "fields": {"Name": {"column": "C", "substr": [-1]}}}`, "substr"},
+		{`{"name": "x", "template": "ACH_Payment", "fields": {}, "extra": 1}`, "unknown field"},
+	} {
+		if _, err := LoadMapping([]byte(tc.json)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want %q", tc.json, err, tc.want)
+		}
+	}
+}
+
+func TestExpr(t *testing.T) {
+	r := Record{"Qty": "3", "Unit Price": "$1,250.50", "Cents": "1234", "Empty": ""}
+	for expr, want := range map[string]string{
+		"{Unit Price} * Qty":     "3751.50",
+		"-(Qty + 1) * 2":         "-8",
+		"Qty / 4":                "0.75",
+		"Qty / 4.00":             "0.75",
+		"Cents / 100":            "12.34",
+		"1 / 3":                  "0.333333333333",
+		"1.5 * 1.5":              "2.25",
+		"Empty + 1.5":            "1.5",
+		"{Unit Price} - 0.5 * 2": "1249.50",
+	} {
+		n, err := parseExpr(expr)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		v, scale, err := n.eval(r)
+		if err != nil || v.FloatString(scale) != want {
+			t.Errorf("%s = %v, %v; want %s", expr, v, err, want)
+		}
+	}
+	n, _ := parseExpr("Qty / (Qty - 3)")
+	if _, _, err := n.eval(r); err == nil {
+		t.Error("division by zero accepted")
+	}
+}
+
+func TestMappingStore(t *testing.T) {
+	store, err := OpenMappings(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := LoadMapping([]byte(clientMapping))
+	if err := store.Save(m); err != nil {
+		t.Fatal(err)
+	}
+	bad := *m
+	bad.Name, bad.Fields = "bad", map[string]FieldMap{"Nope": {Column: "x"}}
+	if err := store.Save(&bad); err == nil {
+		t.Error("invalid mapping saved")
+	}
+	list, err := store.List()
+	if err != nil || len(list) != 1 || list[0].Name != "acme-payments" {
+		t.Errorf("List() = %v, %v", list, err)
+	}
+	got, err := store.Get("acme-payments")
+	if err != nil || got.Fields["Transaction_Code"].Lookup != "codes" {
+		t.Errorf("Get() = %+v, %v", got, err)
+	}
+	if err := store.Delete("acme-payments"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("acme-payments"); err == nil {
+		t.Error("deleted mapping still found")
+	}
+	if _, err := store.Get("../secrets"); err == nil {
+		t.Error("path outside the store accepted")
+	}
+}
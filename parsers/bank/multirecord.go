@@ -0,0 +1,162 @@
+// multirecord.go lets a template write more than one kind of detail
+// record: layouts selected per input row by a discriminator column or a
+// rule, written instead of the template's own detail record or as extra
+// records before or after it, such as addenda for rows with remittance
+// text.
+
+package bank
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RecordLayout is a further detail record of a template. It applies to
+// the rows whose Template.Discriminator column holds Key, or that meet
+// When; a layout with both needs both.
+type RecordLayout struct {
+	Name string     `json:"name"` // names the record in errors, e.g. "addenda"
+	Key  string     `json:"key,omitempty"`
+	When *Condition `json:"when,omitempty"`
+
+	// Emit is where the record goes: "instead" of the template's detail
+	// record (the default; the first such layout that applies wins), or
+	// "before" or "after" it, in the order the layouts are listed.
+	Emit   string  `json:"emit,omitempty"`
+	Fields []Field `json:"fields"`
+}
+
+// Places selectable with RecordLayout.Emit.
+const (
+	EmitInstead = "instead"
+	EmitBefore  = "before"
+	EmitAfter   = "after"
+)
+
+// Condition tests one column of an input row. It holds when the value is
+// not empty, is one of In (ignoring case) if In is set, and matches
+// Pattern if Pattern is set.
+type Condition struct {
+	Column  string   `json:"column"`
+	In      []string `json:"in,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+}
+
+// holds reports whether the condition holds for record r.
+func (c Condition) holds(r Record) (bool, error) {
+	v := strings.TrimSpace(getFieldValue(r, c.Column))
+	if v == "" || (len(c.In) > 0 && !containsFold(c.In, v)) {
+		return false, nil
+	}
+	if c.Pattern != "" {
+		re, err := compilePattern(c.Pattern)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(v), nil
+	}
+	return true, nil
+}
+
+// applies reports whether layout l is used for record r.
+func (t Template) applies(l RecordLayout, r Record) (bool, error) {
+	if l.Key != "" && !strings.EqualFold(strings.TrimSpace(getFieldValue(r, t.Discriminator)), l.Key) {
+		return false, nil
+	}
+	if l.When != nil {
+		return l.When.holds(r)
+	}
+	return true, nil
+}
+
+// rowRecord is one record an input row is written as.
+type rowRecord struct {
+	name   string // the layout's name; "" for the template's detail record
+	fields []Field
+}
+
+// rowRecords returns the records input row r is written as, in order:
+// the layouts that go before it, its detail record (the template's own or
+// the first "instead" layout that applies), then those that go after.
+func (t Template) rowRecords(r Record) ([]rowRecord, error) {
+	detail := rowRecord{fields: t.Fields}
+	var before, after []rowRecord
+	replaced := false
+	for _, l := range t.Records {
+		ok, err := t.applies(l, r)
+		if err != nil {
+			return nil, fmt.Errorf("%s record: %w", l.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		rec := rowRecord{name: l.Name, fields: l.Fields}
+		switch l.Emit {
+		case EmitBefore:
+			before = append(before, rec)
+		case EmitAfter:
+			after = append(after, rec)
+		default:
+			if !replaced {
+				detail, replaced = rec, true
+			}
+		}
+	}
+	return append(append(before, detail), after...), nil
+}
+
+// inputFields returns the fields read from the input: the detail fields,
+// then those of the other record layouts not already named, leaving out
+// fields with a Value.
+func (t Template) inputFields() []Field {
+	fields := slices.Clone(t.Fields)
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		seen[foldName(f.Name)] = true
+	}
+	for _, l := range t.Records {
+		for _, f := range l.Fields {
+			if f.Value == "" && !seen[foldName(f.Name)] {
+				seen[foldName(f.Name)] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// checkRecordLayouts reports record layouts without a name or a way to
+// select them, with an unknown Emit, or with a rule that does not
+// compile. Their fields are checked with the other records'.
+func (t Template) checkRecordLayouts() error {
+	seen := make(map[string]bool, len(t.Records))
+	for i, l := range t.Records {
+		switch {
+		case l.Name == "":
+			return fmt.Errorf("record layout %d has no name", i+1)
+		case seen[l.Name]:
+			return fmt.Errorf("record layout %s is defined twice", l.Name)
+		case slices.Contains([]string{"detail", "header", "trailer", "batch header", "batch trailer"}, l.Name):
+			return fmt.Errorf("record layout name %q is taken by the template's own records", l.Name)
+		case l.Key == "" && l.When == nil:
+			return fmt.Errorf("%s record: set key or when to select its rows", l.Name)
+		case l.Key != "" && t.Discriminator == "":
+			return fmt.Errorf("%s record: key %q needs the template's discriminator column", l.Name, l.Key)
+		case l.When != nil && l.When.Column == "":
+			return fmt.Errorf("%s record: when has no column", l.Name)
+		}
+		seen[l.Name] = true
+		switch l.Emit {
+		case "", EmitInstead, EmitBefore, EmitAfter:
+		default:
+			return fmt.Errorf("%s record: emit %q is not instead, before or after", l.Name, l.Emit)
+		}
+		if l.When != nil && l.When.Pattern != "" {
+			if _, err := compilePattern(l.When.Pattern); err != nil {
+				return fmt.Errorf("%s record: %w", l.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package bank
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const multiTemplate = `{
+  "name": "Payments with addenda",
+  "discriminator": "Type",
+  "fields": [
+    {"name": "Record_Type", "position": 0, "length": 1, "value": "E"},
+    {"name": "Account", "position": 1, "length": 6, "required": true},
+    {"name": "Amount", "position": 7, "length": 8, "type": "numeric", "padding": "0", "align": "right", "scale": 2}
+  ],
+  "records": [
+    {"name": "refund", "key": "R", "fields": [
+      {"name": "Record_Type", "position": 0, "length": 1, "value": "R"},
+      {"name": "Amount", "position": 1, "length": 8, "type": "numeric", "padding": "0", "align": "right", "scale": 2},
+      {"name": "Account", "position": 9, "length": 6}
+    ]},
+    {"name": "notice", "when": {"column": "Notice", "in": ["yes"]}, "emit": "before", "fields": [
+      {"name": "Record_Type", "position": 0, "length": 1, "value": "N"},
+      {"name": "Account", "position": 1, "length": 6}
+    ]},
+    {"name": "addenda", "when": {"column": "Remittance"}, "emit": "after", "fields": [
+      {"name": "Record_Type", "position": 0, "length": 1, "value": "A"},
+      {"name": "Seq", "position": 1, "length": 3, "type": "numeric", "padding": "0", "align": "right", "value": "{seq}"},
+      {"name": "Remittance", "position": 4, "length": 10, "maxLength": 10}
+    ]}
+  ],
+  "footer": {"fields": [
+    {"name": "Record_Type", "position": 0, "length": 1, "value": "T"},
+    {"name": "Count", "position": 1, "length": 3, "type": "numeric", "padding": "0", "align": "right", "value": "{count}"},
+    {"name": "Details", "position": 4, "length": 3, "type": "numeric", "padding": "0", "align": "right", "value": "{details}"}
+  ]}
+}`
+
+func decodeMulti(t *testing.T, csv string) *BankFile {
+	t.Helper()
+	tpl, err := LoadCustomTemplate([]byte(multiTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf, err := DecodeWithTemplate([]byte(csv), *tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bf
+}
+
+func TestFormatRecordLayouts(t *testing.T) {
+	bf := decodeMulti(t, "Type,Account,Amount,Remittance,Notice\n"+
+		"P,ACC001,10.00,INV 7,\n"+
+		"R,ACC002,2.50,,yes\n"+
+		"P,ACC003,1,,\n")
+	out, err := bf.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"EACC00100001000",
+		"A001INV 7     ",
+		"NACC002",
+		"R00000250ACC002",
+		"EACC00300000100",
+		"T003005",
+	}, "\n")
+	if string(out) != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", out, want)
+	}
+
+	// Each record reads back under the detail columns
+	read, err := DecodeFixedWidthWithTemplate(out, bf.Template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Records) != 3 || read.Records[0]["Remittance"] != "INV 7" ||
+		read.Records[1]["Record_Type"] != "R" || read.Records[1]["Amount"] != "2.50" || read.Records[1]["Account"] != "ACC002" {
+		t.Errorf("Records = %v", read.Records)
+	}
+}
+
+func TestRecordLayoutValidation(t *testing.T) {
+	bf := decodeMulti(t, "Type,Account,Amount,Remittance\n"+
+		"P,ACC001,10.00,INVOICE 1234567\n"+
+		"R,,2.50,\n")
+	_, err := bf.Format()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 {
+		t.Fatalf("Format() error = %v", err)
+	}
+	// The refund layout does not require an account; the addenda's
+	// remittance is too long for it.
+	if got := verr.Errors[0].Error(); got != `row 2 (addenda record), Remittance: "INVOICE 1234567" is longer than 10 characters` {
+		t.Errorf("error = %s", got)
+	}
+
+	for _, tc := range []struct{ old, new, want string }{
+		{`"discriminator": "Type"`, `"discriminator": ""`, `refund record: key "R" needs the template's discriminator column`},
+		{`"emit": "after"`, `"emit": "later"`, `addenda record: emit "later" is not instead, before or after`},
+		{`"name": "notice"`, `"name": "header"`, `record layout name "header" is taken by the template's own records`},
+		{`"in": ["yes"]`, `"pattern": "("`, `notice record: invalid pattern "("`},
+		{`"position": 9, "length": 6`, `"position": 10, "length": 6`, `refund record: position 9 before field Account is not covered by any field`},
+	} {
+		_, err := LoadCustomTemplate([]byte(strings.Replace(multiTemplate, tc.old, tc.new, 1)))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %s", tc.new, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,562 @@
+// nacha.go generates NACHA ACH files: a file header, one batch per
+// company, SEC code, entry description and effective date, entry detail
+// records with optional addenda, batch and file control records, and
+// 9-filled lines up to a multiple of the blocking factor.
+
+package bank
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NACHA record layout constants.
+const (
+	achRecordSize     = 94
+	achBlockingFactor = 10
+)
+
+// ACHProfile holds the originator settings of a NACHA file. They come from
+// the bank relationship rather than from the payment rows.
+type ACHProfile struct {
+	ImmediateDestination     string       `json:"immediateDestination"`     // routing number of the receiving point (9 digits)
+	ImmediateDestinationName string       `json:"immediateDestinationName"` // usually the bank's name
+	ImmediateOrigin          string       `json:"immediateOrigin"`          // 10 characters, usually "1" + the company's EIN
+	ImmediateOriginName      string       `json:"immediateOriginName"`      // usually the company's name
+	ODFI                     string       `json:"odfi"`                     // originating bank: first 8 digits of its routing number
+	FileIDModifier           string       `json:"fileIdModifier,omitempty"` // A-Z or 0-9, distinguishes files sent the same day (default A)
+	ReferenceCode            string       `json:"referenceCode,omitempty"`
+	Companies                []ACHCompany `json:"companies"`
+}
+
+// ACHCompany is an originating company. Rows select one by Key in their
+// Company column; rows without one use the first company.
+type ACHCompany struct {
+	Key               string `json:"key,omitempty"`
+	Name              string `json:"name"`              // up to 16 characters
+	ID                string `json:"id"`                // 10 characters, usually "1" + EIN
+	EntryDescription  string `json:"entryDescription"`  // up to 10 characters, e.g. PAYROLL
+	SECCode           string `json:"secCode,omitempty"` // PPD (default), CCD or WEB
+	DiscretionaryData string `json:"discretionaryData,omitempty"`
+}
+
+// achProfile is the process-wide profile used by BankFiles without one.
+var achProfile *ACHProfile
+
+// SetACHProfile installs the profile used for NACHA output when a BankFile
+// does not carry its own. Call it during startup.
+func SetACHProfile(p *ACHProfile) {
+	achProfile = p
+}
+
+// LoadACHProfile parses and validates a JSON ACH profile.
+func LoadACHProfile(data []byte) (*ACHProfile, error) {
+	var p ACHProfile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid ACH profile: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks the routing numbers, identifiers and SEC codes.
+func (p *ACHProfile) Validate() error {
+	if err := checkRoutingNumber(p.ImmediateDestination); err != nil {
+		return fmt.Errorf("ACH profile: immediateDestination: %w", err)
+	}
+	if n := len(strings.TrimSpace(p.ImmediateOrigin)); n == 0 || n > 10 {
+		return fmt.Errorf("ACH profile: immediateOrigin must be 1 to 10 characters")
+	}
+	if len(p.ODFI) != 8 || !isDigits(p.ODFI) {
+		return fmt.Errorf("ACH profile: odfi must be the first 8 digits of the originating bank's routing number")
+	}
+	if m := p.FileIDModifier; m != "" && (len(m) != 1 || !isUpperAlnum(m[0])) {
+		return fmt.Errorf("ACH profile: fileIdModifier must be one character A-Z or 0-9")
+	}
+	if len(p.Companies) == 0 {
+		return fmt.Errorf("ACH profile: no companies")
+	}
+	for i, c := range p.Companies {
+		switch {
+		case strings.TrimSpace(c.Name) == "":
+			return fmt.Errorf("ACH profile: company %d has no name", i+1)
+		case c.ID == "" || len(c.ID) > 10:
+			return fmt.Errorf("ACH profile: company %s: id must be 1 to 10 characters", c.Name)
+		case strings.TrimSpace(c.EntryDescription) == "":
+			return fmt.Errorf("ACH profile: company %s has no entryDescription", c.Name)
+		}
+		if c.SECCode != "" {
+			if _, err := secCode(c.SECCode); err != nil {
+				return fmt.Errorf("ACH profile: company %s: %w", c.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// company returns the company a row selects by key.
+func (p *ACHProfile) company(key string) (*ACHCompany, error) {
+	if key == "" {
+		return &p.Companies[0], nil
+	}
+	for i := range p.Companies {
+		if strings.EqualFold(p.Companies[i].Key, key) {
+			return &p.Companies[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no company %q in the ACH profile", key)
+}
+
+// achEntry is one entry detail record and its optional addenda.
+type achEntry struct {
+	code    int // transaction code
+	rdfi    string
+	account string
+	amount  int64 // cents
+	id      string
+	name    string
+	disc    string
+	addenda string
+}
+
+// achBatch groups entries sharing a company, SEC code, description and
+// effective date.
+type achBatch struct {
+	company   *ACHCompany
+	sec       string
+	desc      string
+	effective time.Time
+	entries   []achEntry
+}
+
+// formatNACHA renders the records as a NACHA file. Each row needs
+// Routing_Number, Account_Number, Amount (in dollars), Name and
+// Transaction_Code (a NACHA code such as 22, or credit/debit with an
+// optional Account_Type of checking or savings). Optional columns:
+// ID_Number, Company (a profile company key), SEC_Code, Entry_Description,
+// Effective_Date (default: the day after creation), Addenda,
+// Payment_Type (WEB: R or S) and Discretionary_Data.
+func (bf *BankFile) formatNACHA() ([]byte, error) {
+	p := bf.Profile
+	if p == nil {
+		p = achProfile
+	}
+	if p == nil {
+		return nil, fmt.Errorf("NACHA output needs an ACH profile with the company and originating bank settings")
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	created := bf.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	batches, err := p.batches(bf.Records, created)
+	if err != nil {
+		return nil, err
+	}
+	if len(batches) == 0 {
+		return nil, fmt.Errorf("no entries")
+	}
+
+	modifier := p.FileIDModifier
+	if modifier == "" {
+		modifier = "A"
+	}
+	lines := []string{"1" + "01" +
+		" " + p.ImmediateDestination +
+		alnumRight(p.ImmediateOrigin, 10) +
+		created.Format("060102") + created.Format("1504") +
+		modifier + "094" + "10" + "1" +
+		alpha(p.ImmediateDestinationName, 23) +
+		alpha(p.ImmediateOriginName, 23) +
+		alpha(p.ReferenceCode, 8)}
+
+	var fileCount, fileHash, fileDebit, fileCredit int64
+	trace := 0
+	for i, b := range batches {
+		batchNumber := num(int64(i+1), 7)
+		var count, hash, debit, credit int64
+		var credits, debits bool
+		var body []string
+		for _, e := range b.entries {
+			trace++
+			traceNumber := p.ODFI + num(int64(trace), 7)
+			indicator := "0"
+			if e.addenda != "" {
+				indicator = "1"
+			}
+			body = append(body, "6"+num(int64(e.code), 2)+e.rdfi+
+				alpha(e.account, 17)+num(e.amount, 10)+alpha(e.id, 15)+alpha(e.name, 22)+
+				alpha(e.disc, 2)+indicator+traceNumber)
+			count++
+			if e.addenda != "" {
+				body = append(body, "7"+"05"+alpha(e.addenda, 80)+"0001"+num(int64(trace), 7))
+				count++
+			}
+			rdfi, _ := strconv.ParseInt(e.rdfi[:8], 10, 64)
+			hash += rdfi
+			if isDebitCode(e.code) {
+				debit += e.amount
+				debits = true
+			} else {
+				credit += e.amount
+				credits = true
+			}
+		}
+		class := "200"
+		switch {
+		case credits && !debits:
+			class = "220"
+		case debits && !credits:
+			class = "225"
+		}
+		if debit > 999999999999 || credit > 999999999999 {
+			return nil, fmt.Errorf("batch %d: total exceeds the 12-digit control field", i+1)
+		}
+		lines = append(lines, "5"+class+alpha(b.company.Name, 16)+alpha(b.company.DiscretionaryData, 20)+
+			alpha(b.company.ID, 10)+b.sec+alpha(b.desc, 10)+strings.Repeat(" ", 6)+
+			b.effective.Format("060102")+strings.Repeat(" ", 3)+"1"+p.ODFI+batchNumber)
+		lines = append(lines, body...)
+		lines = append(lines, "8"+class+num(count, 6)+num(hash%1e10, 10)+num(debit, 12)+num(credit, 12)+
+			alpha(b.company.ID, 10)+strings.Repeat(" ", 19)+strings.Repeat(" ", 6)+p.ODFI+batchNumber)
+		fileCount += count
+		fileHash += hash
+		fileDebit += debit
+		fileCredit += credit
+	}
+	if fileDebit > 999999999999 || fileCredit > 999999999999 {
+		return nil, fmt.Errorf("file total exceeds the 12-digit control field")
+	}
+
+	records := len(lines) + 1
+	blocks := (records + achBlockingFactor - 1) / achBlockingFactor
+	lines = append(lines, "9"+num(int64(len(batches)), 6)+num(int64(blocks), 6)+num(fileCount, 8)+
+		num(fileHash%1e10, 10)+num(fileDebit, 12)+num(fileCredit, 12)+strings.Repeat(" ", 39))
+	for len(lines)%achBlockingFactor != 0 {
+		lines = append(lines, strings.Repeat("9", achRecordSize))
+	}
+
+	for i, l := range lines {
+		if len(l) != achRecordSize {
+			return nil, fmt.Errorf("internal error: record %d is %d characters", i+1, len(l))
+		}
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// batches parses the records into entries grouped by batch, in order of
+// first appearance.
+func (p *ACHProfile) batches(records []Record, created time.Time) ([]*achBatch, error) {
+	type key struct {
+		company   *ACHCompany
+		sec, desc string
+		effective string
+	}
+	var batches []*achBatch
+	index := make(map[key]*achBatch)
+	for i, r := range records {
+		row := i + 2 // 1-based, after the header row
+		company, err := p.company(getFieldValue(r, "Company"))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		secValue := getFieldValue(r, "SEC_Code")
+		if secValue == "" {
+			secValue = company.SECCode
+		}
+		sec, err := secCode(secValue)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		desc := getFieldValue(r, "Entry_Description")
+		if desc == "" {
+			desc = company.EntryDescription
+		}
+		effective := created.AddDate(0, 0, 1)
+		if v := getFieldValue(r, "Effective_Date"); v != "" {
+			if effective, err = parseEffectiveDate(v); err != nil {
+				return nil, fmt.Errorf("row %d: %w", row, err)
+			}
+		}
+		e, err := parseEntry(r, sec)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+
+		k := key{company, sec, strings.ToUpper(desc), effective.Format("060102")}
+		b := index[k]
+		if b == nil {
+			b = &achBatch{company: company, sec: sec, desc: desc, effective: effective}
+			index[k] = b
+			batches = append(batches, b)
+		}
+		b.entries = append(b.entries, e)
+	}
+	return batches, nil
+}
+
+// parseEntry reads one entry detail from a row.
+func parseEntry(r Record, sec string) (achEntry, error) {
+	var e achEntry
+	routing := strings.NewReplacer("-", "", " ", "").Replace(getFieldValue(r, "Routing_Number"))
+	if err := checkRoutingNumber(routing); err != nil {
+		return e, fmt.Errorf("Routing_Number: %w", err)
+	}
+	e.rdfi = routing
+
+	var err error
+	if e.code, err = transactionCode(getFieldValue(r, "Transaction_Code"), getFieldValue(r, "Account_Type")); err != nil {
+		return e, err
+	}
+	if e.amount, err = parseCents(getFieldValue(r, "Amount")); err != nil {
+		return e, fmt.Errorf("Amount: %w", err)
+	}
+	if isPrenoteCode(e.code) && e.amount != 0 {
+		return e, fmt.Errorf("prenote transaction code %d must have a zero amount", e.code)
+	}
+
+	e.account = strings.TrimSpace(getFieldValue(r, "Account_Number"))
+	if e.account == "" {
+		return e, fmt.Errorf("Account_Number is empty")
+	}
+	if len(e.account) > 17 {
+		return e, fmt.Errorf("Account_Number %q is longer than 17 characters", e.account)
+	}
+	e.name = getFieldValue(r, "Name")
+	if strings.TrimSpace(e.name) == "" {
+		return e, fmt.Errorf("Name is empty")
+	}
+	e.id = getFieldValue(r, "ID_Number")
+	e.disc = getFieldValue(r, "Discretionary_Data")
+	if sec == "WEB" {
+		// WEB entries carry the payment type in the discretionary field.
+		switch t := strings.ToUpper(strings.TrimSpace(getFieldValue(r, "Payment_Type"))); t {
+		case "", "S", "SINGLE":
+			e.disc = "S"
+		case "R", "RECURRING":
+			e.disc = "R"
+		default:
+			return e, fmt.Errorf("Payment_Type %q: want R (recurring) or S (single)", t)
+		}
+	}
+	e.addenda = getFieldValue(r, "Addenda")
+	return e, nil
+}
+
+// transactionCode accepts a NACHA transaction code, or credit/debit with
+// an account type of checking (default) or savings.
+func transactionCode(v, accountType string) (int, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if n, err := strconv.Atoi(v); err == nil {
+		switch n {
+		case 22, 23, 24, 27, 28, 29, 32, 33, 34, 37, 38, 39:
+			return n, nil
+		}
+		return 0, fmt.Errorf("Transaction_Code %d is not a checking or savings code", n)
+	}
+	var code int
+	switch v {
+	case "credit", "cr", "c":
+		code = 22
+	case "debit", "dr", "d":
+		code = 27
+	case "":
+		return 0, fmt.Errorf("Transaction_Code is empty")
+	default:
+		return 0, fmt.Errorf("Transaction_Code %q: want a NACHA code, credit or debit", v)
+	}
+	switch strings.ToLower(strings.TrimSpace(accountType)) {
+	case "", "checking", "c", "dda":
+	case "savings", "s", "sav":
+		code += 10
+	default:
+		return 0, fmt.Errorf("Account_Type %q: want checking or savings", accountType)
+	}
+	return code, nil
+}
+
+// isDebitCode reports whether a transaction code debits the receiver.
+func isDebitCode(code int) bool {
+	return code%10 >= 7
+}
+
+// isPrenoteCode reports whether a transaction code is a prenotification.
+func isPrenoteCode(code int) bool {
+	return code%10 == 3 || code%10 == 8
+}
+
+// secCode validates a Standard Entry Class code.
+func secCode(s string) (string, error) {
+	switch s = strings.ToUpper(strings.TrimSpace(s)); s {
+	case "", "PPD":
+		return "PPD", nil
+	case "CCD", "WEB":
+		return s, nil
+	}
+	return "", fmt.Errorf("unsupported SEC code %q (want PPD, CCD or WEB)", s)
+}
+
+// checkRoutingNumber validates a 9-digit ABA routing number's check digit.
+func checkRoutingNumber(s string) error {
+	if len(s) != 9 || !isDigits(s) {
+		return fmt.Errorf("routing number %q must be 9 digits", s)
+	}
+	weights := [9]int{3, 7, 1, 3, 7, 1, 3, 7, 1}
+	sum := 0
+	for i := range 9 {
+		sum += int(s[i]-'0') * weights[i]
+	}
+	if sum%10 != 0 {
+		return fmt.Errorf("routing number %s has an invalid check digit", s)
+	}
+	return nil
+}
+
+// parseCents converts a dollar amount such as "$1,234.5" to cents.
+func parseCents(s string) (int64, error) {
+	v := strings.NewReplacer("$", "", ",", "", " ", "").Replace(strings.TrimSpace(s))
+	if v == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+	dollars, cents, _ := strings.Cut(v, ".")
+	if dollars == "" {
+		dollars = "0"
+	}
+	if !isDigits(dollars) || (cents != "" && !isDigits(cents)) || len(cents) > 2 {
+		return 0, fmt.Errorf("%q is not a positive amount with at most 2 decimals", s)
+	}
+	cents = (cents + "00")[:2]
+	n, err := strconv.ParseInt(dollars+cents, 10, 64)
+	if err != nil || n > 9999999999 {
+		return 0, fmt.Errorf("%q exceeds the 10-digit amount field", s)
+	}
+	return n, nil
+}
+
+// parseEffectiveDate accepts YYMMDD, NACHA's own layout, and the dates
+// parseDate detects.
+func parseEffectiveDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 6 {
+		if t, err := time.Parse("060102", s); err == nil {
+			return t, nil
+		}
+	}
+	t, err := parseDate(s, DateAuto)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Effective_Date: %w", err)
+	}
+	return t, nil
+}
+
+// alpha formats an alphanumeric NACHA field: upper case, printable ASCII,
+// left-justified, space-filled and cut to n characters.
+func alpha(s string, n int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(s)))
+	if len(s) > n {
+		return s[:n]
+	}
+	return padRight(s, n, ' ')
+}
+
+// alnumRight right-justifies s in n characters, as the immediate origin
+// is when it is a 9-digit routing number.
+func alnumRight(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		return s[:n]
+	}
+	return padLeft(s, n, ' ')
+}
+
+// num formats a non-negative number zero-filled to n digits.
+func num(v int64, n int) string {
+	return padLeft(strconv.FormatInt(v, 10), n, '0')
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isUpperAlnum(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// achLayouts describe NACHA records for reading files back with
+// DecodeFixedWidth. Entry details are read into the columns NACHA output
+// takes, and each addenda record into the Addenda column of its entry.
+var achLayouts = func() []layout {
+	text := func(name string, pos, n int) Field {
+		return Field{Name: name, Position: pos, Length: n, Type: "text", Padding: " ", Align: "left"}
+	}
+	number := func(name string, pos, n int, scale *int) Field {
+		return Field{Name: name, Position: pos, Length: n, Type: "numeric", Padding: "0", Align: "right", Scale: scale}
+	}
+	date := func(name string, pos int) Field {
+		return Field{Name: name, Position: pos, Length: 6, Type: "date", DateFormat: "YYMMDD"}
+	}
+	code := func(value string) Field {
+		f := text("Record_Type", 0, len(value))
+		f.Value = value
+		return f
+	}
+	addendaType := text("Addenda_Type", 1, 2)
+	addendaType.Value = "05"
+
+	layouts := []layout{
+		newLayout("detail", []Field{
+			code("6"), text("Transaction_Code", 1, 2), text("Routing_Number", 3, 9),
+			text("Account_Number", 12, 17), number("Amount", 29, 10, cents), text("ID_Number", 39, 15),
+			text("Name", 54, 22), text("Discretionary_Data", 76, 2), text("Addenda_Indicator", 78, 1),
+			text("Trace_Number", 79, 15),
+		}),
+		newLayout("header", []Field{
+			code("1"), text("Priority_Code", 1, 2), text("Immediate_Destination", 3, 10),
+			text("Immediate_Origin", 13, 10), date("File_Creation_Date", 23), text("File_Creation_Time", 29, 4),
+			text("File_ID_Modifier", 33, 1), text("Record_Size", 34, 3), text("Blocking_Factor", 37, 2),
+			text("Format_Code", 39, 1), text("Immediate_Destination_Name", 40, 23),
+			text("Immediate_Origin_Name", 63, 23), text("Reference_Code", 86, 8),
+		}),
+		newLayout("trailer", []Field{
+			code("9"), number("Batch_Count", 1, 6, nil), number("Block_Count", 7, 6, nil),
+			number("Entry_Count", 13, 8, nil), text("Entry_Hash", 21, 10), number("Total_Debit", 31, 12, cents),
+			number("Total_Credit", 43, 12, cents), text("Reserved", 55, 39),
+		}),
+		newLayout("batch header", []Field{
+			code("5"), text("Service_Class_Code", 1, 3), text("Company_Name", 4, 16),
+			text("Company_Discretionary_Data", 20, 20), text("Company_ID", 40, 10), text("SEC_Code", 50, 3),
+			text("Entry_Description", 53, 10), text("Descriptive_Date", 63, 6), date("Effective_Date", 69),
+			text("Settlement_Date", 75, 3), text("Originator_Status", 78, 1), text("ODFI", 79, 8),
+			text("Batch_Number", 87, 7),
+		}),
+		newLayout("batch trailer", []Field{
+			code("8"), text("Service_Class_Code", 1, 3), number("Entry_Count", 4, 6, nil),
+			text("Entry_Hash", 10, 10), number("Total_Debit", 20, 12, cents), number("Total_Credit", 32, 12, cents),
+			text("Company_ID", 44, 10), text("Message_Authentication_Code", 54, 19), text("Reserved", 73, 6),
+			text("ODFI", 79, 8), text("Batch_Number", 87, 7),
+		}),
+		newLayout("addenda", []Field{code("7"), addendaType, text("Addenda", 3, 80)}),
+	}
+	layouts[len(layouts)-1].merge = true
+	for i := range layouts {
+		layouts[i].length = achRecordSize
+	}
+	return layouts
+}()
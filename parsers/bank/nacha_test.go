@@ -0,0 +1,124 @@
+package bank
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testProfile() *ACHProfile {
+	return &ACHProfile{
+		ImmediateDestination:     "091000019",
+		ImmediateDestinationName: "Wells Fargo",
+		ImmediateOrigin:          "1234567890",
+		ImmediateOriginName:      "Acme Corp",
+		ODFI:                     "09100001",
+		Companies: []ACHCompany{
+			{Key: "acme", Name: "Acme Corp", ID: "1234567890", EntryDescription: "Payroll"},
+			{Key: "shop", Name: "Acme Shop", ID: "1987654321", EntryDescription: "Purchase", SECCode: "WEB"},
+		},
+	}
+}
+
+func TestFormatNACHA(t *testing.T) {
+	csv := "Routing_Number,Account_Number,Amount,Name,Transaction_Code,ID_Number,Company,Effective_Date,Addenda\n" +
+		"021000021,111222,\"$1,250.50\",Jane Doe,credit,E1,,2024-03-01,Bonus\n" +
+		"011000015,333444,99,John Roe,32,E2,,2024-03-01,\n" +
+		"021000021,555,12.3,Pat Buyer,debit,C9,shop,2024-03-01,\n" +
+		"011000015,666,5,Late Payee,22,E3,acme,2024-03-04,\n"
+	bf, err := DecodeWithTemplate([]byte(csv), *GetTemplate("ACH_Payment"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf.Profile = testProfile()
+	bf.Created = time.Date(2024, 2, 28, 9, 30, 0, 0, time.UTC)
+
+	out, err := bf.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines)%10 != 0 {
+		t.Fatalf("%d records, want a multiple of 10", len(lines))
+	}
+	var kinds string
+	for i, l := range lines {
+		if len(l) != 94 {
+			t.Fatalf("record %d is %d characters: %q", i+1, len(l), l)
+		}
+		kinds += l[:1]
+	}
+	// Three batches: acme on 03-01 (with an addenda), shop (WEB) on 03-01,
+	// acme on 03-04; then the file control and 9-filled blocking lines.
+	if want := "156768" + "568" + "568" + "9"; !strings.HasPrefix(kinds, want) || strings.Trim(kinds[len(want):], "9") != "" {
+		t.Fatalf("record types = %s", kinds)
+	}
+
+	if want := "101 091000019123456789024022809" + "30A094101WELLS FARGO            ACME CORP                      "; lines[0] != want {
+		t.Errorf("file header\n got %q\nwant %q", lines[0], want)
+	}
+	if want := "5220ACME CORP                           1234567890PPDPAYROLL         240301   1091000010000001"; lines[1] != want {
+		t.Errorf("batch header\n got %q\nwant %q", lines[1], want)
+	}
+	if want := "622021000021111222           0000125050E1             JANE DOE                1091000010000001"; lines[2] != want {
+		t.Errorf("entry\n got %q\nwant %q", lines[2], want)
+	}
+	if want := "705BONUS" + strings.Repeat(" ", 75) + "00010000001"; lines[3] != want {
+		t.Errorf("addenda\n got %q\nwant %q", lines[3], want)
+	}
+	if !strings.HasPrefix(lines[4], "632011000015333444") {
+		t.Errorf("savings credit = %q", lines[4])
+	}
+	// Batch control: 3 entries+addenda, hash 02100002+01100001, no
+	// debits, credits 1250.50 + 99.00.
+	if want := "8220" + "000003" + "0003200003" + "000000000000" + "000000134950" + "1234567890" + strings.Repeat(" ", 25) + "091000010000001"; lines[5] != want {
+		t.Errorf("batch control\n got %q\nwant %q", lines[5], want)
+	}
+	if !strings.HasPrefix(lines[6], "5225ACME SHOP") || lines[6][50:53] != "WEB" {
+		t.Errorf("WEB debit batch header = %q", lines[6])
+	}
+	if lines[7][76:78] != "S " || lines[7][1:3] != "27" {
+		t.Errorf("WEB entry = %q", lines[7])
+	}
+	if lines[9][69:75] != "240304" {
+		t.Errorf("second acme batch effective date = %q", lines[9][69:75])
+	}
+
+	// File control: 3 batches, 2 blocks, 5 entry/addenda records, hash of
+	// the four RDFIs, debits 12.30, credits 1349.50 + 5.00.
+	fc := lines[12]
+	if want := "9000003000002000000050006400006" + "000000001230" + "000000135450" + strings.Repeat(" ", 39); fc != want {
+		t.Errorf("file control\n got %q\nwant %q", fc, want)
+	}
+}
+
+func TestFormatNACHAErrors(t *testing.T) {
+	tpl := *GetTemplate("ACH_Payment")
+	for _, tc := range []struct {
+		row, want string
+	}{
+		{"021000022,1,5,A,22,", "invalid check digit"},
+		{"021000021,1,5,A,21,", "not a checking or savings code"},
+		{"021000021,1,-5,A,22,", "not a positive amount"},
+		{"021000021,1,5,A,23,", "prenote"},
+		{"021000021,,5,A,22,", "Account_Number is empty"},
+		{"021000021,1,5,A,debit,XYZ", "SEC code"},
+	} {
+		bf, err := DecodeWithTemplate([]byte("Routing_Number,Account_Number,Amount,Name,Transaction_Code,SEC_Code\n"+tc.row+"\n"), tpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bf.Profile = testProfile()
+		if _, err := bf.Format(); err == nil || !strings.Contains(err.Error(), tc.want) || !strings.Contains(err.Error(), "row 2") {
+			t.Errorf("%s: error = %v, want %q", tc.row, err, tc.want)
+		}
+	}
+
+	bf := &BankFile{Template: tpl, Records: []Record{{}}}
+	if _, err := bf.Format(); err == nil || !strings.Contains(err.Error(), "ACH profile") {
+		t.Errorf("missing profile: %v", err)
+	}
+	if _, err := LoadACHProfile([]byte(`{"immediateDestination": "091000019", "immediateOrigin": "1", "odfi": "0910", "companies": []}`)); err == nil {
+		t.Error("short ODFI accepted")
+	}
+}
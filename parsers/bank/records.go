@@ -0,0 +1,403 @@
+// records.go lays out a bank file's header, trailer and batch records
+// around its detail records and evaluates the {expressions} in their
+// field values: aggregates such as {count}, {details} and {sum(Amount)}, the
+// creation time {now:YYMMDD}, and sequence numbers.
+
+package bank
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecordSpec describes a header, trailer or batch record. Its fields
+// usually take their content from Value.
+type RecordSpec struct {
+	Fields []Field `json:"fields"`
+}
+
+// BatchSpec groups the detail records into batches, each wrapped in its
+// own header and trailer record. Records with equal values in the GroupBy
+// columns share a batch; batches are written in order of first
+// appearance, and without GroupBy all records form one batch.
+type BatchSpec struct {
+	GroupBy []string    `json:"groupBy,omitempty"`
+	Header  *RecordSpec `json:"header,omitempty"`
+	Footer  *RecordSpec `json:"footer,omitempty"`
+}
+
+// outputRow is one record of the output: a detail record, possibly in
+// one of the template's other record layouts, or an evaluated header,
+// trailer or batch record.
+type outputRow struct {
+	fields []Field
+	record Record
+	detail bool
+	row    int    // input row number of a detail record (the header row is 1)
+	name   string // "header", "batch 1 trailer" and so on, or a detail record's layout
+}
+
+// scope is what the expressions in one record can refer to.
+type scope struct {
+	records []Record  // detail records covered: the file, a batch, or one record
+	seq     int       // batch number in batch records, record number in details, else 1
+	batches int       // number of batches in the file
+	lines   int       // number of records in the file, headers and trailers included
+	details int       // number of detail records covered, in every record layout
+	now     time.Time // file creation time
+	fields  []Field   // the detail fields, whose settings sum() follows
+}
+
+// rows lays out the file: the header, each batch's header, details and
+// trailer, then the trailer. Each input row gives the records its
+// layouts select (see rowRecords). A file read from fixed-width records
+// keeps the records as read.
+func (bf *BankFile) rows() ([]outputRow, error) {
+	if bf.parsed != nil {
+		return bf.parsed, nil
+	}
+	t := bf.Template
+	now := bf.Created
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var groupBy []string
+	var batchHeader, batchFooter *RecordSpec
+	if t.Batch != nil {
+		groupBy, batchHeader, batchFooter = t.Batch.GroupBy, t.Batch.Header, t.Batch.Footer
+	}
+	batches := groupRecords(bf.Records, groupBy)
+	kinds := make([][]rowRecord, len(bf.Records))
+	lines := 0
+	for i, r := range bf.Records {
+		var err error
+		if kinds[i], err = t.rowRecords(r); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+2, err)
+		}
+		lines += len(kinds[i])
+	}
+	for _, spec := range []*RecordSpec{t.Header, t.Footer} {
+		if spec != nil {
+			lines++
+		}
+	}
+	for _, spec := range []*RecordSpec{batchHeader, batchFooter} {
+		if spec != nil {
+			lines += len(batches)
+		}
+	}
+	details := func(indexes ...int) int {
+		n := 0
+		for _, i := range indexes {
+			n += len(kinds[i])
+		}
+		return n
+	}
+	all := make([]int, len(bf.Records))
+	for i := range all {
+		all[i] = i
+	}
+	file := scope{records: bf.Records, seq: 1, batches: len(batches), lines: lines, details: details(all...), now: now, fields: t.inputFields()}
+
+	var out []outputRow
+	add := func(spec *RecordSpec, s scope, what string) error {
+		if spec == nil {
+			return nil
+		}
+		rec, err := s.evaluate(spec.Fields, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+		out = append(out, outputRow{fields: spec.Fields, record: rec, name: what})
+		return nil
+	}
+
+	if err := add(t.Header, file, "header"); err != nil {
+		return nil, err
+	}
+	n := 0
+	for i, indexes := range batches {
+		batch := file
+		batch.records, batch.seq, batch.details = make([]Record, len(indexes)), i+1, details(indexes...)
+		for j, idx := range indexes {
+			batch.records[j] = bf.Records[idx]
+		}
+		if err := add(batchHeader, batch, fmt.Sprintf("batch %d header", i+1)); err != nil {
+			return nil, err
+		}
+		for _, idx := range indexes {
+			n++
+			r := bf.Records[idx]
+			detail := file
+			detail.records, detail.seq, detail.details = []Record{r}, n, len(kinds[idx])
+			for _, k := range kinds[idx] {
+				rec, err := detail.evaluate(k.fields, r)
+				if err != nil {
+					if k.name != "" {
+						err = fmt.Errorf("%s record: %w", k.name, err)
+					}
+					return nil, fmt.Errorf("row %d: %w", idx+2, err)
+				}
+				out = append(out, outputRow{fields: k.fields, record: rec, detail: true, row: idx + 2, name: k.name})
+			}
+		}
+		if err := add(batchFooter, batch, fmt.Sprintf("batch %d trailer", i+1)); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(t.Footer, file, "trailer"); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// groupRecords splits records into batches by their values in columns
+// and returns the indexes of each batch's records.
+func groupRecords(records []Record, columns []string) [][]int {
+	var batches [][]int
+	index := make(map[string]int)
+	for i, r := range records {
+		var key strings.Builder
+		for _, c := range columns {
+			key.WriteString(getFieldValue(r, c))
+			key.WriteByte(0)
+		}
+		b, ok := index[key.String()]
+		if !ok {
+			b = len(batches)
+			index[key.String()] = b
+			batches = append(batches, nil)
+		}
+		batches[b] = append(batches[b], i)
+	}
+	return batches
+}
+
+// evaluate returns record (or an empty record) with every field that has
+// a Value set to its expanded value.
+func (s scope) evaluate(fields []Field, record Record) (Record, error) {
+	out := make(Record, len(record)+len(fields))
+	for k, v := range record {
+		out[k] = v
+	}
+	for _, f := range fields {
+		if f.Value == "" {
+			continue
+		}
+		v, err := s.expand(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		out[f.Name] = v
+	}
+	return out, nil
+}
+
+// expand replaces each {expression} in v with its value; the rest of v is
+// copied as is.
+func (s scope) expand(v string) (string, error) {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(v, '{')
+		if open < 0 {
+			b.WriteString(v)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(v[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated expression in %q", v)
+		}
+		val, err := s.eval(strings.TrimSpace(v[open+1 : open+end]))
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(v[:open])
+		b.WriteString(val)
+		v = v[open+end+1:]
+	}
+}
+
+// eval evaluates one expression.
+func (s scope) eval(expr string) (string, error) {
+	switch expr {
+	case "count":
+		return strconv.Itoa(len(s.records)), nil
+	case "seq", "sequence":
+		return strconv.Itoa(s.seq), nil
+	case "batches":
+		return strconv.Itoa(s.batches), nil
+	case "lines":
+		return strconv.Itoa(s.lines), nil
+	case "details":
+		return strconv.Itoa(s.details), nil
+	}
+	if layout, ok := strings.CutPrefix(expr, "now:"); ok {
+		return formatDate(s.now, layout), nil
+	}
+	if column, ok := cutCall(expr, "sum"); ok {
+		field := s.numericField(column)
+		var sum int64
+		for i, r := range s.records {
+			n, err := parseNumeric(fieldValue(r, field), field)
+			if err != nil {
+				return "", fmt.Errorf("sum(%s): record %d: %w", column, i+1, err)
+			}
+			sum += n
+		}
+		return strconv.FormatInt(sum, 10), nil
+	}
+	if column, ok := cutCall(expr, "first"); ok {
+		if len(s.records) == 0 {
+			return "", nil
+		}
+		return getFieldValue(s.records[0], column), nil
+	}
+	return "", fmt.Errorf("unknown expression {%s}", expr)
+}
+
+// cutCall returns the argument of name(arg).
+func cutCall(expr, name string) (string, bool) {
+	arg, ok := strings.CutPrefix(expr, name+"(")
+	if !ok || !strings.HasSuffix(arg, ")") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimSuffix(arg, ")")), true
+}
+
+// numericField returns the detail field named column, or a numeric field
+// reading that column if there is none, so that sums follow the column's
+// scale, part and separators. The sum is written with a plain sign.
+func (s scope) numericField(column string) Field {
+	for _, f := range s.fields {
+		if strings.EqualFold(f.Name, column) {
+			f.Type, f.Value, f.Sign = "numeric", "", ""
+			return f
+		}
+	}
+	return Field{Name: column, Type: "numeric"}
+}
+
+// parseNumeric reads a value the way field prints it, so "$1,234.50"
+// counts as 123450 with a scale of 2.
+func parseNumeric(v string, field Field) (int64, error) {
+	text, err := numericText(v, field)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is too large", v)
+	}
+	return n, nil
+}
+
+// dateLayout converts a YYYY/YY/MMMM/MMM/MM/DD/DDD/HH/mm/ss pattern to a
+// Go time layout; MMMM and MMM are the month's name and abbreviation and
+// DDD is the day of the year.
+func dateLayout(pattern string) string {
+	return strings.NewReplacer(
+		"YYYY", "2006", "YY", "06", "MMMM", "January", "MMM", "Jan", "MM", "01", "DDD", "002", "DD", "02",
+		"HH", "15", "mm", "04", "ss", "05",
+	).Replace(pattern)
+}
+
+// Validate reports records whose fields overlap or leave gaps, and
+// fields with a bad padding, alignment or type, value expressions that do
+// not parse or validation rules that can never work, in every record
+// layout.
+func (t Template) Validate() error {
+	type part struct {
+		what   string
+		fields []Field
+	}
+	if err := t.checkRecordLayouts(); err != nil {
+		return err
+	}
+	parts := []part{{"detail", t.Fields}}
+	for _, l := range t.Records {
+		parts = append(parts, part{l.Name, l.Fields})
+	}
+	if t.Header != nil {
+		parts = append(parts, part{"header", t.Header.Fields})
+	}
+	if t.Footer != nil {
+		parts = append(parts, part{"trailer", t.Footer.Fields})
+	}
+	if t.Batch != nil && t.Batch.Header != nil {
+		parts = append(parts, part{"batch header", t.Batch.Header.Fields})
+	}
+	if t.Batch != nil && t.Batch.Footer != nil {
+		parts = append(parts, part{"batch trailer", t.Batch.Footer.Fields})
+	}
+	s := scope{records: []Record{{}}}
+	for _, p := range parts {
+		if err := checkLayout(p.fields); err != nil {
+			return fmt.Errorf("%s record: %w", p.what, err)
+		}
+		for _, f := range p.fields {
+			if err := checkFieldRules(f); err != nil {
+				return fmt.Errorf("%s field %s: %w", p.what, f.Name, err)
+			}
+			if f.Value == "" {
+				continue
+			}
+			if _, err := s.expand(f.Value); err != nil {
+				return fmt.Errorf("%s field %s: %w", p.what, f.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLayout reports fields that cannot be written: unnamed, repeated or
+// empty fields, unknown types, paddings and alignments, and positions
+// that overlap or leave unwritten gaps in the line.
+func checkLayout(fields []Field) error {
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		switch {
+		case f.Name == "":
+			return fmt.Errorf("field at position %d has no name", f.Position)
+		case seen[f.Name]:
+			return fmt.Errorf("field %s is defined twice", f.Name)
+		case f.Position < 0:
+			return fmt.Errorf("field %s: position %d is negative", f.Name, f.Position)
+		case f.Length <= 0:
+			return fmt.Errorf("field %s: length must be positive", f.Name)
+		case len(f.Padding) > 1:
+			return fmt.Errorf("field %s: padding %q is not a single character", f.Name, f.Padding)
+		}
+		seen[f.Name] = true
+		switch f.Type {
+		case "", "text", "numeric", "date":
+		default:
+			return fmt.Errorf("field %s: type %q is not text, numeric or date", f.Name, f.Type)
+		}
+		switch f.Align {
+		case "", "left", "right":
+		default:
+			return fmt.Errorf("field %s: align %q is not left or right", f.Name, f.Align)
+		}
+	}
+
+	sorted := slices.Clone(fields)
+	slices.SortStableFunc(sorted, func(a, b Field) int { return a.Position - b.Position })
+	end, last := 0, Field{}
+	for _, f := range sorted {
+		switch {
+		case f.Position < end:
+			return fmt.Errorf("field %s (position %d) overlaps %s (positions %d-%d)",
+				f.Name, f.Position, last.Name, last.Position, end-1)
+		case f.Position == end+1:
+			return fmt.Errorf("position %d before field %s is not covered by any field", end, f.Name)
+		case f.Position > end:
+			return fmt.Errorf("positions %d-%d before field %s are not covered by any field", end, f.Position-1, f.Name)
+		}
+		end, last = f.Position+f.Length, f
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package bank
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const recordsTemplate = `{
+  "name": "Batched payments",
+  "fields": [
+    {"name": "Type", "position": 0, "length": 1, "value": "D"},
+    {"name": "Seq", "position": 1, "length": 3, "type": "numeric", "padding": "0", "align": "right", "value": "{seq}"},
+    {"name": "Branch", "position": 4, "length": 4},
+    {"name": "Amount", "position": 8, "length": 8, "type": "numeric", "scale": 2, "padding": "0", "align": "right"}
+  ],
+  "header": {"fields": [
+    {"name": "Type", "position": 0, "length": 1, "value": "H"},
+    {"name": "Created", "position": 1, "length": 8, "value": "{now:YYYYMMDD}"},
+    {"name": "Julian", "position": 9, "length": 5, "value": "{now:YYDDD}"}
+  ]},
+  "batch": {
+    "groupBy": ["Branch"],
+    "header": {"fields": [
+      {"name": "Type", "position": 0, "length": 1, "value": "B"},
+      {"name": "Batch", "position": 1, "length": 2, "type": "numeric", "padding": "0", "align": "right", "value": "{seq}"},
+      {"name": "Branch", "position": 3, "length": 6, "value": "BR{first(Branch)}"}
+    ]},
+    "footer": {"fields": [
+      {"name": "Type", "position": 0, "length": 1, "value": "C"},
+      {"name": "Count", "position": 1, "length": 3, "type": "numeric", "padding": "0", "align": "right", "value": "{count}"},
+      {"name": "Total", "position": 4, "length": 10, "type": "numeric", "padding": "0", "align": "right", "value": "{sum(Amount)}"}
+    ]}
+  },
+  "footer": {"fields": [
+    {"name": "Type", "position": 0, "length": 1, "value": "T"},
+    {"name": "Batches", "position": 1, "length": 2, "type": "numeric", "padding": "0", "align": "right", "value": "{batches}"},
+    {"name": "Lines", "position": 3, "length": 4, "type": "numeric", "padding": "0", "align": "right", "value": "{lines}"},
+    {"name": "Total", "position": 7, "length": 10, "type": "numeric", "padding": "0", "align": "right", "value": "{sum(Amount)}"}
+  ]}
+}`
+
+func decodeBatched(t *testing.T) *BankFile {
+	t.Helper()
+	tpl, err := LoadCustomTemplate([]byte(recordsTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf, err := DecodeWithTemplate([]byte("Branch,Amount\n0001,10.00\n0002,\"$1,000.50\"\n0001,2.25\n"), *tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf.Created = time.Date(2024, 2, 3, 12, 0, 0, 0, time.UTC)
+	return bf
+}
+
+func TestFormatRecords(t *testing.T) {
+	out, err := decodeBatched(t).Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"H2024020324034",
+		"B01BR0001",
+		"D001000100001000",
+		"D002000100000225",
+		"C0020000001225",
+		"B02BR0002",
+		"D003000200100050",
+		"C0010000100050",
+		"T0200090000101275",
+	}, "\n")
+	if string(out) != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestFormatRecordsCSVAndExcel(t *testing.T) {
+	bf := decodeBatched(t)
+	out, err := bf.FormatAsCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 10 || lines[0] != "Type,Seq,Branch,Amount" || lines[1] != "H,20240203,24034" ||
+		lines[3] != "D,001,0001,00001000" || lines[9] != "T,02,0009,0000101275" {
+		t.Errorf("CSV =\n%s", out)
+	}
+
+	data, err := bf.FormatAsExcel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, _ := f.GetRows("Sheet1")
+	if len(rows) != 10 || rows[2][0] != "B" || rows[5][2] != "0000001225" {
+		t.Errorf("Excel rows = %v", rows)
+	}
+}
+
+func TestTemplateValidate(t *testing.T) {
+	for _, value := range []string{"{total}", "{sum(Amount)", "{first Branch}"} {
+		tpl := Template{Footer: &RecordSpec{Fields: []Field{{Name: "X", Length: 5, Value: value}}}}
+		if err := tpl.Validate(); err == nil {
+			t.Errorf("value %q accepted", value)
+		}
+	}
+	bf := decodeBatched(t)
+	bf.Records = append(bf.Records, Record{"Branch": "0003", "Amount": "n/a"})
+	if _, err := bf.Format(); err == nil || !strings.Contains(err.Error(), `row 5, Amount: "n/a" is not a number`) {
+		t.Errorf("non-numeric sum: %v", err)
+	}
+}
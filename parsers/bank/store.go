@@ -0,0 +1,358 @@
+// store.go keeps custom templates as JSON files in a directory, next to
+// the built-in DefaultTemplates. Every change bumps the template's
+// version and keeps the replaced version in the directory's history, and
+// the directory is re-read when its files change.
+
+package bank
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StoredTemplate is a template with its key and version. It is stored and
+// served as the template's JSON with "key", "version" and "updated"
+// added.
+type StoredTemplate struct {
+	Key     string    `json:"key"`
+	Version int       `json:"version"`
+	Updated time.Time `json:"updated,omitzero"`
+	Builtin bool      `json:"builtin,omitempty"`
+	Template
+}
+
+// Store errors, for callers that map them to statuses.
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrTemplateExists   = errors.New("template already exists")
+	ErrVersionConflict  = errors.New("template version conflict")
+	ErrBuiltinTemplate  = errors.New("built-in templates cannot be changed")
+)
+
+// templateKey restricts template keys to what is safe as a file name.
+var templateKey = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// historyDir holds replaced versions as history/<key>/v<N>.json.
+const historyDir = "history"
+
+// TemplateStore is a directory of custom templates, one <key>.json file
+// each.
+type TemplateStore struct {
+	dir string
+
+	mu        sync.RWMutex
+	templates map[string]*StoredTemplate
+	stamp     string // file names, sizes and times at the last load
+}
+
+// OpenTemplates loads the templates in dir, creating the directory if
+// needed. Files that fail to load are reported in the error; the store
+// still holds every template that loaded.
+func OpenTemplates(dir string) (*TemplateStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("templates: %w", err)
+	}
+	s := &TemplateStore{dir: dir, templates: make(map[string]*StoredTemplate)}
+	return s, s.Reload()
+}
+
+// Reload re-reads the directory.
+func (s *TemplateStore) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *TemplateStore) load() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	templates := make(map[string]*StoredTemplate, len(paths))
+	var errs []error
+	for _, path := range paths {
+		key := strings.TrimSuffix(filepath.Base(path), ".json")
+		t, err := readTemplate(path, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+			continue
+		}
+		templates[key] = t
+	}
+	s.templates = templates
+	s.stamp = s.dirStamp()
+	return errors.Join(errs...)
+}
+
+// readTemplate reads one stored template; its key comes from the file
+// name, and hand-written files without a version are version 1.
+func readTemplate(path, key string) (*StoredTemplate, error) {
+	if _, builtin := DefaultTemplates[key]; builtin {
+		return nil, fmt.Errorf("%s is a built-in template key", key)
+	}
+	if !templateKey.MatchString(key) {
+		return nil, fmt.Errorf("invalid template key %q", key)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t StoredTemplate
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	if t.Key != "" && t.Key != key {
+		return nil, fmt.Errorf("file holds template %q", t.Key)
+	}
+	t.Key, t.Builtin = key, false
+	t.Version = max(t.Version, 1)
+	if err := t.Template.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// dirStamp summarizes the directory's template files so that changes can
+// be noticed without reading them.
+func (s *TemplateStore) dirStamp() string {
+	entries, _ := os.ReadDir(s.dir)
+	var b strings.Builder
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", e.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// Watch reloads the store whenever its files change, checking every
+// interval until stop is closed. Load errors go to report.
+func (s *TemplateStore) Watch(interval time.Duration, stop <-chan struct{}, report func(error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		s.mu.Lock()
+		var err error
+		changed := s.dirStamp() != s.stamp
+		if changed {
+			err = s.load()
+		}
+		s.mu.Unlock()
+		if err != nil && report != nil {
+			report(err)
+		}
+	}
+}
+
+// List returns the stored templates sorted by key.
+func (s *TemplateStore) List() []*StoredTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*StoredTemplate, 0, len(s.templates))
+	for _, t := range s.templates {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Get returns the current version of a stored template.
+func (s *TemplateStore) Get(key string) (*StoredTemplate, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.templates[key]
+	return t, ok
+}
+
+// Version returns the given version of a stored template, from the
+// history if it has been replaced.
+func (s *TemplateStore) Version(key string, version int) (*StoredTemplate, error) {
+	if t, ok := s.Get(key); ok && t.Version == version {
+		return t, nil
+	}
+	if !templateKey.MatchString(key) {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, key)
+	}
+	path := filepath.Join(s.dir, historyDir, key, "v"+strconv.Itoa(version)+".json")
+	t, err := readTemplate(path, key)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s version %d", ErrTemplateNotFound, key, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Version = version
+	return t, nil
+}
+
+// Create validates and stores a new template as version 1.
+func (s *TemplateStore) Create(key string, tpl Template) (*StoredTemplate, error) {
+	if err := checkTemplate(key, tpl); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.templates[key]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateExists, key)
+	}
+	return s.write(&StoredTemplate{Key: key, Version: 1, Template: tpl})
+}
+
+// Update validates and stores a new version of a template. version must
+// be the current version, so that concurrent edits are not lost; the
+// replaced version moves to the history.
+func (s *TemplateStore) Update(key string, tpl Template, version int) (*StoredTemplate, error) {
+	if err := checkTemplate(key, tpl); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.templates[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, key)
+	}
+	if version != cur.Version {
+		return nil, fmt.Errorf("%w: %s is at version %d, not %d", ErrVersionConflict, key, cur.Version, version)
+	}
+	if err := s.archive(cur); err != nil {
+		return nil, err
+	}
+	return s.write(&StoredTemplate{Key: key, Version: cur.Version + 1, Template: tpl})
+}
+
+// Delete removes a template, keeping its last version in the history.
+// version must be the current version, or 0 to delete whatever it is.
+func (s *TemplateStore) Delete(key string, version int) error {
+	if _, builtin := DefaultTemplates[key]; builtin {
+		return fmt.Errorf("%w: %s", ErrBuiltinTemplate, key)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.templates[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, key)
+	}
+	if version != 0 && version != cur.Version {
+		return fmt.Errorf("%w: %s is at version %d, not %d", ErrVersionConflict, key, cur.Version, version)
+	}
+	if err := s.archive(cur); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.dir, key+".json")); err != nil {
+		return err
+	}
+	delete(s.templates, key)
+	s.stamp = s.dirStamp()
+	return nil
+}
+
+// checkTemplate validates a template about to be stored under key.
+func checkTemplate(key string, tpl Template) error {
+	if _, builtin := DefaultTemplates[key]; builtin {
+		return fmt.Errorf("%w: %s", ErrBuiltinTemplate, key)
+	}
+	if !templateKey.MatchString(key) {
+		return fmt.Errorf("invalid template key %q: use letters, digits, '_' and '-'", key)
+	}
+	if strings.TrimSpace(tpl.Name) == "" {
+		return errors.New("template name is empty")
+	}
+	if len(tpl.Fields) == 0 {
+		return errors.New("template has no fields")
+	}
+	return tpl.Validate()
+}
+
+// write stores t as the current version; the caller holds s.mu.
+func (s *TemplateStore) write(t *StoredTemplate) (*StoredTemplate, error) {
+	t.Updated = time.Now().UTC().Truncate(time.Second)
+	if err := writeJSON(filepath.Join(s.dir, t.Key+".json"), t); err != nil {
+		return nil, err
+	}
+	s.templates[t.Key] = t
+	s.stamp = s.dirStamp()
+	return t, nil
+}
+
+// archive copies the current version of t to the history.
+func (s *TemplateStore) archive(t *StoredTemplate) error {
+	dir := filepath.Join(s.dir, historyDir, t.Key)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(dir, "v"+strconv.Itoa(t.Version)+".json"), t)
+}
+
+// writeJSON writes v as indented JSON to path through a temporary file,
+// so readers never see a partial file.
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// templates is the process-wide store consulted after DefaultTemplates;
+// nil until SetTemplates is called.
+var templates *TemplateStore
+
+// SetTemplates installs the store of custom templates. Call it during
+// startup.
+func SetTemplates(s *TemplateStore) {
+	templates = s
+}
+
+// Templates returns the installed store, or nil if none is configured.
+func Templates() *TemplateStore {
+	return templates
+}
+
+// ListTemplates returns the built-in templates followed by the stored
+// ones, each sorted by key.
+func ListTemplates() []*StoredTemplate {
+	keys := make([]string, 0, len(DefaultTemplates))
+	for key := range DefaultTemplates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make([]*StoredTemplate, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, &StoredTemplate{Key: key, Version: 1, Builtin: true, Template: DefaultTemplates[key]})
+	}
+	if s := Templates(); s != nil {
+		out = append(out, s.List()...)
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+package bank
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuiltinTemplatesValidate(t *testing.T) {
+	for key, tpl := range DefaultTemplates {
+		if err := tpl.Validate(); err != nil {
+			t.Errorf("%s: %v", key, err)
+		}
+	}
+}
+
+func TestTemplateLayout(t *testing.T) {
+	for _, tc := range []struct{ fields, want string }{
+		{`{"name": "A", "position": 0, "length": 4}, {"name": "B", "position": 3, "length": 2}`, "field B (position 3) overlaps A (positions 0-3)"},
+		{`{"name": "A", "position": 0, "length": 4}, {"name": "B", "position": 6, "length": 2}`, "positions 4-5 before field B are not covered"},
+		{`{"name": "A", "position": 2, "length": 4}`, "positions 0-1 before field A"},
+		{`{"name": "A", "position": 0, "length": 4}, {"name": "A", "position": 4, "length": 2}`, "field A is defined twice"},
+		{`{"name": "A", "position": 0, "length": 0}`, "length must be positive"},
+		{`{"name": "A", "position": 0, "length": 4, "padding": "00"}`, "not a single character"},
+		{`{"name": "A", "position": 0, "length": 4, "align": "centre"}`, `align "centre"`},
+		{`{"name": "A", "position": 0, "length": 4, "type": "money"}`, `type "money"`},
+		{`{"position": 0, "length": 4}`, "has no name"},
+	} {
+		_, err := LoadCustomTemplate([]byte(`{"name": "T", "fields": [` + tc.fields + `]}`))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want %q", tc.fields, err, tc.want)
+		}
+	}
+	// Fields may be listed out of order
+	if _, err := LoadCustomTemplate([]byte(`{"name": "T", "fields": [{"name": "B", "position": 4, "length": 2}, {"name": "A", "position": 0, "length": 4}]}`)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTemplateStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := Template{Name: "Payroll", Fields: []Field{
+		{Name: "Account", Position: 0, Length: 10},
+		{Name: "Amount", Position: 10, Length: 8, Type: "numeric", Padding: "0", Align: "right"},
+	}}
+	created, err := store.Create("payroll", tpl)
+	if err != nil || created.Version != 1 {
+		t.Fatalf("Create() = %+v, %v", created, err)
+	}
+	if _, err := store.Create("payroll", tpl); !errors.Is(err, ErrTemplateExists) {
+		t.Errorf("second Create() error = %v", err)
+	}
+	if _, err := store.Create("ACH_Payment", tpl); !errors.Is(err, ErrBuiltinTemplate) {
+		t.Errorf("Create(built-in) error = %v", err)
+	}
+	if _, err := store.Create("../payroll", tpl); err == nil {
+		t.Error("key outside the store accepted")
+	}
+	bad := tpl
+	bad.Fields = []Field{{Name: "Account", Position: 0, Length: 10}, {Name: "Amount", Position: 8, Length: 8}}
+	if _, err := store.Create("bad", bad); err == nil || !strings.Contains(err.Error(), "overlaps") {
+		t.Errorf("overlapping template: %v", err)
+	}
+
+	tpl.Description = "v2"
+	if _, err := store.Update("payroll", tpl, 2); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale Update() error = %v", err)
+	}
+	updated, err := store.Update("payroll", tpl, 1)
+	if err != nil || updated.Version != 2 {
+		t.Fatalf("Update() = %+v, %v", updated, err)
+	}
+	if old, err := store.Version("payroll", 1); err != nil || old.Description != "" {
+		t.Errorf("Version(1) = %+v, %v", old, err)
+	}
+	if got := GetTemplate("payroll"); got != nil {
+		t.Error("store consulted before SetTemplates")
+	}
+	SetTemplates(store)
+	defer SetTemplates(nil)
+	if got := GetTemplate("payroll"); got == nil || got.Description != "v2" {
+		t.Errorf("GetTemplate() = %+v", got)
+	}
+
+	// A second store on the same directory sees the files
+	reopened, err := OpenTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reopened.Get("payroll"); !ok || got.Version != 2 || got.Description != "v2" {
+		t.Errorf("reopened Get() = %+v, %v", got, ok)
+	}
+
+	if err := store.Delete("payroll", 1); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale Delete() error = %v", err)
+	}
+	if err := store.Delete("payroll", 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("payroll"); ok {
+		t.Error("deleted template still found")
+	}
+	if _, err := store.Version("payroll", 2); err != nil {
+		t.Errorf("deleted version not kept: %v", err)
+	}
+}
+
+func TestTemplateStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	reported := make(chan error, 1)
+	go store.Watch(10*time.Millisecond, stop, func(err error) {
+		select {
+		case reported <- err:
+		default:
+		}
+	})
+
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("wire.json", `{"name": "Wire", "fields": [{"name": "Ref", "position": 0, "length": 6}]}`)
+	write("broken.json", `{"name": "Broken", "fields": [{"name": "Ref", "position": 1, "length": 6}]}`)
+	select {
+	case err := <-reported:
+		if !strings.Contains(err.Error(), "broken.json") {
+			t.Errorf("reload error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("changes not picked up")
+	}
+	if got, ok := store.Get("wire"); !ok || got.Version != 1 || got.Name != "Wire" {
+		t.Errorf("Get(wire) = %+v, %v", got, ok)
+	}
+	if _, ok := store.Get("broken"); ok {
+		t.Error("invalid template loaded")
+	}
+}
@@ -2,6 +2,7 @@ package bank
 
 import (
 	"encoding/json"
+	"slices"
 )
 
 // DefaultTemplates contains built-in bank file format templates.
@@ -22,12 +23,13 @@ var DefaultTemplates = map[string]Template{
 	},
 	"ACH_Payment": {
 		Name:        "ACH Payment File",
-		Description: "Standard ACH payment format",
+		Description: "NACHA ACH file (PPD, CCD, WEB); company and bank settings come from the ACH profile",
+		Format:      FormatNACHA,
 		Fields: []Field{
 			{Name: "Record_Type", Position: 0, Length: 1, Type: "text", Padding: " ", Align: "left"},
-			{Name: "Routing_Number", Position: 1, Length: 9, Type: "text", Padding: "0", Align: "left"},
+			{Name: "Routing_Number", Position: 1, Length: 9, Type: "text", Padding: "0", Align: "left", Check: CheckABA},
 			{Name: "Account_Number", Position: 10, Length: 17, Type: "text", Padding: " ", Align: "left"},
-			{Name: "Amount", Position: 27, Length: 10, Type: "numeric", Padding: "0", Align: "right"},
+			{Name: "Amount", Position: 27, Length: 10, Type: "numeric", Padding: "0", Align: "right", Scale: cents},
 			{Name: "Name", Position: 37, Length: 22, Type: "text", Padding: " ", Align: "left"},
 			{Name: "Transaction_Code", Position: 59, Length: 2, Type: "text", Padding: "0", Align: "left"},
 			{Name: "ID_Number", Position: 61, Length: 15, Type: "text", Padding: " ", Align: "left"},
@@ -40,7 +42,7 @@ var DefaultTemplates = map[string]Template{
 			{Name: "Transaction_Type", Position: 0, Length: 3, Type: "text", Padding: " ", Align: "left"},
 			{Name: "Bank_Code", Position: 3, Length: 11, Type: "text", Padding: " ", Align: "left"},
 			{Name: "Account", Position: 14, Length: 20, Type: "text", Padding: " ", Align: "left"},
-			{Name: "Amount", Position: 34, Length: 15, Type: "numeric", Padding: "0", Align: "right"},
+			{Name: "Amount", Position: 34, Length: 15, Type: "numeric", Padding: "0", Align: "right", Scale: cents},
 			{Name: "Beneficiary_Name", Position: 49, Length: 35, Type: "text", Padding: " ", Align: "left"},
 			{Name: "Reference", Position: 84, Length: 16, Type: "text", Padding: " ", Align: "left"},
 		},
@@ -52,36 +54,77 @@ var DefaultTemplates = map[string]Template{
 			{Name: "Employee_ID", Position: 0, Length: 10, Type: "text", Padding: "0", Align: "right"},
 			{Name: "First_Name", Position: 10, Length: 15, Type: "text", Padding: " ", Align: "left"},
 			{Name: "Last_Name", Position: 25, Length: 20, Type: "text", Padding: " ", Align: "left"},
-			{Name: "Bank_Routing", Position: 45, Length: 9, Type: "text", Padding: "0", Align: "left"},
+			{Name: "Bank_Routing", Position: 45, Length: 9, Type: "text", Padding: "0", Align: "left", Check: CheckABA},
 			{Name: "Account_Number", Position: 54, Length: 17, Type: "text", Padding: " ", Align: "left"},
-			{Name: "Amount", Position: 71, Length: 12, Type: "numeric", Padding: "0", Align: "right"},
+			{Name: "Amount", Position: 71, Length: 12, Type: "numeric", Padding: "0", Align: "right", Scale: cents},
 			{Name: "Pay_Date", Position: 83, Length: 8, Type: "date", Padding: "0", Align: "left"},
 		},
 	},
 }
 
-// GetTemplate retrieves a template by key, or returns nil if not found.
+// cents is the scale of amounts written in cents.
+var cents = func() *int { n := 2; return &n }()
+
+// GetTemplate retrieves a built-in or stored template by key, or returns
+// nil if not found.
 func GetTemplate(key string) *Template {
 	if tpl, ok := DefaultTemplates[key]; ok {
-		return &tpl
+		return tpl.clone()
+	}
+	if s := Templates(); s != nil {
+		if t, ok := s.Get(key); ok {
+			return t.Template.clone()
+		}
 	}
 	return nil
 }
 
+// clone copies t's field lists, so that callers adjusting a field do not
+// change the template for everyone else.
+func (t Template) clone() *Template {
+	t.Fields = slices.Clone(t.Fields)
+	spec := func(r *RecordSpec) *RecordSpec {
+		if r == nil {
+			return nil
+		}
+		return &RecordSpec{Fields: slices.Clone(r.Fields)}
+	}
+	t.Header, t.Footer = spec(t.Header), spec(t.Footer)
+	t.Records = slices.Clone(t.Records)
+	for i, l := range t.Records {
+		t.Records[i].Fields = slices.Clone(l.Fields)
+		if l.When != nil {
+			w := *l.When
+			w.In = slices.Clone(w.In)
+			t.Records[i].When = &w
+		}
+	}
+	if t.Batch != nil {
+		b := *t.Batch
+		b.GroupBy = slices.Clone(b.GroupBy)
+		b.Header, b.Footer = spec(b.Header), spec(b.Footer)
+		t.Batch = &b
+	}
+	return &t
+}
+
 // GetTemplateList returns a list of all available template keys and names.
 func GetTemplateList() map[string]string {
 	list := make(map[string]string)
-	for key, tpl := range DefaultTemplates {
-		list[key] = tpl.Name
+	for _, t := range ListTemplates() {
+		list[t.Key] = t.Name
 	}
 	return list
 }
 
-// LoadCustomTemplate parses a JSON template definition.
+// LoadCustomTemplate parses and validates a JSON template definition.
 func LoadCustomTemplate(jsonData []byte) (*Template, error) {
 	var tpl Template
 	if err := json.Unmarshal(jsonData, &tpl); err != nil {
 		return nil, err
 	}
+	if err := tpl.Validate(); err != nil {
+		return nil, err
+	}
 	return &tpl, nil
 }
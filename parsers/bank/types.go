@@ -1,13 +1,41 @@
 // Package bank implements a CSV to fixed-width bank file format parser.
 package bank
 
+import "time"
+
 // Template defines the structure for converting CSV to fixed-width format.
 type Template struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Fields      []Field `json:"fields"`
+
+	// Records are further detail layouts, each used for the input rows
+	// its Key (matched against the Discriminator column) or When rule
+	// selects, instead of Fields or as extra records before or after the
+	// row's detail record; see multirecord.go.
+	Discriminator string         `json:"discriminator,omitempty"`
+	Records       []RecordLayout `json:"records,omitempty"`
+
+	// Header and Footer are written before and after the records, and
+	// Batch wraps groups of records in batch headers and trailers. Their
+	// fields get their content from Value expressions. The Format
+	// generators write their own and ignore these.
+	Header *RecordSpec `json:"header,omitempty"`
+	Footer *RecordSpec `json:"footer,omitempty"`
+	Batch  *BatchSpec  `json:"batch,omitempty"`
+
+	// Format selects a built-in generator for the fixed-width output
+	// instead of one line per record: "nacha" writes a NACHA ACH file.
+	// Fields still describe the input columns and the CSV/Excel output.
+	Format string `json:"format,omitempty"`
 }
 
+// Output formats selectable with Template.Format.
+const (
+	FormatFixedWidth = ""      // one fixed-width line per record
+	FormatNACHA      = "nacha" // NACHA ACH file, see nacha.go
+)
+
 // Field defines a single field in the fixed-width format.
 type Field struct {
 	Name        string `json:"name"`
@@ -17,12 +45,71 @@ type Field struct {
 	Padding     string `json:"padding"`     // Character to pad with
 	Align       string `json:"align"`       // "left" or "right"
 	Description string `json:"description"` // Optional field description
+
+	// Value, if set, replaces the column of the same name: constant text
+	// in which {count}, {details}, {sum(Column)}, {first(Column)}, {seq},
+	// {batches}, {lines} and {now:YYMMDD} are replaced (see records.go).
+	Value string `json:"value,omitempty"`
+
+	// Source, if set, names the column to read instead of Name, so that
+	// several fields can take parts of one column.
+	Source string `json:"source,omitempty"`
+
+	// Numeric fields: Scale is the number of implied decimals written
+	// (unset: as many as were typed), Rounding how extra decimals are
+	// dropped ("half-up", "half-even", "down", "up", "none"), Sign how a
+	// negative value is written ("leading", "trailing", "overpunch",
+	// "none"), Decimal the input's decimal separator ("." or "," for
+	// 1.234,56) and Part writes only the "whole" units or the "fraction"
+	// digits of the amount. See decimal.go.
+	Scale    *int   `json:"scale,omitempty"`
+	Rounding string `json:"rounding,omitempty"`
+	Sign     string `json:"sign,omitempty"`
+	Decimal  string `json:"decimal,omitempty"`
+	Part     string `json:"part,omitempty"`
+
+	// Date fields: DateInput is the input layout ("iso", "us", "eu",
+	// "excel" or a pattern such as "DD.MM.YYYY"; unset: detected from the
+	// value) and DateFormat the output layout (default "YYYYMMDD"; also
+	// "YYMMDD", "YYDDD", "CYYDDD"). See dates.go.
+	DateInput  string `json:"dateInput,omitempty"`
+	DateFormat string `json:"dateFormat,omitempty"`
+
+	// Validation rules, checked before any output is written (see
+	// validate.go). Pattern is a regular expression the whole value must
+	// match, Allowed lists the accepted values (ignoring case), Check names
+	// a checksum ("aba") and Min/Max bound a numeric value. A value longer
+	// than Length is always an error rather than truncated.
+	Required  bool     `json:"required,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	MinLength int      `json:"minLength,omitempty"`
+	MaxLength int      `json:"maxLength,omitempty"`
+	Allowed   []string `json:"allowed,omitempty"`
+	Check     string   `json:"check,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
 }
 
 // BankFile represents a parsed bank file with its template and records.
 type BankFile struct {
 	Template Template
 	Records  []Record
+
+	// TemplateKey is the key the template was looked up by. The decoders
+	// that take a key set it; it is empty when a Template was passed in.
+	TemplateKey string
+
+	// Profile supplies the originator settings for NACHA output; nil
+	// means the profile installed with SetACHProfile.
+	Profile *ACHProfile
+
+	// Created is the file creation time written to headers; zero means
+	// the time of formatting.
+	Created time.Time
+
+	// parsed holds every record of a file read with DecodeFixedWidth, in
+	// file order, for output instead of the layout rows works out.
+	parsed []outputRow
 }
 
 // Record represents a single row of data mapped from CSV.
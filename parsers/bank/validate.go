@@ -0,0 +1,324 @@
+// validate.go checks records against the rules declared on their
+// template's fields and reports every failure by row and column, so a
+// bad file is refused before it reaches the bank.
+
+package bank
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Checks selectable with Field.Check.
+const (
+	CheckABA = "aba" // 9-digit ABA routing number with a valid check digit
+)
+
+// FieldError is one value that breaks its field's rules.
+type FieldError struct {
+	Row     int    `json:"row,omitempty"`    // input row (the column header row is 1); 0 outside detail records
+	Record  string `json:"record,omitempty"` // "header", "batch 1 trailer" and so on, or the layout of a detail record
+	Column  string `json:"column"`
+	Value   string `json:"value"`
+	Rule    string `json:"rule"` // required, allowed, pattern, minLength, maxLength, check, numeric, date, min, max, length
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	where := e.Record
+	if e.Row > 0 {
+		where = fmt.Sprintf("row %d", e.Row)
+		if e.Record != "" {
+			where += " (" + e.Record + " record)"
+		}
+	}
+	if e.Column == "" {
+		return where + ": " + e.Message
+	}
+	return fmt.Sprintf("%s, %s: %s", where, e.Column, e.Message)
+}
+
+// ValidationError is returned by the Format methods when records break
+// their fields' rules; Errors lists every failure.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// maxListed bounds how many failures Error spells out.
+const maxListed = 10
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	if len(e.Errors) == 1 {
+		return "validation failed: " + e.Errors[0].Error()
+	}
+	fmt.Fprintf(&b, "validation failed with %d errors:", len(e.Errors))
+	for i, fe := range e.Errors {
+		if i == maxListed {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(e.Errors)-maxListed)
+			break
+		}
+		b.WriteString("\n  " + fe.Error())
+	}
+	return b.String()
+}
+
+// Validate checks every record against its template's field rules and
+// every value, in header, trailer and batch records too, against its
+// field length. It returns nil if all pass.
+func (bf *BankFile) Validate() []FieldError {
+	_, err := bf.checkedRows(true)
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return verr.Errors
+	}
+	if err != nil {
+		return []FieldError{{Record: "file", Rule: "value", Message: err.Error()}}
+	}
+	return nil
+}
+
+// checkedRows lays out the file and fails with a *ValidationError if any
+// value breaks its field's rules. lengths reports values that the output
+// would truncate; generators with their own layout leave it off.
+//
+// If the layout itself fails, typically on an aggregate over a bad value,
+// the records are checked as read so the error names the row at fault.
+func (bf *BankFile) checkedRows(lengths bool) ([]outputRow, error) {
+	rows, err := bf.rows()
+	if err != nil {
+		if errs := validateRows(bf.inputRows(), lengths); len(errs) > 0 {
+			return nil, &ValidationError{Errors: errs}
+		}
+		return nil, err
+	}
+	if errs := validateRows(rows, lengths); len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return rows, nil
+}
+
+// inputRows returns the detail records as read, with only the fields
+// that come from the input.
+func (bf *BankFile) inputRows() []outputRow {
+	var fields []Field
+	for _, f := range bf.Template.inputFields() {
+		if f.Value == "" {
+			fields = append(fields, f)
+		}
+	}
+	out := make([]outputRow, len(bf.Records))
+	for i, r := range bf.Records {
+		out[i] = outputRow{fields: fields, record: r, detail: true, row: i + 2}
+	}
+	return out
+}
+
+func validateRows(rows []outputRow, lengths bool) []FieldError {
+	var errs []FieldError
+	for _, r := range rows {
+		for _, f := range r.fields {
+			value := fieldValue(r.record, f)
+			rule, msg := checkValue(value, f, lengths)
+			if rule == "" {
+				continue
+			}
+			errs = append(errs, FieldError{Row: r.row, Record: r.name, Column: f.Name, Value: value, Rule: rule, Message: msg})
+		}
+	}
+	return errs
+}
+
+// checkValue applies f's rules, and its length if lengths is set, to
+// value and returns the first rule it breaks with a message, or "" if it
+// passes.
+func checkValue(value string, f Field, lengths bool) (rule, msg string) {
+	v := strings.TrimSpace(value)
+	if v == "" {
+		if f.Required {
+			return "required", "a value is required"
+		}
+	} else {
+		if len(f.Allowed) > 0 && !containsFold(f.Allowed, v) {
+			return "allowed", fmt.Sprintf("%q is not one of %s", v, strings.Join(f.Allowed, ", "))
+		}
+		if f.Pattern != "" {
+			re, err := compilePattern(f.Pattern)
+			if err != nil {
+				return "pattern", err.Error()
+			}
+			if !re.MatchString(v) {
+				return "pattern", fmt.Sprintf("%q does not match %s", v, f.Pattern)
+			}
+		}
+		if f.MinLength > 0 && len(v) < f.MinLength {
+			return "minLength", fmt.Sprintf("%q is shorter than %d characters", v, f.MinLength)
+		}
+		if f.MaxLength > 0 && len(v) > f.MaxLength {
+			return "maxLength", fmt.Sprintf("%q is longer than %d characters", v, f.MaxLength)
+		}
+		switch f.Check {
+		case CheckABA:
+			if err := checkRoutingNumber(v); err != nil {
+				return "check", err.Error()
+			}
+		}
+		if f.Min != nil || f.Max != nil {
+			d, err := parseDecimal(v, f.Decimal, f.Sign)
+			if err != nil {
+				return "numeric", err.Error()
+			}
+			if n := d.float(); f.Min != nil && n < *f.Min {
+				return "min", fmt.Sprintf("%s is less than %g", v, *f.Min)
+			} else if f.Max != nil && n > *f.Max {
+				return "max", fmt.Sprintf("%s is more than %g", v, *f.Max)
+			}
+		}
+	}
+	text, err := fieldText(value, f)
+	if err != nil {
+		return f.Type, err.Error() // "numeric" or "date"
+	}
+	if lengths && len(text) > f.Length {
+		return "length", fmt.Sprintf("%q is %d characters and would be truncated to %d", text, len(text), f.Length)
+	}
+	return "", ""
+}
+
+func containsFold(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(strings.TrimSpace(s), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// patterns caches compiled Field.Pattern expressions.
+var patterns sync.Map
+
+// compilePattern compiles a field pattern anchored to the whole value.
+func compilePattern(p string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(p); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(`^(?:` + p + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+	}
+	patterns.Store(p, re)
+	return re, nil
+}
+
+// checkFieldRules reports rules that can never work: patterns that do
+// not compile, unknown checks, numeric modes and date layouts, and
+// inverted ranges.
+func checkFieldRules(f Field) error {
+	if f.Pattern != "" {
+		if _, err := compilePattern(f.Pattern); err != nil {
+			return err
+		}
+	}
+	switch f.Check {
+	case "", CheckABA:
+	default:
+		return fmt.Errorf("unknown check %q", f.Check)
+	}
+	if f.MaxLength > 0 && f.MinLength > f.MaxLength {
+		return fmt.Errorf("minLength %d exceeds maxLength %d", f.MinLength, f.MaxLength)
+	}
+	if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+		return fmt.Errorf("min %g exceeds max %g", *f.Min, *f.Max)
+	}
+	if err := checkNumericRules(f); err != nil {
+		return err
+	}
+	return checkDateRules(f)
+}
+
+// ErrorWorkbook returns the input records as an Excel workbook annotated
+// with errs: each failing cell is filled red, an Errors column explains
+// the row's failures, and an Errors sheet lists them all. Rows keep their
+// input numbering.
+func (bf *BankFile) ErrorWorkbook(errs []FieldError) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+	const sheet = "Sheet1"
+
+	fields := bf.Template.inputFields()
+	bold, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	bad, _ := f.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}},
+		Font: &excelize.Font{Color: "9C0006"},
+	})
+	for i, field := range fields {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheet, cell, field.Name)
+	}
+	errCol := len(fields) + 1
+	cell, _ := excelize.CoordinatesToCellName(errCol, 1)
+	f.SetCellValue(sheet, cell, "Errors")
+	last, _ := excelize.CoordinatesToCellName(errCol, 1)
+	f.SetCellStyle(sheet, "A1", last, bold)
+
+	for i, record := range bf.Records {
+		for j, field := range fields {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+2)
+			f.SetCellValue(sheet, cell, fieldValue(record, field))
+		}
+	}
+
+	column := make(map[string]int, len(fields))
+	for i, field := range fields {
+		column[field.Name] = i + 1
+	}
+	messages := make(map[int][]string)
+	for _, e := range errs {
+		if e.Row < 2 {
+			continue
+		}
+		messages[e.Row] = append(messages[e.Row], e.Column+": "+e.Message)
+		if c, ok := column[e.Column]; ok {
+			cell, _ := excelize.CoordinatesToCellName(c, e.Row)
+			f.SetCellStyle(sheet, cell, cell, bad)
+		}
+	}
+	for row, msgs := range messages {
+		cell, _ := excelize.CoordinatesToCellName(errCol, row)
+		f.SetCellValue(sheet, cell, strings.Join(msgs, "; "))
+		f.SetCellStyle(sheet, cell, cell, bad)
+	}
+	colName, _ := excelize.ColumnNumberToName(errCol)
+	f.SetColWidth(sheet, colName, colName, 60)
+
+	const list = "Errors"
+	f.NewSheet(list)
+	for i, h := range []string{"Row", "Record", "Column", "Value", "Rule", "Message"} {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(list, cell, h)
+	}
+	f.SetCellStyle(list, "A1", "F1", bold)
+	for i, e := range errs {
+		row := []any{e.Row, e.Record, e.Column, e.Value, e.Rule, e.Message}
+		if e.Row == 0 {
+			row[0] = ""
+		}
+		for j, v := range row {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+2)
+			f.SetCellValue(list, cell, v)
+		}
+	}
+	f.SetColWidth(list, "F", "F", 60)
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write Excel file: %w", err)
+	}
+	return buf.Bytes(), nil
+}
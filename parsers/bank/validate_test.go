@@ -0,0 +1,120 @@
+package bank
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const rulesTemplate = `{
+  "name": "Checked payments",
+  "fields": [
+    {"name": "Routing", "position": 0, "length": 9, "check": "aba", "required": true},
+    {"name": "Account", "position": 9, "length": 8, "pattern": "[0-9]+", "minLength": 4},
+    {"name": "Kind", "position": 17, "length": 6, "allowed": ["credit", "debit"]},
+    {"name": "Amount", "position": 23, "length": 6, "type": "numeric", "scale": 2, "padding": "0", "align": "right", "min": 0.01, "max": 5000}
+  ],
+  "footer": {"fields": [
+    {"name": "Total", "position": 0, "length": 4, "type": "numeric", "padding": "0", "align": "right", "value": "{sum(Amount)}"}
+  ]}
+}`
+
+func TestValidate(t *testing.T) {
+	tpl, err := LoadCustomTemplate([]byte(rulesTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	csv := "Routing,Account,Kind,Amount\n" +
+		"021000021,12345678,Credit,10.00\n" +
+		",12a4,refund,0\n" +
+		"021000022,123,debit,6000.00\n" +
+		"021000021,123456789,debit,abc\n"
+	bf, err := DecodeWithTemplate([]byte(csv), *tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf.Records[3]["Amount"] = "1.00"
+
+	var got []string
+	for _, e := range bf.Validate() {
+		got = append(got, e.Error()+" ["+e.Rule+"]")
+	}
+	want := []string{
+		"row 3, Routing: a value is required [required]",
+		"row 3, Account: \"12a4\" does not match [0-9]+ [pattern]",
+		"row 3, Kind: \"refund\" is not one of credit, debit [allowed]",
+		"row 3, Amount: 0 is less than 0.01 [min]",
+		"row 4, Routing: routing number 021000022 has an invalid check digit [check]",
+		"row 4, Account: \"123\" is shorter than 4 characters [minLength]",
+		"row 4, Amount: 6000.00 is more than 5000 [max]",
+		"row 5, Account: \"123456789\" is 9 characters and would be truncated to 8 [length]",
+		"trailer, Total: \"601100\" is 6 characters and would be truncated to 4 [length]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A bad value that breaks an aggregate is reported at its row.
+	bf.Records = append(bf.Records, Record{"Routing": "021000021", "Account": "1234", "Amount": "abc"})
+	if errs := bf.Validate(); len(errs) == 0 || errs[len(errs)-1].Error() != `row 6, Amount: "abc" is not a number` {
+		t.Errorf("Validate() = %v", errs)
+	}
+}
+
+func TestFormatRefusesInvalid(t *testing.T) {
+	bf, err := DecodeWithTemplate([]byte("Employee_ID,Bank_Routing,First_Name\n1,021000021,A name far longer than fifteen\n2,123456789,B\n"), *GetTemplate("Direct_Deposit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, format := range map[string]func() ([]byte, error){
+		"Format": bf.Format, "FormatAsCSV": bf.FormatAsCSV, "FormatAsExcel": bf.FormatAsExcel,
+	} {
+		out, err := format()
+		var verr *ValidationError
+		if !errors.As(err, &verr) || out != nil {
+			t.Errorf("%s: error = %v", name, err)
+			continue
+		}
+		if len(verr.Errors) != 2 || verr.Errors[0].Row != 2 || verr.Errors[0].Column != "First_Name" ||
+			verr.Errors[1].Row != 3 || verr.Errors[1].Rule != "check" {
+			t.Errorf("%s: errors = %+v", name, verr.Errors)
+		}
+	}
+
+	data, err := bf.ErrorWorkbook(bf.Validate())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, _ := f.GetRows("Sheet1")
+	if len(rows) != 3 || len(rows[0]) != 8 || rows[0][7] != "Errors" || !strings.HasPrefix(rows[2][7], "Bank_Routing: ") {
+		t.Errorf("annotated rows = %q", rows)
+	}
+	if style, _ := f.GetCellStyle("Sheet1", "D3"); style == 0 {
+		t.Error("failing cell D3 is not highlighted")
+	}
+	list, _ := f.GetRows("Errors")
+	if len(list) != 3 || list[1][0] != "2" || list[2][4] != "check" {
+		t.Errorf("error list = %q", list)
+	}
+}
+
+func TestTemplateValidateRules(t *testing.T) {
+	for _, f := range []string{
+		`{"name": "X", "length": 5, "pattern": "[0-9"}`,
+		`{"name": "X", "length": 5, "check": "luhn"}`,
+		`{"name": "X", "length": 5, "minLength": 4, "maxLength": 2}`,
+		`{"name": "X", "length": 5, "min": 10, "max": 1}`,
+	} {
+		if _, err := LoadCustomTemplate([]byte(`{"fields": [` + f + `]}`)); err == nil {
+			t.Errorf("%s accepted", f)
+		}
+	}
+}
@@ -94,7 +94,7 @@ func (c *documentConverter) Convert(req ConversionRequest) (*ConversionResult, e
 
 	// Execute Pandoc with data piped through stdin/stdout
 	pandocPath, _ := findBinary("pandoc")
-	cmd := exec.Command(pandocPath, args...)
+	cmd := exec.CommandContext(req.context(), pandocPath, args...)
 	cmd.Stdin = bytes.NewReader(req.Data)
 
 	var stdout, stderr bytes.Buffer
@@ -92,7 +92,7 @@ func (c *ffmpegConverter) Convert(req ConversionRequest) (*ConversionResult, err
 
 	// Execute FFmpeg with data piped through stdin/stdout (no temp files)
 	ffmpegPath, _ := findBinary("ffmpeg")
-	cmd := exec.Command(ffmpegPath, args...)
+	cmd := exec.CommandContext(req.context(), ffmpegPath, args...)
 	cmd.Stdin = bytes.NewReader(req.Data)
 
 	var stdout, stderr bytes.Buffer
@@ -104,7 +104,7 @@ func (c *imagemagickConverter) Convert(req ConversionRequest) (*ConversionResult
 
 	// Execute ImageMagick with data piped through stdin/stdout (no temp files)
 	magickPath, _ := findBinary("magick")
-	cmd := exec.Command(magickPath, args...)
+	cmd := exec.CommandContext(req.context(), magickPath, args...)
 	cmd.Stdin = bytes.NewReader(req.Data)
 
 	var stdout, stderr bytes.Buffer
@@ -4,6 +4,7 @@ package fileconvert
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"fmt"
 	"io"
 	"os/exec"
@@ -48,7 +49,7 @@ func (c *pdfConverter) Convert(req ConversionRequest) (*ConversionResult, error)
 	to := normalizeExt(req.ToFormat)
 
 	// Try pdftotext first (if available via system PATH or bundled)
-	if text, err := extractWithPdftotext(req.Data); err == nil && len(strings.TrimSpace(string(text))) > 0 {
+	if text, err := extractWithPdftotext(req.context(), req.Data); err == nil && len(strings.TrimSpace(string(text))) > 0 {
 		output := formatPdfText(text, to)
 		return &ConversionResult{
 			Data:     output,
@@ -57,6 +58,9 @@ func (c *pdfConverter) Convert(req ConversionRequest) (*ConversionResult, error)
 	}
 
 	// Fallback: extract text streams from PDF manually
+	if err := req.context().Err(); err != nil {
+		return nil, err
+	}
 	text, err := extractPdfTextStreams(req.Data)
 	if err != nil || len(strings.TrimSpace(string(text))) == 0 {
 		return nil, fmt.Errorf("could not extract text from PDF — the PDF may contain only scanned images")
@@ -70,13 +74,13 @@ func (c *pdfConverter) Convert(req ConversionRequest) (*ConversionResult, error)
 }
 
 // extractWithPdftotext tries to use the pdftotext command (from poppler or xpdf).
-func extractWithPdftotext(data []byte) ([]byte, error) {
+func extractWithPdftotext(ctx context.Context, data []byte) ([]byte, error) {
 	path, found := findBinary("pdftotext")
 	if !found {
 		return nil, fmt.Errorf("pdftotext not found")
 	}
 
-	cmd := exec.Command(path, "-", "-") // stdin → stdout
+	cmd := exec.CommandContext(ctx, path, "-", "-") // stdin → stdout
 	cmd.Stdin = bytes.NewReader(data)
 
 	var stdout, stderr bytes.Buffer
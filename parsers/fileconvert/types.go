@@ -3,6 +3,7 @@
 package fileconvert
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
@@ -32,6 +33,18 @@ type ConversionRequest struct {
 	FromFormat string // Source extension (e.g., ".png")
 	ToFormat   string // Target extension (e.g., ".jpg")
 	Quality    int    // Quality setting (1-100), 0 means default
+
+	// Context, if set, cancels the conversion; external tools are killed
+	// when it is done.
+	Context context.Context
+}
+
+// context returns the request context, defaulting to Background.
+func (r ConversionRequest) context() context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+	return r.Context
 }
 
 // ConversionResult contains the converted file data.
@@ -58,6 +71,20 @@ type Converter interface {
 // Registry holds all registered converters.
 var registry []Converter
 
+// filter, if set, hides conversions from GetConverter and GetAllFormats.
+var filter func(c Converter, from, to string) bool
+
+// SetFilter installs a function that decides which conversions are
+// offered, such as a deployment policy. nil offers everything.
+func SetFilter(f func(c Converter, from, to string) bool) {
+	filter = f
+}
+
+// allowed reports whether the filter admits c converting from → to.
+func allowed(c Converter, from, to string) bool {
+	return filter == nil || filter(c, from, to)
+}
+
 // Register adds a converter to the global registry.
 func Register(c Converter) {
 	registry = append(registry, c)
@@ -69,20 +96,34 @@ func GetConverter(from, to string) Converter {
 	to = normalizeExt(to)
 
 	for _, c := range registry {
-		if c.CanConvert(from, to) {
+		if c.CanConvert(from, to) && allowed(c, from, to) {
 			return c
 		}
 	}
 	return nil
 }
 
-// GetAllFormats returns all supported formats grouped by category.
+// GetAllFormats returns all supported formats grouped by category. With a
+// filter set, each format lists only the targets the filter allows, and
+// formats left without targets are omitted.
 func GetAllFormats() map[FormatCategory][]Format {
 	result := make(map[FormatCategory][]Format)
 	seen := make(map[string]bool)
 
 	for _, c := range registry {
 		for _, f := range c.SupportedFormats() {
+			if filter != nil {
+				var targets []string
+				for _, to := range f.CanConvert {
+					if allowed(c, f.Extension, to) {
+						targets = append(targets, to)
+					}
+				}
+				if len(targets) == 0 {
+					continue
+				}
+				f.CanConvert = targets
+			}
 			key := string(f.Category) + f.Extension
 			if !seen[key] {
 				result[f.Category] = append(result[f.Category], f)
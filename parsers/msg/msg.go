@@ -0,0 +1,323 @@
+// Package msg decodes Outlook .msg files (MS-OXMSG): compound files whose
+// storages hold a message's MAPI properties, recipients, attachments, and
+// embedded messages. Messages are decoded into the same model as TNEF
+// streams, so the two formats can be compared with tnef.Compare.
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/lgican/File-Converter/parsers/tnef"
+	"github.com/richardlehane/mscfb"
+)
+
+// ErrNotMsg is returned when the input is not a compound file.
+var ErrNotMsg = errors.New("not an Outlook .msg file")
+
+// ErrMalformed is returned when the compound file structure is damaged.
+var ErrMalformed = errors.New("malformed .msg file")
+
+// cfbSignature starts every compound file.
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Storage and stream names (MS-OXMSG 2.2).
+const (
+	propsStream   = "__properties_version1.0"
+	attachPrefix  = "__attach_version1.0_#"
+	nameIDStorage = "__nameid_version1.0"
+	embeddedMsg   = "__substg1.0_3701000D"
+)
+
+// Property stream header sizes before the 16-byte entries (MS-OXMSG 2.4).
+const (
+	topHeader      = 32
+	embeddedHeader = 24
+	attachHeader   = 8
+)
+
+// Well-known property sets referenced by index in the entry stream.
+var (
+	psMAPI          = tnef.GUID{0x28, 0x03, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+	psPublicStrings = tnef.GUID{0x29, 0x03, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+)
+
+// IsMsg reports whether data starts with the compound file signature.
+// Other compound files (legacy Office documents) share it; Decode tells
+// them apart by the missing property stream.
+func IsMsg(data []byte) bool {
+	return bytes.HasPrefix(data, cfbSignature)
+}
+
+// Decode parses a .msg file. Properties are stored the way the TNEF
+// decoder stores them: strings keep their terminating NUL and
+// multi-valued properties hold their values concatenated.
+func Decode(data []byte) (*tnef.Message, error) {
+	if !IsMsg(data) {
+		return nil, ErrNotMsg
+	}
+	r, err := mscfb.New(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	f, err := readFile(r, len(data))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.streams[propsStream]; !ok {
+		return nil, ErrNotMsg
+	}
+	f.parseNames()
+	return f.message("", topHeader), nil
+}
+
+// file is a compound file read into memory, keyed by slash-separated path.
+type file struct {
+	streams  map[string][]byte
+	storages map[string][]string // storage path ("" for the root) -> child storage names
+	named    map[int]*tnef.NamedID
+}
+
+// readFile loads every stream of r. Streams cannot overlap, so their total
+// size is bounded by the file size; crafted chains that revisit sectors
+// are rejected once they pass it.
+func readFile(r *mscfb.Reader, size int) (*file, error) {
+	f := &file{streams: make(map[string][]byte), storages: map[string][]string{"": nil}}
+	// mscfb's Path slices can alias between sibling storages, so paths are
+	// rebuilt from the traversal order instead: every storage is followed
+	// directly by its descendants.
+	var stack []string
+	total := 0
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		depth := min(len(e.Path), len(stack))
+		stack = stack[:depth]
+		path := strings.Join(append(stack[:depth:depth], e.Name), "/")
+		if e.FileInfo().IsDir() {
+			parent := strings.Join(stack, "/")
+			f.storages[parent] = append(f.storages[parent], e.Name)
+			f.storages[path] = nil
+			stack = append(stack, e.Name)
+			continue
+		}
+		if e.Size < 0 || e.Size > int64(size-total) {
+			return nil, fmt.Errorf("%w: stream %s is larger than the file", ErrMalformed, path)
+		}
+		b := make([]byte, e.Size)
+		if _, err := io.ReadFull(e, b); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrMalformed, path, err)
+		}
+		total += len(b)
+		f.streams[path] = b
+	}
+	return f, nil
+}
+
+// parseNames reads the named property mapping (MS-OXMSG 2.2.3): property
+// IDs from 0x8000 up are assigned by index in the entry stream.
+func (f *file) parseNames() {
+	f.named = make(map[int]*tnef.NamedID)
+	guids := f.streams[nameIDStorage+"/__substg1.0_00020102"]
+	entries := f.streams[nameIDStorage+"/__substg1.0_00030102"]
+	names := f.streams[nameIDStorage+"/__substg1.0_00040102"]
+	for off := 0; off+8 <= len(entries); off += 8 {
+		id := binary.LittleEndian.Uint32(entries[off:])
+		info := binary.LittleEndian.Uint32(entries[off+4:])
+		n := &tnef.NamedID{Kind: int(info & 1)}
+		switch gi := int(info>>1) & 0x7FFF; gi {
+		case 1:
+			n.GUID = psMAPI
+		case 2:
+			n.GUID = psPublicStrings
+		default:
+			if gi < 3 || (gi-3)*16+16 > len(guids) {
+				continue
+			}
+			copy(n.GUID[:], guids[(gi-3)*16:])
+		}
+		if n.Kind == 0 {
+			n.LID = id
+		} else {
+			o := int(id)
+			if o < 0 || o+4 > len(names) {
+				continue
+			}
+			l := int(binary.LittleEndian.Uint32(names[o:]))
+			if l < 0 || l > len(names)-o-4 {
+				continue
+			}
+			n.Name = decodeUTF16(names[o+4 : o+4+l])
+		}
+		f.named[0x8000+int(info>>16)] = n
+	}
+}
+
+// message decodes the message whose storage is prefix ("" for the root).
+func (f *file) message(prefix string, header int) *tnef.Message {
+	m := &tnef.Message{Attributes: f.props(prefix, header)}
+	for _, a := range m.Attributes {
+		switch a.Name {
+		case tnef.MAPIBody:
+			m.Body = a.Data
+		case tnef.MAPIBodyHTML:
+			m.BodyHTML = a.Data
+		case tnef.MAPIRtfCompressed:
+			if rtf, err := tnef.DecompressRTF(a.Data); err == nil {
+				m.BodyRTF = rtf
+				m.BodyRTFHTML = tnef.DeencapsulateHTML(rtf)
+			}
+		}
+	}
+	for _, name := range f.children(prefix, attachPrefix) {
+		m.Attachments = append(m.Attachments, f.attachment(join(prefix, name)))
+	}
+	return m
+}
+
+// attachment decodes the attachment whose storage is prefix. As in TNEF,
+// where the content travels in its own attribute, binary attachment data
+// is kept in Data rather than among the properties.
+func (f *file) attachment(prefix string) *tnef.Attachment {
+	att := &tnef.Attachment{}
+	for _, a := range f.props(prefix, attachHeader) {
+		if a.Name == tnef.MAPIAttachDataObj && a.Type == tnef.PtBinary {
+			att.Data = a.Data
+			continue
+		}
+		att.Attributes = append(att.Attributes, a)
+		switch a.Name {
+		case tnef.MAPIAttachFilename:
+			att.Title = cleanStr(a)
+		case tnef.MAPIAttachLongFname:
+			att.LongName = cleanStr(a)
+		case tnef.MAPIAttachMimeTag:
+			att.MimeType = cleanStr(a)
+		case tnef.MAPIAttachContentID:
+			att.ContentID = cleanStr(a)
+		case tnef.MAPILastModified:
+			if len(a.Data) >= 8 {
+				att.ModTime = tnef.FiletimeToTime(binary.LittleEndian.Uint64(a.Data))
+			}
+		case tnef.MAPIAttachMethod:
+			if len(a.Data) >= 4 {
+				att.Method = int(binary.LittleEndian.Uint32(a.Data))
+			}
+		}
+	}
+	if _, ok := f.storages[join(prefix, embeddedMsg)]; ok && att.Method == tnef.AttachEmbeddedMsg {
+		att.EmbeddedMsg = f.message(join(prefix, embeddedMsg), embeddedHeader)
+	}
+	return att
+}
+
+// children returns the names of the storages directly under prefix that
+// start with kind, in name order.
+func (f *file) children(prefix, kind string) []string {
+	var out []string
+	for _, name := range f.storages[prefix] {
+		if strings.HasPrefix(name, kind) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// join returns the path of name inside the storage at prefix.
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+// props decodes the property stream of the storage at prefix, skipping
+// its header. Fixed-size values are stored in the 16-byte entries; other
+// values live in __substg1.0_ streams named by the property tag.
+func (f *file) props(prefix string, header int) []tnef.MAPIAttr {
+	stream := f.streams[join(prefix, propsStream)]
+	var attrs []tnef.MAPIAttr
+	for off := header; off+16 <= len(stream); off += 16 {
+		tag := binary.LittleEndian.Uint32(stream[off:])
+		pt, mv := int(tag&0xFFFF)&^0x1000, tag&0x1000 != 0
+		a := tnef.MAPIAttr{Type: pt, Name: int(tag >> 16), Named: f.named[int(tag>>16)]}
+		value := stream[off+8 : off+16]
+		sub := join(prefix, fmt.Sprintf("__substg1.0_%08X", tag))
+		switch {
+		case mv && fixedSize(pt) > 0:
+			a.Data = f.streams[sub]
+		case mv:
+			a.Data = f.multiValues(sub, pt)
+		case pt == tnef.PtObject:
+			// An embedded message or OLE storage, decoded separately.
+		case fixedSize(pt) > 0:
+			a.Data = append([]byte(nil), value[:fixedSize(pt)]...)
+		default:
+			a.Data = f.streams[sub]
+			switch pt {
+			case tnef.PtUnicode:
+				a.Data = append(a.Data[:len(a.Data):len(a.Data)], 0, 0)
+			case tnef.PtString8:
+				a.Data = append(a.Data[:len(a.Data):len(a.Data)], 0)
+			}
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+// multiValues concatenates the values of a variable-length multi-valued
+// property, stored one stream per value after a stream of lengths.
+func (f *file) multiValues(sub string, pt int) []byte {
+	lengths := f.streams[sub]
+	step := 4
+	if pt == tnef.PtBinary {
+		step = 8
+	}
+	var b []byte
+	for i := 0; i*step+step <= len(lengths); i++ {
+		b = append(b, f.streams[fmt.Sprintf("%s-%08X", sub, i)]...)
+	}
+	return b
+}
+
+// fixedSize returns the bytes a fixed-size type takes in TNEF form (short
+// and boolean values are widened to four bytes), or 0 for variable-length
+// types.
+func fixedSize(pt int) int {
+	switch pt {
+	case tnef.PtShort, tnef.PtLong, tnef.PtFloat, tnef.PtError, tnef.PtBoolean:
+		return 4
+	case tnef.PtDouble, tnef.PtCurrency, tnef.PtAppTime, tnef.PtI8, tnef.PtSysTime:
+		return 8
+	}
+	return 0
+}
+
+// cleanStr returns a string property without its terminator and
+// surrounding whitespace.
+func cleanStr(a tnef.MAPIAttr) string {
+	s, _ := tnef.DecodeValue(a.Type, a.Data).(string)
+	return strings.TrimSpace(s)
+}
+
+// decodeUTF16 converts little-endian UTF-16 to a string.
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,290 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/lgican/File-Converter/parsers/tnef"
+)
+
+// cfbEntry is one storage or stream of a compound file built by buildCFB.
+// Streams must be under the 4096-byte mini stream cutoff.
+type cfbEntry struct {
+	path string // slash-separated, without the root
+	data []byte // nil for a storage
+}
+
+// buildCFB writes a version 3 compound file holding entries. Every stream
+// lives in the mini stream, and siblings are chained through their right
+// pointers rather than balanced.
+func buildCFB(entries []cfbEntry) []byte {
+	const (
+		endOfChain = 0xFFFFFFFE
+		fatSect    = 0xFFFFFFFD
+		noStream   = 0xFFFFFFFF
+	)
+	type node struct {
+		name                      string
+		kind                      byte
+		left, right, child, start uint32
+		size                      int
+	}
+	nodes := []node{{name: "Root Entry", kind: 5, left: noStream, right: noStream, child: noStream}}
+	index := map[string]int{"": 0}
+	last := map[int]int{} // parent -> last child
+	var mini []byte
+	var miniFAT []uint32
+	for _, e := range entries {
+		parent, name := "", e.path
+		if i := strings.LastIndex(e.path, "/"); i >= 0 {
+			parent, name = e.path[:i], e.path[i+1:]
+		}
+		n := node{name: name, kind: 1, left: noStream, right: noStream, child: noStream, start: endOfChain}
+		if e.data != nil {
+			n.kind, n.size = 2, len(e.data)
+			if len(e.data) > 0 {
+				n.start = uint32(len(miniFAT))
+				sectors := (len(e.data) + 63) / 64
+				for s := range sectors {
+					next := uint32(len(miniFAT) + 1)
+					if s == sectors-1 {
+						next = endOfChain
+					}
+					miniFAT = append(miniFAT, next)
+				}
+				mini = append(mini, e.data...)
+				mini = append(mini, make([]byte, sectors*64-len(e.data))...)
+			}
+		}
+		id, p := len(nodes), index[parent]
+		nodes = append(nodes, n)
+		index[e.path] = id
+		if prev, ok := last[p]; ok {
+			nodes[prev].right = uint32(id)
+		} else {
+			nodes[p].child = uint32(id)
+		}
+		last[p] = id
+	}
+
+	sector := func(n int) int { return (n + 511) / 512 }
+	dirSectors := sector(len(nodes) * 128)
+	miniFATSectors := sector(len(miniFAT) * 4)
+	miniSectors := sector(len(mini))
+	dirStart, miniFATStart := 1, 1+dirSectors
+	miniStart := miniFATStart + miniFATSectors
+	nodes[0].start, nodes[0].size = uint32(miniStart), len(mini)
+
+	fat := []uint32{fatSect}
+	chain := func(n int) {
+		for i := range n {
+			if i == n-1 {
+				fat = append(fat, endOfChain)
+			} else {
+				fat = append(fat, uint32(len(fat)+1))
+			}
+		}
+	}
+	chain(dirSectors)
+	chain(miniFATSectors)
+	chain(miniSectors)
+
+	le := binary.LittleEndian
+	out := make([]byte, 512*(1+miniStart+miniSectors))
+	copy(out, cfbSignature)
+	le.PutUint16(out[24:], 0x3E)
+	le.PutUint16(out[26:], 3)
+	le.PutUint16(out[28:], 0xFFFE)
+	le.PutUint16(out[30:], 9)
+	le.PutUint16(out[32:], 6)
+	le.PutUint32(out[44:], 1)
+	le.PutUint32(out[48:], uint32(dirStart))
+	le.PutUint32(out[56:], 4096)
+	le.PutUint32(out[60:], uint32(miniFATStart))
+	le.PutUint32(out[64:], uint32(miniFATSectors))
+	le.PutUint32(out[68:], endOfChain)
+	for i := 76; i < 512; i += 4 {
+		le.PutUint32(out[i:], noStream)
+	}
+	le.PutUint32(out[76:], 0)
+
+	body := out[512:]
+	for i := range 128 {
+		v := uint32(noStream)
+		if i < len(fat) {
+			v = fat[i]
+		}
+		le.PutUint32(body[i*4:], v)
+	}
+	for i, n := range nodes {
+		d := body[512*dirStart+i*128:]
+		u := utf16.Encode([]rune(n.name))
+		for j, c := range u {
+			le.PutUint16(d[j*2:], c)
+		}
+		le.PutUint16(d[64:], uint16(len(u)*2+2))
+		d[66], d[67] = n.kind, 1
+		le.PutUint32(d[68:], n.left)
+		le.PutUint32(d[72:], n.right)
+		le.PutUint32(d[76:], n.child)
+		le.PutUint32(d[116:], n.start)
+		le.PutUint32(d[120:], uint32(n.size))
+	}
+	for i := len(nodes); i < dirSectors*4; i++ {
+		d := body[512*dirStart+i*128:]
+		le.PutUint32(d[68:], noStream)
+		le.PutUint32(d[72:], noStream)
+		le.PutUint32(d[76:], noStream)
+	}
+	for i, v := range miniFAT {
+		le.PutUint32(body[512*miniFATStart+i*4:], v)
+	}
+	for i := len(miniFAT); i < miniFATSectors*128; i++ {
+		le.PutUint32(body[512*miniFATStart+i*4:], noStream)
+	}
+	copy(body[512*miniStart:], mini)
+	return out
+}
+
+// propStream builds a property stream: a zeroed header of the given size
+// followed by one 16-byte entry per tag, with fixed values inline.
+func propStream(header int, props map[uint32][]byte) []byte {
+	b := make([]byte, header)
+	for tag, v := range props {
+		e := make([]byte, 16)
+		binary.LittleEndian.PutUint32(e, tag)
+		binary.LittleEndian.PutUint32(e[4:], 6)
+		if len(v) <= 8 {
+			copy(e[8:], v)
+		} else {
+			binary.LittleEndian.PutUint32(e[8:], uint32(len(v)))
+		}
+		b = append(b, e...)
+	}
+	return b
+}
+
+func utf16le(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return b
+}
+
+func u32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+// sampleMsg builds a message with a named property, a file attachment,
+// and an embedded message with the given subject.
+func sampleMsg(subject, attachment string) []byte {
+	reminder := []byte{1, 0, 0, 0}
+	return buildCFB([]cfbEntry{
+		{path: "__nameid_version1.0"},
+		{path: "__nameid_version1.0/__substg1.0_00020102", data: []byte{
+			0x08, 0x20, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}},
+		{path: "__nameid_version1.0/__substg1.0_00030102", data: append(u32(0x8503), u32(3<<1)...)},
+		{path: "__nameid_version1.0/__substg1.0_00040102", data: []byte{}},
+		{path: "__properties_version1.0", data: propStream(topHeader, map[uint32][]byte{
+			0x0037001F: utf16le(subject),
+			0x1000001F: utf16le("Hello"),
+			0x00170003: u32(1),
+			0x8000000B: reminder,
+		})},
+		{path: "__substg1.0_0037001F", data: utf16le(subject)},
+		{path: "__substg1.0_1000001F", data: utf16le("Hello")},
+		{path: "__attach_version1.0_#00000000"},
+		{path: "__attach_version1.0_#00000000/__properties_version1.0", data: propStream(attachHeader, map[uint32][]byte{
+			0x3707001F: utf16le("report.txt"),
+			0x37010102: []byte(attachment),
+			0x37050003: u32(tnef.AttachByValue),
+		})},
+		{path: "__attach_version1.0_#00000000/__substg1.0_3707001F", data: utf16le("report.txt")},
+		{path: "__attach_version1.0_#00000000/__substg1.0_37010102", data: []byte(attachment)},
+		{path: "__attach_version1.0_#00000001"},
+		{path: "__attach_version1.0_#00000001/__properties_version1.0", data: propStream(attachHeader, map[uint32][]byte{
+			0x3707001F: utf16le("fwd.msg"),
+			0x3701000D: nil,
+			0x37050003: u32(tnef.AttachEmbeddedMsg),
+		})},
+		{path: "__attach_version1.0_#00000001/__substg1.0_3707001F", data: utf16le("fwd.msg")},
+		{path: "__attach_version1.0_#00000001/__substg1.0_3701000D"},
+		{path: "__attach_version1.0_#00000001/__substg1.0_3701000D/__properties_version1.0", data: propStream(embeddedHeader, map[uint32][]byte{
+			0x0037001F: utf16le("Inner " + subject),
+		})},
+		{path: "__attach_version1.0_#00000001/__substg1.0_3701000D/__substg1.0_0037001F", data: utf16le("Inner " + subject)},
+	})
+}
+
+func TestDecode(t *testing.T) {
+	m, err := Decode(sampleMsg("Quarterly report", "figures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetAttrString(tnef.MAPISubject); got != "Quarterly report" {
+		t.Errorf("subject = %q", got)
+	}
+	if v := tnef.DecodeValue(tnef.PtUnicode, m.Body); v != "Hello" {
+		t.Errorf("body = %q", m.Body)
+	}
+	var reminder *tnef.MAPIAttr
+	for i := range m.Attributes {
+		if m.Attributes[i].Named != nil {
+			reminder = &m.Attributes[i]
+		}
+	}
+	if reminder == nil || tnef.NamedPropName(reminder.Named) != "PidLidReminderSet" || !bytes.Equal(reminder.Data, []byte{1, 0, 0, 0}) {
+		t.Errorf("named property = %+v", reminder)
+	}
+	if len(m.Attachments) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(m.Attachments))
+	}
+	if a := m.Attachments[0]; a.Filename() != "report.txt" || string(a.Data) != "figures" {
+		t.Errorf("attachment 0 = %q, %q", a.Filename(), a.Data)
+	}
+	e := m.Attachments[1].EmbeddedMsg
+	if e == nil || e.GetAttrString(tnef.MAPISubject) != "Inner Quarterly report" {
+		t.Errorf("embedded message = %+v", e)
+	}
+}
+
+// Two .msg files compare the same way two TNEF files do.
+func TestCompare(t *testing.T) {
+	a, err := Decode(sampleMsg("Quarterly report", "figures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Decode(sampleMsg("Annual report", "new figures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := tnef.Compare(a, a); !d.Empty() {
+		t.Errorf("identical messages differ: %+v", d)
+	}
+	d := tnef.Compare(a, b)
+	if len(d.Properties) != 1 || d.Properties[0].Name != "PidTagSubject" {
+		t.Errorf("properties = %+v", d.Properties)
+	}
+	if len(d.Attachments) != 1 || d.Attachments[0].Name != "report.txt" || d.Attachments[0].NewSize != len("new figures") {
+		t.Errorf("attachments = %+v", d.Attachments)
+	}
+	if len(d.Embedded) != 1 || d.Embedded[0].Properties[0].New != `"Inner Annual report"` {
+		t.Errorf("embedded = %+v", d.Embedded)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	if _, err := Decode([]byte("not a compound file")); !errors.Is(err, ErrNotMsg) {
+		t.Errorf("plain bytes: %v", err)
+	}
+	// A compound file without a property stream is some other document.
+	if _, err := Decode(buildCFB([]cfbEntry{{path: "WordDocument", data: []byte("x")}})); !errors.Is(err, ErrNotMsg) {
+		t.Errorf("other compound file: %v", err)
+	}
+	if _, err := Decode(sampleMsg("x", "y")[:600]); !errors.Is(err, ErrMalformed) {
+		t.Errorf("truncated file: %v", err)
+	}
+}
@@ -19,23 +19,28 @@ const (
 
 // TNEF attribute IDs.
 const (
-	attrAttachData     = 0x800F
-	attrAttachTitle    = 0x8010
-	attrAttachRendData = 0x9002
-	attrMAPIProps      = 0x9003
-	attrAttachment     = 0x9005
+	attrAttachData       = 0x800F
+	attrAttachTitle      = 0x8010
+	attrAttachModifyDate = 0x8013
+	attrAttachRendData   = 0x9002
+	attrMAPIProps        = 0x9003
+	attrRecipTable       = 0x9004
+	attrAttachment       = 0x9005
 )
 
 // MAPI property IDs used during decoding.
 const (
 	MAPISubject         = 0x0037 // PR_SUBJECT
+	MAPIClientSubmit    = 0x0039 // PR_CLIENT_SUBMIT_TIME
 	MAPISenderName      = 0x0C1A // PR_SENDER_NAME
 	MAPISenderEmail     = 0x0C1F // PR_SENDER_EMAIL_ADDRESS
 	MAPIDisplayTo       = 0x0E04 // PR_DISPLAY_TO
+	MAPIDeliveryTime    = 0x0E06 // PR_MESSAGE_DELIVERY_TIME
 	MAPIDisplayCc       = 0x0E03 // PR_DISPLAY_CC
 	MAPIBody            = 0x1000 // PR_BODY
 	MAPIRtfCompressed   = 0x1009 // PR_RTF_COMPRESSED
 	MAPIBodyHTML        = 0x1013 // PR_BODY_HTML
+	MAPILastModified    = 0x3008 // PR_LAST_MODIFICATION_TIME
 	MAPIAttachDataObj   = 0x3701 // PR_ATTACH_DATA_OBJ
 	MAPIAttachFilename  = 0x3704 // PR_ATTACH_FILENAME
 	MAPIAttachMethod    = 0x3705 // PR_ATTACH_METHOD
@@ -5,56 +5,50 @@ package tnef
 
 import (
 	"encoding/binary"
+	"errors"
 	"strings"
 )
 
 // Decode parses a raw TNEF byte stream and returns the decoded Message.
+// A truncated stream yields whatever was decoded before the cut.
 func Decode(data []byte) (*Message, error) {
-	if len(data) < 6 {
-		return nil, ErrBadSignature
-	}
-	if binary.LittleEndian.Uint32(data[0:4]) != tnefSignature {
-		return nil, ErrBadSignature
+	records, err := Walk(data)
+	if errors.Is(err, ErrBadSignature) {
+		return nil, err
 	}
 
 	msg := &Message{}
-	offset := 6
 	var cur *Attachment
 
-	for offset+9 <= len(data) {
-		lv := int(data[offset])
-		id := int(binary.LittleEndian.Uint16(data[offset+1 : offset+3]))
-		ln := int(binary.LittleEndian.Uint32(data[offset+5 : offset+9]))
-
-		end := offset + 9 + ln + 2
-		if end > len(data) {
-			break
-		}
-		d := data[offset+9 : offset+9+ln]
-		offset = end
+	for i := range records {
+		r := &records[i]
 
-		if lv == lvlAttachment && id == attrAttachRendData {
+		if r.Level == lvlAttachment && r.ID == attrAttachRendData {
 			cur = &Attachment{}
 			msg.Attachments = append(msg.Attachments, cur)
 			continue
 		}
 
-		if lv == lvlAttachment && cur != nil {
-			switch id {
+		if r.Level == lvlAttachment && cur != nil {
+			switch r.ID {
 			case attrAttachTitle:
-				cur.Title = cleanStr(string(d))
+				cur.Title = cleanStr(string(r.Data))
 			case attrAttachData:
-				cur.Data = d
+				cur.Data = r.Data
+			case attrAttachModifyDate:
+				if cur.ModTime.IsZero() {
+					cur.ModTime = dtrToTime(r.Data)
+				}
 			case attrAttachment:
-				parseAttachProps(cur, d)
+				parseAttachProps(cur, r.Props)
 			}
 			continue
 		}
 
-		if id == attrMAPIProps {
-			attrs := decodeMAPI(d)
-			msg.Attributes = append(msg.Attributes, attrs...)
-			for _, a := range attrs {
+		if r.ID == attrMAPIProps {
+			for _, p := range r.Props {
+				a := p.Attr()
+				msg.Attributes = append(msg.Attributes, a)
 				switch a.Name {
 				case MAPIBody:
 					msg.Body = a.Data
@@ -76,12 +70,14 @@ func Decode(data []byte) (*Message, error) {
 }
 
 // parseAttachProps decodes the MAPI properties for a single attachment,
-// populating filename, MIME type, content-ID, method, and embedded data.
-func parseAttachProps(att *Attachment, data []byte) {
-	attrs := decodeMAPI(data)
+// populating filename, MIME type, content-ID, modification time, method,
+// and embedded data.
+func parseAttachProps(att *Attachment, props []Property) {
 	var obj []byte
 
-	for _, a := range attrs {
+	for _, p := range props {
+		a := p.Attr()
+		att.Attributes = append(att.Attributes, a)
 		switch a.Name {
 		case MAPIAttachFilename:
 			if att.Title == "" {
@@ -93,6 +89,13 @@ func parseAttachProps(att *Attachment, data []byte) {
 			att.MimeType = cleanStr(string(a.Data))
 		case MAPIAttachContentID:
 			att.ContentID = cleanStr(string(a.Data))
+		case MAPILastModified:
+			// Preferred over attAttachModifyDate, which has no zone.
+			if len(a.Data) >= 8 {
+				if t := FiletimeToTime(binary.LittleEndian.Uint64(a.Data)); !t.IsZero() {
+					att.ModTime = t
+				}
+			}
 		case MAPIAttachMethod:
 			if len(a.Data) >= 4 {
 				att.Method = int(binary.LittleEndian.Uint32(a.Data))
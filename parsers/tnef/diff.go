@@ -0,0 +1,278 @@
+// diff.go compares two decoded messages: MAPI properties, bodies, and
+// attachments, recursing into embedded messages.
+
+package tnef
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Change kinds used in a Diff.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Diff describes the differences between two messages. Path is empty for
+// the top-level message and names the attachment for embedded messages.
+type Diff struct {
+	Path        string             `json:"path,omitempty"`
+	Properties  []PropChange       `json:"properties,omitempty"`
+	Bodies      []BodyChange       `json:"bodies,omitempty"`
+	Attachments []AttachmentChange `json:"attachments,omitempty"`
+	Embedded    []*Diff            `json:"embedded,omitempty"`
+}
+
+// PropChange is one added, removed, or changed MAPI property. Old and New
+// are display strings from FormatValue.
+type PropChange struct {
+	Change  string `json:"change"`
+	Key     string `json:"key"` // "0x0037" or property set/name for named properties
+	Name    string `json:"name,omitempty"`
+	OldType string `json:"oldType,omitempty"`
+	NewType string `json:"newType,omitempty"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+}
+
+// BodyChange is a difference in one message body. Unified is set for the
+// plain-text body; other bodies report sizes only.
+type BodyChange struct {
+	Body    string `json:"body"` // "text", "html", "rtf", or "rtf_html"
+	Change  string `json:"change"`
+	OldSize int    `json:"oldSize"`
+	NewSize int    `json:"newSize"`
+	Unified string `json:"unified,omitempty"`
+}
+
+// AttachmentChange is an added, removed, or changed attachment, matched
+// by filename.
+type AttachmentChange struct {
+	Change     string       `json:"change"`
+	Name       string       `json:"name"`
+	OldSize    int          `json:"oldSize,omitempty"`
+	NewSize    int          `json:"newSize,omitempty"`
+	OldSHA256  string       `json:"oldSha256,omitempty"`
+	NewSHA256  string       `json:"newSha256,omitempty"`
+	Properties []PropChange `json:"properties,omitempty"`
+}
+
+// Empty reports whether the messages were identical, including embedded
+// messages.
+func (d *Diff) Empty() bool {
+	if len(d.Properties) > 0 || len(d.Bodies) > 0 || len(d.Attachments) > 0 {
+		return false
+	}
+	for _, e := range d.Embedded {
+		if !e.Empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare returns the differences from message a to message b.
+func Compare(a, b *Message) *Diff {
+	return compareAt("", a, b)
+}
+
+func compareAt(path string, a, b *Message) *Diff {
+	d := &Diff{
+		Path:       path,
+		Properties: compareProps(a.Attributes, b.Attributes),
+	}
+
+	// Body fields are also MAPI properties; report them once, below.
+	kept := d.Properties[:0]
+	for _, p := range d.Properties {
+		switch p.Key {
+		case propKey(MAPIBody, nil), propKey(MAPIBodyHTML, nil), propKey(MAPIRtfCompressed, nil):
+			continue
+		}
+		kept = append(kept, p)
+	}
+	d.Properties = kept
+
+	for _, body := range []struct {
+		name string
+		a, b []byte
+	}{
+		{"text", a.Body, b.Body},
+		{"html", a.BodyHTML, b.BodyHTML},
+		{"rtf", a.BodyRTF, b.BodyRTF},
+		{"rtf_html", a.BodyRTFHTML, b.BodyRTFHTML},
+	} {
+		if bytes.Equal(body.a, body.b) {
+			continue
+		}
+		c := BodyChange{Body: body.name, Change: Changed, OldSize: len(body.a), NewSize: len(body.b)}
+		switch {
+		case len(body.a) == 0:
+			c.Change = Added
+		case len(body.b) == 0:
+			c.Change = Removed
+		}
+		if body.name == "text" {
+			c.Unified = UnifiedDiff(string(body.a), string(body.b), 3)
+		}
+		d.Bodies = append(d.Bodies, c)
+	}
+
+	oldAtts, newAtts := attachmentKeys(a.Attachments), attachmentKeys(b.Attachments)
+	for _, k := range unionKeys(oldAtts, newAtts) {
+		oa, na := oldAtts[k], newAtts[k]
+		switch {
+		case na == nil:
+			d.Attachments = append(d.Attachments, AttachmentChange{
+				Change: Removed, Name: k, OldSize: len(oa.Data), OldSHA256: sha256Hex(oa.Data),
+			})
+		case oa == nil:
+			d.Attachments = append(d.Attachments, AttachmentChange{
+				Change: Added, Name: k, NewSize: len(na.Data), NewSHA256: sha256Hex(na.Data),
+			})
+		default:
+			c := AttachmentChange{Change: Changed, Name: k, Properties: compareProps(oa.Attributes, na.Attributes)}
+			if !bytes.Equal(oa.Data, na.Data) {
+				c.OldSize, c.NewSize = len(oa.Data), len(na.Data)
+				c.OldSHA256, c.NewSHA256 = sha256Hex(oa.Data), sha256Hex(na.Data)
+			}
+			if oa.EmbeddedMsg != nil && na.EmbeddedMsg != nil {
+				// The embedded message is reported in detail; its raw
+				// bytes and container properties are not.
+				c.OldSize, c.NewSize, c.OldSHA256, c.NewSHA256 = 0, 0, "", ""
+				c.Properties = withoutProp(c.Properties, MAPIAttachDataObj)
+				if e := compareAt(joinPath(path, k), oa.EmbeddedMsg, na.EmbeddedMsg); !e.Empty() {
+					d.Embedded = append(d.Embedded, e)
+				}
+			}
+			if c.OldSHA256 != "" || len(c.Properties) > 0 {
+				d.Attachments = append(d.Attachments, c)
+			}
+		}
+	}
+	return d
+}
+
+// compareProps diffs two property lists keyed by property ID, or by
+// property set and name for named properties (whose IDs are assigned per
+// file).
+func compareProps(a, b []MAPIAttr) []PropChange {
+	old, cur := propMap(a), propMap(b)
+	var changes []PropChange
+	for _, k := range unionKeys(old, cur) {
+		o, n := old[k], cur[k]
+		c := PropChange{Key: k}
+		switch {
+		case n == nil:
+			c.Change, c.Name = Removed, propName(o)
+			c.OldType, c.Old = PropTypeName(o.Type), FormatValue(o.Type, o.Data)
+		case o == nil:
+			c.Change, c.Name = Added, propName(n)
+			c.NewType, c.New = PropTypeName(n.Type), FormatValue(n.Type, n.Data)
+		case o.Type != n.Type || !bytes.Equal(o.Data, n.Data):
+			c.Change, c.Name = Changed, propName(n)
+			c.OldType, c.Old = PropTypeName(o.Type), FormatValue(o.Type, o.Data)
+			c.NewType, c.New = PropTypeName(n.Type), FormatValue(n.Type, n.Data)
+		default:
+			continue
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// withoutProp drops changes to the tagged property id.
+func withoutProp(changes []PropChange, id int) []PropChange {
+	key := propKey(id, nil)
+	var out []PropChange
+	for _, c := range changes {
+		if c.Key != key {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// propKey returns the comparison key for a property.
+func propKey(id int, n *NamedID) string {
+	if n == nil {
+		return fmt.Sprintf("0x%04X", id)
+	}
+	set := PropertySetName(n.GUID)
+	if set == "" {
+		set = n.GUID.String()
+	}
+	if n.Kind == 0 {
+		return fmt.Sprintf("%s/0x%04X", set, n.LID)
+	}
+	return set + "/" + n.Name
+}
+
+// propName returns the dictionary name of a property, or "".
+func propName(a *MAPIAttr) string {
+	if a.Named != nil {
+		return NamedPropName(a.Named)
+	}
+	info, _ := LookupProp(a.Name)
+	return info.Name
+}
+
+// propMap indexes properties by key; the first occurrence wins.
+func propMap(attrs []MAPIAttr) map[string]*MAPIAttr {
+	m := make(map[string]*MAPIAttr, len(attrs))
+	for i := range attrs {
+		k := propKey(attrs[i].Name, attrs[i].Named)
+		if _, dup := m[k]; !dup {
+			m[k] = &attrs[i]
+		}
+	}
+	return m
+}
+
+// attachmentKeys indexes attachments by filename, numbering duplicates
+// ("a.txt", "a.txt#2") in stream order.
+func attachmentKeys(atts []*Attachment) map[string]*Attachment {
+	m := make(map[string]*Attachment, len(atts))
+	seen := map[string]int{}
+	for _, att := range atts {
+		name := att.Filename()
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, seen[name])
+		}
+		m[name] = att
+	}
+	return m
+}
+
+// unionKeys returns the sorted keys present in either map.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
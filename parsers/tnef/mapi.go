@@ -2,99 +2,198 @@
 
 package tnef
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
 
-// decodeMAPI parses a raw MAPI property stream into a slice of MAPIAttr,
-// handling fixed-size, variable-length, multi-valued, and named properties.
-func decodeMAPI(data []byte) []MAPIAttr {
-	if len(data) < 4 {
-		return nil
+// ErrMalformedProps is returned when a MAPI property stream ends early or
+// contains impossible lengths.
+var ErrMalformedProps = errors.New("malformed MAPI property stream")
+
+// Property is one MAPI property as it appears in a property stream, with
+// absolute byte offsets into the TNEF data for diagnostics.
+type Property struct {
+	ID          int      // Property ID (e.g. 0x0037 for PidTagSubject).
+	Type        int      // Property type without the multi-value flag.
+	MultiValued bool     // MV flag (0x1000) was set in the tag.
+	Named       *NamedID // Name for IDs 0x8000–0xFFFE, nil otherwise.
+	Offset      int      // Offset of the property tag.
+	Length      int      // Bytes from the tag to the end of the last value.
+	Values      []Value  // One entry per value (one for single-valued props).
+}
+
+// Value is a single property value and the offset of its bytes.
+type Value struct {
+	Offset int
+	Data   []byte
+}
+
+// NamedID identifies a named property by property set and either a
+// numeric ID (LID) or a string name.
+type NamedID struct {
+	GUID GUID
+	Kind int    // 0 = numeric LID, 1 = string name
+	LID  uint32 // valid when Kind is 0
+	Name string // valid when Kind is 1
+}
+
+// GUID is a Windows GUID in its on-disk (mixed-endian) byte order.
+type GUID [16]byte
+
+// String formats g in the registry form, e.g.
+// {00020329-0000-0000-C000-000000000046}.
+func (g GUID) String() string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%X-%X}",
+		binary.LittleEndian.Uint32(g[0:4]),
+		binary.LittleEndian.Uint16(g[4:6]),
+		binary.LittleEndian.Uint16(g[6:8]),
+		g[8:10], g[10:16])
+}
+
+// Tag returns the 32-bit property tag (type in the low word, ID in the
+// high word, MV flag included).
+func (p Property) Tag() uint32 {
+	t := p.Type
+	if p.MultiValued {
+		t |= 0x1000
+	}
+	return uint32(p.ID)<<16 | uint32(t)
+}
+
+// Bytes returns all values concatenated, which is how MAPIAttr stores
+// multi-valued and variable-length properties.
+func (p Property) Bytes() []byte {
+	if len(p.Values) == 1 {
+		return p.Values[0].Data
+	}
+	var b []byte
+	for _, v := range p.Values {
+		b = append(b, v.Data...)
 	}
-	count := int(binary.LittleEndian.Uint32(data[0:4]))
-	off := 4
+	return b
+}
+
+// Attr converts p to the MAPIAttr form stored on decoded messages.
+func (p Property) Attr() MAPIAttr {
+	return MAPIAttr{Type: p.Type, Name: p.ID, Data: p.Bytes(), Named: p.Named}
+}
+
+// DecodeProperties parses a MAPI property stream (a uint32 count followed
+// by that many properties). base is the absolute offset of data within the
+// TNEF stream and is added to every reported offset. On malformed input it
+// returns the properties decoded so far together with an error.
+func DecodeProperties(data []byte, base int) ([]Property, error) {
+	props, _, err := decodeProperties(data, 0, base)
+	return props, err
+}
+
+// decodeProperties parses a property list starting at off and returns the
+// offset just past it.
+func decodeProperties(data []byte, off, base int) ([]Property, int, error) {
+	if off+4 > len(data) {
+		return nil, off, ErrMalformedProps
+	}
+	count := int(binary.LittleEndian.Uint32(data[off : off+4]))
+	off += 4
 
 	// Cap pre-allocation to prevent OOM from crafted files.
 	// Each MAPI attr needs at least 8 bytes, so limit capacity accordingly.
-	maxAttrs := len(data) / 8
-	if count > maxAttrs {
-		count = maxAttrs
+	if maxProps := (len(data) - off) / 8; count > maxProps {
+		count = maxProps + 1
 	}
-	attrs := make([]MAPIAttr, 0, count)
+	props := make([]Property, 0, count)
 
-	for i := 0; i < count && off+4 <= len(data); i++ {
-		pt := int(binary.LittleEndian.Uint16(data[off : off+2]))
-		pid := int(binary.LittleEndian.Uint16(data[off+2 : off+4]))
-		off += 4
-
-		mv := (pt & 0x1000) != 0
-		bt := pt & 0xEFFF
-		fs := fixedPropSize(bt)
-		if fs < 0 {
-			mv = true
+	for i := 0; i < count; i++ {
+		p, next, err := decodeProperty(data, off, base)
+		if err != nil {
+			return props, off, fmt.Errorf("property %d at offset %d: %w", i, base+off, err)
 		}
+		props = append(props, p)
+		off = next
+	}
+	return props, off, nil
+}
 
-		// Named properties carry extra GUID + kind header.
-		if pid >= 0x8000 && pid <= 0xFFFE {
-			if off+16 > len(data) {
-				break
-			}
-			off += 16
-			if off+4 > len(data) {
-				break
-			}
-			kind := int(binary.LittleEndian.Uint32(data[off : off+4]))
+// decodeProperty parses one property starting at off.
+func decodeProperty(data []byte, off, base int) (Property, int, error) {
+	start := off
+	if off+4 > len(data) {
+		return Property{}, off, ErrMalformedProps
+	}
+	pt := int(binary.LittleEndian.Uint16(data[off : off+2]))
+	p := Property{
+		ID:          int(binary.LittleEndian.Uint16(data[off+2 : off+4])),
+		Type:        pt & 0xEFFF,
+		MultiValued: pt&0x1000 != 0,
+		Offset:      base + off,
+	}
+	off += 4
+
+	// Variable-length types always carry a value count, even when the MV
+	// flag is clear.
+	mv := p.MultiValued
+	fs := fixedPropSize(p.Type)
+	if fs < 0 {
+		mv = true
+	}
+
+	// Named properties carry extra GUID + kind header.
+	if p.ID >= 0x8000 && p.ID <= 0xFFFE {
+		if off+24 > len(data) {
+			return p, off, ErrMalformedProps
+		}
+		n := &NamedID{Kind: int(binary.LittleEndian.Uint32(data[off+16 : off+20]))}
+		copy(n.GUID[:], data[off:off+16])
+		off += 20
+		if n.Kind == 0 {
+			n.LID = binary.LittleEndian.Uint32(data[off : off+4])
 			off += 4
-			if kind == 0 {
-				if off+4 > len(data) {
-					break
-				}
-				off += 4
-			} else {
-				if off+4 > len(data) {
-					break
-				}
-				nl := int(binary.LittleEndian.Uint32(data[off : off+4]))
-				off += 4 + nl + padTo4(nl)
+		} else {
+			nl := int(binary.LittleEndian.Uint32(data[off : off+4]))
+			off += 4
+			if nl < 0 || off+nl > len(data) {
+				return p, off, ErrMalformedProps
 			}
+			n.Name = decodeUTF16(data[off : off+nl])
+			off += nl + padTo4(nl)
 		}
+		p.Named = n
+	}
 
-		vc := 1
-		if mv {
-			if off+4 > len(data) {
-				break
-			}
-			vc = int(binary.LittleEndian.Uint32(data[off : off+4]))
-			off += 4
-		}
-		if vc < 0 || vc > 4096 {
-			break
+	vc := 1
+	if mv {
+		if off+4 > len(data) {
+			return p, off, ErrMalformedProps
 		}
+		vc = int(binary.LittleEndian.Uint32(data[off : off+4]))
+		off += 4
+	}
+	if vc < 0 || vc > 4096 {
+		return p, off, ErrMalformedProps
+	}
 
-		var ad []byte
-		ok := true
-		for v := 0; v < vc; v++ {
-			l := fs
-			if fs < 0 {
-				if off+4 > len(data) {
-					ok = false
-					break
-				}
-				l = int(binary.LittleEndian.Uint32(data[off : off+4]))
-				off += 4
-			}
-			if l < 0 || off+l > len(data) {
-				ok = false
-				break
+	end := off
+	for v := 0; v < vc; v++ {
+		l := fs
+		if fs < 0 {
+			if off+4 > len(data) {
+				return p, off, ErrMalformedProps
 			}
-			ad = append(ad, data[off:off+l]...)
-			off += l + padTo4(l)
+			l = int(binary.LittleEndian.Uint32(data[off : off+4]))
+			off += 4
 		}
-		if !ok {
-			break
+		if l < 0 || off+l > len(data) {
+			return p, off, ErrMalformedProps
 		}
-		attrs = append(attrs, MAPIAttr{Type: bt, Name: pid, Data: ad})
+		p.Values = append(p.Values, Value{Offset: base + off, Data: data[off : off+l]})
+		end = off + l
+		off += l + padTo4(l)
 	}
-	return attrs
+	p.Length = end - start
+	return p, off, nil
 }
 
 // fixedPropSize returns the byte size for a fixed-width MAPI property type,
@@ -120,3 +219,17 @@ func fixedPropSize(pt int) int {
 func padTo4(n int) int {
 	return (4 - n%4) % 4
 }
+
+// decodeUTF16 converts little-endian UTF-16 to a string, stopping at the
+// first NUL.
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,753 @@
+// proptags.go names TNEF attributes, MAPI property tags, property types,
+// and named-property sets (MS-OXTNEF, MS-OXPROPS, MS-OXCDATA) for
+// diagnostic output.
+
+package tnef
+
+import "fmt"
+
+// PropInfo describes a tagged MAPI property.
+type PropInfo struct {
+	Name string // canonical PidTag name
+	Type int    // canonical property type, without the MV flag
+}
+
+// MAPI property types (MS-OXCDATA 2.11.1).
+const (
+	PtUnspecified = 0x0000
+	PtNull        = 0x0001
+	PtShort       = 0x0002
+	PtLong        = 0x0003
+	PtFloat       = 0x0004
+	PtDouble      = 0x0005
+	PtCurrency    = 0x0006
+	PtAppTime     = 0x0007
+	PtError       = 0x000A
+	PtBoolean     = 0x000B
+	PtObject      = 0x000D
+	PtI8          = 0x0014
+	PtString8     = 0x001E
+	PtUnicode     = 0x001F
+	PtSysTime     = 0x0040
+	PtClsid       = 0x0048
+	PtSvrEID      = 0x00FB
+	PtRestriction = 0x00FD
+	PtRuleAction  = 0x00FE
+	PtBinary      = 0x0102
+)
+
+var propTypeNames = map[int]string{
+	PtUnspecified: "PT_UNSPECIFIED", PtNull: "PT_NULL", PtShort: "PT_SHORT",
+	PtLong: "PT_LONG", PtFloat: "PT_FLOAT", PtDouble: "PT_DOUBLE",
+	PtCurrency: "PT_CURRENCY", PtAppTime: "PT_APPTIME", PtError: "PT_ERROR",
+	PtBoolean: "PT_BOOLEAN", PtObject: "PT_OBJECT", PtI8: "PT_I8",
+	PtString8: "PT_STRING8", PtUnicode: "PT_UNICODE", PtSysTime: "PT_SYSTIME",
+	PtClsid: "PT_CLSID", PtSvrEID: "PT_SVREID", PtRestriction: "PT_SRESTRICT",
+	PtRuleAction: "PT_ACTIONS", PtBinary: "PT_BINARY",
+}
+
+// PropTypeName returns the PT_ name of a property type (without the MV
+// flag), or its hex value if unknown.
+func PropTypeName(t int) string {
+	if n, ok := propTypeNames[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%04X", t)
+}
+
+// attributeNames maps TNEF attribute IDs to their MS-OXTNEF names.
+var attributeNames = map[int]string{
+	0x0000: "attOwner", 0x0001: "attSentFor", 0x0002: "attDelegate",
+	0x0006: "attDateStart", 0x0007: "attDateEnd", 0x0008: "attAidOwner",
+	0x0009: "attRequestRes", 0x0600: "attOriginalMessageClass",
+	0x8000: "attFrom", 0x8004: "attSubject", 0x8005: "attDateSent",
+	0x8006: "attDateRecd", 0x8007: "attMessageStatus", 0x8008: "attMessageClass",
+	0x8009: "attMessageID", 0x800A: "attParentID", 0x800B: "attConversationID",
+	0x800C: "attBody", 0x800D: "attPriority", 0x800F: "attAttachData",
+	0x8010: "attAttachTitle", 0x8011: "attAttachMetaFile",
+	0x8012: "attAttachCreateDate", 0x8013: "attAttachModifyDate",
+	0x8020: "attDateModified", 0x9001: "attAttachTransportFilename",
+	0x9002: "attAttachRendData", 0x9003: "attMsgProps", 0x9004: "attRecipTable",
+	0x9005: "attAttachment", 0x9006: "attTnefVersion", 0x9007: "attOemCodepage",
+}
+
+// attributeTypeNames maps TNEF attribute types to their MS-OXTNEF names.
+var attributeTypeNames = map[int]string{
+	0x0000: "atpTriples", 0x0001: "atpString", 0x0002: "atpText",
+	0x0003: "atpDate", 0x0004: "atpShort", 0x0005: "atpLong",
+	0x0006: "atpByte", 0x0007: "atpWord", 0x0008: "atpDword", 0x0009: "atpMax",
+}
+
+// AttributeName returns the MS-OXTNEF name of a TNEF attribute ID, or "".
+func AttributeName(id int) string {
+	return attributeNames[id]
+}
+
+// AttributeTypeName returns the MS-OXTNEF name of a TNEF attribute type,
+// or its hex value if unknown.
+func AttributeTypeName(t int) string {
+	if n, ok := attributeTypeNames[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%04X", t)
+}
+
+// LookupProp returns the dictionary entry for a tagged property ID.
+func LookupProp(id int) (PropInfo, bool) {
+	p, ok := propTags[id]
+	return p, ok
+}
+
+// propTags is the MS-OXPROPS dictionary of tagged properties: message,
+// attachment, recipient, folder, store, rule, and address book properties.
+// Where the specification gives one ID several names, the one used on
+// messages is listed.
+var propTags = map[int]PropInfo{
+	0x0001: {"PidTagTemplateData", PtBinary},
+	0x0002: {"PidTagAlternateRecipientAllowed", PtBoolean},
+	0x0004: {"PidTagScriptData", PtBinary},
+	0x0005: {"PidTagAutoForwarded", PtBoolean},
+	0x000F: {"PidTagDeferredDeliveryTime", PtSysTime},
+	0x0010: {"PidTagDeliverTime", PtSysTime},
+	0x0015: {"PidTagExpiryTime", PtSysTime},
+	0x0017: {"PidTagImportance", PtLong},
+	0x001A: {"PidTagMessageClass", PtUnicode},
+	0x0023: {"PidTagOriginatorDeliveryReportRequested", PtBoolean},
+	0x0025: {"PidTagParentKey", PtBinary},
+	0x0026: {"PidTagPriority", PtLong},
+	0x0029: {"PidTagReadReceiptRequested", PtBoolean},
+	0x002A: {"PidTagReceiptTime", PtSysTime},
+	0x002B: {"PidTagRecipientReassignmentProhibited", PtBoolean},
+	0x002E: {"PidTagOriginalSensitivity", PtLong},
+	0x0030: {"PidTagReplyTime", PtSysTime},
+	0x0031: {"PidTagReportTag", PtBinary},
+	0x0032: {"PidTagReportTime", PtSysTime},
+	0x0036: {"PidTagSensitivity", PtLong},
+	0x0037: {"PidTagSubject", PtUnicode},
+	0x0039: {"PidTagClientSubmitTime", PtSysTime},
+	0x003A: {"PidTagReportName", PtUnicode},
+	0x003B: {"PidTagSentRepresentingSearchKey", PtBinary},
+	0x003D: {"PidTagSubjectPrefix", PtUnicode},
+	0x003F: {"PidTagReceivedByEntryId", PtBinary},
+	0x0040: {"PidTagReceivedByName", PtUnicode},
+	0x0041: {"PidTagSentRepresentingEntryId", PtBinary},
+	0x0042: {"PidTagSentRepresentingName", PtUnicode},
+	0x0043: {"PidTagReceivedRepresentingEntryId", PtBinary},
+	0x0044: {"PidTagReceivedRepresentingName", PtUnicode},
+	0x0045: {"PidTagReportEntryId", PtBinary},
+	0x0046: {"PidTagReadReceiptEntryId", PtBinary},
+	0x0047: {"PidTagMessageSubmissionId", PtBinary},
+	0x0049: {"PidTagOriginalSubject", PtUnicode},
+	0x004B: {"PidTagOriginalMessageClass", PtUnicode},
+	0x004C: {"PidTagOriginalAuthorEntryId", PtBinary},
+	0x004D: {"PidTagOriginalAuthorName", PtUnicode},
+	0x004E: {"PidTagOriginalSubmitTime", PtSysTime},
+	0x004F: {"PidTagReplyRecipientEntries", PtBinary},
+	0x0050: {"PidTagReplyRecipientNames", PtUnicode},
+	0x0051: {"PidTagReceivedBySearchKey", PtBinary},
+	0x0052: {"PidTagReceivedRepresentingSearchKey", PtBinary},
+	0x0053: {"PidTagReadReceiptSearchKey", PtBinary},
+	0x0054: {"PidTagReportSearchKey", PtBinary},
+	0x0055: {"PidTagOriginalDeliveryTime", PtSysTime},
+	0x0057: {"PidTagMessageToMe", PtBoolean},
+	0x0058: {"PidTagMessageCcMe", PtBoolean},
+	0x0059: {"PidTagMessageRecipientMe", PtBoolean},
+	0x005A: {"PidTagOriginalSenderName", PtUnicode},
+	0x005B: {"PidTagOriginalSenderEntryId", PtBinary},
+	0x005C: {"PidTagOriginalSenderSearchKey", PtBinary},
+	0x005D: {"PidTagOriginalSentRepresentingName", PtUnicode},
+	0x005E: {"PidTagOriginalSentRepresentingEntryId", PtBinary},
+	0x005F: {"PidTagOriginalSentRepresentingSearchKey", PtBinary},
+	0x0060: {"PidTagStartDate", PtSysTime},
+	0x0061: {"PidTagEndDate", PtSysTime},
+	0x0062: {"PidTagOwnerAppointmentId", PtLong},
+	0x0063: {"PidTagResponseRequested", PtBoolean},
+	0x0064: {"PidTagSentRepresentingAddressType", PtUnicode},
+	0x0065: {"PidTagSentRepresentingEmailAddress", PtUnicode},
+	0x0066: {"PidTagOriginalSenderAddressType", PtUnicode},
+	0x0067: {"PidTagOriginalSenderEmailAddress", PtUnicode},
+	0x0068: {"PidTagOriginalSentRepresentingAddressType", PtUnicode},
+	0x0069: {"PidTagOriginalSentRepresentingEmailAddress", PtUnicode},
+	0x0070: {"PidTagConversationTopic", PtUnicode},
+	0x0071: {"PidTagConversationIndex", PtBinary},
+	0x0072: {"PidTagOriginalDisplayBcc", PtUnicode},
+	0x0073: {"PidTagOriginalDisplayCc", PtUnicode},
+	0x0074: {"PidTagOriginalDisplayTo", PtUnicode},
+	0x0075: {"PidTagReceivedByAddressType", PtUnicode},
+	0x0076: {"PidTagReceivedByEmailAddress", PtUnicode},
+	0x0077: {"PidTagReceivedRepresentingAddressType", PtUnicode},
+	0x0078: {"PidTagReceivedRepresentingEmailAddress", PtUnicode},
+	0x007D: {"PidTagTransportMessageHeaders", PtUnicode},
+	0x007F: {"PidTagTnefCorrelationKey", PtBinary},
+	0x0080: {"PidTagReportDisposition", PtUnicode},
+	0x0081: {"PidTagReportDispositionMode", PtUnicode},
+	0x0807: {"PidTagAddressBookRoomCapacity", PtLong},
+	0x0809: {"PidTagAddressBookRoomDescription", PtUnicode},
+	0x0C04: {"PidTagNonDeliveryReportReasonCode", PtLong},
+	0x0C05: {"PidTagNonDeliveryReportDiagCode", PtLong},
+	0x0C06: {"PidTagNonReceiptNotificationRequested", PtBoolean},
+	0x0C08: {"PidTagOriginatorNonDeliveryReportRequested", PtBoolean},
+	0x0C15: {"PidTagRecipientType", PtLong},
+	0x0C17: {"PidTagReplyRequested", PtBoolean},
+	0x0C19: {"PidTagSenderEntryId", PtBinary},
+	0x0C1A: {"PidTagSenderName", PtUnicode},
+	0x0C1B: {"PidTagSupplementaryInfo", PtUnicode},
+	0x0C1D: {"PidTagSenderSearchKey", PtBinary},
+	0x0C1E: {"PidTagSenderAddressType", PtUnicode},
+	0x0C1F: {"PidTagSenderEmailAddress", PtUnicode},
+	0x0C20: {"PidTagNonDeliveryReportStatusCode", PtLong},
+	0x0C21: {"PidTagRemoteMessageTransferAgent", PtUnicode},
+	0x0E01: {"PidTagDeleteAfterSubmit", PtBoolean},
+	0x0E02: {"PidTagDisplayBcc", PtUnicode},
+	0x0E03: {"PidTagDisplayCc", PtUnicode},
+	0x0E04: {"PidTagDisplayTo", PtUnicode},
+	0x0E05: {"PidTagParentDisplay", PtUnicode},
+	0x0E06: {"PidTagMessageDeliveryTime", PtSysTime},
+	0x0E07: {"PidTagMessageFlags", PtLong},
+	0x0E08: {"PidTagMessageSize", PtLong},
+	0x0E09: {"PidTagParentEntryId", PtBinary},
+	0x0E0A: {"PidTagSentMailEntryId", PtBinary},
+	0x0E0F: {"PidTagResponsibility", PtBoolean},
+	0x0E12: {"PidTagMessageRecipients", PtObject},
+	0x0E13: {"PidTagMessageAttachments", PtObject},
+	0x0E14: {"PidTagSubmitFlags", PtLong},
+	0x0E17: {"PidTagMessageStatus", PtLong},
+	0x0E1B: {"PidTagHasAttachments", PtBoolean},
+	0x0E1D: {"PidTagNormalizedSubject", PtUnicode},
+	0x0E1F: {"PidTagRtfInSync", PtBoolean},
+	0x0E20: {"PidTagAttachSize", PtLong},
+	0x0E21: {"PidTagAttachNumber", PtLong},
+	0x0E23: {"PidTagInternetArticleNumber", PtLong},
+	0x0E28: {"PidTagPrimarySendAccount", PtUnicode},
+	0x0E29: {"PidTagNextSendAcct", PtUnicode},
+	0x0E2B: {"PidTagToDoItemFlags", PtLong},
+	0x0E2C: {"PidTagSwappedToDoStore", PtBinary},
+	0x0E2D: {"PidTagSwappedToDoData", PtBinary},
+	0x0E30: {"PidTagReplItemid", PtLong},
+	0x0E33: {"PidTagReplChangenum", PtI8},
+	0x0E34: {"PidTagReplVersionhistory", PtBinary},
+	0x0E38: {"PidTagReplFlags", PtLong},
+	0x0E3C: {"PidTagReplCopiedfromVersionhistory", PtBinary},
+	0x0E3D: {"PidTagReplCopiedfromItemid", PtBinary},
+	0x0E58: {"PidTagCreatorSid", PtBinary},
+	0x0E59: {"PidTagLastModifierSid", PtBinary},
+	0x0E62: {"PidTagUrlCompNameSet", PtBoolean},
+	0x0E69: {"PidTagRead", PtBoolean},
+	0x0E6A: {"PidTagSecurityDescriptorAsXml", PtUnicode},
+	0x0E79: {"PidTagTrustSender", PtLong},
+	0x0E84: {"PidTagExchangeNTSecurityDescriptor", PtBinary},
+	0x0E99: {"PidTagExtendedRuleMessageActions", PtBinary},
+	0x0E9A: {"PidTagExtendedRuleMessageCondition", PtBinary},
+	0x0E9B: {"PidTagExtendedRuleSizeLimit", PtLong},
+	0x0FF4: {"PidTagAccess", PtLong},
+	0x0FF5: {"PidTagRowType", PtLong},
+	0x0FF6: {"PidTagInstanceKey", PtBinary},
+	0x0FF7: {"PidTagAccessLevel", PtLong},
+	0x0FF8: {"PidTagMappingSignature", PtBinary},
+	0x0FF9: {"PidTagRecordKey", PtBinary},
+	0x0FFB: {"PidTagStoreEntryId", PtBinary},
+	0x0FFE: {"PidTagObjectType", PtLong},
+	0x0FFF: {"PidTagEntryId", PtBinary},
+	0x1000: {"PidTagBody", PtUnicode},
+	0x1001: {"PidTagReportText", PtUnicode},
+	0x1006: {"PidTagRtfSyncBodyCrc", PtLong},
+	0x1007: {"PidTagRtfSyncBodyCount", PtLong},
+	0x1008: {"PidTagRtfSyncBodyTag", PtUnicode},
+	0x1009: {"PidTagRtfCompressed", PtBinary},
+	0x1010: {"PidTagRtfSyncPrefixCount", PtLong},
+	0x1011: {"PidTagRtfSyncTrailingCount", PtLong},
+	0x1013: {"PidTagBodyHtml", PtBinary}, // PidTagHtml; Outlook writes the binary form
+	0x1014: {"PidTagBodyContentLocation", PtUnicode},
+	0x1015: {"PidTagBodyContentId", PtUnicode},
+	0x1016: {"PidTagNativeBody", PtLong},
+	0x1035: {"PidTagInternetMessageId", PtUnicode},
+	0x1039: {"PidTagInternetReferences", PtUnicode},
+	0x1042: {"PidTagInReplyToId", PtUnicode},
+	0x1043: {"PidTagListHelp", PtUnicode},
+	0x1044: {"PidTagListSubscribe", PtUnicode},
+	0x1045: {"PidTagListUnsubscribe", PtUnicode},
+	0x1046: {"PidTagOriginalMessageId", PtUnicode},
+	0x1080: {"PidTagIconIndex", PtLong},
+	0x1081: {"PidTagLastVerbExecuted", PtLong},
+	0x1082: {"PidTagLastVerbExecutionTime", PtSysTime},
+	0x1090: {"PidTagFlagStatus", PtLong},
+	0x1091: {"PidTagFlagCompleteTime", PtSysTime},
+	0x1095: {"PidTagFollowupIcon", PtLong},
+	0x1096: {"PidTagBlockStatus", PtLong},
+	0x1097: {"PidTagItemTemporaryflags", PtLong},
+	0x10C3: {"PidTagICalendarStartTime", PtSysTime},
+	0x10C4: {"PidTagICalendarEndTime", PtSysTime},
+	0x10C5: {"PidTagCdoRecurrenceid", PtSysTime},
+	0x10CA: {"PidTagICalendarReminderNextTime", PtSysTime},
+	0x10F4: {"PidTagAttributeHidden", PtBoolean},
+	0x10F6: {"PidTagAttributeReadOnly", PtBoolean},
+	0x3000: {"PidTagRowid", PtLong},
+	0x3001: {"PidTagDisplayName", PtUnicode},
+	0x3002: {"PidTagAddressType", PtUnicode},
+	0x3003: {"PidTagEmailAddress", PtUnicode},
+	0x3004: {"PidTagComment", PtUnicode},
+	0x3005: {"PidTagDepth", PtLong},
+	0x3007: {"PidTagCreationTime", PtSysTime},
+	0x3008: {"PidTagLastModificationTime", PtSysTime},
+	0x300B: {"PidTagSearchKey", PtBinary},
+	0x3010: {"PidTagTargetEntryId", PtBinary},
+	0x3013: {"PidTagConversationId", PtBinary},
+	0x3016: {"PidTagConversationIndexTracking", PtBoolean},
+	0x3018: {"PidTagArchiveTag", PtBinary},
+	0x3019: {"PidTagPolicyTag", PtBinary},
+	0x301A: {"PidTagRetentionPeriod", PtLong},
+	0x301B: {"PidTagStartDateEtc", PtBinary},
+	0x301C: {"PidTagRetentionDate", PtSysTime},
+	0x301D: {"PidTagRetentionFlags", PtLong},
+	0x301E: {"PidTagArchivePeriod", PtLong},
+	0x301F: {"PidTagArchiveDate", PtSysTime},
+	0x340D: {"PidTagStoreSupportMask", PtLong},
+	0x340E: {"PidTagStoreState", PtLong},
+	0x35E0: {"PidTagIpmSubtreeEntryId", PtBinary},
+	0x35E2: {"PidTagIpmOutboxEntryId", PtBinary},
+	0x35E3: {"PidTagIpmWastebasketEntryId", PtBinary},
+	0x35E4: {"PidTagIpmSentMailEntryId", PtBinary},
+	0x35E7: {"PidTagFinderEntryId", PtBinary},
+	0x3600: {"PidTagContainerFlags", PtLong},
+	0x3601: {"PidTagFolderType", PtLong},
+	0x3602: {"PidTagContentCount", PtLong},
+	0x3603: {"PidTagContentUnreadCount", PtLong},
+	0x3609: {"PidTagSelectable", PtBoolean},
+	0x360A: {"PidTagSubfolders", PtBoolean},
+	0x360C: {"PidTagAnr", PtUnicode},
+	0x360E: {"PidTagContainerHierarchy", PtObject},
+	0x360F: {"PidTagContainerContents", PtObject},
+	0x3610: {"PidTagFolderAssociatedContents", PtObject},
+	0x3613: {"PidTagContainerClass", PtUnicode},
+	0x36D0: {"PidTagIpmAppointmentEntryId", PtBinary},
+	0x36D1: {"PidTagIpmContactEntryId", PtBinary},
+	0x36D2: {"PidTagIpmJournalEntryId", PtBinary},
+	0x36D3: {"PidTagIpmNoteEntryId", PtBinary},
+	0x36D4: {"PidTagIpmTaskEntryId", PtBinary},
+	0x36D5: {"PidTagRemindersOnlineEntryId", PtBinary},
+	0x36D7: {"PidTagIpmDraftsEntryId", PtBinary},
+	0x36D8: {"PidTagAdditionalRenEntryIds", PtBinary},
+	0x36D9: {"PidTagAdditionalRenEntryIdsEx", PtBinary},
+	0x36DA: {"PidTagExtendedFolderFlags", PtBinary},
+	0x36E2: {"PidTagOrdinalMost", PtLong},
+	0x36E4: {"PidTagFreeBusyEntryIds", PtBinary},
+	0x36E5: {"PidTagDefaultPostMessageClass", PtUnicode},
+	0x3701: {"PidTagAttachDataBinary", PtBinary},
+	0x3702: {"PidTagAttachEncoding", PtBinary},
+	0x3703: {"PidTagAttachExtension", PtUnicode},
+	0x3704: {"PidTagAttachFilename", PtUnicode},
+	0x3705: {"PidTagAttachMethod", PtLong},
+	0x3707: {"PidTagAttachLongFilename", PtUnicode},
+	0x3708: {"PidTagAttachPathname", PtUnicode},
+	0x3709: {"PidTagAttachRendering", PtBinary},
+	0x370A: {"PidTagAttachTag", PtBinary},
+	0x370B: {"PidTagRenderingPosition", PtLong},
+	0x370C: {"PidTagAttachTransportName", PtUnicode},
+	0x370D: {"PidTagAttachLongPathname", PtUnicode},
+	0x370E: {"PidTagAttachMimeTag", PtUnicode},
+	0x370F: {"PidTagAttachAdditionalInformation", PtBinary},
+	0x3711: {"PidTagAttachContentBase", PtUnicode},
+	0x3712: {"PidTagAttachContentId", PtUnicode},
+	0x3713: {"PidTagAttachContentLocation", PtUnicode},
+	0x3714: {"PidTagAttachFlags", PtLong},
+	0x3719: {"PidTagAttachPayloadProviderGuidString", PtUnicode},
+	0x371A: {"PidTagAttachPayloadClass", PtUnicode},
+	0x371B: {"PidTagTextAttachmentCharset", PtUnicode},
+	0x3900: {"PidTagDisplayType", PtLong},
+	0x3902: {"PidTagTemplateid", PtBinary},
+	0x3905: {"PidTagDisplayTypeEx", PtLong},
+	0x39FE: {"PidTagSmtpAddress", PtUnicode},
+	0x39FF: {"PidTagAddressBookDisplayNamePrintable", PtUnicode},
+	0x3A00: {"PidTagAccount", PtUnicode},
+	0x3A02: {"PidTagCallbackTelephoneNumber", PtUnicode},
+	0x3A05: {"PidTagGeneration", PtUnicode},
+	0x3A06: {"PidTagGivenName", PtUnicode},
+	0x3A07: {"PidTagGovernmentIdNumber", PtUnicode},
+	0x3A08: {"PidTagBusinessTelephoneNumber", PtUnicode},
+	0x3A09: {"PidTagHomeTelephoneNumber", PtUnicode},
+	0x3A0A: {"PidTagInitials", PtUnicode},
+	0x3A0B: {"PidTagKeyword", PtUnicode},
+	0x3A0C: {"PidTagLanguage", PtUnicode},
+	0x3A0D: {"PidTagLocation", PtUnicode},
+	0x3A0F: {"PidTagMessageHandlingSystemCommonName", PtUnicode},
+	0x3A10: {"PidTagOrganizationalIdNumber", PtUnicode},
+	0x3A11: {"PidTagSurname", PtUnicode},
+	0x3A12: {"PidTagOriginalEntryId", PtBinary},
+	0x3A15: {"PidTagPostalAddress", PtUnicode},
+	0x3A16: {"PidTagCompanyName", PtUnicode},
+	0x3A17: {"PidTagTitle", PtUnicode},
+	0x3A18: {"PidTagDepartmentName", PtUnicode},
+	0x3A19: {"PidTagOfficeLocation", PtUnicode},
+	0x3A1A: {"PidTagPrimaryTelephoneNumber", PtUnicode},
+	0x3A1B: {"PidTagBusiness2TelephoneNumber", PtUnicode},
+	0x3A1C: {"PidTagMobileTelephoneNumber", PtUnicode},
+	0x3A1D: {"PidTagRadioTelephoneNumber", PtUnicode},
+	0x3A1E: {"PidTagCarTelephoneNumber", PtUnicode},
+	0x3A1F: {"PidTagOtherTelephoneNumber", PtUnicode},
+	0x3A20: {"PidTagTransmittableDisplayName", PtUnicode},
+	0x3A21: {"PidTagPagerTelephoneNumber", PtUnicode},
+	0x3A22: {"PidTagUserCertificate", PtBinary},
+	0x3A23: {"PidTagPrimaryFaxNumber", PtUnicode},
+	0x3A24: {"PidTagBusinessFaxNumber", PtUnicode},
+	0x3A25: {"PidTagHomeFaxNumber", PtUnicode},
+	0x3A26: {"PidTagCountry", PtUnicode},
+	0x3A27: {"PidTagLocality", PtUnicode},
+	0x3A28: {"PidTagStateOrProvince", PtUnicode},
+	0x3A29: {"PidTagStreetAddress", PtUnicode},
+	0x3A2A: {"PidTagPostalCode", PtUnicode},
+	0x3A2B: {"PidTagPostOfficeBox", PtUnicode},
+	0x3A2C: {"PidTagTelexNumber", PtUnicode},
+	0x3A2D: {"PidTagIsdnNumber", PtUnicode},
+	0x3A2E: {"PidTagAssistantTelephoneNumber", PtUnicode},
+	0x3A2F: {"PidTagHome2TelephoneNumber", PtUnicode},
+	0x3A30: {"PidTagAssistant", PtUnicode},
+	0x3A40: {"PidTagSendRichInfo", PtBoolean},
+	0x3A41: {"PidTagWeddingAnniversary", PtSysTime},
+	0x3A42: {"PidTagBirthday", PtSysTime},
+	0x3A43: {"PidTagHobbies", PtUnicode},
+	0x3A44: {"PidTagMiddleName", PtUnicode},
+	0x3A45: {"PidTagDisplayNamePrefix", PtUnicode},
+	0x3A46: {"PidTagProfession", PtUnicode},
+	0x3A47: {"PidTagReferredByName", PtUnicode},
+	0x3A48: {"PidTagSpouseName", PtUnicode},
+	0x3A49: {"PidTagComputerNetworkName", PtUnicode},
+	0x3A4A: {"PidTagCustomerId", PtUnicode},
+	0x3A4B: {"PidTagTelecommunicationsDeviceForDeafTelephoneNumber", PtUnicode},
+	0x3A4C: {"PidTagFtpSite", PtUnicode},
+	0x3A4D: {"PidTagGender", PtShort},
+	0x3A4E: {"PidTagManagerName", PtUnicode},
+	0x3A4F: {"PidTagNickname", PtUnicode},
+	0x3A50: {"PidTagPersonalHomePage", PtUnicode},
+	0x3A51: {"PidTagBusinessHomePage", PtUnicode},
+	0x3A57: {"PidTagCompanyMainTelephoneNumber", PtUnicode},
+	0x3A58: {"PidTagChildrensNames", PtUnicode},
+	0x3A59: {"PidTagHomeAddressCity", PtUnicode},
+	0x3A5A: {"PidTagHomeAddressCountry", PtUnicode},
+	0x3A5B: {"PidTagHomeAddressPostalCode", PtUnicode},
+	0x3A5C: {"PidTagHomeAddressStateOrProvince", PtUnicode},
+	0x3A5D: {"PidTagHomeAddressStreet", PtUnicode},
+	0x3A5E: {"PidTagHomeAddressPostOfficeBox", PtUnicode},
+	0x3A5F: {"PidTagOtherAddressCity", PtUnicode},
+	0x3A60: {"PidTagOtherAddressCountry", PtUnicode},
+	0x3A61: {"PidTagOtherAddressPostalCode", PtUnicode},
+	0x3A62: {"PidTagOtherAddressStateOrProvince", PtUnicode},
+	0x3A63: {"PidTagOtherAddressStreet", PtUnicode},
+	0x3A64: {"PidTagOtherAddressPostOfficeBox", PtUnicode},
+	0x3A70: {"PidTagUserX509Certificate", PtBinary},
+	0x3A71: {"PidTagSendInternetEncoding", PtLong},
+	0x3F08: {"PidTagInitialDetailsPane", PtLong},
+	0x3FDE: {"PidTagInternetCodepage", PtLong},
+	0x3FDF: {"PidTagAutoResponseSuppress", PtLong},
+	0x3FE0: {"PidTagAccessControlListData", PtBinary},
+	0x3FE3: {"PidTagDelegatedByRule", PtBoolean},
+	0x3FE7: {"PidTagResolveMethod", PtLong},
+	0x3FEA: {"PidTagHasDeferredActionMessages", PtBoolean},
+	0x3FEB: {"PidTagDeferredSendNumber", PtLong},
+	0x3FEC: {"PidTagDeferredSendUnits", PtLong},
+	0x3FED: {"PidTagExpiryNumber", PtLong},
+	0x3FEE: {"PidTagExpiryUnits", PtLong},
+	0x3FEF: {"PidTagDeferredSendTime", PtSysTime},
+	0x3FF0: {"PidTagConflictEntryId", PtBinary},
+	0x3FF1: {"PidTagMessageLocaleId", PtLong},
+	0x3FF8: {"PidTagCreatorName", PtUnicode},
+	0x3FF9: {"PidTagCreatorEntryId", PtBinary},
+	0x3FFA: {"PidTagLastModifierName", PtUnicode},
+	0x3FFB: {"PidTagLastModifierEntryId", PtBinary},
+	0x3FFD: {"PidTagMessageCodepage", PtLong},
+	0x401A: {"PidTagSentRepresentingFlags", PtLong},
+	0x4029: {"PidTagReadReceiptAddressType", PtUnicode},
+	0x402A: {"PidTagReadReceiptEmailAddress", PtUnicode},
+	0x402B: {"PidTagReadReceiptName", PtUnicode},
+	0x4076: {"PidTagContentFilterSpamConfidenceLevel", PtLong},
+	0x4079: {"PidTagSenderIdStatus", PtLong},
+	0x4082: {"PidTagHierRev", PtSysTime},
+	0x4083: {"PidTagPurportedSenderDomain", PtUnicode},
+	0x5902: {"PidTagInternetMailOverrideFormat", PtLong},
+	0x5909: {"PidTagMessageEditorFormat", PtLong},
+	0x5D01: {"PidTagSenderSmtpAddress", PtUnicode},
+	0x5D02: {"PidTagSentRepresentingSmtpAddress", PtUnicode},
+	0x5D05: {"PidTagReadReceiptSmtpAddress", PtUnicode},
+	0x5D07: {"PidTagReceivedBySmtpAddress", PtUnicode},
+	0x5D08: {"PidTagReceivedRepresentingSmtpAddress", PtUnicode},
+	0x5FDF: {"PidTagRecipientOrder", PtLong},
+	0x5FE1: {"PidTagRecipientProposed", PtBoolean},
+	0x5FE3: {"PidTagRecipientProposedStartTime", PtSysTime},
+	0x5FE4: {"PidTagRecipientProposedEndTime", PtSysTime},
+	0x5FF6: {"PidTagRecipientDisplayName", PtUnicode},
+	0x5FF7: {"PidTagRecipientEntryId", PtBinary},
+	0x5FFB: {"PidTagRecipientTrackStatusTime", PtSysTime},
+	0x5FFD: {"PidTagRecipientFlags", PtLong},
+	0x5FFF: {"PidTagRecipientTrackStatus", PtLong},
+	0x6100: {"PidTagJunkIncludeContacts", PtLong},
+	0x6101: {"PidTagJunkThreshold", PtLong},
+	0x6102: {"PidTagJunkPermanentlyDelete", PtLong},
+	0x6103: {"PidTagJunkAddRecipientsToSafeSendersList", PtLong},
+	0x6107: {"PidTagJunkPhishingEnableLinks", PtBoolean},
+	0x64F0: {"PidTagMimeSkeleton", PtBinary},
+	0x65C2: {"PidTagReplyTemplateId", PtBinary},
+	0x65C6: {"PidTagSecureSubmitFlags", PtLong},
+	0x65E0: {"PidTagSourceKey", PtBinary},
+	0x65E1: {"PidTagParentSourceKey", PtBinary},
+	0x65E2: {"PidTagChangeKey", PtBinary},
+	0x65E3: {"PidTagPredecessorChangeList", PtBinary},
+	0x65E9: {"PidTagRuleMessageState", PtLong},
+	0x65EA: {"PidTagRuleMessageUserFlags", PtLong},
+	0x65EB: {"PidTagRuleMessageProvider", PtUnicode},
+	0x65EC: {"PidTagRuleMessageName", PtUnicode},
+	0x65ED: {"PidTagRuleMessageLevel", PtLong},
+	0x65EE: {"PidTagRuleMessageProviderData", PtBinary},
+	0x65F3: {"PidTagRuleMessageSequence", PtLong},
+	0x6619: {"PidTagUserEntryId", PtBinary},
+	0x661B: {"PidTagMailboxOwnerEntryId", PtBinary},
+	0x661C: {"PidTagMailboxOwnerName", PtUnicode},
+	0x661D: {"PidTagOutOfOfficeState", PtBoolean},
+	0x6622: {"PidTagSchedulePlusFreeBusyEntryId", PtBinary},
+	0x6638: {"PidTagSerializedReplidGuidMap", PtBinary},
+	0x6639: {"PidTagRights", PtLong},
+	0x663A: {"PidTagHasRules", PtBoolean},
+	0x663B: {"PidTagAddressBookEntryId", PtBinary},
+	0x663E: {"PidTagHierarchyChangeNumber", PtLong},
+	0x6645: {"PidTagClientActions", PtBinary},
+	0x6646: {"PidTagDamOriginalEntryId", PtBinary},
+	0x6647: {"PidTagDamBackPatched", PtBoolean},
+	0x6648: {"PidTagRuleError", PtLong},
+	0x6649: {"PidTagRuleActionType", PtLong},
+	0x664A: {"PidTagHasNamedProperties", PtBoolean},
+	0x6650: {"PidTagRuleActionNumber", PtLong},
+	0x6651: {"PidTagRuleFolderEntryId", PtBinary},
+	0x666A: {"PidTagProhibitReceiveQuota", PtLong},
+	0x666C: {"PidTagInConflict", PtBoolean},
+	0x666D: {"PidTagMaximumSubmitMessageSize", PtLong},
+	0x666E: {"PidTagProhibitSendQuota", PtLong},
+	0x6671: {"PidTagMemberId", PtI8},
+	0x6672: {"PidTagMemberName", PtUnicode},
+	0x6673: {"PidTagMemberRights", PtLong},
+	0x6674: {"PidTagRuleId", PtI8},
+	0x6675: {"PidTagRuleIds", PtBinary},
+	0x6676: {"PidTagRuleSequence", PtLong},
+	0x6677: {"PidTagRuleState", PtLong},
+	0x6678: {"PidTagRuleUserFlags", PtLong},
+	0x6679: {"PidTagRuleCondition", PtRestriction},
+	0x6680: {"PidTagRuleActions", PtRuleAction},
+	0x6681: {"PidTagRuleProvider", PtUnicode},
+	0x6682: {"PidTagRuleName", PtUnicode},
+	0x6683: {"PidTagRuleLevel", PtLong},
+	0x6684: {"PidTagRuleProviderData", PtBinary},
+	0x668F: {"PidTagDeletedOn", PtSysTime},
+	0x66A1: {"PidTagLocaleId", PtLong},
+	0x66A8: {"PidTagFolderFlags", PtLong},
+	0x66C3: {"PidTagCodePageId", PtLong},
+	0x6705: {"PidTagSortLocaleId", PtLong},
+	0x6707: {"PidTagUrlName", PtUnicode},
+	0x6709: {"PidTagLocalCommitTime", PtSysTime},
+	0x670A: {"PidTagLocalCommitTimeMax", PtSysTime},
+	0x670B: {"PidTagDeletedCountTotal", PtLong},
+	0x670E: {"PidTagFlatUrlName", PtUnicode},
+	0x6740: {"PidTagSentMailSvrEID", PtSvrEID},
+	0x6741: {"PidTagDeferredActionMessageOriginalEntryId", PtSvrEID},
+	0x6748: {"PidTagFolderId", PtI8},
+	0x6749: {"PidTagParentFolderId", PtI8},
+	0x674A: {"PidTagMid", PtI8},
+	0x674D: {"PidTagInstID", PtI8},
+	0x674E: {"PidTagInstanceNum", PtLong},
+	0x674F: {"PidTagAddressBookMessageId", PtI8},
+	0x67A4: {"PidTagChangeNumber", PtI8},
+	0x67AA: {"PidTagAssociated", PtBoolean},
+	0x67F2: {"PidTagLtpRowId", PtLong},
+	0x67F3: {"PidTagLtpRowVer", PtLong},
+	0x67FF: {"PidTagPstPassword", PtLong},
+	0x6800: {"PidTagOfflineAddressBookName", PtUnicode},
+	0x6801: {"PidTagVoiceMessageDuration", PtLong},
+	0x6802: {"PidTagSenderTelephoneNumber", PtUnicode},
+	0x6803: {"PidTagVoiceMessageSenderName", PtUnicode},
+	0x6804: {"PidTagFaxNumberOfPages", PtLong},
+	0x6805: {"PidTagVoiceMessageAttachmentOrder", PtUnicode},
+	0x6806: {"PidTagCallId", PtUnicode},
+	0x6820: {"PidTagReportingMessageTransferAgent", PtUnicode},
+	0x6834: {"PidTagSearchFolderLastUsed", PtLong},
+	0x683A: {"PidTagSearchFolderExpiration", PtLong},
+	0x6841: {"PidTagSearchFolderTemplateId", PtLong},
+	0x6842: {"PidTagSearchFolderId", PtBinary},
+	0x6843: {"PidTagScheduleInfoDontMailDelegates", PtBoolean},
+	0x6844: {"PidTagSearchFolderRecreateInfo", PtBinary},
+	0x6845: {"PidTagSearchFolderDefinition", PtBinary},
+	0x6846: {"PidTagSearchFolderStorageType", PtLong},
+	0x6847: {"PidTagSearchFolderTag", PtLong},
+	0x6848: {"PidTagSearchFolderEfpFlags", PtLong},
+	0x6849: {"PidTagWlinkType", PtLong},
+	0x684A: {"PidTagWlinkFlags", PtLong},
+	0x684B: {"PidTagWlinkOrdinal", PtBinary},
+	0x684C: {"PidTagWlinkEntryId", PtBinary},
+	0x684D: {"PidTagWlinkRecordKey", PtBinary},
+	0x684E: {"PidTagWlinkStoreEntryId", PtBinary},
+	0x684F: {"PidTagWlinkFolderType", PtBinary},
+	0x6850: {"PidTagWlinkGroupClsid", PtBinary},
+	0x6851: {"PidTagWlinkGroupName", PtUnicode},
+	0x6852: {"PidTagWlinkSection", PtLong},
+	0x6853: {"PidTagWlinkCalendarColor", PtLong},
+	0x6854: {"PidTagWlinkAddressBookEID", PtBinary},
+	0x6868: {"PidTagFreeBusyRangeTimestamp", PtSysTime},
+	0x6869: {"PidTagFreeBusyCountMonths", PtLong},
+	0x686A: {"PidTagScheduleInfoAppointmentTombstone", PtBinary},
+	0x686B: {"PidTagDelegateFlags", PtLong},
+	0x686C: {"PidTagScheduleInfoFreeBusy", PtBinary},
+	0x686D: {"PidTagScheduleInfoAutoAcceptAppointments", PtBoolean},
+	0x686E: {"PidTagScheduleInfoDisallowRecurringAppts", PtBoolean},
+	0x686F: {"PidTagScheduleInfoDisallowOverlappingAppts", PtBoolean},
+	0x6890: {"PidTagWlinkClientID", PtBinary},
+	0x6891: {"PidTagWlinkAddressBookStoreEID", PtBinary},
+	0x6892: {"PidTagWlinkROGroupType", PtLong},
+	0x7001: {"PidTagViewDescriptorBinary", PtBinary},
+	0x7002: {"PidTagViewDescriptorStrings", PtUnicode},
+	0x7006: {"PidTagViewDescriptorName", PtUnicode},
+	0x7007: {"PidTagViewDescriptorVersion", PtLong},
+	0x7C04: {"PidTagRoamingDatatypes", PtLong},
+	0x7C05: {"PidTagRoamingDictionary", PtBinary},
+	0x7C06: {"PidTagRoamingXmlStream", PtBinary},
+	0x7C24: {"PidTagOscSyncEnabled", PtBoolean},
+	0x7D01: {"PidTagProcessed", PtBoolean},
+	0x7FF9: {"PidTagExceptionReplaceTime", PtSysTime},
+	0x7FFA: {"PidTagAttachmentLinkId", PtLong},
+	0x7FFB: {"PidTagExceptionStartTime", PtSysTime},
+	0x7FFC: {"PidTagExceptionEndTime", PtSysTime},
+	0x7FFD: {"PidTagAttachmentFlags", PtLong},
+	0x7FFE: {"PidTagAttachmentHidden", PtBoolean},
+	0x7FFF: {"PidTagAttachmentContactPhoto", PtBoolean},
+	0x8004: {"PidTagAddressBookFolderPathname", PtUnicode},
+	0x8005: {"PidTagAddressBookManagerDistinguishedName", PtUnicode},
+	0x8006: {"PidTagAddressBookHomeMessageDatabase", PtString8},
+	0x8009: {"PidTagAddressBookMember", PtObject},
+	0x800C: {"PidTagAddressBookOwner", PtObject},
+	0x800E: {"PidTagAddressBookReports", PtObject},
+	0x800F: {"PidTagAddressBookProxyAddresses", PtUnicode},
+	0x8011: {"PidTagAddressBookTargetAddress", PtUnicode},
+	0x8015: {"PidTagAddressBookPublicDelegates", PtObject},
+	0x8024: {"PidTagAddressBookOwnerBackLink", PtObject},
+	0x802D: {"PidTagAddressBookExtensionAttribute1", PtUnicode},
+	0x802E: {"PidTagAddressBookExtensionAttribute2", PtUnicode},
+	0x802F: {"PidTagAddressBookExtensionAttribute3", PtUnicode},
+	0x8030: {"PidTagAddressBookExtensionAttribute4", PtUnicode},
+	0x8031: {"PidTagAddressBookExtensionAttribute5", PtUnicode},
+	0x8032: {"PidTagAddressBookExtensionAttribute6", PtUnicode},
+	0x8033: {"PidTagAddressBookExtensionAttribute7", PtUnicode},
+	0x8034: {"PidTagAddressBookExtensionAttribute8", PtUnicode},
+	0x8035: {"PidTagAddressBookExtensionAttribute9", PtUnicode},
+	0x8036: {"PidTagAddressBookExtensionAttribute10", PtUnicode},
+	0x806A: {"PidTagAddressBookDeliveryContentLength", PtLong},
+	0x8073: {"PidTagAddressBookDistributionListMemberSubmitAccepted", PtObject},
+	0x8170: {"PidTagAddressBookNetworkAddress", PtUnicode},
+	0x8C57: {"PidTagAddressBookExtensionAttribute11", PtUnicode},
+	0x8C58: {"PidTagAddressBookExtensionAttribute12", PtUnicode},
+	0x8C59: {"PidTagAddressBookExtensionAttribute13", PtUnicode},
+	0x8C60: {"PidTagAddressBookExtensionAttribute14", PtUnicode},
+	0x8C61: {"PidTagAddressBookExtensionAttribute15", PtUnicode},
+	0x8C6A: {"PidTagAddressBookX509Certificate", PtBinary},
+	0x8C6D: {"PidTagAddressBookObjectGuid", PtBinary},
+	0x8C8E: {"PidTagAddressBookPhoneticGivenName", PtUnicode},
+	0x8C8F: {"PidTagAddressBookPhoneticSurname", PtUnicode},
+	0x8C90: {"PidTagAddressBookPhoneticDepartmentName", PtUnicode},
+	0x8C91: {"PidTagAddressBookPhoneticCompanyName", PtUnicode},
+	0x8C92: {"PidTagAddressBookPhoneticDisplayName", PtUnicode},
+	0x8C93: {"PidTagAddressBookDisplayTypeExtended", PtLong},
+	0x8C94: {"PidTagAddressBookHierarchicalShowInDepartments", PtObject},
+	0x8C96: {"PidTagAddressBookRoomContainers", PtUnicode},
+	0x8C97: {"PidTagAddressBookHierarchicalDepartmentMembers", PtObject},
+	0x8C98: {"PidTagAddressBookHierarchicalRootDepartment", PtString8},
+	0x8C99: {"PidTagAddressBookHierarchicalParentDepartment", PtObject},
+	0x8C9A: {"PidTagAddressBookHierarchicalChildDepartments", PtObject},
+	0x8C9E: {"PidTagThumbnailPhoto", PtBinary},
+	0x8CA0: {"PidTagAddressBookSeniorityIndex", PtLong},
+	0x8CA8: {"PidTagAddressBookOrganizationalUnitRootDistinguishedName", PtUnicode},
+	0x8CAC: {"PidTagAddressBookSenderHintTranslations", PtUnicode},
+	0x8CB5: {"PidTagAddressBookModerationEnabled", PtBoolean},
+	0x8CC2: {"PidTagSpokenName", PtBinary},
+	0x8CD8: {"PidTagAddressBookAuthorizedSenders", PtObject},
+	0x8CD9: {"PidTagAddressBookUnauthorizedSenders", PtObject},
+	0x8CDA: {"PidTagAddressBookDistributionListMemberSubmitRejected", PtObject},
+	0x8CDB: {"PidTagAddressBookDistributionListRejectMessagesFromDLMembers", PtObject},
+	0x8CDD: {"PidTagAddressBookHierarchicalIsHierarchicalGroup", PtBoolean},
+	0x8CE2: {"PidTagAddressBookDistributionListMemberCount", PtLong},
+	0x8CE3: {"PidTagAddressBookDistributionListExternalMemberCount", PtLong},
+	0xFFFB: {"PidTagAddressBookIsMaster", PtBoolean},
+	0xFFFC: {"PidTagAddressBookParentEntryId", PtBinary},
+	0xFFFD: {"PidTagAddressBookContainerId", PtLong},
+}
+
+// propertySets maps well-known property set GUIDs (MS-OXPROPS 1.3.2) to
+// their names.
+var propertySets = map[string]string{
+	"{00020328-0000-0000-C000-000000000046}": "PS_MAPI",
+	"{00020329-0000-0000-C000-000000000046}": "PS_PUBLIC_STRINGS",
+	"{00020386-0000-0000-C000-000000000046}": "PS_INTERNET_HEADERS",
+	"{00062002-0000-0000-C000-000000000046}": "PSETID_Appointment",
+	"{00062003-0000-0000-C000-000000000046}": "PSETID_Task",
+	"{00062004-0000-0000-C000-000000000046}": "PSETID_Address",
+	"{00062008-0000-0000-C000-000000000046}": "PSETID_Common",
+	"{0006200A-0000-0000-C000-000000000046}": "PSETID_Log",
+	"{0006200E-0000-0000-C000-000000000046}": "PSETID_Note",
+	"{00062013-0000-0000-C000-000000000046}": "PSETID_Report",
+	"{00062014-0000-0000-C000-000000000046}": "PSETID_RemoteMessage",
+	"{00062040-0000-0000-C000-000000000046}": "PSETID_Sharing",
+	"{00062041-0000-0000-C000-000000000046}": "PSETID_PostRss",
+	"{6ED8DA90-450B-101B-98DA-00AA003F1305}": "PSETID_Meeting",
+	"{4442858E-A9E3-4E80-B900-317A210CC15B}": "PSETID_UnifiedMessaging",
+	"{71035549-0739-4DCB-9163-00F0580DBBDF}": "PSETID_AirSync",
+	"{41F28F13-83F4-4114-A584-EEDB5A6B0BFF}": "PSETID_Messaging",
+	"{96357F7F-59E1-47D0-99A7-46515C183B54}": "PSETID_Attachment",
+	"{11000E07-B51B-40D6-AF21-CAA85EDAB1D0}": "PSETID_CalendarAssistant",
+	"{23239608-685D-4732-9C55-4C95CB4E8E33}": "PSETID_XmlExtractedEntities",
+}
+
+// namedLIDs maps (property set, LID) pairs to their PidLid names.
+var namedLIDs = map[string]map[uint32]string{
+	"PSETID_Common": {
+		0x8501: "PidLidReminderDelta", 0x8502: "PidLidReminderTime",
+		0x8503: "PidLidReminderSet", 0x8506: "PidLidPrivate",
+		0x8510: "PidLidSideEffects", 0x8514: "PidLidSmartNoAttach",
+		0x8516: "PidLidCommonStart", 0x8517: "PidLidCommonEnd",
+		0x8518: "PidLidTaskMode", 0x851C: "PidLidReminderOverride",
+		0x851E: "PidLidReminderPlaySound", 0x851F: "PidLidReminderFileParameter",
+		0x8520: "PidLidVerbStream", 0x8524: "PidLidVerbResponse",
+		0x8530: "PidLidFlagRequest", 0x8552: "PidLidCurrentVersion",
+		0x8554: "PidLidCurrentVersionName", 0x8560: "PidLidReminderSignalTime",
+		0x8580: "PidLidInternetAccountName", 0x8581: "PidLidInternetAccountStamp",
+		0x8582: "PidLidUseTnef", 0x85A0: "PidLidToDoOrdinalDate",
+		0x85A1: "PidLidToDoSubOrdinal", 0x85A4: "PidLidToDoTitle",
+		0x85BF: "PidLidValidFlagStringProof",
+	},
+	"PSETID_Appointment": {
+		0x8205: "PidLidBusyStatus", 0x8208: "PidLidLocation",
+		0x820D: "PidLidAppointmentStartWhole", 0x820E: "PidLidAppointmentEndWhole",
+		0x8213: "PidLidAppointmentDuration", 0x8215: "PidLidAppointmentSubType",
+		0x8216: "PidLidAppointmentRecur", 0x8217: "PidLidAppointmentStateFlags",
+		0x8218: "PidLidResponseStatus", 0x8223: "PidLidRecurring",
+		0x8231: "PidLidRecurrenceType", 0x8232: "PidLidRecurrencePattern",
+		0x8234: "PidLidTimeZoneDescription",
+	},
+	"PSETID_Meeting": {
+		0x0003: "PidLidGlobalObjectId", 0x0023: "PidLidCleanGlobalObjectId",
+	},
+	"PSETID_Task": {
+		0x8101: "PidLidTaskStatus", 0x8102: "PidLidPercentComplete",
+		0x8104: "PidLidTaskStartDate", 0x8105: "PidLidTaskDueDate",
+		0x811C: "PidLidTaskComplete",
+	},
+	"PSETID_Address": {
+		0x8005: "PidLidFileUnder", 0x8080: "PidLidEmail1DisplayName",
+		0x8083: "PidLidEmail1EmailAddress",
+	},
+}
+
+// PropertySetName returns the symbolic name of a property set GUID, or "".
+func PropertySetName(g GUID) string {
+	return propertySets[g.String()]
+}
+
+// NamedPropName returns the PidLid name for a numeric named property, or
+// the string name for a string-named one. It returns "" for unknown LIDs.
+func NamedPropName(n *NamedID) string {
+	if n.Kind != 0 {
+		return n.Name
+	}
+	return namedLIDs[PropertySetName(n.GUID)][n.LID]
+}
@@ -0,0 +1,191 @@
+// rtftext.go extracts plain text from native (not HTML-encapsulated) RTF
+// bodies so messages without PR_BODY still yield a readable body.txt.
+
+package tnef
+
+import (
+	"strconv"
+	"strings"
+)
+
+// rtfSkipDestinations are groups whose content is not message text.
+var rtfSkipDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true,
+	"pict": true, "object": true, "header": true, "footer": true,
+	"headerl": true, "headerr": true, "footerl": true, "footerr": true,
+	"listtable": true, "listoverridetable": true, "revtbl": true,
+	"rsidtbl": true, "generator": true, "xmlnstbl": true, "themedata": true,
+	"colorschememapping": true, "latentstyles": true, "datastore": true,
+	"fldinst": true,
+}
+
+// cp1252 maps bytes 0x80–0x9F of Windows-1252 to Unicode. Bytes 0xA0–0xFF
+// match Latin-1; undefined entries map to U+FFFD.
+var cp1252 = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+// RTFToText returns the visible text of an RTF document. Paragraph and line
+// breaks become "\n", tabs are kept, \'XX escapes are decoded as
+// Windows-1252 and \uN escapes as Unicode. Tables, pictures, and other
+// layout are not reproduced. It returns nil if no text was found.
+func RTFToText(rtf []byte) []byte {
+	type state struct {
+		skip bool // inside an ignored destination
+		uc   int  // fallback characters that follow a \uN escape
+	}
+	var out strings.Builder
+	stack := []state{{uc: 1}}
+	cur := &stack[0]
+	pendingSkip := 0 // fallback characters still to drop after \uN
+	n := len(rtf)
+
+	write := func(s string) {
+		if !cur.skip {
+			out.WriteString(s)
+		}
+	}
+
+	for i := 0; i < n; {
+		c := rtf[i]
+		switch c {
+		case '{':
+			stack = append(stack, *cur)
+			cur = &stack[len(stack)-1]
+			pendingSkip = 0
+			i++
+			// {\*\dest ...} groups are optional destinations: ignore them.
+			if i+1 < n && rtf[i] == '\\' && rtf[i+1] == '*' {
+				cur.skip = true
+			}
+			continue
+		case '}':
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+				cur = &stack[len(stack)-1]
+			}
+			pendingSkip = 0
+			i++
+			continue
+		case '\r', '\n':
+			i++
+			continue
+		case '\\':
+		default:
+			if pendingSkip > 0 {
+				pendingSkip--
+			} else {
+				write(string(decodeCP1252(c)))
+			}
+			i++
+			continue
+		}
+
+		// Control symbol or word.
+		if i+1 >= n {
+			break
+		}
+		next := rtf[i+1]
+		if !isAlpha(next) {
+			i += 2
+			if pendingSkip > 0 && next != '\'' {
+				pendingSkip--
+				continue
+			}
+			switch next {
+			case '\\', '{', '}':
+				write(string(next))
+			case '~':
+				write(" ")
+			case '_':
+				write("-")
+			case '\'':
+				if i+1 < n {
+					hi, lo := unhex(rtf[i]), unhex(rtf[i+1])
+					i += 2
+					if pendingSkip > 0 {
+						pendingSkip--
+					} else if hi >= 0 && lo >= 0 {
+						write(string(decodeCP1252(byte(hi<<4 | lo))))
+					}
+				}
+			case '\r', '\n':
+				write("\n")
+			}
+			continue
+		}
+
+		j := i + 1
+		for j < n && isAlpha(rtf[j]) {
+			j++
+		}
+		word := string(rtf[i+1 : j])
+		k := j
+		if k < n && (rtf[k] == '-' || (rtf[k] >= '0' && rtf[k] <= '9')) {
+			k++
+			for k < n && rtf[k] >= '0' && rtf[k] <= '9' {
+				k++
+			}
+		}
+		param, hasParam := 0, k > j
+		if hasParam {
+			param, _ = strconv.Atoi(string(rtf[j:k]))
+		}
+		if k < n && rtf[k] == ' ' {
+			k++
+		}
+		i = k
+
+		if rtfSkipDestinations[word] {
+			cur.skip = true
+			continue
+		}
+		switch word {
+		case "par", "line", "row", "sect", "page":
+			write("\n")
+		case "tab", "cell":
+			write("\t")
+		case "emdash":
+			write("—")
+		case "endash":
+			write("–")
+		case "bullet":
+			write("•")
+		case "lquote":
+			write("‘")
+		case "rquote":
+			write("’")
+		case "ldblquote":
+			write("“")
+		case "rdblquote":
+			write("”")
+		case "uc":
+			if hasParam {
+				cur.uc = param
+			}
+		case "u":
+			if hasParam {
+				if param < 0 {
+					param += 65536
+				}
+				write(string(rune(param)))
+				pendingSkip = cur.uc
+			}
+		}
+	}
+
+	text := strings.TrimSpace(strings.ReplaceAll(out.String(), "\r", ""))
+	if text == "" {
+		return nil
+	}
+	return []byte(text + "\n")
+}
+
+// decodeCP1252 converts a Windows-1252 byte to a rune.
+func decodeCP1252(b byte) rune {
+	if b >= 0x80 && b < 0xA0 {
+		return cp1252[b-0x80]
+	}
+	return rune(b)
+}
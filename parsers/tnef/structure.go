@@ -0,0 +1,115 @@
+// structure.go walks the TNEF attribute envelope and exposes every record
+// with its byte offsets, for Decode and for diagnostic tools.
+
+package tnef
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrTruncated is returned when an attribute extends past the end of the
+// data.
+var ErrTruncated = errors.New("truncated TNEF attribute")
+
+// Attribute levels.
+const (
+	LevelMessage    = lvlMessage
+	LevelAttachment = lvlAttachment
+)
+
+// Attribute is one TNEF attribute record: level byte, 16-bit ID, 16-bit
+// type, 32-bit length, data, and a 16-bit checksum.
+type Attribute struct {
+	Level    int
+	ID       int
+	Type     int
+	Offset   int    // Offset of the level byte.
+	Data     []byte // Attribute payload; its offset is Offset+9.
+	Checksum uint16 // Stored checksum.
+
+	// Props holds the decoded properties of attMsgProps and attAttachment
+	// records. Rows holds one property list per recipient for
+	// attRecipTable. PropErr is set if either could not be fully decoded.
+	Props   []Property
+	Rows    [][]Property
+	PropErr error
+}
+
+// DataOffset returns the absolute offset of the attribute payload.
+func (a *Attribute) DataOffset() int {
+	return a.Offset + 9
+}
+
+// ChecksumOK reports whether the stored checksum matches the payload
+// (the sum of the data bytes modulo 65536).
+func (a *Attribute) ChecksumOK() bool {
+	var sum uint16
+	for _, b := range a.Data {
+		sum += uint16(b)
+	}
+	return sum == a.Checksum
+}
+
+// Walk parses the attribute envelope of a TNEF stream. Property streams
+// inside attributes are decoded into Props and Rows. If the stream is cut
+// short, Walk returns the attributes read so far and an error wrapping
+// ErrTruncated.
+func Walk(data []byte) ([]Attribute, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint32(data[0:4]) != tnefSignature {
+		return nil, ErrBadSignature
+	}
+
+	var attrs []Attribute
+	offset := 6
+	for offset+9 <= len(data) {
+		ln := int(binary.LittleEndian.Uint32(data[offset+5 : offset+9]))
+		end := offset + 9 + ln + 2
+		if ln < 0 || end > len(data) || end < offset {
+			return attrs, fmt.Errorf("attribute at offset %d: %w", offset, ErrTruncated)
+		}
+		a := Attribute{
+			Level:    int(data[offset]),
+			ID:       int(binary.LittleEndian.Uint16(data[offset+1 : offset+3])),
+			Type:     int(binary.LittleEndian.Uint16(data[offset+3 : offset+5])),
+			Offset:   offset,
+			Data:     data[offset+9 : offset+9+ln],
+			Checksum: binary.LittleEndian.Uint16(data[end-2 : end]),
+		}
+		switch a.ID {
+		case attrMAPIProps, attrAttachment:
+			a.Props, a.PropErr = DecodeProperties(a.Data, a.DataOffset())
+		case attrRecipTable:
+			a.Rows, a.PropErr = decodeRecipients(a.Data, a.DataOffset())
+		}
+		attrs = append(attrs, a)
+		offset = end
+	}
+	if offset < len(data) {
+		return attrs, fmt.Errorf("attribute at offset %d: %w", offset, ErrTruncated)
+	}
+	return attrs, nil
+}
+
+// decodeRecipients parses attRecipTable: a uint32 row count followed by
+// one property list per recipient.
+func decodeRecipients(data []byte, base int) ([][]Property, error) {
+	if len(data) < 4 {
+		return nil, ErrMalformedProps
+	}
+	count := int(binary.LittleEndian.Uint32(data[0:4]))
+	off := 4
+	var rows [][]Property
+	for i := 0; i < count; i++ {
+		props, next, err := decodeProperties(data, off, base)
+		if len(props) > 0 || err == nil {
+			rows = append(rows, props)
+		}
+		if err != nil {
+			return rows, fmt.Errorf("recipient %d: %w", i, err)
+		}
+		off = next
+	}
+	return rows, nil
+}
@@ -2,7 +2,10 @@ package tnef
 
 import (
 	"encoding/binary"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 )
 
 func validTNEFHeader() []byte {
@@ -44,3 +47,185 @@ func TestDeencapsulateHTMLEmpty(t *testing.T) {
 		t.Fatalf("expected empty result, got %d bytes", len(result))
 	}
 }
+
+func TestRTFToText(t *testing.T) {
+	rtf := []byte(`{\rtf1\ansi\ansicpg1252{\fonttbl{\f0\fswiss Arial;}}{\colortbl;\red0\green0\blue0;}` +
+		`{\*\generator Riched20;}\f0 Hello\par Caf\'e9 \ldblquote ok\rdblquote\tab\'805 \u8364?\par}`)
+	want := "Hello\nCafé “ok”\t€5 €\n"
+	if got := string(RTFToText(rtf)); got != want {
+		t.Errorf("RTFToText = %q, want %q", got, want)
+	}
+	if got := RTFToText([]byte(`{\rtf1{\fonttbl{\f0 Arial;}}}`)); got != nil {
+		t.Errorf("expected nil for RTF without text, got %q", got)
+	}
+}
+
+// tnefAttr encodes one attribute record with a valid checksum.
+func tnefAttr(level byte, id, typ uint16, data []byte) []byte {
+	b := []byte{level, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(b[1:], id)
+	binary.LittleEndian.PutUint16(b[3:], typ)
+	binary.LittleEndian.PutUint32(b[5:], uint32(len(data)))
+	b = append(b, data...)
+	var sum uint16
+	for _, c := range data {
+		sum += uint16(c)
+	}
+	return binary.LittleEndian.AppendUint16(b, sum)
+}
+
+func TestWalkAndDecodeProperties(t *testing.T) {
+	le := binary.LittleEndian
+	// Property stream: PidTagSubject (PT_STRING8) and a named PT_LONG in
+	// PSETID_Common identified by LID 0x8503.
+	props := le.AppendUint32(nil, 2)
+	props = le.AppendUint32(props, 0x0037001E)
+	props = le.AppendUint32(props, 1)
+	props = le.AppendUint32(props, 3)
+	props = append(props, 'H', 'i', 0, 0)
+	props = le.AppendUint32(props, 0x8000_0003)
+	props = append(props, 0x08, 0x20, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46)
+	props = le.AppendUint32(props, 0) // kind: LID
+	props = le.AppendUint32(props, 0x8503)
+	props = le.AppendUint32(props, 1)
+
+	data := validTNEFHeader()
+	data = append(data, tnefAttr(lvlMessage, 0x9006, 0x0008, []byte{0, 0, 1, 0})...)
+	propsOffset := len(data) + 9
+	data = append(data, tnefAttr(lvlMessage, attrMAPIProps, 0x0006, props)...)
+
+	attrs, err := Walk(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attrs) != 2 || attrs[1].DataOffset() != propsOffset || !attrs[1].ChecksumOK() {
+		t.Fatalf("unexpected attributes: %+v", attrs)
+	}
+	p := attrs[1].Props
+	if len(p) != 2 {
+		t.Fatalf("expected 2 properties, got %d (%v)", len(p), attrs[1].PropErr)
+	}
+	if p[0].Offset != propsOffset+4 || p[0].Values[0].Offset != propsOffset+16 || string(p[0].Bytes()) != "Hi\x00" {
+		t.Errorf("unexpected subject property: %+v", p[0])
+	}
+	if info, ok := LookupProp(p[0].ID); !ok || info.Name != "PidTagSubject" {
+		t.Errorf("unexpected dictionary entry: %+v", info)
+	}
+	n := p[1].Named
+	if n == nil || PropertySetName(n.GUID) != "PSETID_Common" || NamedPropName(n) != "PidLidReminderSet" {
+		t.Errorf("unexpected named property: %+v", n)
+	}
+
+	if _, err := Walk(data[:len(data)-3]); !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	common := NamedID{Kind: 0, LID: 0x8503}
+	copy(common.GUID[:], []byte{0x08, 0x20, 0x06, 0, 0, 0, 0, 0, 0xC0, 0, 0, 0, 0, 0, 0, 0x46})
+	a := &Message{
+		Body: []byte("hello\nworld\n"),
+		Attributes: []MAPIAttr{
+			{Type: PtString8, Name: MAPISubject, Data: []byte("Old\x00")},
+			{Type: PtLong, Name: 0x0E07, Data: []byte{1, 0, 0, 0}},
+			{Type: PtBoolean, Name: 0x8001, Data: []byte{1, 0, 0, 0}, Named: &common},
+		},
+		Attachments: []*Attachment{
+			{LongName: "same.txt", Data: []byte("x")},
+			{LongName: "gone.txt", Data: []byte("y")},
+			{LongName: "fwd.msg", EmbeddedMsg: &Message{BodyHTML: []byte("<p>a</p>")}},
+		},
+	}
+	b := &Message{
+		Body: []byte("hello\nthere\n"),
+		Attributes: []MAPIAttr{
+			{Type: PtUnicode, Name: MAPISubject, Data: []byte("N\x00e\x00w\x00\x00\x00")},
+			// Same named property under a different per-file ID.
+			{Type: PtBoolean, Name: 0x8007, Data: []byte{1, 0, 0, 0}, Named: &common},
+		},
+		Attachments: []*Attachment{
+			{LongName: "same.txt", Data: []byte("x")},
+			{LongName: "new.txt", Data: []byte("z")},
+			{LongName: "fwd.msg", EmbeddedMsg: &Message{BodyHTML: []byte("<p>ab</p>")}},
+		},
+	}
+	d := Compare(a, b)
+
+	if len(d.Properties) != 2 {
+		t.Fatalf("expected 2 property changes, got %+v", d.Properties)
+	}
+	if c := d.Properties[0]; c.Key != "0x0037" || c.Change != Changed || c.Old != `"Old"` || c.New != `"New"` || c.NewType != "PT_UNICODE" {
+		t.Errorf("unexpected subject change: %+v", c)
+	}
+	if c := d.Properties[1]; c.Key != "0x0E07" || c.Change != Removed || c.Name != "PidTagMessageFlags" {
+		t.Errorf("unexpected flags change: %+v", c)
+	}
+	if len(d.Bodies) != 1 || !strings.Contains(d.Bodies[0].Unified, "-world\n+there\n") {
+		t.Errorf("unexpected body changes: %+v", d.Bodies)
+	}
+	if len(d.Attachments) != 2 || d.Attachments[0].Name != "gone.txt" || d.Attachments[1].Change != Added ||
+		d.Attachments[1].NewSHA256 != "594e519ae499312b29433b7dd8a97ff068defcba9755b6d5d00e84c524d67b06" {
+		t.Errorf("unexpected attachment changes: %+v", d.Attachments)
+	}
+	if len(d.Embedded) != 1 || d.Embedded[0].Path != "fwd.msg" || d.Embedded[0].Bodies[0].NewSize != 9 {
+		t.Errorf("unexpected embedded diff: %+v", d.Embedded)
+	}
+	if !Compare(a, a).Empty() {
+		t.Error("message differs from itself")
+	}
+}
+
+func TestAttachmentModTime(t *testing.T) {
+	le := binary.LittleEndian
+	dtr := func(y, mo, d, h, mi, s uint16) []byte {
+		var b []byte
+		for _, v := range []uint16{y, mo, d, h, mi, s, 0} {
+			b = le.AppendUint16(b, v)
+		}
+		return b
+	}
+	data := validTNEFHeader()
+	data = append(data, tnefAttr(lvlAttachment, attrAttachRendData, 0x0002, make([]byte, 14))...)
+	data = append(data, tnefAttr(lvlAttachment, attrAttachModifyDate, 0x0003, dtr(2023, 5, 6, 7, 8, 9))...)
+	data = append(data, tnefAttr(lvlAttachment, attrAttachRendData, 0x0002, make([]byte, 14))...)
+	data = append(data, tnefAttr(lvlAttachment, attrAttachModifyDate, 0x0003, dtr(2023, 5, 6, 7, 8, 9))...)
+
+	// The second attachment also has PR_LAST_MODIFICATION_TIME, which wins.
+	props := le.AppendUint32(nil, 1)
+	props = le.AppendUint32(props, MAPILastModified<<16|0x0040)
+	props = le.AppendUint64(props, 133000000000000000) // 2022-06-18 04:26:40 UTC
+	data = append(data, tnefAttr(lvlAttachment, attrAttachment, 0x0006, props)...)
+
+	msg, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Attachments) != 2 {
+		t.Fatalf("got %d attachments", len(msg.Attachments))
+	}
+	if got := msg.Attachments[0].ModTime.Format(time.RFC3339); got != "2023-05-06T07:08:09Z" {
+		t.Errorf("attAttachModifyDate = %s", got)
+	}
+	if got := msg.Attachments[1].ModTime.Format(time.RFC3339); got != "2022-06-18T04:26:40Z" {
+		t.Errorf("PR_LAST_MODIFICATION_TIME = %s", got)
+	}
+}
+
+func TestLookupProp(t *testing.T) {
+	for id, want := range map[int]PropInfo{
+		0x0E1D: {"PidTagNormalizedSubject", PtUnicode},
+		0x3A1C: {"PidTagMobileTelephoneNumber", PtUnicode},
+		0x3602: {"PidTagContentCount", PtLong},
+		0x6679: {"PidTagRuleCondition", PtRestriction},
+		0x8C6D: {"PidTagAddressBookObjectGuid", PtBinary},
+	} {
+		if got, ok := LookupProp(id); !ok || got != want {
+			t.Errorf("LookupProp(0x%04X) = %+v, %v; want %+v", id, got, ok, want)
+		}
+	}
+	if _, ok := LookupProp(0x7FF0); ok {
+		t.Error("unassigned tag found in the dictionary")
+	}
+}
@@ -5,7 +5,9 @@ package tnef
 
 import (
 	"bytes"
+	"encoding/binary"
 	"strings"
+	"time"
 )
 
 // Message holds the decoded contents of a TNEF stream.
@@ -38,15 +40,50 @@ func (m *Message) GetAttrString(propID int) string {
 	return ""
 }
 
+// GetAttrTime returns the value of a PT_SYSTIME attribute matching propID,
+// or the zero time if it is absent or not an 8-byte FILETIME.
+func (m *Message) GetAttrTime(propID int) time.Time {
+	a := m.GetAttr(propID)
+	if a == nil || len(a.Data) < 8 {
+		return time.Time{}
+	}
+	return FiletimeToTime(binary.LittleEndian.Uint64(a.Data))
+}
+
+// SentTime returns the best available send time: PR_CLIENT_SUBMIT_TIME,
+// falling back to PR_MESSAGE_DELIVERY_TIME. The zero time means unknown.
+func (m *Message) SentTime() time.Time {
+	if t := m.GetAttrTime(MAPIClientSubmit); !t.IsZero() {
+		return t
+	}
+	return m.GetAttrTime(MAPIDeliveryTime)
+}
+
+// FiletimeToTime converts a Windows FILETIME (100 ns intervals since
+// 1601-01-01 UTC) to a time.Time.
+func FiletimeToTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	const epochDiff = 116444736000000000   // 1601 -> 1970 in 100 ns units
+	const maxIntervals = (1<<63 - 1) / 100 // keep nanoseconds within int64
+	if ft < epochDiff || ft-epochDiff > maxIntervals {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ft-epochDiff)*100).UTC()
+}
+
 // Attachment holds a single attachment (file, embedded message, or OLE object).
 type Attachment struct {
-	Title       string   // Short filename (8.3 format).
-	LongName    string   // Long filename.
-	Data        []byte   // Raw attachment content.
-	MimeType    string   // MIME type, if available.
-	ContentID   string   // Content-ID for inline images (cid: references).
-	Method      int      // AttachByValue, AttachEmbeddedMsg, or AttachOLE.
-	EmbeddedMsg *Message // Decoded nested message, if Method is AttachEmbeddedMsg.
+	Title       string     // Short filename (8.3 format).
+	LongName    string     // Long filename.
+	Data        []byte     // Raw attachment content.
+	MimeType    string     // MIME type, if available.
+	ContentID   string     // Content-ID for inline images (cid: references).
+	ModTime     time.Time  // Last modification time, if available.
+	Method      int        // AttachByValue, AttachEmbeddedMsg, or AttachOLE.
+	EmbeddedMsg *Message   // Decoded nested message, if Method is AttachEmbeddedMsg.
+	Attributes  []MAPIAttr // All decoded MAPI properties of the attachment.
 }
 
 // Filename returns the best available display name for the attachment,
@@ -61,11 +98,26 @@ func (a *Attachment) Filename() string {
 	return "unnamed"
 }
 
+// dtrToTime converts a TNEF DTR structure (seven little-endian WORDs:
+// year, month, day, hour, minute, second, day of week) to a time.Time.
+// DTR values carry no zone and are taken as UTC.
+func dtrToTime(b []byte) time.Time {
+	if len(b) < 12 {
+		return time.Time{}
+	}
+	w := func(i int) int { return int(binary.LittleEndian.Uint16(b[2*i:])) }
+	if w(0) < 1601 || w(1) < 1 || w(1) > 12 || w(2) < 1 || w(2) > 31 {
+		return time.Time{}
+	}
+	return time.Date(w(0), time.Month(w(1)), w(2), w(3), w(4), w(5), 0, time.UTC)
+}
+
 // MAPIAttr holds a single decoded MAPI property.
 type MAPIAttr struct {
-	Type int    // MAPI property type (e.g. PT_LONG, PT_STRING8, PT_BINARY).
-	Name int    // MAPI property ID (e.g. 0x0037 for PR_SUBJECT).
-	Data []byte // Raw property value bytes.
+	Type  int      // MAPI property type (e.g. PT_LONG, PT_STRING8, PT_BINARY).
+	Name  int      // MAPI property ID (e.g. 0x0037 for PR_SUBJECT).
+	Data  []byte   // Raw property value bytes.
+	Named *NamedID // Property set and name for named properties (IDs >= 0x8000).
 }
 
 // ResolveContentIDs replaces cid: references in BodyHTML and BodyRTFHTML
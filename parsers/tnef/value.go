@@ -0,0 +1,102 @@
+// value.go decodes MAPI property values into Go values for display.
+
+package tnef
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DecodeValue converts a single property value of type t to a Go value:
+// integers, bool, float64, time.Time for PT_SYSTIME, string for string
+// types and PT_CLSID, and a hex string for PT_ERROR. It returns nil for
+// binary and object values, unknown types, short data, and non-finite
+// floats.
+func DecodeValue(t int, d []byte) any {
+	le := binary.LittleEndian
+	switch t {
+	case PtShort:
+		if len(d) >= 2 {
+			return int16(le.Uint16(d))
+		}
+	case PtLong:
+		if len(d) >= 4 {
+			return int32(le.Uint32(d))
+		}
+	case PtError:
+		if len(d) >= 4 {
+			return fmt.Sprintf("0x%08X", le.Uint32(d))
+		}
+	case PtBoolean:
+		if len(d) >= 2 {
+			return le.Uint16(d) != 0
+		}
+	case PtFloat:
+		if len(d) >= 4 {
+			return finite(float64(math.Float32frombits(le.Uint32(d))))
+		}
+	case PtDouble, PtAppTime:
+		if len(d) >= 8 {
+			return finite(math.Float64frombits(le.Uint64(d)))
+		}
+	case PtCurrency:
+		if len(d) >= 8 {
+			return float64(int64(le.Uint64(d))) / 10000
+		}
+	case PtI8:
+		if len(d) >= 8 {
+			return int64(le.Uint64(d))
+		}
+	case PtSysTime:
+		if len(d) >= 8 {
+			if ts := FiletimeToTime(le.Uint64(d)); !ts.IsZero() {
+				return ts
+			}
+		}
+	case PtClsid:
+		if len(d) >= 16 {
+			var g GUID
+			copy(g[:], d)
+			return g.String()
+		}
+	case PtString8:
+		return strings.TrimRight(string(d), "\x00")
+	case PtUnicode:
+		return decodeUTF16(d)
+	}
+	return nil
+}
+
+// FormatValue renders a property value as a single display string.
+// Values DecodeValue cannot represent are shown as hex when short and by
+// length otherwise.
+func FormatValue(t int, d []byte) string {
+	switch v := DecodeValue(t, d).(type) {
+	case nil:
+		if len(d) <= 32 {
+			return fmt.Sprintf("0x%x", d)
+		}
+		return fmt.Sprintf("<%d bytes>", len(d))
+	case string:
+		if t == PtString8 || t == PtUnicode {
+			return strconv.Quote(v)
+		}
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// finite returns f, or nil for NaN and infinities, which JSON cannot encode.
+func finite(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
@@ -10,6 +10,9 @@
   const fileListEl = document.getElementById('fileList');
   const fileCount = document.getElementById('fileCount');
   const downloadAll = document.getElementById('downloadAll');
+  const messagePreview = document.getElementById('messagePreview');
+  const recursiveToggle = document.getElementById('recursiveToggle');
+  const manifestToggle = document.getElementById('manifestToggle');
   const resetBtn = document.getElementById('resetBtn');
   const versionLabel = document.getElementById('versionLabel');
   const successEl = document.getElementById('successState');
@@ -31,6 +34,9 @@
   const bankSuccessEl = document.getElementById('bankSuccessState');
   const templateSelect = document.getElementById('templateSelect');
   const bankOutputFormat = document.getElementById('bankOutputFormat');
+  const bankAchProfile = document.getElementById('bankAchProfile');
+  const bankErrorWorkbook = document.getElementById('bankErrorWorkbook');
+  const bankMapping = document.getElementById('bankMapping');
   const bankQueueList = document.getElementById('bankQueueList');
   const bankQueueCount = document.getElementById('bankQueueCount');
   const bankAddMoreBtn = document.getElementById('bankAddMoreBtn');
@@ -82,16 +88,17 @@
     .then(function (r) { return r.json(); })
     .then(function (templates) {
       templateSelect.innerHTML = '';
-      // Sort keys so BeanStream_Detail (BMO) comes first
-      var keys = Object.keys(templates).sort(function(a, b) {
-        if (a === 'BeanStream_Detail') return -1;
-        if (b === 'BeanStream_Detail') return 1;
-        return a.localeCompare(b);
+      // Built-in templates come first, with BeanStream_Detail (BMO) at the top
+      templates.sort(function(a, b) {
+        if (a.key === 'BeanStream_Detail') return -1;
+        if (b.key === 'BeanStream_Detail') return 1;
+        if (!!a.builtin !== !!b.builtin) return a.builtin ? -1 : 1;
+        return a.key.localeCompare(b.key);
       });
-      keys.forEach(function(key) {
+      templates.forEach(function(t) {
         var option = document.createElement('option');
-        option.value = key;
-        option.textContent = templates[key] + ' (' + key + ')';
+        option.value = t.key;
+        option.textContent = t.name + ' (' + t.key + (t.builtin ? '' : ', v' + t.version) + ')';
         templateSelect.appendChild(option);
       });
     })
@@ -99,6 +106,29 @@
       templateSelect.innerHTML = '<option value="">Error loading templates</option>';
     });
 
+  // Load saved column mapping profiles; choosing one selects its template
+  fetch('api/bank/mappings')
+    .then(function (r) { return r.json(); })
+    .then(function (mappings) {
+      mappings.forEach(function (m) {
+        var option = document.createElement('option');
+        option.value = m.name;
+        option.dataset.template = m.template;
+        option.textContent = m.name + ' → ' + m.template;
+        bankMapping.appendChild(option);
+      });
+    })
+    .catch(function () {
+      // No mapping profiles — the selector keeps its "None" entry
+    });
+
+  bankMapping.addEventListener('change', function () {
+    var selected = bankMapping.options[bankMapping.selectedIndex];
+    if (selected.dataset.template) {
+      templateSelect.value = selected.dataset.template;
+    }
+  });
+
   // Load file converter formats
   fetch('api/fileconvert/formats')
     .then(function (r) { return r.json(); })
@@ -401,6 +431,8 @@
   // Reset
   resetBtn.addEventListener('click', function () {
     resultsEl.classList.add('hidden');
+    messagePreview.removeAttribute('src');
+    messagePreview.classList.add('hidden');
     successEl.classList.remove('flex');
     successEl.classList.add('hidden');
     resetBtn.classList.add('hidden');
@@ -421,6 +453,12 @@
 
     var form = new FormData();
     form.append('file', file);
+    if (recursiveToggle.checked) {
+      form.append('recursive', 'true');
+    }
+    if (manifestToggle.checked) {
+      form.append('manifest', 'true');
+    }
 
     fetch('api/convert', { method: 'POST', body: form })
       .then(function (resp) {
@@ -469,10 +507,26 @@
     downloadAll.href = 'api/zip/' + sid;
     fileListEl.innerHTML = '';
 
+    // Show the rendered message view (headers, body, attachment links)
+    // by default when the converter produced one.
+    if (data.preview) {
+      messagePreview.src = 'api/files/' + sid + '/' + encodeURIComponent(data.preview);
+      messagePreview.classList.remove('hidden');
+    } else {
+      messagePreview.removeAttribute('src');
+      messagePreview.classList.add('hidden');
+    }
+
     files.forEach(function (f, i) {
       var li = document.createElement('li');
       li.style.animationDelay = (i * 50) + 'ms';
       var fileUrl = 'api/files/' + sid + '/' + encodeURIComponent(f.name);
+      var origin = '';
+      if (f.provenance) {
+        origin = ' · from ' + f.provenance.slice(1).map(function (o) {
+          return o.name;
+        }).join(' › ');
+      }
 
       li.innerHTML =
         '<div class="file-icon ' + escAttr(f.type) + '">' +
@@ -482,7 +536,7 @@
           '<span class="file-name" title="' + escAttr(f.name) + '">' +
             escHtml(f.name) +
           '</span>' +
-          '<span class="file-size">' + humanSize(f.size) + '</span>' +
+          '<span class="file-size">' + humanSize(f.size) + escHtml(origin) + '</span>' +
         '</div>' +
         '<div class="file-actions">' +
           '<a href="' + fileUrl + '" target="_blank">' +
@@ -626,6 +680,15 @@
     form.append('file', file);
     form.append('template', template);
     form.append('outputFormat', bankOutputFormat.value);
+    if (bankMapping.value) {
+      form.append('mapping', bankMapping.value);
+    }
+    if (bankAchProfile.files.length > 0) {
+      form.append('achProfile', bankAchProfile.files[0]);
+    }
+    if (bankErrorWorkbook.checked) {
+      form.append('errorWorkbook', 'true');
+    }
 
     fetch('api/bank/convert', { method: 'POST', body: form })
       .then(function (resp) {
@@ -634,9 +697,20 @@
         });
       })
       .then(function (result) {
+        var invalid = result.data.validationErrors;
         if (!result.ok) {
           bankStatusEl.className = 'status error';
-          bankStatusEl.textContent = result.data.error || 'Conversion failed';
+          if (invalid) {
+            bankStatusEl.innerHTML = validationSummary(invalid);
+          } else {
+            bankStatusEl.textContent = result.data.error || 'Conversion failed';
+          }
+          return;
+        }
+        if (invalid) {
+          bankStatusEl.className = 'status error';
+          bankStatusEl.innerHTML = validationSummary(invalid);
+          showBankResults(result.data);
           return;
         }
         bankStatusEl.textContent = '';
@@ -648,6 +722,21 @@
       });
   }
 
+  /**
+   * Describe validation errors from /api/bank/convert as HTML.
+   * @param {Array} errs - Row/column errors
+   * @returns {string}
+   */
+  function validationSummary(errs) {
+    var shown = errs.slice(0, 10).map(function (e) {
+      var where = e.row ? 'Row ' + e.row : e.record;
+      return '<li>' + escHtml(where + ', ' + e.column + ': ' + e.message) + '</li>';
+    }).join('');
+    var more = errs.length > 10 ? '<li>… and ' + (errs.length - 10) + ' more</li>' : '';
+    return escHtml(errs.length + ' validation error' + (errs.length === 1 ? '' : 's') +
+      ' — no bank file was produced') + '<ul>' + shown + more + '</ul>';
+  }
+
   /**
    * Show a brief success animation, then render the bank file list.
    * @param {Object} data - Response from /api/bank/convert
@@ -678,6 +767,12 @@
       var li = document.createElement('li');
       li.style.animationDelay = (i * 50) + 'ms';
       var fileUrl = 'api/files/' + sid + '/' + encodeURIComponent(f.name);
+      var origin = '';
+      if (f.provenance) {
+        origin = ' · from ' + f.provenance.slice(1).map(function (o) {
+          return o.name;
+        }).join(' › ');
+      }
 
       li.innerHTML =
         '<div class="file-icon ' + escAttr(f.type) + '">' +
@@ -1108,6 +1203,12 @@
       var li = document.createElement('li');
       li.style.animationDelay = (i * 50) + 'ms';
       var fileUrl = 'api/files/' + sid + '/' + encodeURIComponent(f.name);
+      var origin = '';
+      if (f.provenance) {
+        origin = ' · from ' + f.provenance.slice(1).map(function (o) {
+          return o.name;
+        }).join(' › ');
+      }
 
       li.innerHTML =
         '<div class="file-icon ' + escAttr(f.type) + '">' +